@@ -0,0 +1,124 @@
+<#
+.SYNOPSIS
+    Regression test for replicode -strict-stdout
+
+.DESCRIPTION
+    Runs replicode against a provider checkout with stdout and stderr captured separately and
+    checks the strict stdout contract the PowerShell import relies on: a successful run writes
+    exactly one JSON document to stdout, and a failed run writes nothing to stdout and its
+    error to stderr.
+
+.PARAMETER RepositoryDirectory
+    Path to terraform-provider-azurerm repository
+
+.PARAMETER ReplicodePath
+    Path to the replicode binary (default: tools\replicode\replicode.exe next to this script)
+
+.PARAMETER Service
+    Service directory under internal\services to analyze (default: resource)
+
+.EXAMPLE
+    .\Test-StrictStdout.ps1 -RepositoryDirectory "C:\github.com\hashicorp\terraform-provider-azurerm"
+#>
+
+param(
+    [Parameter(Mandatory = $true)]
+    [string]$RepositoryDirectory,
+
+    [Parameter(Mandatory = $false)]
+    [string]$ReplicodePath = (Join-Path $PSScriptRoot "..\tools\replicode\replicode.exe"),
+
+    [Parameter(Mandatory = $false)]
+    [string]$Service = "resource"
+)
+
+$ErrorActionPreference = "Stop"
+
+# Runs replicode and returns its exit code, stdout, and stderr, each captured on its own
+function Invoke-Replicode {
+    param([string[]]$Arguments)
+
+    $startInfo = New-Object System.Diagnostics.ProcessStartInfo
+    $startInfo.FileName = $ReplicodePath
+    foreach ($argument in $Arguments) {
+        $startInfo.ArgumentList.Add($argument)
+    }
+    $startInfo.RedirectStandardOutput = $true
+    $startInfo.RedirectStandardError = $true
+    $startInfo.UseShellExecute = $false
+
+    $process = [System.Diagnostics.Process]::Start($startInfo)
+    $stderrTask = $process.StandardError.ReadToEndAsync()
+    $stdout = $process.StandardOutput.ReadToEnd()
+    $process.WaitForExit()
+
+    return @{ ExitCode = $process.ExitCode; Stdout = $stdout; Stderr = $stderrTask.Result }
+}
+
+$failures = @()
+
+function Assert-Case {
+    param([string]$Name, [bool]$Condition, [string]$Detail)
+
+    if ($Condition) {
+        Write-Host "[PASS] $Name" -ForegroundColor Green
+    }
+    else {
+        Write-Host "[FAIL] $Name - $Detail" -ForegroundColor Red
+        $script:failures += $Name
+    }
+}
+
+function Test-SingleJsonDocument {
+    param([string]$Text)
+
+    try {
+        $null = $Text | ConvertFrom-Json
+        return $Text.TrimStart().StartsWith("{")
+    }
+    catch {
+        return $false
+    }
+}
+
+$serviceDir = Join-Path $RepositoryDirectory "internal\services\$Service"
+$testFile = Get-ChildItem -Path $serviceDir -Recurse -Filter "*_test.go" | Select-Object -First 1
+if (-not $testFile) {
+    Write-Host "[ERROR] No test files under $serviceDir" -ForegroundColor Red
+    exit 1
+}
+
+Write-Host ""
+Write-Host "  Replicode: $ReplicodePath" -ForegroundColor Gray
+Write-Host "  Test File: $($testFile.FullName)" -ForegroundColor Gray
+Write-Host ""
+
+# A single file: stdout is the JSON document alone
+$run = Invoke-Replicode -Arguments @("-file", $testFile.FullName, "-reporoot", $RepositoryDirectory, "-strict-stdout")
+Assert-Case -Name "file analysis exits 0" -Condition ($run.ExitCode -eq 0) -Detail "exit code $($run.ExitCode): $($run.Stderr)"
+Assert-Case -Name "file analysis stdout is one JSON document" -Condition (Test-SingleJsonDocument $run.Stdout) -Detail "stdout starts with: $($run.Stdout.Substring(0, [Math]::Min(80, $run.Stdout.Length)))"
+
+# A directory, which warns on stderr about what it skips: stdout is still the document alone
+$run = Invoke-Replicode -Arguments @("-dir", $serviceDir, "-reporoot", $RepositoryDirectory, "-strict-stdout")
+Assert-Case -Name "directory analysis exits 0" -Condition ($run.ExitCode -eq 0) -Detail "exit code $($run.ExitCode): $($run.Stderr)"
+Assert-Case -Name "directory analysis stdout is one JSON document" -Condition (Test-SingleJsonDocument $run.Stdout) -Detail "stdout is not a single JSON document"
+
+# A failure: nothing on stdout, the error on stderr
+$missing = Join-Path $serviceDir "does_not_exist_test.go"
+$run = Invoke-Replicode -Arguments @("-file", $missing, "-reporoot", $RepositoryDirectory, "-strict-stdout")
+Assert-Case -Name "missing file exits non-zero" -Condition ($run.ExitCode -ne 0) -Detail "exit code 0"
+Assert-Case -Name "missing file leaves stdout empty" -Condition ($run.Stdout.Length -eq 0) -Detail "stdout: $($run.Stdout)"
+Assert-Case -Name "missing file reports on stderr" -Condition ($run.Stderr -match "Error:") -Detail "stderr: $($run.Stderr)"
+
+# A text format is not JSON and passes through unchecked
+$run = Invoke-Replicode -Arguments @("report", "hotspots", "-dir", $serviceDir, "-reporoot", $RepositoryDirectory, "-format", "text", "-strict-stdout")
+Assert-Case -Name "text report exits 0" -Condition ($run.ExitCode -eq 0) -Detail "exit code $($run.ExitCode): $($run.Stderr)"
+Assert-Case -Name "text report writes its table" -Condition ($run.Stdout -match "RANK") -Detail "stdout: $($run.Stdout)"
+
+Write-Host ""
+if ($failures.Count -gt 0) {
+    Write-Host "[WARNING] $($failures.Count) strict stdout checks failed." -ForegroundColor Yellow
+    exit 1
+}
+Write-Host "[SUCCESS] All strict stdout checks passed!" -ForegroundColor Green
+exit 0
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Impacted test discovery mechanisms, as in go_test_commands.txt
+const (
+	impactDirect     = "DIRECT"     // A step uses a template that references the resource
+	impactTemplate   = "TEMPLATE"   // A step uses a template embedding one that does
+	impactSequential = "SEQUENTIAL" // Runs an impacted test through RunTestsInSequence or t.Run
+)
+
+// dbFunction is a test or template function row with its file resolved
+type dbFunction struct {
+	Name    string
+	Struct  string
+	File    string
+	Service string
+	Line    int
+}
+
+// dbStep is a TestFunctionSteps row
+type dbStep struct {
+	Test          int
+	Template      int
+	TargetService string
+	Line          int
+}
+
+// dbLink is a row relating two functions (SequentialReferences, TemplateCallChain)
+type dbLink struct {
+	From, To   int
+	Group, Key string // SequentialReferences only
+	Line       int    // TemplateCallChain only
+}
+
+// AnalysisDatabase is the CSV database the PowerShell discovery mode exports
+// (see docs/DATABASE_SCHEMA.md), loaded for the canned queries of query-serve
+type AnalysisDatabase struct {
+	Dir        string
+	resources  map[int]string // ResourceRefId -> resource type
+	tests      map[int]dbFunction
+	templates  map[int]dbFunction
+	steps      []dbStep
+	directRefs map[int][]int // ResourceRefId -> templates referencing it
+	sequential []dbLink      // Entry point -> referenced test
+	callChain  []dbLink      // Source template -> embedded template
+}
+
+// readCSVTable reads a table exported with Export-Csv as one map per row,
+// keyed by column header. Optional tables that don't exist read as empty.
+func readCSVTable(dir, table string, optional bool) ([]map[string]string, error) {
+	file, err := os.Open(filepath.Join(dir, table+".csv"))
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	// Windows PowerShell writes a byte order mark, which csv rejects before a quoted header
+	reader := bufio.NewReader(file)
+	if bom, err := reader.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+		reader.Discard(3)
+	}
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s.csv: %v", table, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// atoi parses an ID or line column; empty (null) values read as 0
+func atoi(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
+
+// LoadAnalysisDatabase reads the tables the canned queries need from a
+// database directory
+func LoadAnalysisDatabase(dir string) (*AnalysisDatabase, error) {
+	tables := map[string][]map[string]string{}
+	for _, table := range []struct {
+		name     string
+		optional bool
+	}{
+		{"Resources", false},
+		{"Services", false},
+		{"Files", false},
+		{"Structs", true},
+		{"TestFunctions", false},
+		{"TemplateFunctions", false},
+		{"TestFunctionSteps", false},
+		{"DirectResourceReferences", false},
+		{"SequentialReferences", true},
+		{"TemplateCallChain", true},
+	} {
+		rows, err := readCSVTable(dir, table.name, table.optional)
+		if err != nil {
+			return nil, fmt.Errorf("loading database %s: %v", dir, err)
+		}
+		tables[table.name] = rows
+	}
+
+	db := &AnalysisDatabase{
+		Dir:        dir,
+		resources:  map[int]string{},
+		tests:      map[int]dbFunction{},
+		templates:  map[int]dbFunction{},
+		directRefs: map[int][]int{},
+	}
+	for _, row := range tables["Resources"] {
+		db.resources[atoi(row["ResourceRefId"])] = row["ResourceName"]
+	}
+	services := map[int]string{}
+	for _, row := range tables["Services"] {
+		services[atoi(row["ServiceRefId"])] = row["Name"]
+	}
+	structs := map[int]string{}
+	for _, row := range tables["Structs"] {
+		structs[atoi(row["StructRefId"])] = row["StructName"]
+	}
+	type dbFile struct{ path, service string }
+	files := map[int]dbFile{}
+	for _, row := range tables["Files"] {
+		files[atoi(row["FileRefId"])] = dbFile{row["FilePath"], services[atoi(row["ServiceRefId"])]}
+	}
+
+	function := func(row map[string]string, name string) dbFunction {
+		file := files[atoi(row["FileRefId"])]
+		return dbFunction{Name: name, Struct: structs[atoi(row["StructRefId"])], File: file.path, Service: file.service, Line: atoi(row["Line"])}
+	}
+	for _, row := range tables["TestFunctions"] {
+		db.tests[atoi(row["TestFunctionRefId"])] = function(row, row["FunctionName"])
+	}
+	for _, row := range tables["TemplateFunctions"] {
+		db.templates[atoi(row["TemplateFunctionRefId"])] = function(row, row["TemplateFunctionName"])
+	}
+
+	for _, row := range tables["TestFunctionSteps"] {
+		db.steps = append(db.steps, dbStep{
+			Test:          atoi(row["TestFunctionRefId"]),
+			Template:      atoi(row["TemplateFunctionRefId"]),
+			TargetService: services[atoi(row["TargetServiceRefId"])],
+			Line:          atoi(row["Line"]),
+		})
+	}
+	for _, row := range tables["DirectResourceReferences"] {
+		resource := atoi(row["ResourceRefId"])
+		db.directRefs[resource] = append(db.directRefs[resource], atoi(row["TemplateFunctionRefId"]))
+	}
+	for _, row := range tables["SequentialReferences"] {
+		db.sequential = append(db.sequential, dbLink{
+			From:  atoi(row["EntryPointFunctionRefId"]),
+			To:    atoi(row["ReferencedFunctionRefId"]),
+			Group: row["SequentialGroup"],
+			Key:   row["SequentialKey"],
+		})
+	}
+	for _, row := range tables["TemplateCallChain"] {
+		db.callChain = append(db.callChain, dbLink{
+			From: atoi(row["SourceTemplateFunctionRefId"]),
+			To:   atoi(row["TargetTemplateFunctionRefId"]),
+			Line: atoi(row["Line"]),
+		})
+	}
+	return db, nil
+}
+
+// templateName is the Struct.method name of a template
+func (db *AnalysisDatabase) templateName(id int) string {
+	template := db.templates[id]
+	if template.Struct == "" {
+		return template.Name
+	}
+	return template.Struct + "." + template.Name
+}
+
+// Resources returns the resource types the database was discovered for
+func (db *AnalysisDatabase) Resources() []string {
+	var resources []string
+	for _, name := range db.resources {
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// DatabaseTest is a test the database says a resource change impacts
+type DatabaseTest struct {
+	Test      string   `json:"test"`
+	File      string   `json:"file"`
+	Service   string   `json:"service"`
+	Line      int      `json:"line"`
+	Via       string   `json:"via"`                 // DIRECT, TEMPLATE, or SEQUENTIAL
+	Templates []string `json:"templates,omitempty"` // Impacted templates the test's steps use
+}
+
+// ImpactedTestsResult is the response of GET /impacted-tests
+type ImpactedTestsResult struct {
+	Resource string         `json:"resource"`
+	Tests    []DatabaseTest `json:"tests"`
+}
+
+// ImpactedTests finds the tests whose steps use a template referencing the
+// resource, directly or through embedded templates, and the sequential entry
+// points that run them. It returns false when the resource is not in the
+// database.
+func (db *AnalysisDatabase) ImpactedTests(resource string) (*ImpactedTestsResult, bool) {
+	resourceID, found := 0, false
+	for id, name := range db.resources {
+		if name == resource {
+			resourceID, found = id, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Templates referencing the resource, then those embedding them
+	direct := map[int]bool{}
+	for _, template := range db.directRefs[resourceID] {
+		direct[template] = true
+	}
+	impacted := map[int]bool{}
+	for template := range direct {
+		impacted[template] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, call := range db.callChain {
+			if impacted[call.To] && !impacted[call.From] {
+				impacted[call.From], changed = true, true
+			}
+		}
+	}
+
+	tests := map[int]*DatabaseTest{}
+	add := func(id int, via string) *DatabaseTest {
+		fn := db.tests[id]
+		test := &DatabaseTest{Test: fn.Name, File: fn.File, Service: fn.Service, Line: fn.Line, Via: via}
+		tests[id] = test
+		return test
+	}
+	for _, step := range db.steps {
+		if !impacted[step.Template] {
+			continue
+		}
+		test := tests[step.Test]
+		if test == nil {
+			test = add(step.Test, impactTemplate)
+		}
+		if direct[step.Template] {
+			test.Via = impactDirect // A direct step outranks one through an embedded template
+		}
+		test.Templates = append(test.Templates, db.templateName(step.Template))
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, link := range db.sequential {
+			if tests[link.To] != nil && tests[link.From] == nil {
+				add(link.From, impactSequential)
+				changed = true
+			}
+		}
+	}
+
+	result := &ImpactedTestsResult{Resource: resource, Tests: []DatabaseTest{}}
+	for _, test := range tests {
+		test.Templates = uniqueSorted(test.Templates)
+		result.Tests = append(result.Tests, *test)
+	}
+	sort.Slice(result.Tests, func(i, j int) bool {
+		return result.Tests[i].Test < result.Tests[j].Test
+	})
+	return result, true
+}
+
+// CrossServiceReference is a test step or template call that crosses a
+// service boundary
+type CrossServiceReference struct {
+	Kind          string `json:"kind"` // TEST_STEP or TEMPLATE_CALL
+	Source        string `json:"source"`
+	SourceService string `json:"source_service"`
+	Target        string `json:"target"` // Struct.method of the template used
+	TargetService string `json:"target_service"`
+	File          string `json:"file"`
+	Line          int    `json:"line"`
+}
+
+// CrossServiceReferences lists the test steps and template calls whose
+// target template belongs to another service. A non-empty service keeps the
+// references with that service on either side.
+func (db *AnalysisDatabase) CrossServiceReferences(service string) []CrossServiceReference {
+	refs := []CrossServiceReference{}
+	keep := func(ref CrossServiceReference) {
+		if ref.SourceService == "" || ref.TargetService == "" || ref.SourceService == ref.TargetService {
+			return
+		}
+		if service == "" || ref.SourceService == service || ref.TargetService == service {
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, step := range db.steps {
+		test := db.tests[step.Test]
+		keep(CrossServiceReference{Kind: "TEST_STEP", Source: test.Name, SourceService: test.Service,
+			Target: db.templateName(step.Template), TargetService: step.TargetService, File: test.File, Line: step.Line})
+	}
+	for _, call := range db.callChain {
+		source, target := db.templates[call.From], db.templates[call.To]
+		keep(CrossServiceReference{Kind: "TEMPLATE_CALL", Source: db.templateName(call.From), SourceService: source.Service,
+			Target: db.templateName(call.To), TargetService: target.Service, File: source.File, Line: call.Line})
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		return refs[i].Line < refs[j].Line
+	})
+	return refs
+}
+
+// SequentialMember is one function a sequential group runs
+type SequentialMember struct {
+	Key      string `json:"key"`
+	Function string `json:"function"`
+}
+
+// SequentialGroup is one group of an entry point's RunTestsInSequence map
+type SequentialGroup struct {
+	EntryPoint string             `json:"entry_point"`
+	File       string             `json:"file"`
+	Service    string             `json:"service"`
+	Group      string             `json:"group"`
+	Members    []SequentialMember `json:"members"`
+}
+
+// SequentialGroups lists the sequential groups, optionally of one entry point
+func (db *AnalysisDatabase) SequentialGroups(entryPoint string) []SequentialGroup {
+	byKey := map[string]*SequentialGroup{}
+	for _, link := range db.sequential {
+		entry := db.tests[link.From]
+		if entryPoint != "" && entry.Name != entryPoint {
+			continue
+		}
+		key := entry.Name + "\x00" + link.Group
+		group := byKey[key]
+		if group == nil {
+			group = &SequentialGroup{EntryPoint: entry.Name, File: entry.File, Service: entry.Service, Group: link.Group}
+			byKey[key] = group
+		}
+		group.Members = append(group.Members, SequentialMember{Key: link.Key, Function: db.tests[link.To].Name})
+	}
+
+	groups := []SequentialGroup{}
+	for _, group := range byKey {
+		sort.Slice(group.Members, func(i, j int) bool {
+			return group.Members[i].Key < group.Members[j].Key
+		})
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].EntryPoint != groups[j].EntryPoint {
+			return groups[i].EntryPoint < groups[j].EntryPoint
+		}
+		return groups[i].Group < groups[j].Group
+	})
+	return groups
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// analysisSnapshot is what a diff compares of one analysis run: its test and
+// template nodes and the dependency edges between nodes, keyed by graph IDs
+// so that a JSON analysis and an exported database compare alike
+type analysisSnapshot struct {
+	tests     map[string]bool
+	templates map[string]bool
+	edges     map[EdgeChange]bool
+}
+
+// EdgeChange is a dependency edge present in only one of two analyses. Edges
+// are compared by endpoints and kind, so a reference that only moved lines is
+// not a change.
+type EdgeChange struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// AnalysisDiff lists what changed in the dependency graph between two
+// analysis runs. Direct resource references are the resource_ref edges of
+// templates, reported apart from the edges between functions.
+type AnalysisDiff struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+
+	AddedTests        []string     `json:"added_tests"`
+	RemovedTests      []string     `json:"removed_tests"`
+	AddedTemplates    []string     `json:"added_templates"`
+	RemovedTemplates  []string     `json:"removed_templates"`
+	AddedReferences   []EdgeChange `json:"added_references"`
+	RemovedReferences []EdgeChange `json:"removed_references"`
+	AddedEdges        []EdgeChange `json:"added_edges"`
+	RemovedEdges      []EdgeChange `json:"removed_edges"`
+}
+
+// empty reports whether the two analyses have the same graph
+func (d *AnalysisDiff) empty() bool {
+	return len(d.AddedTests)+len(d.RemovedTests)+len(d.AddedTemplates)+len(d.RemovedTemplates)+
+		len(d.AddedReferences)+len(d.RemovedReferences)+len(d.AddedEdges)+len(d.RemovedEdges) == 0
+}
+
+// loadAnalysisSnapshot reads an analysis from the JSON output of -dir or
+// -file, or from a database directory the PowerShell discovery mode exported
+func loadAnalysisSnapshot(path string) (*analysisSnapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		db, err := LoadAnalysisDatabase(path)
+		if err != nil {
+			return nil, err
+		}
+		return databaseSnapshot(db), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document struct {
+		Files    []*analyzer.Result `json:"files"`
+		FilePath string             `json:"file_path"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("reading analysis %s: %v", path, err)
+	}
+	results := document.Files
+	if document.FilePath != "" {
+		var result analyzer.Result
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("reading analysis %s: %v", path, err)
+		}
+		results = []*analyzer.Result{&result}
+	}
+	if results == nil {
+		return nil, fmt.Errorf("reading analysis %s: neither a -dir nor a -file analysis", path)
+	}
+	return graphSnapshot(BuildDependencyGraph(results)), nil
+}
+
+// graphSnapshot takes the test and template nodes of a graph and its edges
+// other than service membership
+func graphSnapshot(graph *DependencyGraph) *analysisSnapshot {
+	snapshot := &analysisSnapshot{tests: map[string]bool{}, templates: map[string]bool{}, edges: map[EdgeChange]bool{}}
+	for id, node := range graph.Nodes {
+		switch node.Kind {
+		case NodeTest:
+			snapshot.tests[id] = true
+		case NodeTemplate:
+			snapshot.templates[id] = true
+		}
+		for _, edge := range graph.OutEdges(id) {
+			if edge.Kind != EdgeMemberOf {
+				snapshot.edges[EdgeChange{From: edge.From, To: edge.To, Kind: edge.Kind}] = true
+			}
+		}
+	}
+	return snapshot
+}
+
+// databaseSnapshot takes the functions and relationships of an exported
+// database, which holds the step, template call, sequential, and direct
+// resource references
+func databaseSnapshot(db *AnalysisDatabase) *analysisSnapshot {
+	snapshot := &analysisSnapshot{tests: map[string]bool{}, templates: map[string]bool{}, edges: map[EdgeChange]bool{}}
+	testID := func(id int) string { return testNodeID(db.tests[id].Name) }
+	templateID := func(id int) string { return templateNodeID(db.templates[id].Struct, db.templates[id].Name) }
+
+	for id := range db.tests {
+		snapshot.tests[testID(id)] = true
+	}
+	for id := range db.templates {
+		snapshot.templates[templateID(id)] = true
+	}
+	for _, step := range db.steps {
+		snapshot.edges[EdgeChange{From: testID(step.Test), To: templateID(step.Template), Kind: EdgeStepRef}] = true
+	}
+	for _, call := range db.callChain {
+		snapshot.edges[EdgeChange{From: templateID(call.From), To: templateID(call.To), Kind: EdgeTemplateCall}] = true
+	}
+	for _, seq := range db.sequential {
+		snapshot.edges[EdgeChange{From: testID(seq.From), To: testID(seq.To), Kind: EdgeSequentialRef}] = true
+	}
+	for resource, templates := range db.directRefs {
+		for _, template := range templates {
+			snapshot.edges[EdgeChange{From: templateID(template), To: resourceNodeID(db.resources[resource]), Kind: EdgeResourceRef}] = true
+		}
+	}
+	return snapshot
+}
+
+// DiffAnalyses compares two analysis snapshots
+func DiffAnalyses(oldName string, before *analysisSnapshot, newName string, after *analysisSnapshot) *AnalysisDiff {
+	diff := &AnalysisDiff{Old: oldName, New: newName}
+	diff.AddedTests, diff.RemovedTests = diffNames(before.tests, after.tests)
+	diff.AddedTemplates, diff.RemovedTemplates = diffNames(before.templates, after.templates)
+
+	diff.AddedReferences, diff.AddedEdges = []EdgeChange{}, []EdgeChange{}
+	diff.RemovedReferences, diff.RemovedEdges = []EdgeChange{}, []EdgeChange{}
+	for edge := range after.edges {
+		if before.edges[edge] {
+			continue
+		}
+		if edge.Kind == EdgeResourceRef {
+			diff.AddedReferences = append(diff.AddedReferences, edge)
+		} else {
+			diff.AddedEdges = append(diff.AddedEdges, edge)
+		}
+	}
+	for edge := range before.edges {
+		if after.edges[edge] {
+			continue
+		}
+		if edge.Kind == EdgeResourceRef {
+			diff.RemovedReferences = append(diff.RemovedReferences, edge)
+		} else {
+			diff.RemovedEdges = append(diff.RemovedEdges, edge)
+		}
+	}
+	for _, edges := range [][]EdgeChange{diff.AddedReferences, diff.RemovedReferences, diff.AddedEdges, diff.RemovedEdges} {
+		sortEdgeChanges(edges)
+	}
+	return diff
+}
+
+// diffNames returns the names only in after and those only in before, sorted
+func diffNames(before, after map[string]bool) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// sortEdgeChanges orders edges by source, kind, and target
+func sortEdgeChanges(edges []EdgeChange) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].Kind != edges[j].Kind {
+			return edges[i].Kind < edges[j].Kind
+		}
+		return edges[i].To < edges[j].To
+	})
+}
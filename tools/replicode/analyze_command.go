@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// runAnalyzeCommand writes the analysis of a single file or of a directory
+// as JSON, or with -format table a summary of it to read in a terminal. It
+// is also what replicode runs when the arguments don't start with a command,
+// the invocation the PowerShell modules use.
+func runAnalyzeCommand(args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	filePath := fs.String("file", "", "Go file to analyze, or - to read the source from stdin")
+	filename := fs.String("filename", "", "With -file -, the path the source is analyzed as, for service attribution and output paths, relative to -reporoot unless absolute (e.g., internal/services/network/subnet_resource_test.go)")
+	resourceName := fs.String("resourcename", "", "Target resource name to filter direct references (e.g., azurerm_resource_group)")
+	validate := fs.Bool("validate", false, "Exit non-zero when more than -validate-threshold of TestSteps or template calls are unresolved")
+	threshold := fs.Float64("validate-threshold", 0.05, "With -validate, the largest fraction (0-1) of TestSteps without a ConfigStruct or template calls without a TargetService")
+	format := fs.String("format", "json", "Output format: json, or table for a per-service summary of what was found")
+	var maxMemory byteSize
+	fs.Var(&maxMemory, "max-memory", "Soft memory target (e.g., 2GiB): the Go runtime collects garbage more aggressively near it, but it is not a cap, and a run needing more memory goes over it rather than failing")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if *filePath == "" && source.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Usage: replicode analyze -file <path-to-go-file> [-reporoot <repo-root>]")
+		fmt.Fprintln(os.Stderr, "       replicode analyze -file - -filename <path> [-reporoot <repo-root>] < source.go")
+		fmt.Fprintln(os.Stderr, "       replicode analyze -dir <directory> [-reporoot <repo-root>] [-format json|table] [-validate [-validate-threshold <fraction>]]")
+		return 1
+	}
+	// The path results are attributed to; the virtual -filename of stdin
+	path := *filePath
+	if *filePath == "-" {
+		if *filename == "" {
+			fmt.Fprintln(os.Stderr, "Error: -filename parameter is required with -file -")
+			return 1
+		}
+		path = *filename
+	} else if *filename != "" {
+		fmt.Fprintln(os.Stderr, "Error: -filename is only used with -file -")
+		return 1
+	}
+	if path != "" && source.RepoRoot == "" {
+		// The file's module or repository, so that wrappers need not pass it;
+		// for stdin that of the working directory, as -filename names no file
+		from := path
+		if *filePath == "-" {
+			from = "."
+		}
+		if source.RepoRoot = findRepoRoot(from); source.RepoRoot == "" {
+			fmt.Fprintf(os.Stderr, "Error: -reporoot parameter is required: no go.mod or .git above %s\n", from)
+			return 1
+		}
+	}
+	if *filePath == "-" && !filepath.IsAbs(path) {
+		path = filepath.Join(source.RepoRoot, path)
+	}
+	if err := validateFormat(*format, "json", "table"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *threshold < 0 || *threshold > 1 {
+		fmt.Fprintln(os.Stderr, "Error: -validate-threshold must be between 0 and 1")
+		return 1
+	}
+
+	applyMemoryLimit(maxMemory)
+	opts, err := source.analyzeOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	opts.ResourceName = *resourceName
+	// Without -resourcename, a user at a terminal picks the resource types
+	// whose direct references the JSON keeps, among those of the analysis
+	var pick func([]*analyzer.Result) (map[string]bool, error)
+	if *resourceName == "" && *filePath != "-" && *format == "json" && stdinIsTerminal() {
+		pick = pickAnalyzedResources
+	}
+	if *filePath == "-" {
+		// An unsaved editor buffer, analyzed as if it were at -filename
+		if opts.Source, err = io.ReadAll(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: reading stdin: %v\n", err)
+			return 1
+		}
+	}
+	start := time.Now()
+
+	// Write JSON to stdout (PowerShell will capture this; it ignores the provenance key)
+	var results []*analyzer.Result
+	if *filePath != "" {
+		var result *analyzer.Result
+		result, err = analyzer.Analyze(path, opts)
+		source.trace.export("analyze "+filepath.ToSlash(path), start, nil)
+		if err == nil && pick != nil {
+			var picked map[string]bool
+			if picked, err = pick([]*analyzer.Result{result}); err == nil {
+				keepDirectReferences(result, picked)
+			}
+		}
+		if err == nil {
+			results = []*analyzer.Result{result}
+			if *format == "json" {
+				err = writeDocument(os.Stdout, newProvenance(fs, opts.RepoRoot), result)
+			}
+		}
+	} else if *format == "table" {
+		results, err = analyzer.AnalyzeDir(source.Dir, opts)
+		source.exportTrace(start, len(results))
+	} else {
+		// Files are written as they are analyzed, so the trace covers both
+		results, err = writeDirectoryResult(os.Stdout, newProvenance(fs, opts.RepoRoot), source.Dir, opts, pick)
+		source.exportTrace(start, len(results))
+	}
+	if err == nil && *format == "table" {
+		err = writeSummaryTable(os.Stdout, SummarizeAnalysis(results, analyzer.ServicePatterns(source.Services)))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *validate {
+		completeness := resolutionCompleteness(results)
+		completeness.writeText(os.Stderr, *threshold)
+		if completeness.exceeds(*threshold) {
+			fmt.Fprintf(os.Stderr, "Error: unresolved references exceed %.1f%%\n", 100**threshold)
+			return exitUnresolved
+		}
+	}
+	return 0
+}
+
+// pickAnalyzedResources lets the user pick among the resource types of the
+// direct references of analysis results. Picking none, or having none to
+// pick from, returns nil, keeping every reference.
+func pickAnalyzedResources(results []*analyzer.Result) (map[string]bool, error) {
+	seen := map[string]bool{}
+	var candidates []string
+	for _, result := range results {
+		for _, ref := range result.DirectResourceRefs {
+			if !seen[ref.ResourceName] {
+				seen[ref.ResourceName] = true
+				candidates = append(candidates, ref.ResourceName)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Strings(candidates)
+	picked, err := pickResources(os.Stdin, os.Stderr, candidates)
+	if errors.Is(err, errNothingPicked) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	resources := make(map[string]bool, len(picked))
+	for _, resource := range picked {
+		resources[resource] = true
+	}
+	return resources, nil
+}
+
+// keepDirectReferences drops the direct references of a result to resource
+// types outside resources; a nil resources keeps them all
+func keepDirectReferences(result *analyzer.Result, resources map[string]bool) {
+	if resources == nil {
+		return
+	}
+	kept := result.DirectResourceRefs[:0]
+	for _, ref := range result.DirectResourceRefs {
+		if resources[ref.ResourceName] {
+			kept = append(kept, ref)
+		}
+	}
+	result.DirectResourceRefs = kept
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Check run annotation levels accepted by the GitHub checks API
+const (
+	annotationNotice  = "notice"
+	annotationWarning = "warning"
+)
+
+// CheckAnnotation is one entry of output.annotations in a GitHub check run
+type CheckAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title"`
+	Message         string `json:"message"`
+}
+
+// CheckRunOutput is the output object of a GitHub check run create/update request
+type CheckRunOutput struct {
+	Title       string            `json:"title"`
+	Summary     string            `json:"summary"`
+	Annotations []CheckAnnotation `json:"annotations"`
+}
+
+// CollectAnnotations turns analyzer findings into check run annotations:
+// templates at least fanInThreshold tests depend on, references that could not
+// be resolved to a template, and hardcoded names that make tests mutually
+// exclusive. When changes is non-empty only annotations on changed lines are kept.
+func CollectAnnotations(graph *DependencyGraph, fanInThreshold int, changes []CodeChange) []CheckAnnotation {
+	var annotations []CheckAnnotation
+
+	hotspots, _ := ComputeTemplateHotspots(graph, "dependent-tests")
+	for _, h := range hotspots {
+		if h.DependentTests < fanInThreshold || h.Template.File == "" {
+			continue
+		}
+		message := fmt.Sprintf("%d tests depend on this template", h.DependentTests)
+		if h.CrossServiceIn > 0 {
+			message += fmt.Sprintf("; %d direct referrers are in other services", h.CrossServiceIn)
+		}
+		annotations = append(annotations, CheckAnnotation{
+			Path:            h.Template.File,
+			StartLine:       h.Template.Line,
+			EndLine:         h.Template.Line,
+			AnnotationLevel: annotationWarning,
+			Title:           "Heavily referenced template " + h.Template.Name,
+			Message:         message + ". Changes here affect every dependent acceptance test.",
+		})
+	}
+
+	for _, ref := range graph.Unresolved {
+		if ref.File == "" {
+			continue
+		}
+		message := fmt.Sprintf("%s does not resolve to a known template, so its dependencies are not tracked.", ref.Detail)
+		if len(ref.Candidates) > 0 {
+			message = fmt.Sprintf("%s is declared by several packages (%s), so its dependencies are not tracked.", ref.Detail, strings.Join(ref.Candidates, ", "))
+		}
+		annotations = append(annotations, CheckAnnotation{
+			Path:            ref.File,
+			StartLine:       ref.Line,
+			EndLine:         ref.Line,
+			AnnotationLevel: annotationWarning,
+			Title:           "Unresolvable " + string(ref.Kind) + " reference",
+			Message:         message,
+		})
+	}
+
+	for _, deps := range graph.singletons {
+		for _, dep := range deps {
+			if dep.Kind != "HARDCODED_NAME" || dep.TemplateFile == "" {
+				continue
+			}
+			annotations = append(annotations, CheckAnnotation{
+				Path:            dep.TemplateFile,
+				StartLine:       dep.TemplateLine,
+				EndLine:         dep.TemplateLine,
+				AnnotationLevel: annotationNotice,
+				Title:           "Hardcoded resource name",
+				Message:         fmt.Sprintf("`%s` uses a fixed name, so tests using this template cannot run concurrently.", dep.Context),
+			})
+		}
+	}
+
+	if len(changes) > 0 {
+		var onChanged []CheckAnnotation
+		for _, annotation := range annotations {
+			location := CoveredRange{File: annotation.Path, StartLine: annotation.StartLine, EndLine: annotation.EndLine}
+			for _, change := range changes {
+				if change.Matches(location) {
+					onChanged = append(onChanged, annotation)
+					break
+				}
+			}
+		}
+		annotations = onChanged
+	}
+
+	sort.Slice(annotations, func(i, j int) bool {
+		if annotations[i].Path != annotations[j].Path {
+			return annotations[i].Path < annotations[j].Path
+		}
+		if annotations[i].StartLine != annotations[j].StartLine {
+			return annotations[i].StartLine < annotations[j].StartLine
+		}
+		return annotations[i].Title < annotations[j].Title
+	})
+	if annotations == nil {
+		annotations = []CheckAnnotation{}
+	}
+	return annotations
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// BenchResult is the throughput of the full extraction over a corpus, from
+// the fastest of the runs, for tracking regressions between replicode versions
+type BenchResult struct {
+	Directory        string           `json:"directory"`
+	Runs             int              `json:"runs"`
+	Files            int              `json:"files"`
+	Skipped          int              `json:"skipped"` // Files that failed to analyze
+	Records          int              `json:"records"`
+	Seconds          float64          `json:"seconds"`
+	FilesPerSecond   float64          `json:"files_per_second"`
+	RecordsPerSecond float64          `json:"records_per_second"`
+	Extractors       []BenchExtractor `json:"extractors"`
+}
+
+// BenchExtractor is the time one analysis step took over every file. Steps of
+// a file run concurrently once it is parsed, so the step times can add up to
+// more than the run's wall time; share is of their sum.
+type BenchExtractor struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+	Share   float64 `json:"share"`
+	Records *int    `json:"records,omitempty"` // Unset for steps that find no records, such as parsing
+}
+
+// benchRun is the measurement of one run over the corpus
+type benchRun struct {
+	files, skipped, records int
+	elapsed                 time.Duration
+	steps                   map[string]*benchStep
+}
+
+type benchStep struct {
+	elapsed time.Duration
+	records int
+	counted bool
+}
+
+// record adds the child spans of a file's span to the per-step totals
+func (r *benchRun) record(span analyzer.Span) {
+	for _, child := range span.Children {
+		step := r.steps[child.Name]
+		if step == nil {
+			step = &benchStep{}
+			r.steps[child.Name] = step
+		}
+		step.elapsed += child.End.Sub(child.Start)
+		if records, ok := child.Attributes[analyzer.AttrRecords].(int); ok {
+			step.records += records
+			step.counted = true
+		}
+	}
+}
+
+// resultRecords counts the records extracted from a file
+func resultRecords(result *analyzer.Result) int {
+	n := len(result.Functions) + len(result.Calls) + len(result.Imports) + len(result.TestSteps) +
+		len(result.TemplateCalls) + len(result.SequentialReferences) + len(result.DirectResourceRefs) +
+		len(result.SingletonDeps) + len(result.Requirements) + len(result.LocationFindings) + len(result.TemplateSources)
+	for _, records := range result.Extensions {
+		n += len(records)
+	}
+	return n
+}
+
+// benchmark analyzes the source directory runs times and returns the fastest run
+func benchmark(source *sourceOptions, runs int) (*benchRun, error) {
+	opts, err := source.analyzeOptions()
+	if err != nil {
+		return nil, err
+	}
+	export := opts.Trace
+
+	var best *benchRun
+	for i := 0; i < runs; i++ {
+		run := &benchRun{steps: map[string]*benchStep{}}
+		opts.Trace = func(span analyzer.Span) {
+			run.record(span)
+			if export != nil {
+				export(span)
+			}
+		}
+		first := i == 0
+		opts.Skipped = func(path string, err error) {
+			run.skipped++
+			if first {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			}
+		}
+
+		start := time.Now()
+		err := analyzer.AnalyzeDirFunc(source.Dir, opts, func(result *analyzer.Result) error {
+			run.files++
+			run.records += resultRecords(result)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		run.elapsed = time.Since(start)
+		source.exportTrace(start, run.files)
+
+		if best == nil || run.elapsed < best.elapsed {
+			best = run
+		}
+	}
+	return best, nil
+}
+
+// benchResult summarizes a run
+func benchResult(dir string, runs int, run *benchRun) *BenchResult {
+	seconds := run.elapsed.Seconds()
+	result := &BenchResult{
+		Directory:  dir,
+		Runs:       runs,
+		Files:      run.files,
+		Skipped:    run.skipped,
+		Records:    run.records,
+		Seconds:    seconds,
+		Extractors: []BenchExtractor{},
+	}
+	if seconds > 0 {
+		result.FilesPerSecond = float64(run.files) / seconds
+		result.RecordsPerSecond = float64(run.records) / seconds
+	}
+
+	var total time.Duration
+	for _, step := range run.steps {
+		total += step.elapsed
+	}
+	for name, step := range run.steps {
+		extractor := BenchExtractor{Name: name, Seconds: step.elapsed.Seconds()}
+		if total > 0 {
+			extractor.Share = float64(step.elapsed) / float64(total)
+		}
+		if step.counted {
+			records := step.records
+			extractor.Records = &records
+		}
+		result.Extractors = append(result.Extractors, extractor)
+	}
+	sort.Slice(result.Extractors, func(i, j int) bool { return result.Extractors[i].Name < result.Extractors[j].Name })
+	return result
+}
+
+// writeBenchText writes the benchmark as a summary line and a table of steps, slowest first
+func writeBenchText(w io.Writer, result *BenchResult) {
+	fmt.Fprintf(w, "Analyzed %d files (%d records) in %.3fs: %.1f files/s, %.1f records/s (fastest of %d runs)\n",
+		result.Files, result.Records, result.Seconds, result.FilesPerSecond, result.RecordsPerSecond, result.Runs)
+	if result.Skipped > 0 {
+		fmt.Fprintf(w, "Skipped %d files that failed to analyze\n", result.Skipped)
+	}
+
+	extractors := append([]BenchExtractor{}, result.Extractors...)
+	sort.SliceStable(extractors, func(i, j int) bool { return extractors[i].Seconds > extractors[j].Seconds })
+	fmt.Fprintf(w, "\n%-40s %10s %7s %10s\n", "STEP", "TIME", "SHARE", "RECORDS")
+	for _, extractor := range extractors {
+		records := "-"
+		if extractor.Records != nil {
+			records = fmt.Sprint(*extractor.Records)
+		}
+		fmt.Fprintf(w, "%-40s %9.3fs %6.1f%% %10s\n", extractor.Name, extractor.Seconds, extractor.Share*100, records)
+	}
+}
+
+// runBenchCommand measures extraction throughput over a corpus
+func runBenchCommand(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	runs := fs.Int("runs", 3, "Number of runs; the fastest is reported")
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if source.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+	if *runs < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -runs must be at least 1")
+		return 1
+	}
+	if *format != "json" && *format != "text" {
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q (expected json or text)\n", *format)
+		return 1
+	}
+
+	run, err := benchmark(&source, *runs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	result := benchResult(filepath.ToSlash(source.Dir), *runs, run)
+
+	if *format == "text" {
+		writeBenchText(os.Stdout, result)
+		return 0
+	}
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
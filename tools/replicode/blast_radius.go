@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// BlastRadius lists the tests of other services that depend on one service
+type BlastRadius struct {
+	Service   string   `json:"service"`
+	Templates int      `json:"templates"` // Templates the service declares
+	Resources []string `json:"resources"` // Resource types its tests are bound to (acceptance.BuildTestData)
+
+	Tests    int                  `json:"tests"` // External tests across all services
+	Services []BlastRadiusService `json:"services"`
+}
+
+// BlastRadiusService groups the external tests owned by one service
+type BlastRadiusService struct {
+	Service string            `json:"service"`
+	Tests   []BlastRadiusTest `json:"tests"`
+}
+
+// BlastRadiusTest is an external test and what it reaches of the target service
+type BlastRadiusTest struct {
+	Name      string   `json:"name"`
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	Templates []string `json:"templates,omitempty"` // Templates of the target service in its closure
+	Resources []string `json:"resources,omitempty"` // Resource types of the target service in its closure
+}
+
+// ComputeBlastRadius finds every runnable test outside service that
+// transitively reaches one of its templates or resource types, grouped by the
+// test's own service. A service's resource types are the ones its tests bind
+// with acceptance.BuildTestData, since the graph has no other notion of
+// ownership: azurerm_subnet belongs to network because network tests it.
+func ComputeBlastRadius(graph *DependencyGraph, results []*analyzer.Result, service string) *BlastRadius {
+	radius := &BlastRadius{Service: service, Resources: []string{}, Services: []BlastRadiusService{}}
+
+	owned := map[string]bool{}
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if fn.ServiceName == service && fn.ResourceUnderTest != "" && !owned[fn.ResourceUnderTest] {
+				owned[fn.ResourceUnderTest] = true
+				radius.Resources = append(radius.Resources, fn.ResourceUnderTest)
+			}
+		}
+	}
+	sort.Strings(radius.Resources)
+
+	byService := map[string][]BlastRadiusTest{}
+	for id, node := range graph.Nodes {
+		if node.Kind == NodeTemplate && node.Service == service {
+			radius.Templates++
+		}
+		if node.Kind != NodeTest || node.Service == service || !isRunnableTest(node.Name) {
+			continue
+		}
+
+		test := BlastRadiusTest{Name: node.Name, File: node.File, Line: node.Line}
+		for reachedID := range graph.Reachable(id, DirectionOut, 0) {
+			reached := graph.Nodes[reachedID]
+			if reached == nil {
+				continue
+			}
+			if reached.Kind == NodeTemplate && reached.Service == service {
+				test.Templates = append(test.Templates, reached.Name)
+			} else if reached.Kind == NodeResource && owned[reached.Name] {
+				test.Resources = append(test.Resources, reached.Name)
+			}
+		}
+		if len(test.Templates) == 0 && len(test.Resources) == 0 {
+			continue
+		}
+		sort.Strings(test.Templates)
+		sort.Strings(test.Resources)
+		byService[node.Service] = append(byService[node.Service], test)
+		radius.Tests++
+	}
+
+	for name, tests := range byService {
+		sort.Slice(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
+		radius.Services = append(radius.Services, BlastRadiusService{Service: name, Tests: tests})
+	}
+	sort.Slice(radius.Services, func(i, j int) bool {
+		a, b := radius.Services[i], radius.Services[j]
+		if len(a.Tests) != len(b.Tests) {
+			return len(a.Tests) > len(b.Tests)
+		}
+		return a.Service < b.Service
+	})
+	return radius
+}
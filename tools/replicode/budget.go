@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// budgetGranularity is the time resolution of the budget knapsack
+const budgetGranularity = time.Minute
+
+// BudgetSummary reports how a duration budget was spent
+type BudgetSummary struct {
+	BudgetSeconds int            `json:"budget_seconds"`
+	UsedSeconds   int            `json:"used_seconds"`
+	Deferred      []DeferredTest `json:"deferred"`
+}
+
+// DeferredTest is an impacted test left out to stay within the budget
+type DeferredTest struct {
+	Name             string  `json:"name"`
+	EstimatedSeconds int     `json:"estimated_seconds"`
+	RiskScore        float64 `json:"risk_score"`
+	Reason           string  `json:"reason"`
+}
+
+// Deferral reasons
+const (
+	deferredExceedsBudget = "exceeds_budget"  // The test's sequential group alone is longer than the budget
+	deferredLowerValue    = "lower_value"     // Higher-risk tests used the available time
+	deferredGroupExceeds  = "group_too_large" // A sequential partner made the group longer than the budget
+)
+
+// ApplyDurationBudget keeps the subset of selected tests with the highest
+// total risk score whose estimated duration fits within the budget. Tests
+// linked through sequential references are kept or deferred together. The
+// kept tests retain their input order.
+func ApplyDurationBudget(graph *DependencyGraph, tests []SelectedTest, budget time.Duration) ([]SelectedTest, *BudgetSummary) {
+	units := sequentialUnits(graph, tests)
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].tests[0] < units[j].tests[0]
+	})
+
+	capacity := int(budget / budgetGranularity)
+	weights := make([]int, len(units))
+	for i, unit := range units {
+		weights[i] = int((time.Duration(unit.seconds)*time.Second + budgetGranularity - 1) / budgetGranularity)
+	}
+
+	// 0/1 knapsack over units: best[c] is the highest risk achievable in c units of time
+	best := make([]float64, capacity+1)
+	take := make([][]bool, len(units))
+	for i, unit := range units {
+		take[i] = make([]bool, capacity+1)
+		for c := capacity; c >= weights[i]; c-- {
+			if candidate := best[c-weights[i]] + unit.risk; candidate > best[c] {
+				best[c] = candidate
+				take[i][c] = true
+			}
+		}
+	}
+
+	kept := map[string]bool{}
+	for i, c := len(units)-1, capacity; i >= 0; i-- {
+		if take[i][c] {
+			for _, name := range units[i].tests {
+				kept[name] = true
+			}
+			c -= weights[i]
+		}
+	}
+
+	reasons := map[string]string{}
+	for i, unit := range units {
+		if weights[i] <= capacity {
+			continue
+		}
+		for _, name := range unit.tests {
+			reasons[name] = deferredExceedsBudget
+			if len(unit.tests) > 1 {
+				reasons[name] = deferredGroupExceeds
+			}
+		}
+	}
+
+	summary := &BudgetSummary{BudgetSeconds: int(budget.Seconds()), Deferred: []DeferredTest{}}
+	selected := []SelectedTest{}
+	for _, test := range tests {
+		if kept[test.Name] {
+			selected = append(selected, test)
+			summary.UsedSeconds += test.EstimatedSeconds
+			continue
+		}
+
+		reason := reasons[test.Name]
+		if reason == "" {
+			reason = deferredLowerValue
+		}
+		summary.Deferred = append(summary.Deferred, DeferredTest{
+			Name:             test.Name,
+			EstimatedSeconds: test.EstimatedSeconds,
+			RiskScore:        test.RiskScore,
+			Reason:           reason,
+		})
+	}
+
+	return selected, summary
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// CacheInfo summarizes the entries of a result cache directory
+type CacheInfo struct {
+	Directory string     `json:"directory"`
+	Entries   int        `json:"entries"`
+	Bytes     int64      `json:"bytes"`
+	Oldest    *time.Time `json:"oldest,omitempty"` // Write time of the oldest entry
+	Newest    *time.Time `json:"newest,omitempty"` // Write time of the newest entry
+}
+
+// runCacheCommand dispatches the cache subcommands, which inspect and clean up
+// a -cache directory
+func runCacheCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "info":
+			return runCacheInfo(args[1:])
+		case "clear":
+			return runCacheClear(args[1:], false)
+		case "prune":
+			return runCacheClear(args[1:], true)
+		}
+		if !isHelpFlag(args[0]) {
+			fmt.Fprintf(os.Stderr, "Error: unknown cache command %q\n", args[0])
+		}
+	}
+	fmt.Fprintln(usageOutput(args), "Usage: replicode cache info -cache <directory> [-format json|text]")
+	fmt.Fprintln(usageOutput(args), "       replicode cache clear -cache <directory>")
+	fmt.Fprintln(usageOutput(args), "       replicode cache prune -cache <directory> -older-than <duration>")
+	return usageStatus(args)
+}
+
+// cacheEntryPattern matches the files a result cache writes: "<key>.json"
+// entries and the "<key>.<random>.tmp" files of interrupted writes, where the
+// key is a hex SHA-256. Nothing else in the directory is touched.
+var cacheEntryPattern = regexp.MustCompile(`^[0-9a-f]{64}(\.json|\.[0-9]+\.tmp)$`)
+
+// cacheEntries lists the entry files of a cache directory
+func cacheEntries(dir string) ([]os.DirEntry, error) {
+	all, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []os.DirEntry
+	for _, entry := range all {
+		if entry.Type().IsRegular() && cacheEntryPattern.MatchString(entry.Name()) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// runCacheInfo reports the number, size, and age of the entries in a cache
+func runCacheInfo(args []string) int {
+	fs := flag.NewFlagSet("cache info", flag.ContinueOnError)
+	dir := fs.String("cache", "", "Result cache directory")
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -cache parameter is required")
+		return 1
+	}
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	entries, err := cacheEntries(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	info := CacheInfo{Directory: filepath.ToSlash(*dir)}
+	for _, entry := range entries {
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		modified := stat.ModTime().UTC().Truncate(time.Second)
+		info.Entries++
+		info.Bytes += stat.Size()
+		if info.Oldest == nil || modified.Before(*info.Oldest) {
+			info.Oldest = &modified
+		}
+		if info.Newest == nil || modified.After(*info.Newest) {
+			newest := modified
+			info.Newest = &newest
+		}
+	}
+
+	if *format == "text" {
+		fmt.Printf("%s: %d entries, %.1f MiB\n", info.Directory, info.Entries, float64(info.Bytes)/(1<<20))
+		if info.Entries > 0 {
+			fmt.Printf("Oldest %s, newest %s\n", info.Oldest.Format(time.RFC3339), info.Newest.Format(time.RFC3339))
+		}
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, ""), info); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runCacheClear deletes the entries of a cache: every entry, or with prune
+// the entries written longer than -older-than ago. Since entries are keyed by
+// the build that wrote them, pruning is how a cache shared across upgrades
+// sheds the entries no build will read again.
+func runCacheClear(args []string, prune bool) int {
+	name := "cache clear"
+	if prune {
+		name = "cache prune"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dir := fs.String("cache", "", "Result cache directory")
+	var olderThan *time.Duration
+	if prune {
+		olderThan = fs.Duration("older-than", 0, "Delete entries written longer than this ago (e.g., 720h)")
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -cache parameter is required")
+		return 1
+	}
+	if prune && *olderThan <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -older-than parameter is required")
+		return 1
+	}
+
+	entries, err := cacheEntries(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	removed := 0
+	for _, entry := range entries {
+		if prune {
+			stat, err := entry.Info()
+			if err != nil || time.Since(stat.ModTime()) < *olderThan {
+				continue
+			}
+		}
+		if err := os.Remove(filepath.Join(*dir, entry.Name())); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		removed++
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d of %d cache entries\n", removed, len(entries))
+	return 0
+}
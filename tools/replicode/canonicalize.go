@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// acceptanceImportPath is the package providing RunTestsInSequence, added to
+// files that don't import it yet
+const acceptanceImportPath = "github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+
+// sequentialRewrite is one map-based sequential test rewritten, or left
+// alone with the reason, by the canonicalize codemod
+type sequentialRewrite struct {
+	File     string
+	Line     int
+	Function string
+	Variable string
+	Skipped  string // Why the pattern was left as it is; "" when rewritten
+}
+
+// canonicalizeFile rewrites the ad-hoc sequential tests of one file, where a
+// map[string]map[string]func(t *testing.T) variable is run by nested range
+// loops over t.Run, into the canonical inline
+// acceptance.RunTestsInSequence(t, map[...]{...}) form. It returns the new
+// content, gofmt-formatted, and every map-based pattern it looked at. Only
+// patterns whose loop does exactly what RunTestsInSequence does are rewritten,
+// and the rewritten file must yield the same groups, keys, and functions.
+func canonicalizeFile(path string, src []byte) ([]byte, []sequentialRewrite, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing file: %v", err)
+	}
+	before := analyzer.DetectPatterns(file, path)
+
+	qualifier, imported := acceptanceQualifier(file)
+	var edits []textEdit
+	var rewrites []sequentialRewrite
+	rewritten := map[string][]analyzer.SequentialFunctionMapping{} // Function -> mappings expected inline
+	for _, pattern := range before.MapBasedTests {
+		if pattern.IsInlineArgument {
+			continue
+		}
+		rewrite := sequentialRewrite{
+			File:     path,
+			Line:     fset.Position(token.Pos(pattern.Line)).Line,
+			Variable: pattern.MapVariableName,
+		}
+		edit, function, reason := sequentialEdit(fset, file, src, token.Pos(pattern.Line), qualifier)
+		rewrite.Function = function
+		if reason != "" {
+			rewrite.Skipped = reason
+		} else if _, exists := rewritten[function]; exists {
+			rewrite.Skipped = "function already has a rewritten sequential test"
+		} else {
+			edits = append(edits, edit)
+			rewritten[function] = pattern.Mappings
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(edits) == 0 {
+		return src, rewrites, nil
+	}
+	if !imported {
+		edits = append(edits, importEdits(fset, file, src, acceptanceImportPath)...)
+	}
+
+	out := applyEdits(src, edits)
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, nil, fmt.Errorf("formatting rewritten file: %v", err)
+	}
+
+	// The rewrite must keep every group, key, and function of each test
+	check, err := parser.ParseFile(token.NewFileSet(), path, formatted, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing rewritten file: %v", err)
+	}
+	after := analyzer.DetectPatterns(check, path)
+	for function, mappings := range rewritten {
+		if !inlineMappingsMatch(after, function, mappings) {
+			return nil, nil, fmt.Errorf("rewriting %s changed its sequential tests", function)
+		}
+	}
+	return formatted, rewrites, nil
+}
+
+// sequentialEdit returns the edit replacing the map declaration at pos and
+// the loop running it with a RunTestsInSequence call, the name of the
+// function holding them, and why they can't be rewritten when they can't
+func sequentialEdit(fset *token.FileSet, file *ast.File, src []byte, pos token.Pos, qualifier string) (textEdit, string, string) {
+	var funcDecl *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil && fn.Pos() <= pos && pos < fn.End() {
+			funcDecl = fn
+		}
+	}
+	if funcDecl == nil {
+		return textEdit{}, "", "not inside a function"
+	}
+	function := funcDecl.Name.Name
+	tName := testingParam(funcDecl.Type)
+	if tName == "" {
+		return textEdit{}, function, "function has no *testing.T parameter"
+	}
+
+	// The declaration must be a statement of the function body, immediately
+	// followed by the loop, so that nothing runs between them
+	stmts := funcDecl.Body.List
+	index := -1
+	for i, stmt := range stmts {
+		if stmt.Pos() <= pos && pos < stmt.End() {
+			index = i
+		}
+	}
+	if index < 0 {
+		return textEdit{}, function, "map is not declared directly in the function body"
+	}
+	variable, literal := mapDeclaration(stmts[index])
+	if variable == nil || literal == nil {
+		return textEdit{}, function, "declaration is not a single map variable"
+	}
+	if index+1 == len(stmts) {
+		return textEdit{}, function, "map is not run by a loop"
+	}
+	loop, ok := stmts[index+1].(*ast.RangeStmt)
+	if !ok || !isIdent(loop.X, variable.Name) {
+		return textEdit{}, function, "map is not run by the loop that follows it"
+	}
+	if !isSequentialLoop(loop, tName) {
+		return textEdit{}, function, "loop does not run the map as RunTestsInSequence does"
+	}
+	if uses := countUses(funcDecl.Body, variable.Obj); uses != 1 {
+		return textEdit{}, function, "map is used outside the loop"
+	}
+	for _, group := range file.Comments {
+		if group.Pos() > literal.End() && group.End() <= loop.End() {
+			return textEdit{}, function, "comments in the loop would be lost"
+		}
+	}
+
+	start := fset.Position(stmts[index].Pos()).Offset
+	end := fset.Position(loop.End()).Offset
+	mapText := src[fset.Position(literal.Pos()).Offset:fset.Position(literal.End()).Offset]
+	call := fmt.Sprintf("%s.RunTestsInSequence(%s, %s)", qualifier, tName, mapText)
+	return textEdit{start: start, end: end, text: call}, function, ""
+}
+
+// mapDeclaration returns the variable and map literal of a statement that
+// declares a single map[string]map[string]func(...) variable
+func mapDeclaration(stmt ast.Stmt) (*ast.Ident, *ast.CompositeLit) {
+	var name *ast.Ident
+	var value ast.Expr
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok != token.DEFINE || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			return nil, nil
+		}
+		name, _ = s.Lhs[0].(*ast.Ident)
+		value = s.Rhs[0]
+	case *ast.DeclStmt:
+		decl, ok := s.Decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.VAR || len(decl.Specs) != 1 {
+			return nil, nil
+		}
+		spec := decl.Specs[0].(*ast.ValueSpec)
+		if len(spec.Names) != 1 || len(spec.Values) != 1 {
+			return nil, nil
+		}
+		name, value = spec.Names[0], spec.Values[0]
+	}
+	literal, ok := value.(*ast.CompositeLit)
+	if name == nil || !ok {
+		return nil, nil
+	}
+	outer, ok := literal.Type.(*ast.MapType)
+	if !ok {
+		return nil, nil
+	}
+	inner, ok := outer.Value.(*ast.MapType)
+	if !ok {
+		return nil, nil
+	}
+	if _, ok := inner.Value.(*ast.FuncType); !ok {
+		return nil, nil
+	}
+	return name, literal
+}
+
+// isSequentialLoop reports whether a loop over the map runs it the way
+// RunTestsInSequence does:
+//
+//	for group, m := range tests {
+//		t.Run(group, func(t *testing.T) {
+//			for name, tc := range m {
+//				t.Run(name, func(t *testing.T) { tc(t) })
+//			}
+//		})
+//	}
+//
+// allowing m := m and tc := tc copies and t.Run(name, tc) as the inner call.
+// Anything else, such as t.Parallel or setup between the loops, changes what
+// the tests do and is left alone.
+func isSequentialLoop(loop *ast.RangeStmt, tName string) bool {
+	group, tests, ok := rangeVars(loop)
+	if !ok {
+		return false
+	}
+	body, ok := lastStmt(loop.Body, tests)
+	if !ok {
+		return false
+	}
+	innerT, groupBody, ok := tRunFuncLit(body, tName, group)
+	if !ok {
+		return false
+	}
+	stmt, ok := lastStmt(groupBody, "")
+	if !ok {
+		return false
+	}
+	inner, ok := stmt.(*ast.RangeStmt)
+	if !ok || !isIdent(inner.X, tests) {
+		return false
+	}
+	name, test, ok := rangeVars(inner)
+	if !ok {
+		return false
+	}
+	stmt, ok = lastStmt(inner.Body, test)
+	if !ok {
+		return false
+	}
+
+	// t.Run(name, tc)
+	if call, ok := tRunCall(stmt, innerT, name); ok && isIdent(call.Args[1], test) {
+		return true
+	}
+	// t.Run(name, func(t *testing.T) { tc(t) })
+	testT, testBody, ok := tRunFuncLit(stmt, innerT, name)
+	if !ok || len(testBody.List) != 1 {
+		return false
+	}
+	expr, ok := testBody.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	return ok && isIdent(call.Fun, test) && len(call.Args) == 1 && isIdent(call.Args[0], testT)
+}
+
+// rangeVars returns the key and value names of a for k, v := range loop
+func rangeVars(loop *ast.RangeStmt) (string, string, bool) {
+	key, ok := loop.Key.(*ast.Ident)
+	if !ok || loop.Tok != token.DEFINE {
+		return "", "", false
+	}
+	value, ok := loop.Value.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	return key.Name, value.Name, true
+}
+
+// lastStmt returns the only statement of a block other than v := v copies of
+// the named variable (or of any variable when copied is "")
+func lastStmt(block *ast.BlockStmt, copied string) (ast.Stmt, bool) {
+	var rest []ast.Stmt
+	for _, stmt := range block.List {
+		if assign, ok := stmt.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE && len(assign.Lhs) == 1 && len(assign.Rhs) == 1 {
+			if lhs, ok := assign.Lhs[0].(*ast.Ident); ok && isIdent(assign.Rhs[0], lhs.Name) && (copied == "" || lhs.Name == copied) {
+				continue
+			}
+		}
+		rest = append(rest, stmt)
+	}
+	if len(rest) != 1 {
+		return nil, false
+	}
+	return rest[0], true
+}
+
+// tRunCall matches the statement t.Run(name, ...)
+func tRunCall(stmt ast.Stmt, tName, name string) (*ast.CallExpr, bool) {
+	expr, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return nil, false
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 || !isIdent(call.Args[0], name) {
+		return nil, false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" || !isIdent(sel.X, tName) {
+		return nil, false
+	}
+	return call, true
+}
+
+// tRunFuncLit matches t.Run(name, func(t *testing.T) {...}), returning the
+// subtest's *testing.T name and body
+func tRunFuncLit(stmt ast.Stmt, tName, name string) (string, *ast.BlockStmt, bool) {
+	call, ok := tRunCall(stmt, tName, name)
+	if !ok {
+		return "", nil, false
+	}
+	lit, ok := call.Args[1].(*ast.FuncLit)
+	if !ok {
+		return "", nil, false
+	}
+	inner := testingParam(lit.Type)
+	if inner == "" {
+		return "", nil, false
+	}
+	return inner, lit.Body, true
+}
+
+// testingParam returns the name of a function's only parameter when it is a *testing.T
+func testingParam(funcType *ast.FuncType) string {
+	if funcType.Params == nil || len(funcType.Params.List) != 1 || len(funcType.Params.List[0].Names) != 1 {
+		return ""
+	}
+	param := funcType.Params.List[0]
+	star, ok := param.Type.(*ast.StarExpr)
+	if !ok {
+		return ""
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "T" || !isIdent(sel.X, "testing") {
+		return ""
+	}
+	return param.Names[0].Name
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// countUses counts the references to a declared object in a block, other than its declaration
+func countUses(block *ast.BlockStmt, obj *ast.Object) int {
+	if obj == nil {
+		return -1
+	}
+	uses := 0
+	ast.Inspect(block, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj == obj && ident.Pos() != obj.Pos() {
+			uses++
+		}
+		return true
+	})
+	return uses
+}
+
+// acceptanceQualifier returns the name the file refers to the acceptance
+// package by, and whether the file imports it already
+func acceptanceQualifier(file *ast.File) (string, bool) {
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil || (path != acceptanceImportPath && !strings.HasSuffix(path, "/acceptance")) {
+			continue
+		}
+		if imp.Name != nil && imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name, true
+		}
+		if imp.Name == nil {
+			return "acceptance", true
+		}
+	}
+	return "acceptance", false
+}
+
+// inlineMappingsMatch reports whether a function runs an inline
+// RunTestsInSequence map with the given groups, keys, and functions
+func inlineMappingsMatch(patterns *analyzer.PatternDetector, function string, want []analyzer.SequentialFunctionMapping) bool {
+	key := func(mappings []analyzer.SequentialFunctionMapping) []string {
+		keys := make([]string, 0, len(mappings))
+		for _, m := range mappings {
+			keys = append(keys, m.SequentialGroup+"\x00"+m.SequentialKey+"\x00"+m.FunctionName)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	var entry *analyzer.SequentialTestInfo
+	for i, test := range patterns.SequentialTests {
+		if test.FunctionName == function && test.Pattern == "RunTestsInSequence" {
+			entry = &patterns.SequentialTests[i]
+		}
+	}
+	if entry == nil {
+		return false
+	}
+	for _, pattern := range patterns.MapBasedTests {
+		if pattern.IsInlineArgument && pattern.Line == entry.Line && reflect.DeepEqual(key(pattern.Mappings), key(want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// runCanonicalizeCommand rewrites ad-hoc map-based sequential tests into the
+// canonical RunTestsInSequence form, printing the changes as a patch or
+// writing them to the files
+func runCanonicalizeCommand(args []string) int {
+	fs := flag.NewFlagSet("canonicalize", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Directory to rewrite recursively (e.g., internal/services)")
+	repoRoot := fs.String("reporoot", "", "Repository root the patch paths are relative to (defaults to the module or repository containing -dir)")
+	output := newCodemodOutput(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+	root := *repoRoot
+	if root == "" {
+		root = defaultRepoRoot(*dir)
+	}
+	if err := output.start(root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	stamps, err := scanTestFiles(*dir, analyzer.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	files := make([]string, 0, len(stamps))
+	for path := range stamps {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	rewritten, skipped, changedFiles := 0, 0, 0
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		if !bytes.Contains(src, []byte("map[string]map[string]func")) {
+			continue
+		}
+		out, rewrites, err := canonicalizeFile(path, src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		rel, err := analyzer.RelativePath(root, path)
+		if err != nil {
+			rel = filepath.ToSlash(path)
+		}
+		for _, rewrite := range rewrites {
+			if rewrite.Skipped != "" {
+				skipped++
+				output.left("Left %s:%d (%s, map %s): %s", rel, rewrite.Line, rewrite.Function, rewrite.Variable, rewrite.Skipped)
+			} else {
+				rewritten++
+			}
+		}
+		if bytes.Equal(out, src) {
+			continue
+		}
+		changedFiles++
+		if err := output.apply(path, rel, src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Rewrote"
+	if !output.writing() {
+		verb = "Would rewrite"
+	}
+	return output.finish("%s %d sequential tests in %d files; left %d as they are", verb, rewritten, changedFiles, skipped)
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// textEdit replaces the source bytes [start, end) with text
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// standardImport reports whether an import path is of the standard library
+func standardImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// importEdits add imports of the given paths to the file's first import
+// declaration, or as a new declaration after the package clause. Standard
+// library imports join the first group and others the last, where gofmt sorts
+// them; a group of the other kind is started when the declaration has none.
+func importEdits(fset *token.FileSet, file *ast.File, src []byte, paths ...string) []textEdit {
+	var std, other []string
+	for _, path := range paths {
+		if standardImport(path) {
+			std = append(std, strconv.Quote(path))
+		} else {
+			other = append(other, strconv.Quote(path))
+		}
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		first := gen.Specs[0].(*ast.ImportSpec)
+		last := gen.Specs[len(gen.Specs)-1].(*ast.ImportSpec)
+		firstPath, _ := strconv.Unquote(first.Path.Value)
+		lastPath, _ := strconv.Unquote(last.Path.Value)
+		if !gen.Rparen.IsValid() {
+			start := fset.Position(gen.Pos()).Offset
+			end := fset.Position(gen.End()).Offset
+			existing := string(src[fset.Position(first.Pos()).Offset:end])
+			if standardImport(firstPath) {
+				std = append([]string{existing}, std...)
+			} else {
+				other = append([]string{existing}, other...)
+			}
+			return []textEdit{{start: start, end: end, text: "import (\n" + importGroups(std, other) + ")"}}
+		}
+
+		var edits []textEdit
+		if len(std) > 0 {
+			offset := fset.Position(first.Pos()).Offset
+			separator := "\n\t"
+			if !standardImport(firstPath) {
+				separator = "\n\n\t"
+			}
+			edits = append(edits, textEdit{start: offset, end: offset, text: strings.Join(std, "\n\t") + separator})
+		}
+		if len(other) > 0 {
+			offset := fset.Position(last.End()).Offset
+			separator := "\n\t"
+			if standardImport(lastPath) {
+				separator = "\n\n\t"
+			}
+			edits = append(edits, textEdit{start: offset, end: offset, text: separator + strings.Join(other, "\n\t")})
+		}
+		return edits
+	}
+	offset := fset.Position(file.Name.End()).Offset
+	if len(std)+len(other) == 1 {
+		return []textEdit{{start: offset, end: offset, text: "\n\nimport " + append(std, other...)[0]}}
+	}
+	return []textEdit{{start: offset, end: offset, text: "\n\nimport (\n" + importGroups(std, other) + ")"}}
+}
+
+// importGroups formats import specs as a standard library group and a group
+// of the others, each line indented and ending with a newline
+func importGroups(std, other []string) string {
+	var groups []string
+	for _, group := range [][]string{std, other} {
+		if len(group) > 0 {
+			groups = append(groups, "\t"+strings.Join(group, "\n\t")+"\n")
+		}
+	}
+	return strings.Join(groups, "\n")
+}
+
+// applyEdits applies non-overlapping edits to src
+func applyEdits(src []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		out.Write(src[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// lineEdit returns the edit removing src[start:end], widened to whole lines
+// when nothing else shares them
+func lineEdit(src []byte, start, end int) textEdit {
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if len(bytes.TrimSpace(src[lineStart:start])) == 0 && len(bytes.TrimSpace(src[end:lineEnd])) == 0 {
+		return textEdit{start: lineStart, end: lineEnd}
+	}
+	return textEdit{start: start, end: end}
+}
+
+// declStart returns where a declaration starts, including its doc comment
+func declStart(decl ast.Decl) token.Pos {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
+
+// declText returns a declaration's source with its doc comment
+func declText(fset *token.FileSet, src []byte, decl ast.Decl) string {
+	return string(src[fset.Position(declStart(decl)).Offset:fset.Position(decl.End()).Offset])
+}
+
+// usedImports returns the names of the packages declarations refer to
+func usedImports(decls []ast.Decl) map[string]bool {
+	used := map[string]bool{}
+	for _, decl := range decls {
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					used[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	return used
+}
+
+// importSpecName returns the name a file refers to an import by
+func importSpecName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	p, _ := strconv.Unquote(spec.Path.Value)
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+// pruneImports returns the edits removing the file's imports whose names
+// are not in used, and import declarations left empty
+func pruneImports(fset *token.FileSet, file *ast.File, src []byte, used map[string]bool) []textEdit {
+	var edits []textEdit
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		var removed []textEdit
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if name := importSpecName(imp); name == "_" || name == "." || used[name] {
+				continue
+			}
+			removed = append(removed, lineEdit(src, fset.Position(imp.Pos()).Offset, fset.Position(imp.End()).Offset))
+		}
+		if len(removed) == len(gen.Specs) {
+			// Drop the whole declaration rather than leave it empty
+			removed = []textEdit{lineEdit(src, fset.Position(gen.Pos()).Offset, fset.Position(gen.End()).Offset)}
+		}
+		edits = append(edits, removed...)
+	}
+	return edits
+}
+
+// sourceFile is a parsed source file and the edits a codemod makes to it
+type sourceFile struct {
+	path  string
+	rel   string // Repository-relative path, as the analysis records it
+	src   []byte
+	fset  *token.FileSet
+	file  *ast.File
+	edits map[int]textEdit // By start offset, so an edit found twice is made once
+}
+
+// edit records an edit to the file
+func (f *sourceFile) edit(e textEdit) {
+	f.edits[e.start] = e
+}
+
+// offset returns the byte offset of a position in the file
+func (f *sourceFile) offset(pos token.Pos) int {
+	return f.fset.Position(pos).Offset
+}
+
+// output returns the file's content with its edits applied
+func (f *sourceFile) output() []byte {
+	edits := make([]textEdit, 0, len(f.edits))
+	for _, e := range f.edits {
+		edits = append(edits, e)
+	}
+	return applyEdits(f.src, edits)
+}
+
+// sourceFiles parses the files a codemod edits on first use, by
+// repository-relative path
+type sourceFiles struct {
+	root  string
+	files map[string]*sourceFile
+}
+
+func newSourceFiles(root string) *sourceFiles {
+	return &sourceFiles{root: root, files: map[string]*sourceFile{}}
+}
+
+// open returns the parsed file at a repository-relative path
+func (s *sourceFiles) open(rel string) (*sourceFile, error) {
+	if f, ok := s.files[rel]; ok {
+		return f, nil
+	}
+	path := filepath.Join(s.root, filepath.FromSlash(rel))
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", rel, err)
+	}
+	f := &sourceFile{path: path, rel: rel, src: src, fset: fset, file: file, edits: map[int]textEdit{}}
+	s.files[rel] = f
+	return f, nil
+}
+
+// edited returns the files with edits, sorted by path
+func (s *sourceFiles) edited() []*sourceFile {
+	var files []*sourceFile
+	for _, f := range s.files {
+		if len(f.edits) > 0 {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files
+}
+
+// recordedCalls returns the selectors of the method's calls in the expression
+// an edge records, with the function containing them: the call starting at
+// the line of a template call, or the calls in the Config of the TestStep
+// starting at the line of a step reference
+func (f *sourceFile) recordedCalls(edge *GraphEdge, method string) (*ast.FuncDecl, []*ast.SelectorExpr) {
+	for _, decl := range f.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || f.fset.Position(fn.Pos()).Line > edge.Line || f.fset.Position(fn.End()).Line < edge.Line {
+			continue
+		}
+		var sels []*ast.SelectorExpr
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			var expr ast.Expr
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if edge.Kind == EdgeTemplateCall && f.fset.Position(n.Pos()).Line == edge.Line {
+					expr = n
+				}
+			case *ast.CompositeLit:
+				if edge.Kind == EdgeStepRef && f.fset.Position(n.Pos()).Line == edge.Line {
+					expr = configValue(n)
+				}
+			}
+			if expr == nil {
+				return len(sels) == 0
+			}
+			ast.Inspect(expr, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == method {
+						sels = append(sels, sel)
+					}
+				}
+				return true
+			})
+			return len(sels) == 0
+		})
+		if len(sels) > 0 {
+			return fn, sels
+		}
+	}
+	return nil, nil
+}
+
+// configValue returns the value of a TestStep literal's Config field, or nil
+func configValue(lit *ast.CompositeLit) ast.Expr {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Config" {
+				return kv.Value
+			}
+		}
+	}
+	return nil
+}
+
+// CodemodSummary is the machine-readable summary of a codemod run that
+// -summary writes
+type CodemodSummary struct {
+	Applied bool         `json:"applied"` // The files were rewritten (-write)
+	Files   []FileChange `json:"files"`
+	Left    []string     `json:"left"`    // What the codemod left as it is, and why
+	Message string       `json:"message"` // The summary line printed on stderr
+}
+
+// FileChange is one file a codemod changes
+type FileChange struct {
+	Path    string `json:"path"`   // Repository-relative
+	Status  string `json:"status"` // modified, added, or deleted
+	Added   int    `json:"lines_added"`
+	Removed int    `json:"lines_removed"`
+}
+
+// codemodOutput delivers the changes of a codemod: as a unified diff that
+// git apply accepts, which -dry-run asks for and most codemods print by
+// default, or by rewriting the files with -write. -summary also writes a
+// JSON summary of the changes either way.
+type codemodOutput struct {
+	fs      *flag.FlagSet
+	dryRun  *bool
+	write   *bool
+	path    *string
+	root    string
+	summary CodemodSummary
+}
+
+// newCodemodOutput registers the -dry-run, -write, and -summary flags of a
+// codemod
+func newCodemodOutput(fs *flag.FlagSet) *codemodOutput {
+	return &codemodOutput{
+		fs:     fs,
+		dryRun: fs.Bool("dry-run", false, "Print the changes as a unified diff without touching any files"),
+		write:  fs.Bool("write", false, "Rewrite the files in place instead of printing a diff"),
+		path:   fs.String("summary", "", "Also write a JSON summary of the changes to this file"),
+	}
+}
+
+// start checks the parsed flags and sets the repository root the summary's
+// provenance records
+func (o *codemodOutput) start(root string) error {
+	if *o.dryRun && *o.write {
+		return fmt.Errorf("-dry-run and -write cannot be used together")
+	}
+	o.root = root
+	o.summary.Applied = *o.write
+	o.summary.Files = []FileChange{}
+	o.summary.Left = []string{}
+	return nil
+}
+
+// requested reports whether -dry-run or -write asked for the changes, for
+// codemods that report something else by default
+func (o *codemodOutput) requested() bool {
+	return *o.dryRun || *o.write
+}
+
+// writing reports whether the files are rewritten
+func (o *codemodOutput) writing() bool {
+	return *o.write
+}
+
+// apply changes a file from before to after: nil before adds the file and
+// nil after deletes it. New files get the given permissions.
+func (o *codemodOutput) apply(path, rel string, before, after []byte, perm os.FileMode) error {
+	change := FileChange{Path: rel, Status: "modified"}
+	oldName, newName := "a/"+rel, "b/"+rel
+	switch {
+	case before == nil:
+		change.Status, oldName = "added", "/dev/null"
+	case after == nil:
+		change.Status, newName = "deleted", "/dev/null"
+	}
+	diff := unifiedDiff(oldName, newName, before, after)
+	if diff == "" {
+		return nil
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			change.Added++
+		case strings.HasPrefix(line, "-"):
+			change.Removed++
+		}
+	}
+	o.summary.Files = append(o.summary.Files, change)
+
+	if !*o.write {
+		fmt.Print(diff)
+		return nil
+	}
+	switch change.Status {
+	case "added":
+		return os.WriteFile(path, after, perm)
+	case "deleted":
+		return os.Remove(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, after, info.Mode().Perm())
+}
+
+// left reports on stderr, and records in the summary, what the codemod
+// leaves as it is
+func (o *codemodOutput) left(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, message)
+	o.summary.Left = append(o.summary.Left, message)
+}
+
+// finish prints the codemod's summary line on stderr and writes the JSON
+// summary when -summary is given, returning the command exit code
+func (o *codemodOutput) finish(format string, args ...interface{}) int {
+	o.summary.Message = fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, o.summary.Message)
+	if *o.path == "" {
+		return 0
+	}
+	f, err := os.Create(*o.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	if err := writeDocument(f, newProvenance(o.fs, o.root), o.summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the configuration file looked up from the working
+// directory up to the repository root
+const configFileName = ".terracorder.yaml"
+
+// configEnvPrefix prefixes the environment variables that set flags
+const configEnvPrefix = "TERRACORDER_"
+
+// configPathEnv names a configuration file to use instead of the lookup
+const configPathEnv = configEnvPrefix + "CONFIG"
+
+// configFile is a parsed configuration file: flag values keyed by their
+// dotted path, such as "cache" or "report.hotspots.top". Top-level keys apply
+// to every command with the flag, sections to one command and its
+// subcommands.
+type configFile struct {
+	Path   string
+	Values map[string][]string
+}
+
+// configSource is where the effective value of a flag comes from
+type configSource struct {
+	Values []string
+	Origin string // Environment variable or "<file>: <key>"
+}
+
+// loadedConfig is the configuration file of this run, read on first use
+var loadedConfig *configFile
+
+// activeConfig returns the configuration file of this run: the one named by
+// TERRACORDER_CONFIG, or the nearest .terracorder.yaml from the working
+// directory up to the repository root (the first directory with a .git).
+// No file is an empty configuration.
+func activeConfig() (*configFile, error) {
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+	path := os.Getenv(configPathEnv)
+	if path == "" {
+		path = findConfigFile()
+	}
+	config := &configFile{Values: map[string][]string{}}
+	if path != "" {
+		var err error
+		if config, err = parseConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	loadedConfig = config
+	return config, nil
+}
+
+// findConfigFile returns the nearest configuration file, or ""
+func findConfigFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// parseConfigFile reads the YAML subset a configuration needs: nested
+// "key: value" mappings, with lists written as "key: [a, b]" or as "- item"
+// lines under the key. Comments and quoted scalars are supported; anchors,
+// multi-line strings, and flow mappings are not.
+func parseConfigFile(path string) (*configFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := &configFile{Path: path, Values: map[string][]string{}}
+	type section struct {
+		indent int
+		key    string
+	}
+	var stack []section
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := stripConfigComment(scanner.Text())
+		content := strings.TrimSpace(line)
+		if content == "" || content == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line[indent:], "\t") {
+			return nil, fmt.Errorf("%s:%d: indent with spaces, not tabs", path, number)
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		if item, ok := strings.CutPrefix(content, "- "); ok || content == "-" {
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%s:%d: list item outside of a key", path, number)
+			}
+			key := stack[len(stack)-1].key
+			config.Values[key] = append(config.Values[key], unquoteConfig(strings.TrimSpace(item)))
+			continue
+		}
+
+		name, value, ok := strings.Cut(content, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, number)
+		}
+		key := name
+		if len(stack) > 0 {
+			key = stack[len(stack)-1].key + "." + name
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			stack = append(stack, section{indent: indent, key: key})
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			config.Values[key] = []string{}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					config.Values[key] = append(config.Values[key], unquoteConfig(item))
+				}
+			}
+		default:
+			config.Values[key] = []string{unquoteConfig(value)}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// stripConfigComment removes a "#" comment that is not inside quotes
+func stripConfigComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteConfig removes the quotes around a scalar
+func unquoteConfig(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// configScopes returns the scopes a command's flags are looked up in, most
+// specific first: "report hotspots" is looked up in report.hotspots, report,
+// and the top level
+func configScopes(command string) []string {
+	parts := strings.Fields(command)
+	scopes := make([]string, 0, len(parts)+1)
+	for i := len(parts); i > 0; i-- {
+		scopes = append(scopes, strings.Join(parts[:i], "."))
+	}
+	return append(scopes, "")
+}
+
+// configEnvName returns the environment variable setting a flag in a scope
+// (e.g., TERRACORDER_REPORT_HOTSPOTS_TOP)
+func configEnvName(scope, name string) string {
+	key := name
+	if scope != "" {
+		key = scope + "." + name
+	}
+	return configEnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// lookup returns the configured value of a flag of a command: from the
+// environment, most specific variable first, then from the file, most
+// specific section first. ok is false when neither configures it.
+func (c *configFile) lookup(command, name string) (configSource, bool) {
+	scopes := configScopes(command)
+	for _, scope := range scopes {
+		env := configEnvName(scope, name)
+		if value, ok := os.LookupEnv(env); ok {
+			return configSource{Values: []string{value}, Origin: env}, true
+		}
+	}
+	for _, scope := range scopes {
+		key := name
+		if scope != "" {
+			key = scope + "." + name
+		}
+		if values, ok := c.Values[key]; ok {
+			return configSource{Values: values, Origin: c.Path + ": " + key}, true
+		}
+	}
+	return configSource{}, false
+}
+
+// configuredFlags records, per flag set parsed by parseFlags, the flags set
+// from the environment or the configuration file, with the origin of each
+var configuredFlags = map[*flag.FlagSet]map[string]string{}
+
+// parseFlags parses a command's arguments, then sets every flag the
+// arguments leave out from the environment or the configuration file.
+// Precedence is flag, then environment variable, then file, then default.
+// List values are set one item at a time, so a list flag takes them as if
+// repeated. The flags set that way are recorded in configuredFlags. Every
+// command gets -o, sending its output to a file, and -strict-stdout.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	output := registerOutputFlags(fs)
+	if err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	config, err := activeConfig()
+	if err != nil {
+		fmt.Fprintf(fs.Output(), "Error: %v\n", err)
+		return err
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var setErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] || setErr != nil {
+			return
+		}
+		source, ok := config.lookup(fs.Name(), f.Name)
+		if !ok {
+			return
+		}
+		for _, value := range source.Values {
+			if err := fs.Set(f.Name, value); err != nil {
+				setErr = fmt.Errorf("invalid value %q for -%s from %s: %v", value, f.Name, source.Origin, err)
+				return
+			}
+		}
+		if configuredFlags[fs] == nil {
+			configuredFlags[fs] = map[string]string{}
+		}
+		configuredFlags[fs][f.Name] = source.Origin
+	})
+	if setErr != nil {
+		fmt.Fprintf(fs.Output(), "Error: %v\n", setErr)
+		return setErr
+	}
+
+	if err := output.start(); err != nil {
+		fmt.Fprintf(fs.Output(), "Error: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// parseArgs parses a command's arguments. The usage the flag package prints
+// goes to stdout when it was asked for with -h, as the usage of replicode
+// help does, and to stderr with the error of an invalid argument.
+func parseArgs(fs *flag.FlagSet, args []string) error {
+	var usage bytes.Buffer
+	output := fs.Output()
+	fs.SetOutput(&usage)
+	err := fs.Parse(args)
+	fs.SetOutput(output)
+	if errors.Is(err, flag.ErrHelp) {
+		output = os.Stdout
+	}
+	_, _ = usage.WriteTo(output)
+	return err
+}
+
+// flagStatus is the exit code of a command whose arguments failed to parse:
+// asking for its usage with -h is not an error
+func flagStatus(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitError
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ConfigSetting is the effective value of one flag
+type ConfigSetting struct {
+	Flag   string   `json:"flag"`
+	Values []string `json:"values"`
+	Source string   `json:"source"` // Environment variable or "<file>: <key>"
+}
+
+// EffectiveConfig is the output of config show
+type EffectiveConfig struct {
+	File     string          `json:"file,omitempty"`    // Configuration file in use
+	Command  string          `json:"command,omitempty"` // Command the settings are resolved for; "" for the top level
+	Settings []ConfigSetting `json:"settings"`
+}
+
+// runConfigCommand dispatches the config subcommands
+func runConfigCommand(args []string) int {
+	if len(args) > 0 && args[0] == "show" {
+		return runConfigShow(args[1:])
+	}
+	if len(args) > 0 && !isHelpFlag(args[0]) {
+		fmt.Fprintf(os.Stderr, "Error: unknown config command %q\n", args[0])
+	}
+	fmt.Fprintln(usageOutput(args), "Usage: replicode config show [-format json|text] [<command> [<subcommand>]]")
+	return usageStatus(args)
+}
+
+// runConfigShow prints the flag values the configuration file and
+// environment give a command, after precedence: flags given on the command
+// line override all of them
+func runConfigShow(args []string) int {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseArgs(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	config, err := activeConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	effective := ResolveConfig(config, strings.Join(fs.Args(), " "))
+
+	if *format == "text" {
+		file := effective.File
+		if file == "" {
+			file = "(none)"
+		}
+		fmt.Printf("Configuration file: %s\n\n", file)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FLAG\tVALUE\tSOURCE")
+		for _, setting := range effective.Settings {
+			fmt.Fprintf(w, "-%s\t%s\t%s\n", setting.Flag, strings.Join(setting.Values, ","), setting.Source)
+		}
+		w.Flush()
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, ""), effective); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ResolveConfig lists the flags the configuration file and environment set
+// for a command (e.g., "report hotspots"), with the value that wins for each.
+// Only the file keys and TERRACORDER_* variables in the command's scopes are
+// candidates, so a key of another command's section is not shown.
+func ResolveConfig(config *configFile, command string) *EffectiveConfig {
+	scopes := configScopes(command)
+	names := map[string]bool{}
+	for key := range config.Values {
+		for _, scope := range scopes {
+			name := key
+			if scope != "" {
+				var ok bool
+				if name, ok = strings.CutPrefix(key, scope+"."); !ok {
+					continue
+				}
+			}
+			if !strings.Contains(name, ".") {
+				names[name] = true
+			}
+		}
+	}
+	for _, entry := range os.Environ() {
+		variable, _, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(variable, configEnvPrefix) || variable == configPathEnv {
+			continue
+		}
+		// A variable belongs to the most specific scope it is named under
+		for _, scope := range scopes {
+			if rest, ok := strings.CutPrefix(variable, configEnvName(scope, "")); ok && rest != "" {
+				names[strings.ToLower(strings.ReplaceAll(rest, "_", "-"))] = true
+				break
+			}
+		}
+	}
+
+	effective := &EffectiveConfig{File: config.Path, Command: command, Settings: []ConfigSetting{}}
+	for name := range names {
+		if source, ok := config.lookup(command, name); ok {
+			effective.Settings = append(effective.Settings, ConfigSetting{Flag: name, Values: source.Values, Source: source.Origin})
+		}
+	}
+	sort.Slice(effective.Settings, func(i, j int) bool {
+		return effective.Settings[i].Flag < effective.Settings[j].Flag
+	})
+	return effective
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// coverageFileName is the table written alongside the exported analysis database CSVs
+const coverageFileName = "TestCoverage.csv"
+
+// coverageHeader is the column layout of TestCoverage.csv
+var coverageHeader = []string{"TestName", "File", "StartLine", "EndLine"}
+
+// CoveredRange is a span of source lines executed by a test
+type CoveredRange struct {
+	File      string // Path as written in the profile (usually module import path + file)
+	StartLine int
+	EndLine   int
+}
+
+// CoverageModel maps test function names to the source ranges they executed
+type CoverageModel struct {
+	Tests map[string][]CoveredRange
+}
+
+// NewCoverageModel creates an empty coverage model
+func NewCoverageModel() *CoverageModel {
+	return &CoverageModel{Tests: make(map[string][]CoveredRange)}
+}
+
+// IngestProfile reads a Go coverage profile (go test -coverprofile) recorded
+// for a single test and adds its executed blocks to the model. Blocks with a
+// zero count are ignored. Existing ranges for the test are replaced.
+func (m *CoverageModel) IngestProfile(path, testName string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	seen := map[CoveredRange]bool{}
+	var ranges []CoveredRange
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// Format: name.go:line.column,line.column numberOfStatements count
+		fields := strings.Fields(line)
+		colon := strings.LastIndex(fields[0], ":")
+		if len(fields) != 3 || colon < 0 {
+			return 0, fmt.Errorf("%s:%d: malformed coverage block %q", path, lineNum, line)
+		}
+		if count, err := strconv.Atoi(fields[2]); err != nil || count == 0 {
+			continue
+		}
+
+		start, end, ok := strings.Cut(fields[0][colon+1:], ",")
+		if !ok {
+			return 0, fmt.Errorf("%s:%d: malformed coverage block %q", path, lineNum, line)
+		}
+		startLine, err1 := strconv.Atoi(strings.SplitN(start, ".", 2)[0])
+		endLine, err2 := strconv.Atoi(strings.SplitN(end, ".", 2)[0])
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("%s:%d: malformed coverage block %q", path, lineNum, line)
+		}
+
+		covered := CoveredRange{File: fields[0][:colon], StartLine: startLine, EndLine: endLine}
+		if !seen[covered] {
+			seen[covered] = true
+			ranges = append(ranges, covered)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	m.Tests[testName] = ranges
+	return len(ranges), nil
+}
+
+// CodeChange is a changed Go file, optionally limited to a line range
+type CodeChange struct {
+	File      string // Repository-relative path
+	StartLine int    // 0 when the whole file is considered changed
+	EndLine   int
+}
+
+// ParseCodeChange parses "path" or "path:start-end" (or "path:line")
+func ParseCodeChange(value string) (CodeChange, error) {
+	change := CodeChange{File: value}
+	colon := strings.LastIndex(value, ":")
+	if colon < 0 || colon == len(value)-1 || value[colon+1] < '0' || value[colon+1] > '9' {
+		return change, nil // No line range (or a Windows drive letter)
+	}
+
+	change.File = value[:colon]
+	lines := value[colon+1:]
+	start, end, isRange := strings.Cut(lines, "-")
+	if !isRange {
+		end = start
+	}
+	var err1, err2 error
+	change.StartLine, err1 = strconv.Atoi(start)
+	change.EndLine, err2 = strconv.Atoi(end)
+	if err1 != nil || err2 != nil || change.StartLine < 1 || change.EndLine < change.StartLine {
+		return change, fmt.Errorf("invalid change %q (expected path or path:start-end)", value)
+	}
+	return change, nil
+}
+
+// Matches reports whether a covered range executed any of the changed lines.
+// Profile paths are import paths, so the changed file matches by path suffix.
+func (c CodeChange) Matches(covered CoveredRange) bool {
+	file := filepath.ToSlash(c.File)
+	if covered.File != file && !strings.HasSuffix(covered.File, "/"+file) {
+		return false
+	}
+	if c.StartLine == 0 {
+		return true
+	}
+	return covered.StartLine <= c.EndLine && covered.EndLine >= c.StartLine
+}
+
+// String renders the change in the form accepted by ParseCodeChange
+func (c CodeChange) String() string {
+	switch {
+	case c.StartLine == 0:
+		return c.File
+	case c.StartLine == c.EndLine:
+		return fmt.Sprintf("%s:%d", c.File, c.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", c.File, c.StartLine, c.EndLine)
+}
+
+// TestsCovering returns, for each test that executed any changed code, the sorted changes it covers
+func (m *CoverageModel) TestsCovering(changes []CodeChange) map[string][]string {
+	matched := map[string][]string{}
+	for test, ranges := range m.Tests {
+		for _, change := range changes {
+			for _, covered := range ranges {
+				if change.Matches(covered) {
+					matched[test] = append(matched[test], change.String())
+					break
+				}
+			}
+		}
+		sort.Strings(matched[test])
+	}
+	return matched
+}
+
+// LoadCoverageModel reads a TestCoverage.csv file
+func LoadCoverageModel(path string) (*CoverageModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	model := NewCoverageModel()
+	for i, row := range rows {
+		if i == 0 {
+			continue // Header
+		}
+		if len(row) != len(coverageHeader) {
+			return nil, fmt.Errorf("%s: row %d has %d columns, expected %d", path, i+1, len(row), len(coverageHeader))
+		}
+		covered := CoveredRange{File: row[1]}
+		covered.StartLine, _ = strconv.Atoi(row[2])
+		covered.EndLine, _ = strconv.Atoi(row[3])
+		model.Tests[row[0]] = append(model.Tests[row[0]], covered)
+	}
+
+	return model, nil
+}
+
+// Save writes the model as TestCoverage.csv
+func (m *CoverageModel) Save(path string) error {
+	names := make([]string, 0, len(m.Tests))
+	for name := range m.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write(coverageHeader)
+	for _, name := range names {
+		ranges := m.Tests[name]
+		sort.Slice(ranges, func(i, j int) bool {
+			if ranges[i].File != ranges[j].File {
+				return ranges[i].File < ranges[j].File
+			}
+			return ranges[i].StartLine < ranges[j].StartLine
+		})
+		for _, covered := range ranges {
+			writer.Write([]string{name, covered.File, strconv.Itoa(covered.StartLine), strconv.Itoa(covered.EndLine)})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// resolveCoveragePath accepts either a TestCoverage.csv path or the database
+// directory that contains it
+func resolveCoveragePath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, coverageFileName)
+	}
+	return path
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CoverageIngestSummary reports what a coverage ingest run recorded
+type CoverageIngestSummary struct {
+	Database string            `json:"database"` // Path of the TestCoverage.csv written
+	Profiles map[string]string `json:"profiles"` // Test name -> profile file
+	Blocks   int               `json:"blocks"`   // Executed blocks recorded from the profiles
+	Tests    int               `json:"tests"`    // Distinct tests in the model after ingestion
+}
+
+// runCoverageCommand dispatches the coverage subcommands
+func runCoverageCommand(args []string) int {
+	if len(args) > 0 && args[0] == "ingest" {
+		return runCoverageIngest(args[1:])
+	}
+	if len(args) > 0 && !isHelpFlag(args[0]) {
+		fmt.Fprintf(os.Stderr, "Error: unknown coverage command %q\n", args[0])
+	}
+	fmt.Fprintln(usageOutput(args), "Usage: replicode coverage ingest -db <path> [-test <TestName>] <profile>...")
+	return usageStatus(args)
+}
+
+// runCoverageIngest merges per-test Go coverage profiles into the
+// TestCoverage.csv table of an exported analysis database. Each profile must
+// come from a run of a single test; the test name is taken from -test or
+// from the profile file name (TestAccFoo_basic.out -> TestAccFoo_basic).
+func runCoverageIngest(args []string) int {
+	fs := flag.NewFlagSet("coverage ingest", flag.ContinueOnError)
+	db := fs.String("db", "", "Database directory (or TestCoverage.csv path) to update")
+	testName := fs.String("test", "", "Test the profile was recorded for (default: profile file name)")
+	reset := fs.Bool("reset", false, "Discard existing coverage data instead of merging into it")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if *db == "" {
+		fmt.Fprintln(os.Stderr, "Error: -db parameter is required")
+		return 1
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one coverage profile is required")
+		return 1
+	}
+	if *testName != "" && fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "Error: -test can only be used with a single profile")
+		return 1
+	}
+
+	path := resolveCoveragePath(*db)
+	model := NewCoverageModel()
+	if !*reset {
+		existing, err := LoadCoverageModel(path)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if existing != nil {
+			model = existing
+		}
+	}
+
+	summary := &CoverageIngestSummary{Database: path, Profiles: map[string]string{}}
+	for _, profile := range fs.Args() {
+		name := *testName
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(profile), filepath.Ext(profile))
+		}
+		blocks, err := model.IngestProfile(profile, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		summary.Profiles[name] = profile
+		summary.Blocks += blocks
+	}
+	summary.Tests = len(model.Tests)
+
+	if err := model.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, ""), summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// fileStamp is what the daemon compares to tell that a file changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// scanTestFiles stamps every Go test file under dir, as AnalyzeDir selects them
+// with the walk options of opts
+func scanTestFiles(dir string, opts analyzer.Options) (map[string]fileStamp, error) {
+	// A file reachable through a link is listed once, so no file is analyzed
+	// or rewritten twice
+	opts.Symlinks = func(analyzer.Symlink) {}
+	paths, err := analyzer.TestFiles(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Deleted since the directory was read
+		}
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// diffStamps returns the files added or modified and the files removed
+// between two scans, sorted
+func diffStamps(before, after map[string]fileStamp) (changed, removed []string) {
+	for path, stamp := range after {
+		if previous, ok := before[path]; !ok || previous != stamp {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// refresh re-analyzes the changed files, drops the removed ones, and rebuilds
+// the graph. A changed file that fails to analyze is dropped from the index,
+// as a directory load would skip it.
+func (x *analysisIndex) refresh(changed, removed []string) {
+	root := x.source.root()
+	drop := map[string]bool{}
+	var updated []*analyzer.Result
+
+	start := time.Now()
+	for _, path := range removed {
+		if rel, err := analyzer.RelativePath(root, path); err == nil {
+			drop[rel] = true
+		}
+	}
+	for _, path := range changed {
+		if rel, err := analyzer.RelativePath(root, path); err == nil {
+			drop[rel] = true
+		}
+		opts := x.opts
+		if opts.Prefilter || opts.SkipGenerated {
+			// As in a directory load, a file the prefilters reject is left out
+			src, err := os.ReadFile(path)
+			if err == nil && ((opts.Prefilter && !analyzer.Relevant(src)) || (opts.SkipGenerated && analyzer.Generated(src))) {
+				continue
+			}
+			opts.Source = src
+		}
+		result, err := analyzer.Analyze(path, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		updated = append(updated, result)
+	}
+	x.metrics.observeAnalysis(len(changed), time.Since(start))
+	x.source.exportTrace(start, len(changed))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	results := make([]*analyzer.Result, 0, len(x.results)+len(updated))
+	for _, existing := range x.results {
+		if !drop[existing.FilePath] {
+			results = append(results, existing)
+		}
+	}
+	x.results = append(results, updated...)
+	sort.Slice(x.results, func(i, j int) bool { return x.results[i].FilePath < x.results[j].FilePath })
+	x.graph = BuildDependencyGraph(x.results)
+	x.loaded = time.Now().UTC()
+}
+
+// watch polls the source directory and refreshes the index with the files
+// that changed. Polling keeps replicode free of platform file-event APIs; a
+// scan only stats files, so it is cheap next to the analysis it avoids.
+func (x *analysisIndex) watch(stamps map[string]fileStamp, interval time.Duration) {
+	for range time.Tick(interval) {
+		current, err := scanTestFiles(x.source.Dir, x.opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		changed, removed := diffStamps(stamps, current)
+		stamps = current
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+		x.refresh(changed, removed)
+		fmt.Fprintf(os.Stderr, "Re-analyzed %d changed files, removed %d\n", len(changed), len(removed))
+	}
+}
+
+// runDaemonCommand keeps a watched, incrementally updated index and answers
+// the serve queries over a unix socket
+func runDaemonCommand(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	index := &analysisIndex{}
+	index.metrics = newServerMetrics(index.gauges)
+	index.source.register(fs)
+	socket := fs.String("socket", filepath.Join(os.TempDir(), "replicode.sock"), "Unix socket to listen on")
+	interval := fs.Duration("poll", 2*time.Second, "How often to check the directory for changed files")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if index.source.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -poll must be positive")
+		return 1
+	}
+
+	if conn, err := net.Dial("unix", *socket); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "Error: a daemon is already listening on %s\n", *socket)
+		return 1
+	}
+
+	opts, err := index.source.analyzeOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	index.opts = opts
+
+	// Stamp before loading, so that an edit made during the load is picked up
+	stamps, err := scanTestFiles(index.source.Dir, index.opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := index.load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	os.Remove(*socket) // Left by a daemon that did not shut down cleanly
+	listener, err := net.Listen("unix", *socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close() // Removes the socket file
+	}()
+
+	go index.watch(stamps, *interval)
+	fmt.Fprintf(os.Stderr, "Indexed %d files from %s; watching every %s, listening on %s\n", len(index.results), index.source.Dir, *interval, *socket)
+
+	server := &http.Server{Handler: newServeMux(index, fs, false), ReadHeaderTimeout: 10 * time.Second}
+	if err := server.Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// DuplicateGroup is a set of templates returning the same HCL: the one kept
+// and the copies whose callers are pointed at it
+type DuplicateGroup struct {
+	Hash   string              `json:"hash"` // SHA-256 of the normalized HCL
+	Keep   DuplicateTemplate   `json:"keep"`
+	Remove []DuplicateTemplate `json:"remove"`
+}
+
+// DuplicateTemplate is one template of a duplicate group
+type DuplicateTemplate struct {
+	Template   string `json:"template"` // StructName.method
+	Service    string `json:"service"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	References int    `json:"references"`        // Step references and template calls
+	Skipped    string `json:"skipped,omitempty"` // Why a copy is kept after all
+	node       *GraphNode
+	edges      []*GraphEdge
+}
+
+// normalizeHCL collapses the whitespace of a template's HCL outside quoted
+// strings and drops blank lines, so copies differing only in indentation
+// or alignment compare equal
+func normalizeHCL(hcl string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(hcl, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		quoted, space := false, false
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if !quoted && (c == ' ' || c == '\t') {
+				space = true
+				continue
+			}
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteByte(c)
+			switch {
+			case c == '\\' && quoted && i+1 < len(line):
+				i++
+				b.WriteByte(line[i])
+			case c == '"':
+				quoted = !quoted
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// templateHashes returns the hash of each template's normalized HCL and
+// fmt.Sprintf arguments. A template call argument counts by the hash of the
+// template it calls, so copies calling their own copies of a template hash
+// alike.
+func templateHashes(graph *DependencyGraph) map[string]string {
+	hashes := make(map[string]string, len(graph.templateSources))
+	var hash func(id string) string
+	hash = func(id string) string {
+		if h, done := hashes[id]; done {
+			if h == "" {
+				return id // A template calling itself
+			}
+			return h
+		}
+		source, ok := graph.templateSources[id]
+		if !ok {
+			return id
+		}
+		hashes[id] = ""
+		sum := sha256.New()
+		fmt.Fprintf(sum, "%t\x00%s", source.Sprintf, normalizeHCL(source.Format))
+		for _, arg := range source.Args {
+			if arg.Target != "" {
+				fmt.Fprintf(sum, "\x00template %s", hash(arg.Target))
+			} else {
+				fmt.Fprintf(sum, "\x00%s", arg.Expr)
+			}
+		}
+		hashes[id] = hex.EncodeToString(sum.Sum(nil))
+		return hashes[id]
+	}
+	for id := range graph.templateSources {
+		hash(id)
+	}
+	return hashes
+}
+
+// findDuplicateTemplates groups templates with equal hashes. Each group keeps
+// the template named in keep, or else the one with the most references (then
+// the first by name); the groups are ordered by the template kept.
+func findDuplicateTemplates(graph *DependencyGraph, keep map[string]bool) []DuplicateGroup {
+	byHash := map[string][]DuplicateTemplate{}
+	for id, h := range templateHashes(graph) {
+		node := graph.Nodes[id]
+		if node == nil || strings.TrimSpace(graph.templateSources[id].Format) == "" {
+			continue
+		}
+		t := DuplicateTemplate{Template: node.Name, Service: node.Service, File: node.File, Line: node.Line, node: node}
+		for _, edge := range graph.InEdges(id) {
+			if edge.Kind == EdgeStepRef || edge.Kind == EdgeTemplateCall {
+				t.edges = append(t.edges, edge)
+			}
+		}
+		t.References = len(t.edges)
+		byHash[h] = append(byHash[h], t)
+	}
+
+	var groups []DuplicateGroup
+	for h, templates := range byHash {
+		if len(templates) < 2 {
+			continue
+		}
+		sort.Slice(templates, func(i, j int) bool {
+			a, b := templates[i], templates[j]
+			if keep[a.Template] != keep[b.Template] {
+				return keep[a.Template]
+			}
+			if a.References != b.References {
+				return a.References > b.References
+			}
+			return a.Template < b.Template
+		})
+		groups = append(groups, DuplicateGroup{Hash: h, Keep: templates[0], Remove: templates[1:]})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Keep.Template < groups[j].Keep.Template })
+	return groups
+}
+
+// templateDedupe removes the copies of duplicate groups and points their
+// recorded references at the template each group keeps
+type templateDedupe struct {
+	files      *sourceFiles
+	removed    map[string][]ast.Decl // File -> declarations removed from it
+	handled    map[*ast.SelectorExpr]bool
+	references int
+	missing    []string // Recorded references not found in the source
+}
+
+// signature returns the source of a method's parameters and results
+func signature(f *sourceFile, fn *ast.FuncDecl) string {
+	return string(f.src[f.offset(fn.Type.Params.Pos()):f.offset(fn.Type.End())])
+}
+
+// remove marks a copy's declaration for removal, unless its signature differs
+// from the kept template's, and returns why it was skipped
+func (d *templateDedupe) remove(keep, dup *DuplicateTemplate) (string, error) {
+	keepFile, err := d.files.open(keep.File)
+	if err != nil {
+		return "", err
+	}
+	f, err := d.files.open(dup.File)
+	if err != nil {
+		return "", err
+	}
+	keepStruct, keepMethod, _ := strings.Cut(keep.Template, ".")
+	structName, method, _ := strings.Cut(dup.Template, ".")
+	keepDecl, decl := findFunc(keepFile.file, keepMethod, keepStruct), findFunc(f.file, method, structName)
+	if keepDecl == nil || decl == nil {
+		return "", fmt.Errorf("declaration of %s or %s not found", keep.Template, dup.Template)
+	}
+	if signature(keepFile, keepDecl) != signature(f, decl) {
+		return fmt.Sprintf("signature differs from %s", keep.Template), nil
+	}
+	f.edit(lineEdit(f.src, f.offset(declStart(decl)), f.offset(decl.End())))
+	d.removed[f.rel] = append(d.removed[f.rel], decl)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			d.handled[sel] = true // Calls within the copy go with it
+		}
+		return true
+	})
+	return "", nil
+}
+
+// retarget points the calls an edge records at the kept template: through
+// the enclosing method's receiver when it is a method of the kept template's
+// struct in its package, or a StructName{} literal otherwise. Edges from
+// removed copies are left alone.
+func (d *templateDedupe) retarget(keep *DuplicateTemplate, method string, edge *GraphEdge, removedNodes map[string]bool) error {
+	if removedNodes[edge.From] {
+		return nil
+	}
+	f, err := d.files.open(edge.File)
+	if err != nil {
+		return err
+	}
+	fn, sels := f.recordedCalls(edge, method)
+	if len(sels) == 0 {
+		d.missing = append(d.missing, fmt.Sprintf("%s:%d: %s", edge.File, edge.Line, edge.Detail))
+		return nil
+	}
+	keepStruct, keepMethod, _ := strings.Cut(keep.Template, ".")
+	receiver := keepStruct + "{}"
+	if receiverTypeName(fn) == keepStruct && path.Dir(f.rel) == path.Dir(keep.File) {
+		if names := fn.Recv.List[0].Names; len(names) == 1 && names[0].Name != "_" {
+			receiver = names[0].Name
+		}
+	}
+	for _, sel := range sels {
+		d.handled[sel] = true
+		f.edit(textEdit{start: f.offset(sel.X.Pos()), end: f.offset(sel.End()), text: receiver + "." + keepMethod})
+	}
+	d.references++
+	return nil
+}
+
+// output returns a file's new content, with imports only removed copies
+// used dropped and formatted when copies were removed from it
+func (d *templateDedupe) output(f *sourceFile) ([]byte, error) {
+	removed, ok := d.removed[f.rel]
+	if !ok {
+		out := f.output()
+		if _, err := parser.ParseFile(token.NewFileSet(), f.path, out, parser.ParseComments); err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", f.rel, err)
+		}
+		return out, nil
+	}
+	var remaining []ast.Decl
+	for _, decl := range f.file.Decls {
+		kept := true
+		for _, r := range removed {
+			kept = kept && decl != r
+		}
+		if kept {
+			remaining = append(remaining, decl)
+		}
+	}
+	for _, e := range pruneImports(f.fset, f.file, f.src, usedImports(remaining)) {
+		f.edit(e)
+	}
+	out, err := format.Source(f.output())
+	if err != nil {
+		return nil, fmt.Errorf("formatting %s: %v", f.rel, err)
+	}
+	return out, nil
+}
+
+// runDedupeTemplatesCommand reports templates returning the same HCL and,
+// with -dry-run or -write, removes the copies and points their callers at the
+// template each group keeps
+func runDedupeTemplatesCommand(args []string) int {
+	fs := flag.NewFlagSet("dedupe-templates", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	var keep stringList
+	fs.Var(&keep, "keep", "Template to keep in its group (e.g., VirtualNetworkResource.template), comma-separated or repeated")
+	outputFormat := fs.String("format", "text", "Report format: text or json")
+	output := newCodemodOutput(fs)
+	force := fs.Bool("force", false, "With -write, deduplicate even when calls the analysis does not record are left behind")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if err := validateFormat(*outputFormat, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := output.start(source.root()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+	keepSet := map[string]bool{}
+	for _, template := range keep {
+		node, err := graph.ResolveNode(template)
+		if err != nil || node.Kind != NodeTemplate {
+			fmt.Fprintf(os.Stderr, "Error: template %q not found\n", template)
+			return 1
+		}
+		keepSet[node.Name] = true
+	}
+	groups := findDuplicateTemplates(graph, keepSet)
+
+	dedupe := &templateDedupe{files: newSourceFiles(source.root()), removed: map[string][]ast.Decl{}, handled: map[*ast.SelectorExpr]bool{}}
+	removedNodes := map[string]bool{}
+	for g := range groups {
+		group := &groups[g]
+		for i := range group.Remove {
+			reason, err := dedupe.remove(&group.Keep, &group.Remove[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			group.Remove[i].Skipped = reason
+			if reason == "" {
+				removedNodes[group.Remove[i].node.ID] = true
+			}
+		}
+	}
+	removedCount := 0
+	for _, group := range groups {
+		for _, dup := range group.Remove {
+			if dup.Skipped != "" {
+				continue
+			}
+			removedCount++
+			_, method, _ := strings.Cut(dup.Template, ".")
+			for _, edge := range dup.edges {
+				if err := dedupe.retarget(&group.Keep, method, edge, removedNodes); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					return 1
+				}
+			}
+		}
+	}
+
+	if !output.requested() {
+		if *outputFormat == "json" {
+			if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), groups); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			return 0
+		}
+		fmt.Printf("%d duplicate groups: %d copies to remove, %d references to update\n\n", len(groups), removedCount, dedupe.references)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HASH\tACTION\tTEMPLATE\tSERVICE\tREFERENCES\tLOCATION")
+		for _, group := range groups {
+			fmt.Fprintf(w, "%s\tkeep\t%s\t%s\t%d\t%s:%d\n", group.Hash[:12], group.Keep.Template, group.Keep.Service, group.Keep.References, group.Keep.File, group.Keep.Line)
+			for _, dup := range group.Remove {
+				action := "remove"
+				if dup.Skipped != "" {
+					action = "skip (" + dup.Skipped + ")"
+				}
+				fmt.Fprintf(w, "\t%s\t%s\t%s\t%d\t%s:%d\n", action, dup.Template, dup.Service, dup.References, dup.File, dup.Line)
+			}
+		}
+		w.Flush()
+		return 0
+	}
+
+	stamps, err := scanTestFiles(source.Dir, source.walkOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	paths := make([]string, 0, len(stamps))
+	for p := range stamps {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var unrecorded []string
+	for _, group := range groups {
+		for _, dup := range group.Remove {
+			if dup.Skipped != "" {
+				continue
+			}
+			structName, method, _ := strings.Cut(dup.Template, ".")
+			calls, err := unrecordedCalls(dedupe.files, paths, structName, method, dup.File, dedupe.handled)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			unrecorded = append(unrecorded, calls...)
+		}
+	}
+
+	for _, group := range groups {
+		for _, dup := range group.Remove {
+			if dup.Skipped != "" {
+				output.left("Left %s:%d (%s): %s", dup.File, dup.Line, dup.Template, dup.Skipped)
+			}
+		}
+	}
+	for _, reference := range dedupe.missing {
+		fmt.Fprintf(os.Stderr, "Warning: recorded reference not found in the source: %s\n", reference)
+	}
+	for _, reference := range unrecorded {
+		output.left("Left %s (not recorded by the analysis)", reference)
+	}
+	if output.writing() && len(unrecorded) > 0 && !*force {
+		fmt.Fprintf(os.Stderr, "Error: deduplicating would leave %d calls of removed templates behind; update them by hand or pass -force\n", len(unrecorded))
+		return 1
+	}
+
+	edited := dedupe.files.edited()
+	for _, f := range edited {
+		out, err := dedupe.output(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := output.apply(f.path, f.rel, f.src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Removed"
+	if !output.writing() {
+		verb = "Would remove"
+	}
+	return output.finish("%s %d copies in %d duplicate groups with %d references in %d files; left %d unrecorded calls",
+		verb, removedCount, len(groups), dedupe.references, len(edited), len(unrecorded))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// DeprecatedList maps deprecated or removed resource types to the reason or
+// replacement given for them (may be empty)
+type DeprecatedList map[string]string
+
+// LoadDeprecatedList reads a deprecated-resource list from a file path or an
+// http(s) URL, in the flaky list format:
+//
+//	azurerm_sql_server  # use azurerm_mssql_server
+func LoadDeprecatedList(location string) (DeprecatedList, error) {
+	list, err := readNameList(location, "deprecated resource list")
+	if err != nil {
+		return nil, err
+	}
+	return DeprecatedList(list), nil
+}
+
+// DeprecatedTemplateUse is a template that uses a deprecated resource, either
+// declaring or referencing it itself or by embedding a template that does
+type DeprecatedTemplateUse struct {
+	Template string `json:"template"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Depth    int    `json:"depth"` // 1 when the template references the resource itself, more through embedded templates
+}
+
+// DeprecatedTestUse is a runnable test that still exercises a deprecated resource
+type DeprecatedTestUse struct {
+	Test    string `json:"test"`
+	Service string `json:"service"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// DeprecatedUsage is every template and test still using one deprecated resource
+type DeprecatedUsage struct {
+	Resource  string                  `json:"resource"`
+	Reason    string                  `json:"reason,omitempty"`
+	Templates []DeprecatedTemplateUse `json:"templates"`
+	Tests     []DeprecatedTestUse     `json:"tests"`
+}
+
+// DeprecationReport is the output of the deprecated report
+type DeprecationReport struct {
+	Checked   int               `json:"checked"`   // Deprecated resource types looked up
+	Resources []DeprecatedUsage `json:"resources"` // Those still in use, sorted by name
+}
+
+// FindDeprecatedUsage reports the templates and tests still using each
+// deprecated resource, following template embedding and sequential sub-tests
+// back from the resource. Unused resources are left out.
+func FindDeprecatedUsage(graph *DependencyGraph, deprecated DeprecatedList) *DeprecationReport {
+	report := &DeprecationReport{Checked: len(deprecated), Resources: []DeprecatedUsage{}}
+
+	for resource, reason := range deprecated {
+		id := resourceNodeID(resource)
+		if graph.Nodes[id] == nil {
+			continue
+		}
+
+		usage := DeprecatedUsage{Resource: resource, Reason: reason, Templates: []DeprecatedTemplateUse{}, Tests: []DeprecatedTestUse{}}
+		for reachedID, depth := range graph.Reachable(id, DirectionIn, 0) {
+			node := graph.Nodes[reachedID]
+			switch {
+			case node == nil:
+			case node.Kind == NodeTemplate:
+				usage.Templates = append(usage.Templates, DeprecatedTemplateUse{Template: node.Name, File: node.File, Line: node.Line, Depth: depth})
+			case node.Kind == NodeTest && isRunnableTest(node.Name):
+				usage.Tests = append(usage.Tests, DeprecatedTestUse{Test: node.Name, Service: node.Service, File: node.File, Line: node.Line})
+			}
+		}
+		if len(usage.Templates) == 0 && len(usage.Tests) == 0 {
+			continue
+		}
+
+		sort.Slice(usage.Templates, func(i, j int) bool {
+			a, b := usage.Templates[i], usage.Templates[j]
+			if a.Depth != b.Depth {
+				return a.Depth < b.Depth
+			}
+			return a.Template < b.Template
+		})
+		sort.Slice(usage.Tests, func(i, j int) bool {
+			return usage.Tests[i].Test < usage.Tests[j].Test
+		})
+		report.Resources = append(report.Resources, usage)
+	}
+
+	sort.Slice(report.Resources, func(i, j int) bool {
+		return report.Resources[i].Resource < report.Resources[j].Resource
+	})
+	return report
+}
+
+// deprecationReason shortens a schema description to its first line
+func deprecationReason(description string) string {
+	return strings.TrimSpace(strings.SplitN(description, "\n", 2)[0])
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runDiffCommand compares the dependency graphs of two analysis runs
+func runDiffCommand(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", "json", "Output format: json or text")
+	exitCode := fs.Bool("exit-code", false, "Exit with 2 when the analyses differ, as git diff --exit-code does with 1")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: replicode diff [-format json|text] [-exit-code] <old analysis> <new analysis>")
+		return 1
+	}
+
+	oldName, newName := fs.Arg(0), fs.Arg(1)
+	before, err := loadAnalysisSnapshot(oldName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	after, err := loadAnalysisSnapshot(newName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	diff := DiffAnalyses(oldName, before, newName, after)
+
+	if *format == "text" {
+		fmt.Printf("--- %s\n+++ %s\n", oldName, newName)
+		writeNameChanges("test", diff.AddedTests, diff.RemovedTests)
+		writeNameChanges("template", diff.AddedTemplates, diff.RemovedTemplates)
+		writeEdgeChanges(diff.AddedReferences, diff.RemovedReferences)
+		writeEdgeChanges(diff.AddedEdges, diff.RemovedEdges)
+	} else if err := writeDocument(os.Stdout, newProvenance(fs, ""), diff); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *exitCode && !diff.empty() {
+		return exitDiffers
+	}
+	return 0
+}
+
+// writeNameChanges prints added and removed nodes of one kind
+func writeNameChanges(kind string, added, removed []string) {
+	for _, name := range removed {
+		fmt.Printf("- %s %s\n", kind, name)
+	}
+	for _, name := range added {
+		fmt.Printf("+ %s %s\n", kind, name)
+	}
+}
+
+// writeEdgeChanges prints added and removed edges
+func writeEdgeChanges(added, removed []EdgeChange) {
+	for _, edge := range removed {
+		fmt.Printf("- %s -> %s (%s)\n", edge.From, edge.To, edge.Kind)
+	}
+	for _, edge := range added {
+		fmt.Printf("+ %s -> %s (%s)\n", edge.From, edge.To, edge.Kind)
+	}
+}
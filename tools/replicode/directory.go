@@ -0,0 +1,394 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// DirectoryAnalysisResult is the consolidated output structure for a directory
+// run, which writeDirectoryResult writes member by member
+type DirectoryAnalysisResult struct {
+	Directory string             `json:"directory"`
+	Files     []*analyzer.Result `json:"files"`
+
+	// TestResourceClosure maps every test function to the full set of azurerm
+	// resources it touches through its steps, nested templates, cross-service
+	// template calls, and sequential sub-tests, keyed as the graph qualifies
+	// the names of same-named tests in other packages ("<name>@<package>")
+	TestResourceClosure map[string][]string `json:"test_resource_closure"`
+
+	Diagnostics Diagnostics `json:"diagnostics"`
+}
+
+// Diagnostics lists the problems a directory run worked around: syntax errors
+// in files analyzed from what the parser recovered, files left out because
+// they could not be analyzed at all, the symlinks followed or skipped, and the
+// references left unresolved because several packages declare their target
+type Diagnostics struct {
+	ParseErrors  []analyzer.ParseError `json:"parse_errors"`
+	SkippedFiles []SkippedFile         `json:"skipped_files"`
+	Symlinks     []analyzer.Symlink    `json:"symlinks"`
+	Ambiguities  []UnresolvedReference `json:"ambiguities"`
+}
+
+// SkippedFile is a file a directory run left out, with the reason
+type SkippedFile struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// writeDirectoryResult analyzes a directory and writes its consolidated
+// DirectoryAnalysisResult with the provenance as its first key. Each file's
+// record is written as soon as the file is analyzed rather than once the whole
+// document is built; the cross-file sections follow, as they need every file,
+// from the graph inputs kept of each, which are returned.
+//
+// A non-nil pick is given every file's result before anything is written and
+// returns the resource types whose direct references the records keep, as
+// -resourcename does for one (nil keeps them all); the results are then
+// written from memory rather than analyzed again.
+func writeDirectoryResult(w io.Writer, provenance *Provenance, dir string, opts analyzer.Options, pick func([]*analyzer.Result) (map[string]bool, error)) ([]*analyzer.Result, error) {
+	var results []*analyzer.Result
+	diagnostics := Diagnostics{ParseErrors: []analyzer.ParseError{}, SkippedFiles: []SkippedFile{}, Symlinks: []analyzer.Symlink{}, Ambiguities: []UnresolvedReference{}}
+	opts.Symlinks = func(link analyzer.Symlink) {
+		if rel, err := analyzer.RelativePath(opts.RepoRoot, link.Path); err == nil {
+			link.Path = rel
+		}
+		diagnostics.Symlinks = append(diagnostics.Symlinks, link)
+		if !link.Followed {
+			fmt.Fprintf(os.Stderr, "Warning: skipping symlink %s -> %s: %s\n", link.Path, link.Target, link.Reason)
+		}
+	}
+	skipped := opts.Skipped
+	opts.Skipped = func(path string, err error) {
+		file := filepath.ToSlash(path)
+		if rel, relErr := analyzer.RelativePath(opts.RepoRoot, path); relErr == nil {
+			file = rel
+		}
+		diagnostics.SkippedFiles = append(diagnostics.SkippedFiles, SkippedFile{File: file, Error: err.Error()})
+		if skipped != nil {
+			skipped(path, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+		}
+	}
+	analyze := func(fn func(*analyzer.Result) error) error {
+		return analyzer.AnalyzeDirFunc(dir, opts, fn)
+	}
+	if pick != nil {
+		var analyzed []*analyzer.Result
+		if err := analyze(func(result *analyzer.Result) error {
+			analyzed = append(analyzed, result)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		resources, err := pick(analyzed)
+		if err != nil {
+			return nil, err
+		}
+		analyze = func(fn func(*analyzer.Result) error) error {
+			for _, result := range analyzed {
+				keepDirectReferences(result, resources)
+				if err := fn(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	countDocument(w)
+	stream := newJSONStream(w)
+	stream.open('{')
+	stream.member("provenance")
+	stream.value(provenance)
+	stream.member("directory")
+	stream.value(filepath.ToSlash(dir))
+	stream.member("files")
+	stream.open('[')
+	err := analyze(func(result *analyzer.Result) error {
+		if len(result.ParseErrors) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s has %d parse errors; analyzed what could be parsed\n", result.FilePath, len(result.ParseErrors))
+			diagnostics.ParseErrors = append(diagnostics.ParseErrors, result.ParseErrors...)
+		}
+		results = append(results, graphInputs(result))
+		stream.member("")
+		stream.value(result)
+		return stream.err
+	})
+	if err != nil {
+		return nil, err
+	}
+	stream.close(']')
+
+	graph := BuildDependencyGraph(results)
+	stream.member("test_resource_closure")
+	stream.value(graph.TestResourceClosures())
+	for _, ref := range graph.Unresolved {
+		if len(ref.Candidates) > 0 {
+			diagnostics.Ambiguities = append(diagnostics.Ambiguities, ref)
+		}
+	}
+	stream.member("diagnostics")
+	stream.value(diagnostics)
+	stream.close('}')
+	return results, stream.finish()
+}
+
+// servicePatternUsage describes the -service-pattern flag of every command
+// that analyzes files
+const servicePatternUsage = "Regular expression whose first capturing group is the service of a file path, tried before internal/services/<name>/ (e.g., internal/provider/([^/]+)/); repeatable"
+
+// followSymlinksUsage describes the -follow-symlinks flag of every command
+// that analyzes a directory
+const followSymlinksUsage = "Walk symlinked directories, each real directory once, instead of skipping them"
+
+// excludeDirUsage and includeDirUsage describe the directory exclusion flags
+// of every command
+const (
+	excludeDirUsage = "Name of a directory not to walk into, besides vendor, .git, and third_party; comma-separated or repeated"
+	includeDirUsage = "Name of a directory excluded by default (vendor, .git, or third_party) to walk into anyway; comma-separated or repeated"
+)
+
+// repoUsage describes the -repo flag of the commands taking several repositories
+const repoUsage = "Further repository to analyze with -dir, as <provider>=<directory> (e.g., azuread=../terraform-provider-azuread); its paths and services are prefixed with the provider and its resource types linked across repositories; repeatable"
+
+// repository is one -repo value: the provider name, which prefixes the
+// repository's paths and services and its resource types ("azuread_"), and
+// the directory analyzed, to which its paths are relative
+type repository struct {
+	Name string
+	Dir  string
+}
+
+// repoList is a repeatable flag of <provider>=<directory> repositories
+type repoList []repository
+
+func (l *repoList) String() string {
+	values := make([]string, 0, len(*l))
+	for _, repo := range *l {
+		values = append(values, repo.Name+"="+repo.Dir)
+	}
+	return strings.Join(values, " ")
+}
+
+func (l *repoList) Set(value string) error {
+	name, dir, ok := strings.Cut(value, "=")
+	if !ok || name == "" || dir == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid repository %q (expected <provider>=<directory>)", value)
+	}
+	for _, repo := range *l {
+		if repo.Name == name {
+			return fmt.Errorf("repository %q given twice", name)
+		}
+	}
+	*l = append(*l, repository{Name: name, Dir: dir})
+	return nil
+}
+
+// patternList is a repeatable flag of regular expressions, compiled as they
+// are set, which unlike stringList are not split on commas
+type patternList analyzer.ServicePatterns
+
+func (l *patternList) String() string {
+	return strings.Join(analyzer.ServicePatterns(*l).Strings(), " ")
+}
+
+func (l *patternList) Set(value string) error {
+	patterns, err := analyzer.CompileServicePatterns([]string{value})
+	if err != nil {
+		return err
+	}
+	*l = append(*l, patterns...)
+	return nil
+}
+
+// sourceOptions holds the flags shared by commands that analyze a directory tree
+type sourceOptions struct {
+	Dir          string
+	RepoRoot     string
+	NamespaceMap string
+	Plugins      stringList
+	Services     patternList
+	Trace        string
+	Cache        string
+	Prefilter    bool
+	Symlinks     bool
+	Generated    bool
+	Checks       bool
+	Destroys     bool
+	Calls        bool
+	Exclude      stringList
+	Include      stringList
+	Repos        repoList // Further repositories of a multi-root analysis; only commands that register them
+
+	trace *spanTrace // Set by analyzeOptions
+}
+
+// register adds the source flags to a command's flag set
+func (o *sourceOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.Dir, "dir", "", "Directory to analyze recursively (e.g., internal/services)")
+	fs.StringVar(&o.RepoRoot, "reporoot", "", "Repository root directory (defaults to the nearest directory above -dir or -file with a go.mod or .git, else -dir)")
+	fs.StringVar(&o.NamespaceMap, "namespace-map", "", "JSON object of resource type prefixes to ARM namespaces, layered over the built-in mapping")
+	fs.Var(&o.Plugins, "extractor-plugin", "Go plugin adding custom extractors, comma-separated or repeated")
+	fs.Var(&o.Services, "service-pattern", servicePatternUsage)
+	fs.StringVar(&o.Trace, "trace", "", "Export an OpenTelemetry trace of the analysis to a file (OTLP JSON lines) or an OTLP/HTTP traces URL")
+	fs.StringVar(&o.Cache, "cache", "", "Directory of cached file analyses to reuse across runs for unchanged files")
+	fs.BoolVar(&o.Prefilter, "prefilter", false, "Skip, without parsing, files with no test functions, templates, or test steps")
+	fs.BoolVar(&o.Symlinks, "follow-symlinks", false, followSymlinksUsage)
+	fs.BoolVar(&o.Generated, "skip-generated", false, "Skip, without parsing, files marked \"// Code generated ... DO NOT EDIT.\"")
+	fs.BoolVar(&o.Checks, "check-assertions", false, "Extract the assertions of TestStep Check fields into check_assertions")
+	fs.BoolVar(&o.Destroys, "check-destroy", false, "Extract the resource types TestCase CheckDestroy functions verify into check_destroy_references")
+	fs.BoolVar(&o.Calls, "check-calls", false, "Record the calls inside TestStep Check fields, which the call records leave out, into check_calls")
+	fs.Var(&o.Exclude, "exclude-dir", excludeDirUsage)
+	fs.Var(&o.Include, "include-dir", includeDirUsage)
+}
+
+// registerRepos adds the -repo flag of the commands that only read the
+// analysis, and so can take the files of several repositories at once
+func (o *sourceOptions) registerRepos(fs *flag.FlagSet) {
+	fs.Var(&o.Repos, "repo", repoUsage)
+}
+
+// load analyzes the configured directory, then the directory of each -repo,
+// and returns the per-file results. With -repo, the HCL of every file is
+// scanned for the resource types of every repository's provider, so that an
+// azuread_application block in an azurerm template links to the azuread tests.
+func (o *sourceOptions) load() ([]*analyzer.Result, error) {
+	if o.Dir == "" {
+		return nil, fmt.Errorf("-dir parameter is required")
+	}
+
+	opts, err := o.analyzeOptions()
+	if err != nil {
+		return nil, err
+	}
+	if len(o.Repos) > 0 {
+		opts.ResourcePrefixes = []string{analyzer.DefaultResourcePrefix}
+		for _, repo := range o.Repos {
+			opts.ResourcePrefixes = append(opts.ResourcePrefixes, repo.Name+"_")
+		}
+	}
+	start := time.Now()
+	results, err := analyzer.AnalyzeDir(o.Dir, opts)
+	for _, repo := range o.Repos {
+		if err != nil {
+			break
+		}
+		repoOpts := opts
+		repoOpts.RepoRoot, repoOpts.Repository = repo.Dir, repo.Name
+		var repoResults []*analyzer.Result
+		repoResults, err = analyzer.AnalyzeDir(repo.Dir, repoOpts)
+		results = append(results, repoResults...)
+	}
+	if err == nil {
+		o.exportTrace(start, len(results))
+	}
+	return results, err
+}
+
+// exportTrace exports the spans of a directory analysis started at start
+func (o *sourceOptions) exportTrace(start time.Time, files int) {
+	o.trace.export("analyze "+filepath.ToSlash(o.Dir), start, map[string]interface{}{
+		"replicode.directory": filepath.ToSlash(o.Dir),
+		"replicode.files":     files,
+	})
+}
+
+// walkOptions returns the options selecting the files a load analyzes
+func (o *sourceOptions) walkOptions() analyzer.Options {
+	return analyzer.Options{FollowSymlinks: o.Symlinks, ExcludeDirs: o.Exclude, IncludeDirs: o.Include}
+}
+
+// analyzeOptions returns the per-file analysis options the source flags select
+func (o *sourceOptions) analyzeOptions() (analyzer.Options, error) {
+	o.trace = newSpanTrace(o.Trace)
+	opts := o.trace.options(analyzer.Options{RepoRoot: o.root(), Prefilter: o.Prefilter, FollowSymlinks: o.Symlinks, SkipGenerated: o.Generated,
+		ExcludeDirs: o.Exclude, IncludeDirs: o.Include, CheckAssertions: o.Checks, CheckDestroy: o.Destroys, CheckCalls: o.Calls,
+		ServicePatterns: analyzer.ServicePatterns(o.Services)})
+	if err := loadExtractorPlugins(o.Plugins); err != nil {
+		return opts, err
+	}
+	if o.NamespaceMap != "" {
+		namespaces, err := analyzer.LoadNamespaceMap(o.NamespaceMap)
+		if err != nil {
+			return opts, err
+		}
+		opts.Namespaces = namespaces
+	}
+	if o.Cache != "" {
+		cache, err := openResultCache(o.Cache)
+		if err != nil {
+			return opts, err
+		}
+		opts.Cache = cache
+	}
+	return opts, nil
+}
+
+// openResultCache opens the -cache directory. Entries are salted with a hash
+// of the running executable, so that a rebuilt replicode, whose extractors
+// may have changed, never reads the results of the previous build.
+func openResultCache(dir string) (*analyzer.ResultCache, error) {
+	salt := toolVersion()
+	if executable, err := os.Executable(); err == nil {
+		if data, err := os.ReadFile(executable); err == nil {
+			sum := sha256.Sum256(data)
+			salt = hex.EncodeToString(sum[:])
+		}
+	}
+	return analyzer.NewResultCache(dir, salt)
+}
+
+// root is the directory result paths are relative to
+func (o *sourceOptions) root() string {
+	if o.RepoRoot == "" {
+		return defaultRepoRoot(o.Dir)
+	}
+	return o.RepoRoot
+}
+
+// defaultRepoRoot is the root of a -dir given without -reporoot: the module
+// or repository containing it, or the directory itself outside of one
+func defaultRepoRoot(dir string) string {
+	if root := findRepoRoot(dir); root != "" {
+		return root
+	}
+	return dir
+}
+
+// findRepoRoot returns the nearest directory at or above path (a file or a
+// directory) holding a go.mod or a .git, or "" when there is none. The
+// provider's go.mod is at its repository root, so either marks it; a nested
+// module's go.mod wins over the enclosing repository.
+func findRepoRoot(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		for _, marker := range []string{"go.mod", ".git"} {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
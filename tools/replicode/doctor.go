@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// DoctorExpectations are the bounds doctor holds a provider checkout's
+// analysis to. Fractions are between 0 and 1.
+type DoctorExpectations struct {
+	MinTests           int     `json:"min_tests"`
+	MinTemplates       int     `json:"min_templates"`
+	MaxZeroStepTests   float64 `json:"max_zero_step_tests"`  // Tests with no TestSteps that run no other test
+	MaxEmptyTemplates  float64 `json:"max_empty_templates"`  // Templates whose HCL could not be extracted
+	MaxUnresolvedSteps float64 `json:"max_unresolved_steps"` // TestSteps without a ConfigStruct
+	MaxUnresolvedCalls float64 `json:"max_unresolved_calls"` // Template calls without a TargetService
+	MaxFileErrors      int     `json:"max_file_errors"`      // Files skipped or with parse errors
+}
+
+// defaultDoctorExpectations are the bounds used for anything an -expect file
+// leaves out
+var defaultDoctorExpectations = DoctorExpectations{
+	MinTests:           1,
+	MinTemplates:       1,
+	MaxZeroStepTests:   0.05,
+	MaxEmptyTemplates:  0.10,
+	MaxUnresolvedSteps: 0.05,
+	MaxUnresolvedCalls: 0.05,
+	MaxFileErrors:      0,
+}
+
+// DoctorMetric is one sanity metric and whether it meets its expectation,
+// a lower bound on a count or an upper bound on a count or fraction
+type DoctorMetric struct {
+	Name     string   `json:"name"`
+	Count    int      `json:"count"`
+	Fraction *float64 `json:"fraction,omitempty"` // Of all tests, templates, steps, or calls
+	Min      *int     `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"` // A fraction when Fraction is set, else a count
+	OK       bool     `json:"ok"`
+}
+
+// expected describes the metric's bound
+func (m DoctorMetric) expected() string {
+	switch {
+	case m.Min != nil:
+		return fmt.Sprintf(">= %d", *m.Min)
+	case m.Fraction != nil:
+		return fmt.Sprintf("<= %.1f%%", 100**m.Max)
+	default:
+		return fmt.Sprintf("<= %.0f", *m.Max)
+	}
+}
+
+// DoctorReport is the output of the doctor command
+type DoctorReport struct {
+	Repo      string         `json:"repo"`
+	Files     int            `json:"files"`
+	Tests     int            `json:"tests"`
+	Templates int            `json:"templates"`
+	Metrics   []DoctorMetric `json:"metrics"`
+	Passed    bool           `json:"passed"`
+}
+
+// loadDoctorExpectations reads an expectations file over the defaults
+func loadDoctorExpectations(path string) (DoctorExpectations, error) {
+	expect := defaultDoctorExpectations
+	if path == "" {
+		return expect, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return expect, fmt.Errorf("reading expectations: %v", err)
+	}
+	if err := json.Unmarshal(data, &expect); err != nil {
+		return expect, fmt.Errorf("parsing expectations %s: %v", path, err)
+	}
+	return expect, nil
+}
+
+// diagnoseResults computes the sanity metrics of an analysis against the
+// expectations; fileErrors counts the files skipped or parsed with errors
+func diagnoseResults(results []*analyzer.Result, fileErrors int, expect DoctorExpectations) DoctorReport {
+	report := DoctorReport{Files: len(results)}
+	graph := BuildDependencyGraph(results)
+
+	// Tests that neither have steps nor run other tests
+	tests := map[string]bool{}
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if fn.IsTestFunc {
+				tests[fn.FunctionName] = true
+			}
+		}
+	}
+	active := map[string]bool{}
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			active[step.SourceFunction] = true
+		}
+		for _, seq := range result.SequentialReferences {
+			active[seq.EntryPointFunction] = true
+		}
+		for _, call := range result.Calls {
+			if !call.IsMethodCall && tests[call.MethodName] {
+				active[call.CallerFunction] = true
+			}
+		}
+	}
+	zeroStep := 0
+	for name := range tests {
+		if !active[name] {
+			zeroStep++
+		}
+	}
+	report.Tests = len(tests)
+
+	// Templates whose returned HCL the analysis could not extract
+	empty := 0
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTemplate {
+			continue
+		}
+		report.Templates++
+		if source, ok := graph.templateSources[id]; !ok || source.Format == "" {
+			empty++
+		}
+	}
+
+	completeness := resolutionCompleteness(results)
+	fraction := func(n, of int) float64 {
+		if of == 0 {
+			return 0
+		}
+		return float64(n) / float64(of)
+	}
+	atLeast := func(name string, count, min int) DoctorMetric {
+		return DoctorMetric{Name: name, Count: count, Min: &min, OK: count >= min}
+	}
+	atMost := func(name string, count, of int, max float64) DoctorMetric {
+		value := fraction(count, of)
+		return DoctorMetric{Name: name, Count: count, Fraction: &value, Max: &max, OK: value <= max}
+	}
+	maxFileErrors := float64(expect.MaxFileErrors)
+	report.Metrics = []DoctorMetric{
+		atLeast("tests", report.Tests, expect.MinTests),
+		atLeast("templates", report.Templates, expect.MinTemplates),
+		atMost("zero_step_tests", zeroStep, report.Tests, expect.MaxZeroStepTests),
+		atMost("empty_templates", empty, report.Templates, expect.MaxEmptyTemplates),
+		atMost("unresolved_steps", completeness.UnresolvedSteps, completeness.TestSteps, expect.MaxUnresolvedSteps),
+		atMost("unresolved_calls", completeness.UnresolvedCalls, completeness.TemplateCalls, expect.MaxUnresolvedCalls),
+		{Name: "file_errors", Count: fileErrors, Max: &maxFileErrors, OK: fileErrors <= expect.MaxFileErrors},
+	}
+	report.Passed = true
+	for _, metric := range report.Metrics {
+		report.Passed = report.Passed && metric.OK
+	}
+	return report
+}
+
+// runDoctorCommand analyzes a provider checkout and checks sanity metrics of
+// the analysis against expectations, failing when any regresses
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	repo := fs.String("repo", "", "Provider checkout to analyze (e.g., ../terraform-provider-azurerm)")
+	services := fs.String("services", "internal/services", "Directory of the checkout holding the service packages")
+	expectFile := fs.String("expect", "", "JSON file of expectations overriding the defaults (min_tests, min_templates, max_zero_step_tests, max_empty_templates, max_unresolved_steps, max_unresolved_calls, max_file_errors)")
+	var patterns patternList
+	fs.Var(&patterns, "service-pattern", servicePatternUsage)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *repo == "" {
+		fmt.Fprintln(os.Stderr, "Error: -repo parameter is required")
+		return 1
+	}
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	expect, err := loadDoctorExpectations(*expectFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	fileErrors := 0
+	opts := analyzer.Options{
+		RepoRoot:        *repo,
+		ServicePatterns: analyzer.ServicePatterns(patterns),
+		Skipped: func(path string, err error) {
+			fileErrors++
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+		},
+	}
+	results, err := analyzer.AnalyzeDir(filepath.Join(*repo, filepath.FromSlash(*services)), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, result := range results {
+		if len(result.ParseErrors) > 0 {
+			fileErrors++
+			fmt.Fprintf(os.Stderr, "Warning: %s has %d parse errors\n", result.FilePath, len(result.ParseErrors))
+		}
+	}
+
+	report := diagnoseResults(results, fileErrors, expect)
+	report.Repo = filepath.ToSlash(*repo)
+	if *format == "text" {
+		fmt.Printf("Analyzed %d files: %d tests, %d templates\n\n", report.Files, report.Tests, report.Templates)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "METRIC\tVALUE\tEXPECTED\tSTATUS")
+		for _, metric := range report.Metrics {
+			value := fmt.Sprintf("%d", metric.Count)
+			if metric.Fraction != nil {
+				value = fmt.Sprintf("%d (%.1f%%)", metric.Count, 100**metric.Fraction)
+			}
+			status := "ok"
+			if !metric.OK {
+				status = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", metric.Name, value, metric.expected(), status)
+		}
+		w.Flush()
+	} else if err := writeDocument(os.Stdout, newProvenance(fs, *repo), report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if !report.Passed {
+		fmt.Fprintln(os.Stderr, "Error: the analysis does not meet its expectations")
+		return 1
+	}
+	return 0
+}
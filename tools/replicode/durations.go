@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationsFileName is the table written alongside the exported analysis database CSVs
+const durationsFileName = "TestDurations.csv"
+
+// durationsHeader is the column layout of TestDurations.csv
+var durationsHeader = []string{"TestName", "Runs", "Passes", "Failures", "Skips", "TotalSeconds", "MeanSeconds", "MaxSeconds", "LastSeconds"}
+
+// TestDurationStats is the historical timing record for one test function
+type TestDurationStats struct {
+	TestName     string  `json:"test_name"`
+	Runs         int     `json:"runs"`
+	Passes       int     `json:"passes"`
+	Failures     int     `json:"failures"`
+	Skips        int     `json:"skips"`
+	TotalSeconds float64 `json:"total_seconds"` // Sum over passed and failed runs (skips excluded)
+	MaxSeconds   float64 `json:"max_seconds"`
+	LastSeconds  float64 `json:"last_seconds"`
+}
+
+// MeanSeconds is the average duration of runs that actually executed
+func (s *TestDurationStats) MeanSeconds() float64 {
+	executed := s.Passes + s.Failures
+	if executed == 0 {
+		return 0
+	}
+	return s.TotalSeconds / float64(executed)
+}
+
+// FailureRate is the fraction of executed runs that failed
+func (s *TestDurationStats) FailureRate() float64 {
+	executed := s.Passes + s.Failures
+	if executed == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(executed)
+}
+
+// SkipRate is the fraction of recorded runs that skipped
+func (s *TestDurationStats) SkipRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.Skips) / float64(s.Runs)
+}
+
+// DurationModel holds historical timing data keyed by test function name
+type DurationModel struct {
+	Tests map[string]*TestDurationStats
+}
+
+// NewDurationModel creates an empty duration model
+func NewDurationModel() *DurationModel {
+	return &DurationModel{Tests: make(map[string]*TestDurationStats)}
+}
+
+// testOutcome is a single test result parsed from a results file
+type testOutcome struct {
+	name    string
+	action  string // "pass", "fail", or "skip"
+	seconds float64
+}
+
+// Record adds one test outcome to the model
+func (m *DurationModel) Record(name, action string, seconds float64) {
+	stats := m.Tests[name]
+	if stats == nil {
+		stats = &TestDurationStats{TestName: name}
+		m.Tests[name] = stats
+	}
+
+	stats.Runs++
+	switch action {
+	case "pass":
+		stats.Passes++
+	case "fail":
+		stats.Failures++
+	case "skip":
+		stats.Skips++
+		return // Skipped runs say nothing about duration
+	}
+
+	stats.TotalSeconds += seconds
+	stats.LastSeconds = seconds
+	if seconds > stats.MaxSeconds {
+		stats.MaxSeconds = seconds
+	}
+}
+
+// Estimate returns the historical mean duration of a test, if known
+func (m *DurationModel) Estimate(name string) (time.Duration, bool) {
+	if m == nil {
+		return 0, false
+	}
+	stats := m.Tests[name]
+	if stats == nil || stats.Passes+stats.Failures == 0 {
+		return 0, false
+	}
+	return time.Duration(stats.MeanSeconds() * float64(time.Second)), true
+}
+
+// SkipRate returns the recorded skip rate of a test, 0 when it is unknown
+func (m *DurationModel) SkipRate(name string) float64 {
+	if m == nil || m.Tests[name] == nil {
+		return 0
+	}
+	return m.Tests[name].SkipRate()
+}
+
+// IngestFile parses a go test -json or JUnit XML results file into the model
+func (m *DurationModel) IngestFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var outcomes []testOutcome
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		outcomes, err = parseJUnitResults(data)
+	} else {
+		outcomes, err = parseGoTestJSON(data)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, outcome := range outcomes {
+		m.Record(outcome.name, outcome.action, outcome.seconds)
+	}
+	return len(outcomes), nil
+}
+
+// goTestEvent is one line of `go test -json` output
+type goTestEvent struct {
+	Action  string
+	Test    string
+	Elapsed float64
+}
+
+// parseGoTestJSON extracts top-level test outcomes from `go test -json` output.
+// Subtests (names containing "/") are folded into their parent's duration.
+func parseGoTestJSON(data []byte) ([]testOutcome, error) {
+	var outcomes []testOutcome
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue // Tolerate interleaved non-JSON build output
+		}
+
+		var event goTestEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+		if event.Test == "" || strings.Contains(event.Test, "/") {
+			continue
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+			outcomes = append(outcomes, testOutcome{name: event.Test, action: event.Action, seconds: event.Elapsed})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return outcomes, nil
+}
+
+// junitTestCase is a <testcase> element in a JUnit XML report
+type junitTestCase struct {
+	Name    string    `xml:"name,attr"`
+	Time    string    `xml:"time,attr"`
+	Failure *struct{} `xml:"failure"`
+	Error   *struct{} `xml:"error"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// parseJUnitResults extracts test outcomes from a JUnit XML report. Both
+// <testsuites> and bare <testsuite> roots are accepted by scanning for
+// <testcase> elements anywhere in the document.
+func parseJUnitResults(data []byte) ([]testOutcome, error) {
+	var outcomes []testOutcome
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "testcase" {
+			continue
+		}
+
+		var testCase junitTestCase
+		if err := decoder.DecodeElement(&testCase, &start); err != nil {
+			return nil, err
+		}
+		if testCase.Name == "" || strings.Contains(testCase.Name, "/") {
+			continue
+		}
+
+		seconds, _ := strconv.ParseFloat(testCase.Time, 64)
+		action := "pass"
+		switch {
+		case testCase.Skipped != nil:
+			action = "skip"
+		case testCase.Failure != nil || testCase.Error != nil:
+			action = "fail"
+		}
+		outcomes = append(outcomes, testOutcome{name: testCase.Name, action: action, seconds: seconds})
+	}
+
+	return outcomes, nil
+}
+
+// LoadDurationModel reads a TestDurations.csv file
+func LoadDurationModel(path string) (*DurationModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	model := NewDurationModel()
+	for i, row := range rows {
+		if i == 0 {
+			continue // Header
+		}
+		if len(row) != len(durationsHeader) {
+			return nil, fmt.Errorf("%s: row %d has %d columns, expected %d", path, i+1, len(row), len(durationsHeader))
+		}
+
+		stats := &TestDurationStats{TestName: row[0]}
+		stats.Runs, _ = strconv.Atoi(row[1])
+		stats.Passes, _ = strconv.Atoi(row[2])
+		stats.Failures, _ = strconv.Atoi(row[3])
+		stats.Skips, _ = strconv.Atoi(row[4])
+		stats.TotalSeconds, _ = strconv.ParseFloat(row[5], 64)
+		// row[6] (MeanSeconds) is derived and recomputed on demand
+		stats.MaxSeconds, _ = strconv.ParseFloat(row[7], 64)
+		stats.LastSeconds, _ = strconv.ParseFloat(row[8], 64)
+		model.Tests[stats.TestName] = stats
+	}
+
+	return model, nil
+}
+
+// Save writes the model as TestDurations.csv
+func (m *DurationModel) Save(path string) error {
+	names := make([]string, 0, len(m.Tests))
+	for name := range m.Tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(durationsHeader)
+	for _, name := range names {
+		stats := m.Tests[name]
+		writer.Write([]string{
+			stats.TestName,
+			strconv.Itoa(stats.Runs),
+			strconv.Itoa(stats.Passes),
+			strconv.Itoa(stats.Failures),
+			strconv.Itoa(stats.Skips),
+			formatFloat(stats.TotalSeconds),
+			formatFloat(stats.MeanSeconds()),
+			formatFloat(stats.MaxSeconds),
+			formatFloat(stats.LastSeconds),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// formatFloat renders seconds with millisecond precision
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
+
+// resolveDurationsPath accepts either a TestDurations.csv path or the database
+// directory that contains it
+func resolveDurationsPath(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return filepath.Join(path, durationsFileName)
+	}
+	return path
+}
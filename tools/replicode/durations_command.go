@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// DurationIngestSummary reports what a durations ingest run recorded
+type DurationIngestSummary struct {
+	Database string   `json:"database"` // Path of the TestDurations.csv written
+	Files    []string `json:"files"`
+	Outcomes int      `json:"outcomes"` // Test results recorded from the input files
+	Tests    int      `json:"tests"`    // Distinct tests in the model after ingestion
+}
+
+// runDurationsCommand dispatches the durations subcommands
+func runDurationsCommand(args []string) int {
+	if len(args) > 0 && args[0] == "ingest" {
+		return runDurationsIngest(args[1:])
+	}
+	if len(args) > 0 && !isHelpFlag(args[0]) {
+		fmt.Fprintf(os.Stderr, "Error: unknown durations command %q\n", args[0])
+	}
+	fmt.Fprintln(usageOutput(args), "Usage: replicode durations ingest -db <path> [-reset] <results-file>...")
+	return usageStatus(args)
+}
+
+// runDurationsIngest merges go test -json or JUnit XML result files into the
+// TestDurations.csv table of an exported analysis database
+func runDurationsIngest(args []string) int {
+	fs := flag.NewFlagSet("durations ingest", flag.ContinueOnError)
+	db := fs.String("db", "", "Database directory (or TestDurations.csv path) to update")
+	reset := fs.Bool("reset", false, "Discard existing timing data instead of merging into it")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if *db == "" {
+		fmt.Fprintln(os.Stderr, "Error: -db parameter is required")
+		return 1
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Error: at least one results file is required")
+		return 1
+	}
+
+	path := resolveDurationsPath(*db)
+	model := NewDurationModel()
+	if !*reset {
+		existing, err := LoadDurationModel(path)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if existing != nil {
+			model = existing
+		}
+	}
+
+	summary := &DurationIngestSummary{Database: path, Files: fs.Args()}
+	for _, file := range fs.Args() {
+		count, err := model.IngestFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		summary.Outcomes += count
+	}
+	summary.Tests = len(model.Tests)
+
+	if err := model.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, ""), summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+)
+
+// ExclusionPair is two tests that cannot run concurrently
+type ExclusionPair struct {
+	TestA string   `json:"test_a"`
+	TestB string   `json:"test_b"`
+	Keys  []string `json:"keys"` // Shared singleton dependencies
+}
+
+// SingletonKeys returns the singleton dependency keys a test or template
+// reaches through its steps, template calls, and sequential sub-tests
+func (g *DependencyGraph) SingletonKeys(id string) map[string]bool {
+	keys := map[string]bool{}
+	reached := g.Reachable(id, DirectionOut, 0)
+	reached[id] = 0
+	for reachedID := range reached {
+		for _, dep := range g.singletons[reachedID] {
+			keys[dep.Key] = true
+		}
+	}
+	return keys
+}
+
+// MutualExclusions returns every pair of the given tests that share a
+// singleton dependency, sorted by test names
+func MutualExclusions(graph *DependencyGraph, tests []string) []ExclusionPair {
+	byKey := map[string][]string{}
+	for _, test := range tests {
+		for key := range graph.SingletonKeys(testNodeID(test)) {
+			byKey[key] = append(byKey[key], test)
+		}
+	}
+
+	shared := map[[2]string][]string{}
+	for key, users := range byKey {
+		sort.Strings(users)
+		for i := 0; i < len(users); i++ {
+			for j := i + 1; j < len(users); j++ {
+				if users[i] == users[j] {
+					continue
+				}
+				pair := [2]string{users[i], users[j]}
+				shared[pair] = append(shared[pair], key)
+			}
+		}
+	}
+
+	pairs := []ExclusionPair{}
+	for pair, keys := range shared {
+		sort.Strings(keys)
+		pairs = append(pairs, ExclusionPair{TestA: pair[0], TestB: pair[1], Keys: keys})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].TestA != pairs[j].TestA {
+			return pairs[i].TestA < pairs[j].TestA
+		}
+		return pairs[i].TestB < pairs[j].TestB
+	})
+	return pairs
+}
+
+// sharesSingleton reports whether two singleton key sets overlap
+func sharesSingleton(a, b map[string]bool) bool {
+	for key := range a {
+		if b[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeExclusiveUnits merges shard units whose tests share a singleton
+// dependency, so conflicting tests run on the same agent rather than
+// concurrently on different ones
+func mergeExclusiveUnits(graph *DependencyGraph, units []*shardUnit) []*shardUnit {
+	keys := make([]map[string]bool, len(units))
+	for i, unit := range units {
+		keys[i] = map[string]bool{}
+		for _, test := range unit.tests {
+			for key := range graph.SingletonKeys(testNodeID(test)) {
+				keys[i][key] = true
+			}
+		}
+	}
+
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(units) && !merged; i++ {
+			for j := i + 1; j < len(units); j++ {
+				if !sharesSingleton(keys[i], keys[j]) {
+					continue
+				}
+				units[i].tests = append(units[i].tests, units[j].tests...)
+				units[i].seconds += units[j].seconds
+				units[i].risk += units[j].risk
+				sort.Strings(units[i].tests)
+				for key := range keys[j] {
+					keys[i][key] = true
+				}
+				units = append(units[:j], units[j+1:]...)
+				keys = append(keys[:j], keys[j+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+
+	return units
+}
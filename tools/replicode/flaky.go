@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Flaky-test handling modes for select -flaky-mode
+const (
+	flakyAnnotate   = "annotate"   // Mark flaky tests only
+	flakyRetry      = "retry"      // Mark and assign retries
+	flakyQuarantine = "quarantine" // Mark and move into a separate shard
+)
+
+// flakyModes are the accepted values of select -flaky-mode
+var flakyModes = map[string]bool{flakyAnnotate: true, flakyRetry: true, flakyQuarantine: true}
+
+// FlakyList maps known-flaky test names to the reason given in the list (may be empty)
+type FlakyList map[string]string
+
+// LoadFlakyList reads a flaky-test list from a file path or an http(s) URL.
+//
+// The format is one test name per line. Blank lines and lines starting with
+// "#" are ignored; anything after a "#" on a test line is kept as the reason:
+//
+//	TestAccVirtualNetwork_basic  # intermittent 429 from the API
+func LoadFlakyList(location string) (FlakyList, error) {
+	list, err := readNameList(location, "flaky list")
+	if err != nil {
+		return nil, err
+	}
+	return FlakyList(list), nil
+}
+
+// readNameList reads a list of names with optional "#" reasons, one per line,
+// from a file path or an http(s) URL. what names the list in errors.
+func readNameList(location, what string) (map[string]string, error) {
+	var reader io.Reader
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(location)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %v", what, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s returned %s", what, location, resp.Status)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	list := map[string]string{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, reason, _ := strings.Cut(line, "#")
+		list[strings.TrimSpace(name)] = strings.TrimSpace(reason)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", what, err)
+	}
+
+	return list, nil
+}
+
+// ApplyFlakyList annotates the selected tests found in the list. In retry mode
+// flaky tests are also assigned the given number of retries.
+func ApplyFlakyList(tests []SelectedTest, list FlakyList, mode string, retries int) {
+	for i := range tests {
+		reason, flaky := list[tests[i].Name]
+		if !flaky {
+			continue
+		}
+		tests[i].Flaky = true
+		tests[i].FlakyReason = reason
+		if mode == flakyRetry {
+			tests[i].Retries = retries
+		}
+	}
+}
+
+// QuarantineShards shards the stable tests into n shards and places every
+// flaky test, together with any test sharing its sequential group, into one
+// extra shard marked as quarantined
+func QuarantineShards(graph *DependencyGraph, tests []SelectedTest, n int) []TestShard {
+	isFlaky := map[string]bool{}
+	for _, test := range tests {
+		isFlaky[test.Name] = test.Flaky
+	}
+
+	quarantined := map[string]bool{}
+	for _, unit := range sequentialUnits(graph, tests) {
+		flaky := false
+		for _, name := range unit.tests {
+			flaky = flaky || isFlaky[name]
+		}
+		if flaky {
+			for _, name := range unit.tests {
+				quarantined[name] = true
+			}
+		}
+	}
+
+	var stable, flaky []SelectedTest
+	for _, test := range tests {
+		if quarantined[test.Name] {
+			flaky = append(flaky, test)
+		} else {
+			stable = append(stable, test)
+		}
+	}
+
+	shards := ShardTests(graph, stable, n)
+	if len(flaky) > 0 {
+		quarantine := ShardTests(graph, flaky, 1)[0]
+		quarantine.Index = len(shards) + 1
+		quarantine.Quarantine = true
+		shards = append(shards, quarantine)
+	}
+	return shards
+}
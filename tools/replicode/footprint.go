@@ -0,0 +1,166 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// footprintListItem matches one element of a literal for_each list or set
+var footprintListItem = regexp.MustCompile(`"[^"]*"|[^,\s\[\]]+`)
+
+// TemplateFootprint counts the resource blocks one template declares itself
+type TemplateFootprint struct {
+	Blocks   int // resource blocks
+	Weighted int // Blocks with count and for_each constants applied
+	Dynamic  int // Blocks whose count or for_each is not a constant, weighted as 1
+}
+
+// hclFootprint counts the top-level resource blocks of template HCL. A
+// literal count multiplies a block, as does a literal for_each list, set, or
+// single-line map; any other count or for_each leaves it at 1 and marks it
+// dynamic.
+func hclFootprint(hcl string) TemplateFootprint {
+	var footprint TemplateFootprint
+	depth, weight, dynamic, inResource := 0, 1, false, false
+	for _, line := range strings.Split(hcl, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && strings.HasPrefix(trimmed, "resource ") && strings.HasSuffix(trimmed, "{") {
+			inResource, weight, dynamic = true, 1, false
+		} else if depth == 1 && inResource {
+			if name, value, ok := strings.Cut(trimmed, "="); ok {
+				switch strings.TrimSpace(name) {
+				case "count":
+					weight, dynamic = countWeight(strings.TrimSpace(value))
+				case "for_each":
+					weight, dynamic = forEachWeight(strings.TrimSpace(value))
+				}
+			}
+		}
+
+		depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+		if depth < 0 {
+			depth = 0
+		}
+		if depth == 0 && inResource {
+			footprint.Blocks++
+			footprint.Weighted += weight
+			if dynamic {
+				footprint.Dynamic++
+			}
+			inResource = false
+		}
+	}
+	return footprint
+}
+
+// countWeight is the number of instances a count expression creates
+func countWeight(value string) (int, bool) {
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return n, false
+	}
+	return 1, true
+}
+
+// forEachWeight is the number of instances a for_each expression creates
+func forEachWeight(value string) (int, bool) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "toset("), ")")
+	switch {
+	case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+		return len(footprintListItem.FindAllString(value, -1)), false
+	case strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}"):
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		if inner == "" {
+			return 0, false
+		}
+		return len(strings.Split(strings.TrimSuffix(inner, ","), ",")), false
+	}
+	return 1, true
+}
+
+// TestFootprint is the resource footprint of a test's transitive template closure
+type TestFootprint struct {
+	Test           string `json:"test"`
+	Service        string `json:"service"`
+	Templates      int    `json:"templates"`       // Distinct templates reached, including through sequential sub-tests
+	ResourceBlocks int    `json:"resource_blocks"` // resource blocks across those templates
+	Footprint      int    `json:"footprint"`       // ResourceBlocks weighted by count and for_each constants
+	DynamicBlocks  int    `json:"dynamic_blocks"`  // Blocks with a non-constant count or for_each, weighted as 1
+}
+
+// templateFootprint counts a template's own resource blocks from its returned
+// HCL, falling back to its resource block references when the HCL is not a
+// literal
+func (g *DependencyGraph) templateFootprint(id string) TemplateFootprint {
+	if source, ok := g.templateSources[id]; ok {
+		return hclFootprint(source.Format)
+	}
+	var footprint TemplateFootprint
+	for _, edge := range g.OutEdges(id) {
+		if edge.Kind == EdgeResourceRef && edge.Detail == "RESOURCE_BLOCK" {
+			footprint.Blocks++
+			footprint.Weighted++
+		}
+	}
+	return footprint
+}
+
+// Footprint sums the resource blocks of every template a test reaches. Each
+// template counts once however many steps use it, so the result is the
+// number of resources the test creates over its whole run.
+func (g *DependencyGraph) Footprint(test string) TestFootprint {
+	id := testNodeID(test)
+	footprint := TestFootprint{Test: test}
+	if node := g.Nodes[id]; node != nil {
+		footprint.Service = node.Service
+	}
+	for reachedID := range g.Reachable(id, DirectionOut, 0) {
+		if node := g.Nodes[reachedID]; node == nil || node.Kind != NodeTemplate {
+			continue
+		}
+		template := g.templateFootprint(reachedID)
+		footprint.Templates++
+		footprint.ResourceBlocks += template.Blocks
+		footprint.Footprint += template.Weighted
+		footprint.DynamicBlocks += template.Dynamic
+	}
+	return footprint
+}
+
+// ComputeFootprints returns the footprint of each test, largest first
+func ComputeFootprints(graph *DependencyGraph, tests []string) []TestFootprint {
+	footprints := make([]TestFootprint, 0, len(tests))
+	for _, test := range tests {
+		footprints = append(footprints, graph.Footprint(test))
+	}
+	sort.Slice(footprints, func(i, j int) bool {
+		if footprints[i].Footprint != footprints[j].Footprint {
+			return footprints[i].Footprint > footprints[j].Footprint
+		}
+		return footprints[i].Test < footprints[j].Test
+	})
+	return footprints
+}
+
+// AttachTestFootprints records the footprint of each selected test
+func AttachTestFootprints(graph *DependencyGraph, tests []SelectedTest) {
+	for i := range tests {
+		tests[i].Footprint = graph.Footprint(tests[i].Name).Footprint
+	}
+}
+
+// AttachPlanFootprints records the footprint of each plan item; sequential
+// members count their own function's templates
+func AttachPlanFootprints(graph *DependencyGraph, plan *ExecutionPlan) {
+	for s := range plan.Stages {
+		for i := range plan.Stages[s].Items {
+			item := &plan.Stages[s].Items[i]
+			name := item.Test
+			if item.Function != "" {
+				name = item.Function
+			}
+			item.Footprint = graph.Footprint(name).Footprint
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uncommittedCommit is the hash git blame reports for lines not yet committed
+const uncommittedCommit = "0000000000000000000000000000000000000000"
+
+// GitMetadata is the most recent commit touching a test or template function
+type GitMetadata struct {
+	Commit   string    `json:"commit"`
+	Author   string    `json:"author"`
+	Modified time.Time `json:"modified"`
+	AgeDays  int       `json:"age_days"`
+}
+
+// blameLine is the commit that last changed one line of a file
+type blameLine struct {
+	commit string
+	author string
+	time   time.Time
+}
+
+// gitBlamer runs git blame against a repository, caching one blame per file
+type gitBlamer struct {
+	root  string
+	now   time.Time
+	files map[string][]blameLine
+}
+
+// newGitBlamer checks that root is inside a git work tree
+func newGitBlamer(root string) (*gitBlamer, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git metadata requires git on PATH: %w", err)
+	}
+	out, err := exec.Command("git", "-C", root, "rev-parse", "--is-inside-work-tree").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return nil, fmt.Errorf("%s is not inside a git work tree", root)
+	}
+	return &gitBlamer{root: root, now: time.Now(), files: map[string][]blameLine{}}, nil
+}
+
+// blame returns the per-line commits of a repository-relative file. Files git
+// cannot blame (untracked, deleted) yield no lines rather than an error.
+func (b *gitBlamer) blame(file string) []blameLine {
+	if lines, ok := b.files[file]; ok {
+		return lines
+	}
+
+	var lines []blameLine
+	out, err := exec.Command("git", "-C", b.root, "blame", "--line-porcelain", "--", file).Output()
+	if err == nil {
+		lines = parseBlamePorcelain(out)
+	}
+	b.files[file] = lines
+	return lines
+}
+
+// parseBlamePorcelain reads git blame --line-porcelain output, where every line
+// of the file is preceded by a full header block for its commit
+func parseBlamePorcelain(out []byte) []blameLine {
+	var lines []blameLine
+	var current blameLine
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			lines = append(lines, current)
+			current = blameLine{}
+		case strings.HasPrefix(text, "author "):
+			current.author = strings.TrimPrefix(text, "author ")
+		case strings.HasPrefix(text, "author-time "):
+			if seconds, err := strconv.ParseInt(strings.TrimPrefix(text, "author-time "), 10, 64); err == nil {
+				current.time = time.Unix(seconds, 0).UTC()
+			}
+		case current.commit == "":
+			if fields := strings.Fields(text); len(fields) >= 3 && len(fields[0]) == len(uncommittedCommit) {
+				current.commit = fields[0]
+			}
+		}
+	}
+	return lines
+}
+
+// latest returns the newest committed change within lines [start, end] of a file
+func (b *gitBlamer) latest(file string, start, end int) *GitMetadata {
+	lines := b.blame(file)
+	if start < 1 {
+		start = 1
+	}
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if end < start {
+		end = start
+	}
+	if start > len(lines) {
+		return nil
+	}
+
+	var newest *blameLine
+	for i := start - 1; i < end; i++ {
+		line := &lines[i]
+		if line.commit == uncommittedCommit {
+			continue
+		}
+		if newest == nil || line.time.After(newest.time) {
+			newest = line
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+
+	return &GitMetadata{
+		Commit:   newest.commit,
+		Author:   newest.author,
+		Modified: newest.time,
+		AgeDays:  int(b.now.Sub(newest.time).Hours() / 24),
+	}
+}
+
+// EnrichGitMetadata attaches the last commit, author, and age to the given test
+// and template nodes (every one when ids is nil). The graph only records
+// declaration lines, so a function is taken to span from its declaration to the
+// next declaration in the same file.
+func EnrichGitMetadata(graph *DependencyGraph, repoRoot string, ids map[string]bool) error {
+	blamer, err := newGitBlamer(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	byFile := map[string][]*GraphNode{}
+	wanted := map[string]bool{}
+	for id, node := range graph.Nodes {
+		if (node.Kind == NodeTest || node.Kind == NodeTemplate) && node.File != "" && node.Line > 0 {
+			byFile[node.File] = append(byFile[node.File], node)
+			if ids == nil || ids[id] {
+				wanted[node.File] = true
+			}
+		}
+	}
+
+	for file := range wanted {
+		nodes := byFile[file]
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Line < nodes[j].Line })
+		for i, node := range nodes {
+			end := 0
+			if i+1 < len(nodes) {
+				end = nodes[i+1].Line - 1
+			}
+			if ids == nil || ids[node.ID] {
+				node.Git = blamer.latest(file, node.Line, end)
+			}
+		}
+	}
+	return nil
+}
+
+// AttachTestGitMetadata copies the git metadata of each selected test's node
+func AttachTestGitMetadata(graph *DependencyGraph, tests []SelectedTest, repoRoot string) error {
+	ids := make(map[string]bool, len(tests))
+	for _, test := range tests {
+		ids[testNodeID(test.Name)] = true
+	}
+	if err := EnrichGitMetadata(graph, repoRoot, ids); err != nil {
+		return err
+	}
+
+	for i := range tests {
+		if node := graph.Nodes[testNodeID(tests[i].Name)]; node != nil {
+			tests[i].Git = node.Git
+		}
+	}
+	return nil
+}
@@ -0,0 +1,739 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// NodeKind identifies the kind of entity a graph node represents
+type NodeKind string
+
+const (
+	NodeTest     NodeKind = "test"     // Test function (TestAcc* or testAcc*)
+	NodeTemplate NodeKind = "template" // Template method returning HCL (e.g., VirtualNetworkResource.basic)
+	NodeResource NodeKind = "resource" // Azure resource type (e.g., azurerm_subnet)
+	NodeService  NodeKind = "service"  // Service package (e.g., network)
+)
+
+// EdgeKind identifies the relationship an edge represents
+type EdgeKind string
+
+const (
+	EdgeStepRef       EdgeKind = "step_ref"       // test -> template (Config field of a TestStep)
+	EdgeTemplateCall  EdgeKind = "template_call"  // template -> template (fmt.Sprintf argument)
+	EdgeSequentialRef EdgeKind = "sequential_ref" // entry point test -> sequentially executed test
+	EdgePreConfig     EdgeKind = "pre_config"     // test -> test or template a step's PreConfig function calls
+	EdgeResourceRef   EdgeKind = "resource_ref"   // template -> resource (HCL block or attribute reference), test -> resource (RequiresImportError)
+	EdgeTaintRef      EdgeKind = "taint_ref"      // test -> resource (Taint address of a TestStep)
+	EdgeDestroyRef    EdgeKind = "destroy_ref"    // test -> resource (type its CheckDestroy function checks)
+	EdgeMemberOf      EdgeKind = "member_of"      // test/template -> owning service
+)
+
+// GraphNode is a single entity in the dependency graph
+type GraphNode struct {
+	ID      string   `json:"id"`   // Kind-qualified identifier (e.g., "template:VirtualNetworkResource.basic"), suffixed with "@<package>" when another package declared the name first
+	Kind    NodeKind `json:"kind"` // test, template, resource, or service
+	Name    string   `json:"name"` // Display name (e.g., "VirtualNetworkResource.basic")
+	Service string   `json:"service,omitempty"`
+	File    string   `json:"file,omitempty"`
+	Line    int      `json:"line,omitempty"`
+
+	Namespace string `json:"namespace,omitempty"` // ARM namespace of a resource node
+
+	Git *GitMetadata `json:"git,omitempty"` // Last change to the function, when -git is given
+}
+
+// GraphEdge is a directed relationship between two nodes with source evidence
+type GraphEdge struct {
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Kind   EdgeKind `json:"kind"`
+	File   string   `json:"file,omitempty"`   // File containing the evidence for this edge
+	Line   int      `json:"line,omitempty"`   // Line of the evidence (step, call, or template declaration)
+	Detail string   `json:"detail,omitempty"` // Config expression, call expression, or reference type
+}
+
+// UnresolvedReference records an extracted reference the graph could not attach to a node
+type UnresolvedReference struct {
+	Kind   EdgeKind `json:"kind"`
+	From   string   `json:"from"`
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Detail string   `json:"detail"`
+
+	// Candidates are the nodes of an ambiguous reference: several packages
+	// declare its target and none is the package of the referencing file
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// functionKey identifies a function declaration by the package of its file,
+// its receiver type ("" for a plain function), and its name
+type functionKey struct {
+	pkg, receiver, name string
+}
+
+// packageOf returns the package of a file, the directory holding it
+func packageOf(file string) string {
+	return path.Dir(filepath.ToSlash(file))
+}
+
+// declarationKey returns the key of a function; tests are keyed without their
+// receiver, as test nodes are named by the function alone
+func declarationKey(fn analyzer.FunctionInfo) functionKey {
+	if fn.IsTestFunc {
+		return functionKey{packageOf(fn.File), "", fn.FunctionName}
+	}
+	return functionKey{packageOf(fn.File), fn.ReceiverType, fn.FunctionName}
+}
+
+// DependencyGraph is the in-memory model of tests, templates, resources, and services
+type DependencyGraph struct {
+	Nodes      map[string]*GraphNode
+	Unresolved []UnresolvedReference
+
+	singletons      map[string][]analyzer.SingletonDependency  // Template node ID -> singleton dependencies in its HCL
+	requirements    map[string][]analyzer.FunctionRequirements // Test/template node ID -> declared prerequisites
+	templateSources map[string]analyzer.TemplateSource         // Template node ID -> returned HCL and its arguments
+	functions       map[functionKey]string                     // Declaration -> its node ID
+	declared        map[string][]string                        // Unqualified node ID -> node IDs of every package declaring it
+	locations       map[string][]analyzer.LocationFinding      // Test/template node ID -> regions it pins or reads
+	skippable       map[string]int                             // Test node ID -> steps with a SkipFunc
+	outEdges        map[string][]*GraphEdge
+	inEdges         map[string][]*GraphEdge
+	edgeSeen        map[string]bool
+}
+
+// GraphExport is the serializable form of a graph or subgraph
+type GraphExport struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// Direction controls which edges are followed during traversal
+type Direction string
+
+const (
+	DirectionOut  Direction = "out"  // Follow edges from dependents to dependencies
+	DirectionIn   Direction = "in"   // Follow edges from dependencies back to dependents
+	DirectionBoth Direction = "both" // Follow edges in either direction
+)
+
+// parseDirection validates a direction flag value
+func parseDirection(value string) (Direction, error) {
+	switch Direction(value) {
+	case DirectionOut, DirectionIn, DirectionBoth:
+		return Direction(value), nil
+	}
+	return "", fmt.Errorf("invalid direction %q (expected out, in, or both)", value)
+}
+
+// testNodeID returns the node ID for a test function
+func testNodeID(name string) string {
+	return string(NodeTest) + ":" + name
+}
+
+// templateNodeID returns the node ID for a template method on a struct
+func templateNodeID(structName, name string) string {
+	return string(NodeTemplate) + ":" + structName + "." + name
+}
+
+// resourceNodeID returns the node ID for an Azure resource type
+func resourceNodeID(name string) string {
+	return string(NodeResource) + ":" + name
+}
+
+// serviceNodeID returns the node ID for a service
+func serviceNodeID(name string) string {
+	return string(NodeService) + ":" + name
+}
+
+// BuildDependencyGraph builds the graph from per-file analysis results
+func BuildDependencyGraph(results []*analyzer.Result) *DependencyGraph {
+	g := &DependencyGraph{
+		Nodes:           make(map[string]*GraphNode),
+		singletons:      make(map[string][]analyzer.SingletonDependency),
+		requirements:    make(map[string][]analyzer.FunctionRequirements),
+		templateSources: make(map[string]analyzer.TemplateSource),
+		functions:       make(map[functionKey]string),
+		declared:        make(map[string][]string),
+		locations:       make(map[string][]analyzer.LocationFinding),
+		skippable:       make(map[string]int),
+		outEdges:        make(map[string][]*GraphEdge),
+		inEdges:         make(map[string][]*GraphEdge),
+		edgeSeen:        make(map[string]bool),
+	}
+
+	// Pass 1: register test and template nodes so references can resolve across files
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			g.addFunctionNode(fn)
+		}
+	}
+
+	// Pass 2: connect references
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			from, _ := g.resolveFunction(result, "", step.SourceFunction)
+			if step.SourceFunction == "" || from == "" {
+				continue
+			}
+			for _, call := range step.PreConfigCalls {
+				// A method call resolves to a template and a function call to a
+				// test; setup helpers that are neither have no node
+				if call.Receiver != "" && call.Struct == "" {
+					continue // Method of an unresolved receiver
+				}
+				if to, _ := g.resolveFunction(result, call.Struct, call.Method); to != "" {
+					g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgePreConfig, File: step.SourceFile, Line: call.Line, Detail: call.Expr})
+				}
+			}
+			for _, taint := range step.Taint {
+				if taint.ResourceType == "" {
+					continue
+				}
+				to := resourceNodeID(taint.ResourceType)
+				if g.Nodes[to] == nil {
+					g.Nodes[to] = &GraphNode{ID: to, Kind: NodeResource, Name: taint.ResourceType}
+				}
+				g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeTaintRef, File: step.SourceFile, Line: step.SourceLine, Detail: taint.Expr})
+			}
+			to, candidates := g.resolveTemplate(result, from, step.ConfigExpr, step.ConfigStruct, step.ConfigMethod)
+			if to == "" {
+				g.Unresolved = append(g.Unresolved, UnresolvedReference{
+					Kind: EdgeStepRef, From: from, File: step.SourceFile, Line: step.SourceLine, Detail: step.ConfigExpr, Candidates: candidates,
+				})
+				continue
+			}
+			g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeStepRef, File: step.SourceFile, Line: step.SourceLine, Detail: step.ConfigExpr})
+			if step.SkipFunc != "" {
+				g.skippable[from]++
+			}
+		}
+
+		for _, call := range result.TemplateCalls {
+			source := enclosingFunction(result, call.SourceFunction, call.SourceLine)
+			if source == nil {
+				continue
+			}
+			from := g.functionNodeID(*source)
+			if from == "" {
+				continue
+			}
+			to, candidates := g.resolveTemplate(result, from, call.TargetExpr, call.TargetStruct, call.TargetMethod)
+			if to == "" {
+				g.Unresolved = append(g.Unresolved, UnresolvedReference{
+					Kind: EdgeTemplateCall, From: from, File: call.SourceFile, Line: call.SourceLine, Detail: call.TargetExpr, Candidates: candidates,
+				})
+				continue
+			}
+			g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeTemplateCall, File: call.SourceFile, Line: call.SourceLine, Detail: call.TargetExpr})
+		}
+
+		for _, seq := range result.SequentialReferences {
+			from, _ := g.resolveFunction(result, "", seq.EntryPointFunction)
+			if from == "" {
+				from = testNodeID(seq.EntryPointFunction)
+			}
+			to, _ := g.resolveFunction(result, "", seq.ReferencedFunction)
+			if to == "" {
+				to = testNodeID(seq.ReferencedFunction)
+			}
+			if g.Nodes[to] == nil {
+				// Sequential targets can live in files outside the analyzed set
+				g.Nodes[to] = &GraphNode{ID: to, Kind: NodeTest, Name: seq.ReferencedFunction}
+			}
+			detail := seq.SequentialGroup
+			if seq.SequentialKey != "" {
+				detail += "/" + seq.SequentialKey
+			}
+			g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeSequentialRef, File: seq.EntryPointFile, Line: seq.EntryPointLine, Detail: detail})
+		}
+
+		for _, ref := range result.DirectResourceRefs {
+			source := functionAtLine(result, ref.TemplateFunction, ref.TemplateLine)
+			if source == nil {
+				continue
+			}
+			from := g.functionNodeID(*source)
+			if from == "" {
+				continue
+			}
+			to := resourceNodeID(ref.ResourceName)
+			if g.Nodes[to] == nil {
+				g.Nodes[to] = &GraphNode{ID: to, Kind: NodeResource, Name: ref.ResourceName, Namespace: ref.Namespace}
+			} else if g.Nodes[to].Namespace == "" {
+				g.Nodes[to].Namespace = ref.Namespace // Taint addresses create nodes without one
+			}
+			g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeResourceRef, File: ref.TemplateFile, Line: ref.TemplateLine, Detail: ref.ReferenceType})
+		}
+
+		for _, ref := range result.CheckDestroyRefs {
+			from, _ := g.resolveFunction(result, "", ref.SourceFunction)
+			if from == "" {
+				continue
+			}
+			to := resourceNodeID(ref.ResourceName)
+			if g.Nodes[to] == nil {
+				g.Nodes[to] = &GraphNode{ID: to, Kind: NodeResource, Name: ref.ResourceName, Namespace: ref.Namespace}
+			}
+			g.addEdge(&GraphEdge{From: from, To: to, Kind: EdgeDestroyRef, File: ref.SourceFile, Line: ref.SourceLine, Detail: ref.CheckExpr})
+		}
+
+		for _, reqs := range result.Requirements {
+			if source := functionAtLine(result, reqs.FunctionName, reqs.Line); source != nil {
+				if id := g.functionNodeID(*source); id != "" {
+					g.requirements[id] = append(g.requirements[id], reqs)
+				}
+			}
+		}
+
+		for _, finding := range result.LocationFindings {
+			if source := functionAtLine(result, finding.FunctionName, finding.FunctionLine); source != nil {
+				if id := g.functionNodeID(*source); id != "" {
+					g.locations[id] = append(g.locations[id], finding)
+				}
+			}
+		}
+
+		for _, source := range result.TemplateSources {
+			fn := functionAtLine(result, source.FunctionName, source.Line)
+			if fn == nil {
+				continue
+			}
+			id := g.functionNodeID(*fn)
+			if _, exists := g.templateSources[id]; id == "" || exists {
+				continue // First definition wins, as for nodes
+			}
+			source.Args = append([]analyzer.TemplateArg{}, source.Args...)
+			for i, arg := range source.Args {
+				if arg.TargetMethod != "" {
+					source.Args[i].Target, _ = g.resolveTemplate(result, id, arg.Expr, arg.TargetStruct, arg.TargetMethod)
+				}
+			}
+			g.templateSources[id] = source
+		}
+
+		for _, dep := range result.SingletonDeps {
+			if source := functionAtLine(result, dep.TemplateFunction, dep.TemplateLine); source != nil {
+				if id := g.functionNodeID(*source); id != "" {
+					g.singletons[id] = append(g.singletons[id], dep)
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// functionNodeID returns the node ID for a tracked function, or "" for
+// functions that aren't graph nodes (e.g., newXxxResource constructors)
+func (g *DependencyGraph) functionNodeID(fn analyzer.FunctionInfo) string {
+	return g.functions[declarationKey(fn)]
+}
+
+// unqualifiedNodeID returns the node ID a tracked function has when no other
+// package declares its name, or "" for functions that aren't graph nodes
+func unqualifiedNodeID(fn analyzer.FunctionInfo) string {
+	if fn.IsTestFunc {
+		return testNodeID(fn.FunctionName)
+	}
+	if fn.ReceiverType != "" {
+		return templateNodeID(fn.ReceiverType, fn.FunctionName)
+	}
+	return ""
+}
+
+// addFunctionNode registers a test or template node and links it to its
+// service. The first package to declare a name gets the unqualified ID, and
+// other packages declaring the same name get IDs qualified with their package,
+// so their references don't merge.
+func (g *DependencyGraph) addFunctionNode(fn analyzer.FunctionInfo) {
+	id := unqualifiedNodeID(fn)
+	key := declarationKey(fn)
+	if _, exists := g.functions[key]; id == "" || exists {
+		return // First definition within a package wins
+	}
+	if g.Nodes[id] != nil {
+		id += "@" + key.pkg
+	}
+	g.functions[key] = id
+	unqualified := unqualifiedNodeID(fn)
+	g.declared[unqualified] = append(g.declared[unqualified], id)
+
+	node := &GraphNode{ID: id, Service: fn.ServiceName, File: fn.File, Line: fn.Line}
+	if fn.IsTestFunc {
+		node.Kind = NodeTest
+		node.Name = fn.FunctionName
+	} else {
+		node.Kind = NodeTemplate
+		node.Name = fn.ReceiverType + "." + fn.FunctionName
+	}
+	g.Nodes[id] = node
+
+	if fn.ServiceName != "" {
+		serviceID := serviceNodeID(fn.ServiceName)
+		if g.Nodes[serviceID] == nil {
+			g.Nodes[serviceID] = &GraphNode{ID: serviceID, Kind: NodeService, Name: fn.ServiceName, Service: fn.ServiceName}
+		}
+		g.addEdge(&GraphEdge{From: id, To: serviceID, Kind: EdgeMemberOf, File: fn.File, Line: fn.Line})
+	}
+}
+
+// resolveFunction finds the node of a test (receiver "") or template
+// referenced from a result's file: the declaration in the file's own package,
+// else the only package's declaration. When several other packages declare
+// it, the reference is ambiguous and their nodes are returned instead.
+func (g *DependencyGraph) resolveFunction(result *analyzer.Result, receiver, name string) (string, []string) {
+	if id, ok := g.functions[functionKey{packageOf(result.FilePath), receiver, name}]; ok {
+		return id, nil
+	}
+	unqualified := testNodeID(name)
+	if receiver != "" {
+		unqualified = templateNodeID(receiver, name)
+	}
+	switch candidates := g.declared[unqualified]; len(candidates) {
+	case 0:
+		return "", nil
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", candidates
+	}
+}
+
+// qualifiedLiteralReceiver matches a call expression whose receiver is a
+// struct literal of another package (e.g., network.SubnetResource{}.basic(data))
+var qualifiedLiteralReceiver = regexp.MustCompile(`^&?\(?[A-Za-z_]\w*\.[A-Za-z_]\w*\{`)
+
+// resolveTemplate finds the template node for a struct/method pair, as
+// resolveFunction does. When the struct is unknown, a method name that is
+// unique within the same file is accepted as the target, unless that is the
+// caller itself or expr selects the method from another package's struct,
+// which the file's methods can't be.
+func (g *DependencyGraph) resolveTemplate(result *analyzer.Result, caller, expr, structName, method string) (string, []string) {
+	if method == "" {
+		return "", nil
+	}
+	if structName != "" {
+		return g.resolveFunction(result, structName, method)
+	}
+	if qualifiedLiteralReceiver.MatchString(expr) {
+		return "", nil
+	}
+
+	match := ""
+	for _, fn := range result.Functions {
+		if fn.IsTestFunc || fn.ReceiverType == "" || fn.FunctionName != method {
+			continue
+		}
+		id := g.functionNodeID(fn)
+		if id == caller {
+			continue // A template does not call itself
+		}
+		if match != "" {
+			return "", nil // Ambiguous within the file
+		}
+		match = id
+	}
+	return match, nil
+}
+
+// enclosingFunction finds the named function in a result that contains the given line
+func enclosingFunction(result *analyzer.Result, name string, line int) *analyzer.FunctionInfo {
+	var best *analyzer.FunctionInfo
+	for i := range result.Functions {
+		fn := &result.Functions[i]
+		if fn.FunctionName != name || fn.Line > line {
+			continue
+		}
+		if best == nil || fn.Line > best.Line {
+			best = fn
+		}
+	}
+	return best
+}
+
+// functionAtLine finds the named function declared on the given line
+func functionAtLine(result *analyzer.Result, name string, line int) *analyzer.FunctionInfo {
+	for i := range result.Functions {
+		if result.Functions[i].FunctionName == name && result.Functions[i].Line == line {
+			return &result.Functions[i]
+		}
+	}
+	return nil
+}
+
+// addEdge adds an edge unless an identical one (same endpoints, kind, and evidence) exists
+func (g *DependencyGraph) addEdge(edge *GraphEdge) {
+	key := fmt.Sprintf("%s|%s|%s|%s|%d|%s", edge.From, edge.To, edge.Kind, edge.File, edge.Line, edge.Detail)
+	if g.edgeSeen[key] {
+		return
+	}
+	g.edgeSeen[key] = true
+	g.outEdges[edge.From] = append(g.outEdges[edge.From], edge)
+	g.inEdges[edge.To] = append(g.inEdges[edge.To], edge)
+}
+
+// OutEdges returns the edges leaving a node
+func (g *DependencyGraph) OutEdges(id string) []*GraphEdge {
+	return g.outEdges[id]
+}
+
+// InEdges returns the edges entering a node
+func (g *DependencyGraph) InEdges(id string) []*GraphEdge {
+	return g.inEdges[id]
+}
+
+// ResolveNode looks up a node by ID, or by name when the query has no kind prefix
+func (g *DependencyGraph) ResolveNode(query string) (*GraphNode, error) {
+	if node, ok := g.Nodes[query]; ok {
+		return node, nil
+	}
+
+	var matches []*GraphNode
+	for _, node := range g.Nodes {
+		if node.Name == query {
+			matches = append(matches, node)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no node matches %q", query)
+	case 1:
+		return matches[0], nil
+	}
+
+	ids := make([]string, 0, len(matches))
+	for _, node := range matches {
+		ids = append(ids, node.ID)
+	}
+	sort.Strings(ids)
+	return nil, &AmbiguousNodeError{Query: query, Candidates: ids}
+}
+
+// AmbiguousNodeError is the error of a node name several nodes share
+type AmbiguousNodeError struct {
+	Query      string
+	Candidates []string // IDs of the nodes named by the query, sorted
+}
+
+func (e *AmbiguousNodeError) Error() string {
+	return fmt.Sprintf("%q is ambiguous, use one of: %s", e.Query, strings.Join(e.Candidates, ", "))
+}
+
+// Neighbors returns the edges adjacent to a node in the given direction
+func (g *DependencyGraph) Neighbors(id string, direction Direction) []*GraphEdge {
+	var edges []*GraphEdge
+	if direction == DirectionOut || direction == DirectionBoth {
+		edges = append(edges, g.outEdges[id]...)
+	}
+	if direction == DirectionIn || direction == DirectionBoth {
+		edges = append(edges, g.inEdges[id]...)
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// Reachable returns the IDs of every node reachable from start, with the
+// number of hops needed to reach each one. A maxDepth of 0 means unlimited.
+// Service membership edges are never traversed through: a service node can
+// be reached, but reaching it doesn't pull in the rest of the service.
+func (g *DependencyGraph) Reachable(start string, direction Direction, maxDepth int) map[string]int {
+	depths := map[string]int{start: 0}
+	queue := []string{start}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if maxDepth > 0 && depths[current] >= maxDepth {
+			continue
+		}
+		if current != start && g.Nodes[current] != nil && g.Nodes[current].Kind == NodeService {
+			continue
+		}
+
+		for _, edge := range g.Neighbors(current, direction) {
+			next := edge.To
+			if next == current {
+				next = edge.From
+			}
+			if _, seen := depths[next]; seen {
+				continue
+			}
+			depths[next] = depths[current] + 1
+			queue = append(queue, next)
+		}
+	}
+
+	delete(depths, start)
+	return depths
+}
+
+// ResourceClosure returns the sorted resource types transitively reachable from a node
+func (g *DependencyGraph) ResourceClosure(id string) []string {
+	resources := []string{}
+	for reachedID := range g.Reachable(id, DirectionOut, 0) {
+		if node := g.Nodes[reachedID]; node != nil && node.Kind == NodeResource {
+			resources = append(resources, node.Name)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// TestResourceClosures returns the resource closure of every test function,
+// including tests with an empty closure so lookups never miss. Tests are
+// keyed by their node ID without the kind prefix: the bare name, suffixed
+// with "@<package>" for a name another package declared first, so that
+// same-named tests of different packages keep their own closures.
+func (g *DependencyGraph) TestResourceClosures() map[string][]string {
+	closures := make(map[string][]string)
+	for id, node := range g.Nodes {
+		if node.Kind == NodeTest {
+			closures[strings.TrimPrefix(id, string(NodeTest)+":")] = g.ResourceClosure(id)
+		}
+	}
+	return closures
+}
+
+// GraphPath is one chain of nodes connecting two nodes
+type GraphPath struct {
+	Nodes []string   `json:"nodes"`
+	Hops  []GraphHop `json:"hops"`
+}
+
+// GraphHop is a single step in a path with every edge connecting its endpoints
+type GraphHop struct {
+	From  string       `json:"from"`
+	To    string       `json:"to"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// Paths returns every simple path following outgoing edges from one node to
+// another, stopping after maxPaths paths (0 = unlimited). Service membership
+// edges are only followed when the target itself is a service.
+func (g *DependencyGraph) Paths(from, to string, maxPaths int) []GraphPath {
+	paths := []GraphPath{}
+	onPath := map[string]bool{from: true}
+	current := []string{from}
+
+	var visit func(id string) bool
+	visit = func(id string) bool {
+		if id == to {
+			paths = append(paths, g.pathFromNodes(current))
+			return maxPaths > 0 && len(paths) >= maxPaths
+		}
+
+		for _, next := range g.successors(id, to) {
+			if onPath[next] {
+				continue
+			}
+			onPath[next] = true
+			current = append(current, next)
+			done := visit(next)
+			current = current[:len(current)-1]
+			onPath[next] = false
+			if done {
+				return true
+			}
+		}
+		return false
+	}
+	visit(from)
+
+	return paths
+}
+
+// successors returns the distinct nodes reachable over one outgoing edge, in stable order
+func (g *DependencyGraph) successors(id, target string) []string {
+	seen := map[string]bool{}
+	var next []string
+	for _, edge := range g.outEdges[id] {
+		if edge.Kind == EdgeMemberOf && edge.To != target {
+			continue
+		}
+		if !seen[edge.To] {
+			seen[edge.To] = true
+			next = append(next, edge.To)
+		}
+	}
+	sort.Strings(next)
+	return next
+}
+
+// pathFromNodes attaches the connecting edges to a sequence of node IDs
+func (g *DependencyGraph) pathFromNodes(nodes []string) GraphPath {
+	path := GraphPath{Nodes: append([]string(nil), nodes...), Hops: []GraphHop{}}
+	for i := 0; i+1 < len(nodes); i++ {
+		hop := GraphHop{From: nodes[i], To: nodes[i+1], Edges: []*GraphEdge{}}
+		for _, edge := range g.outEdges[nodes[i]] {
+			if edge.To == nodes[i+1] {
+				hop.Edges = append(hop.Edges, edge)
+			}
+		}
+		sortEdges(hop.Edges)
+		path.Hops = append(path.Hops, hop)
+	}
+	return path
+}
+
+// Subgraph returns the nodes in ids along with every edge between them
+func (g *DependencyGraph) Subgraph(ids map[string]bool) *GraphExport {
+	export := &GraphExport{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+
+	for id := range ids {
+		if node := g.Nodes[id]; node != nil {
+			export.Nodes = append(export.Nodes, node)
+		}
+	}
+	sortNodes(export.Nodes)
+
+	for id := range ids {
+		for _, edge := range g.outEdges[id] {
+			if ids[edge.To] {
+				export.Edges = append(export.Edges, edge)
+			}
+		}
+	}
+	sortEdges(export.Edges)
+
+	return export
+}
+
+// sortNodes orders nodes by kind then ID for stable output
+func sortNodes(nodes []*GraphNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Kind != nodes[j].Kind {
+			return nodes[i].Kind < nodes[j].Kind
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
+
+// sortEdges orders edges by endpoints, kind, and evidence location for stable output
+func sortEdges(edges []*GraphEdge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ReachableNode is a node returned by a reachability query with its hop count
+type ReachableNode struct {
+	*GraphNode
+	Depth int `json:"depth"`
+}
+
+// NeighborsResult is the output of a neighbors query
+type NeighborsResult struct {
+	Node      *GraphNode   `json:"node"`
+	Edges     []*GraphEdge `json:"edges"`
+	Neighbors []*GraphNode `json:"neighbors"`
+}
+
+// runGraphCommand dispatches the graph subcommands
+func runGraphCommand(args []string) int {
+	if len(args) > 0 && args[0] == "path" {
+		return runGraphPath(args[1:])
+	}
+
+	if len(args) < 2 || args[0] != "query" {
+		if len(args) > 0 && args[0] != "query" && !isHelpFlag(args[0]) {
+			fmt.Fprintf(os.Stderr, "Error: unknown graph command %q\n", args[0])
+		}
+		printGraphUsage(usageOutput(args))
+		return usageStatus(args)
+	}
+
+	switch args[1] {
+	case "neighbors", "reachable", "subgraph":
+		return runGraphQuery(args[1], args[2:])
+	}
+
+	if !isHelpFlag(args[1]) {
+		fmt.Fprintf(os.Stderr, "Error: unknown graph query %q\n", args[1])
+	}
+	printGraphUsage(usageOutput(args[1:]))
+	return usageStatus(args[1:])
+}
+
+func printGraphUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: replicode graph query <neighbors|reachable|subgraph> -dir <path> -node <node> [options]")
+	fmt.Fprintln(w, "       replicode graph path -dir <path> -from <node> -to <node> [options]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Queries:")
+	fmt.Fprintln(w, "  neighbors  Edges directly adjacent to a node")
+	fmt.Fprintln(w, "  reachable  Every node reachable from a node")
+	fmt.Fprintln(w, "  subgraph   The nodes around a node and the edges between them")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Nodes are given as kind-qualified IDs (test:TestAccX_basic, template:XResource.basic,")
+	fmt.Fprintln(w, "resource:azurerm_subnet, service:network) or as bare names when unambiguous.")
+}
+
+// runGraphQuery runs a single graph query and writes the result as JSON
+func runGraphQuery(query string, args []string) int {
+	fs := flag.NewFlagSet("graph query "+query, flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	nodeQuery := fs.String("node", "", "Node to start from (ID or unambiguous name)")
+	directionFlag := fs.String("direction", "", "Edge direction to follow: out, in, or both")
+	depth := fs.Int("depth", -1, "Maximum hops to follow (0 = unlimited)")
+	kind := fs.String("kind", "", "Only return nodes of this kind (test, template, resource, service)")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if *nodeQuery == "" {
+		fmt.Fprintln(os.Stderr, "Error: -node parameter is required")
+		return 1
+	}
+
+	// Defaults differ per query: neighbors/reachable follow dependencies,
+	// subgraph shows the immediate surroundings in both directions
+	defaultDirection, defaultDepth := "out", 0
+	if query == "subgraph" {
+		defaultDirection, defaultDepth = "both", 1
+	}
+	if *directionFlag == "" {
+		*directionFlag = defaultDirection
+	}
+	if *depth < 0 {
+		*depth = defaultDepth
+	}
+
+	direction, err := parseDirection(*directionFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	node, err := graph.ResolveNode(*nodeQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var output interface{}
+	switch query {
+	case "neighbors":
+		edges := graph.Neighbors(node.ID, direction)
+		neighborIDs := make(map[string]bool)
+		for _, edge := range edges {
+			if edge.From != node.ID {
+				neighborIDs[edge.From] = true
+			}
+			if edge.To != node.ID {
+				neighborIDs[edge.To] = true
+			}
+		}
+		neighbors := []*GraphNode{}
+		for id := range neighborIDs {
+			if n := graph.Nodes[id]; n != nil && (*kind == "" || string(n.Kind) == *kind) {
+				neighbors = append(neighbors, n)
+			}
+		}
+		sortNodes(neighbors)
+		if edges == nil {
+			edges = []*GraphEdge{}
+		}
+		output = NeighborsResult{Node: node, Edges: edges, Neighbors: neighbors}
+
+	case "reachable":
+		reachable := []ReachableNode{}
+		for id, hops := range graph.Reachable(node.ID, direction, *depth) {
+			if n := graph.Nodes[id]; n != nil && (*kind == "" || string(n.Kind) == *kind) {
+				reachable = append(reachable, ReachableNode{GraphNode: n, Depth: hops})
+			}
+		}
+		sort.Slice(reachable, func(i, j int) bool {
+			if reachable[i].Depth != reachable[j].Depth {
+				return reachable[i].Depth < reachable[j].Depth
+			}
+			return reachable[i].ID < reachable[j].ID
+		})
+		output = reachable
+
+	case "subgraph":
+		ids := map[string]bool{node.ID: true}
+		for id := range graph.Reachable(node.ID, direction, *depth) {
+			if n := graph.Nodes[id]; n != nil && (*kind == "" || string(n.Kind) == *kind) {
+				ids[id] = true
+			}
+		}
+		output = graph.Subgraph(ids)
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runGraphPath prints every dependency chain connecting two nodes
+func runGraphPath(args []string) int {
+	fs := flag.NewFlagSet("graph path", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	fromQuery := fs.String("from", "", "Node the chains start from (e.g., TestAccVirtualNetwork_basic)")
+	toQuery := fs.String("to", "", "Node the chains end at (e.g., azurerm_subnet)")
+	maxPaths := fs.Int("max-paths", 100, "Stop after this many paths (0 = unlimited)")
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if *fromQuery == "" || *toQuery == "" {
+		fmt.Fprintln(os.Stderr, "Error: -from and -to parameters are required")
+		return 1
+	}
+	if *format != "json" && *format != "text" {
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q (expected json or text)\n", *format)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	from, err := graph.ResolveNode(*fromQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	to, err := graph.ResolveNode(*toQuery)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	paths := graph.Paths(from.ID, to.ID, *maxPaths)
+
+	if *format == "text" {
+		if len(paths) == 0 {
+			fmt.Printf("No path from %s to %s\n", from.ID, to.ID)
+		}
+		for i, path := range paths {
+			fmt.Printf("Path %d:\n", i+1)
+			fmt.Printf("  %s\n", path.Nodes[0])
+			for _, hop := range path.Hops {
+				for _, edge := range hop.Edges {
+					fmt.Printf("    --%s--> %s:%d %s\n", edge.Kind, edge.File, edge.Line, edge.Detail)
+				}
+				fmt.Printf("  %s\n", hop.To)
+			}
+		}
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), paths); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
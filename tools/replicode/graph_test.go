@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// sameNamedTest is a test file declaring TestAccShared_basic, whose template
+// creates the given resource type
+func sameNamedTest(pkg, resource string) string {
+	return `package ` + pkg + `_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+)
+
+type SharedResource struct{}
+
+func TestAccShared_basic(t *testing.T) {
+	data := acceptance.BuildTestData(t, "` + resource + `", "test")
+	r := SharedResource{}
+	data.ResourceTest(t, r, []acceptance.TestStep{{Config: r.basic(data)}})
+}
+
+func (SharedResource) basic(data acceptance.TestData) string {
+	return ` + "`" + `
+resource "` + resource + `" "test" {
+  name = "acctest-%d"
+}
+` + "`" + `
+}
+`
+}
+
+func TestTestResourceClosuresSameNamedTests(t *testing.T) {
+	root := t.TempDir()
+	var results []*analyzer.Result
+	for _, service := range []struct{ pkg, resource string }{{"network", "azurerm_subnet"}, {"compute", "azurerm_virtual_machine"}} {
+		dir := filepath.Join(root, "internal", "services", service.pkg)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "shared_resource_test.go")
+		if err := os.WriteFile(path, []byte(sameNamedTest(service.pkg, service.resource)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := analyzer.Analyze(path, analyzer.Options{RepoRoot: root})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+
+	graph := BuildDependencyGraph(results)
+	closures := graph.TestResourceClosures()
+	if len(closures) != 2 {
+		t.Fatalf("closures = %v, want one per package", closures)
+	}
+	seen := map[string]bool{}
+	for name, closure := range closures {
+		node, err := graph.ResolveNode("test:" + name)
+		if err != nil {
+			t.Fatalf("closure key %q is not a test node: %v", name, err)
+		}
+		want := []string{"azurerm_subnet"}
+		if node.Service == "compute" {
+			want = []string{"azurerm_virtual_machine"}
+		}
+		if !reflect.DeepEqual(closure, want) {
+			t.Errorf("closure of %s = %v, want %v", node.ID, closure, want)
+		}
+		seen[node.Service] = true
+	}
+	if !seen["network"] || !seen["compute"] {
+		t.Errorf("closures cover services %v, want network and compute", seen)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TemplateHotspot captures how heavily a template is depended upon and how much it pulls in
+type TemplateHotspot struct {
+	Template *GraphNode `json:"template"`
+
+	FanIn          int `json:"fan_in"`           // Distinct tests and templates referencing this template directly
+	FanInTests     int `json:"fan_in_tests"`     // Distinct tests referencing it from a TestStep Config
+	FanInTemplates int `json:"fan_in_templates"` // Distinct templates embedding it via fmt.Sprintf
+	CrossServiceIn int `json:"cross_service_in"` // Direct referrers owned by a different service
+	DependentTests int `json:"dependent_tests"`  // Tests depending on it directly or transitively
+
+	FanOut          int `json:"fan_out"`           // Distinct templates and resources referenced directly
+	FanOutTemplates int `json:"fan_out_templates"` // Distinct templates it embeds
+	FanOutResources int `json:"fan_out_resources"` // Distinct resource types in its own HCL
+}
+
+// hotspotSortKeys maps -sort values to the metric used for ranking
+var hotspotSortKeys = map[string]func(h TemplateHotspot) int{
+	"fan-in":          func(h TemplateHotspot) int { return h.FanIn },
+	"fan-out":         func(h TemplateHotspot) int { return h.FanOut },
+	"dependent-tests": func(h TemplateHotspot) int { return h.DependentTests },
+	// Dependent tests weighted by days since the template last changed (requires git metadata)
+	"staleness": func(h TemplateHotspot) int {
+		if h.Template.Git == nil {
+			return 0
+		}
+		return h.DependentTests * h.Template.Git.AgeDays
+	},
+}
+
+// ComputeTemplateHotspots computes fan-in and fan-out for every template,
+// ranked by the given sort key (fan-in, fan-out, dependent-tests, or staleness)
+func ComputeTemplateHotspots(graph *DependencyGraph, sortKey string) ([]TemplateHotspot, error) {
+	metric, ok := hotspotSortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort key %q (expected fan-in, fan-out, dependent-tests, or staleness)", sortKey)
+	}
+
+	hotspots := []TemplateHotspot{}
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTemplate {
+			continue
+		}
+
+		hotspot := TemplateHotspot{Template: node}
+
+		referrers := map[string]bool{}
+		for _, edge := range graph.InEdges(id) {
+			if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+				continue
+			}
+			if referrers[edge.From] {
+				continue
+			}
+			referrers[edge.From] = true
+
+			if edge.Kind == EdgeStepRef {
+				hotspot.FanInTests++
+			} else {
+				hotspot.FanInTemplates++
+			}
+			if from := graph.Nodes[edge.From]; from != nil && from.Service != node.Service {
+				hotspot.CrossServiceIn++
+			}
+		}
+		hotspot.FanIn = len(referrers)
+
+		targets := map[string]bool{}
+		for _, edge := range graph.OutEdges(id) {
+			if edge.Kind != EdgeTemplateCall && edge.Kind != EdgeResourceRef {
+				continue
+			}
+			if targets[edge.To] {
+				continue
+			}
+			targets[edge.To] = true
+
+			if edge.Kind == EdgeTemplateCall {
+				hotspot.FanOutTemplates++
+			} else {
+				hotspot.FanOutResources++
+			}
+		}
+		hotspot.FanOut = len(targets)
+
+		for dependentID := range graph.Reachable(id, DirectionIn, 0) {
+			if dependent := graph.Nodes[dependentID]; dependent != nil && dependent.Kind == NodeTest {
+				hotspot.DependentTests++
+			}
+		}
+
+		hotspots = append(hotspots, hotspot)
+	}
+
+	sort.Slice(hotspots, func(i, j int) bool {
+		a, b := metric(hotspots[i]), metric(hotspots[j])
+		if a != b {
+			return a > b
+		}
+		if hotspots[i].DependentTests != hotspots[j].DependentTests {
+			return hotspots[i].DependentTests > hotspots[j].DependentTests
+		}
+		return hotspots[i].Template.ID < hotspots[j].Template.ID
+	})
+
+	return hotspots, nil
+}
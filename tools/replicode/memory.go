@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// byteSize is a flag.Value holding a size in bytes, written as a number with
+// an optional KB, MB, GB (powers of 1000) or KiB, MiB, GiB (powers of 1024) suffix
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"B", 1},
+}
+
+func (s *byteSize) String() string {
+	if *s == 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	number, unit := strings.TrimSpace(value), int64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(number, u.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid size %q: expected a positive number of bytes, optionally with a KiB, MiB, or GiB suffix", value)
+	}
+	*s = byteSize(n * unit)
+	return nil
+}
+
+// applyMemoryLimit sets the -max-memory target as the runtime's soft memory
+// limit, which is not a cap: as the heap approaches it, the garbage
+// collector runs more often and returns freed memory to the OS sooner, so
+// each file's AST is reclaimed soon after the file is written. Memory the run
+// still needs is never freed, so the limit can be exceeded when it is set
+// below what the retained graph inputs take.
+func applyMemoryLimit(limit byteSize) {
+	if limit > 0 {
+		debug.SetMemoryLimit(int64(limit))
+	}
+}
+
+// graphInputs is the part of a file's result that the consolidated output's
+// cross-file sections need once the file's record has been written: the
+// functions and the references between them, without the step bodies
+func graphInputs(result *analyzer.Result) *analyzer.Result {
+	steps := make([]analyzer.TestStepInfo, len(result.TestSteps))
+	for i, step := range result.TestSteps {
+		step.StepBody = ""
+		steps[i] = step
+	}
+	return &analyzer.Result{
+		FilePath:             result.FilePath,
+		Functions:            result.Functions,
+		TestSteps:            steps,
+		TemplateCalls:        result.TemplateCalls,
+		SequentialReferences: result.SequentialReferences,
+		DirectResourceRefs:   result.DirectResourceRefs,
+		CheckDestroyRefs:     result.CheckDestroyRefs,
+	}
+}
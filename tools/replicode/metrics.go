@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestKey labels a request counter
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+// latencyHistogram is a cumulative Prometheus histogram
+type latencyHistogram struct {
+	counts []int64 // Per bucket in latencyBuckets, not cumulative
+	count  int64
+	sum    float64
+}
+
+// gauge is a value sampled when /metrics is scraped
+type gauge struct {
+	name, help string
+	value      float64
+}
+
+// serverMetrics collects the metrics of a server command and writes them in
+// the Prometheus text exposition format
+type serverMetrics struct {
+	mu              sync.Mutex
+	filesAnalyzed   int64
+	analysisSeconds float64
+	cacheHits       int64
+	cacheMisses     int64
+	requests        map[requestKey]int64
+	latencies       map[string]*latencyHistogram
+	gauges          func() []gauge // Sampled on every scrape
+}
+
+func newServerMetrics(gauges func() []gauge) *serverMetrics {
+	return &serverMetrics{
+		requests:  map[requestKey]int64{},
+		latencies: map[string]*latencyHistogram{},
+		gauges:    gauges,
+	}
+}
+
+// observeAnalysis records files analyzed and the time taken
+func (m *serverMetrics) observeAnalysis(files int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filesAnalyzed += int64(files)
+	m.analysisSeconds += elapsed.Seconds()
+}
+
+// observeCache records an analysis cache lookup
+func (m *serverMetrics) observeCache(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMisses++
+	}
+}
+
+// statusRecorder captures the status code a handler writes
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// instrument counts and times the requests of one endpoint. The endpoint is
+// the route pattern, not the request path, to keep label cardinality fixed.
+func (m *serverMetrics) instrument(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler(recorder, r)
+		elapsed := time.Since(start).Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requests[requestKey{endpoint, recorder.code}]++
+		histogram := m.latencies[endpoint]
+		if histogram == nil {
+			histogram = &latencyHistogram{counts: make([]int64, len(latencyBuckets))}
+			m.latencies[endpoint] = histogram
+		}
+		for i, bound := range latencyBuckets {
+			if elapsed <= bound {
+				histogram.counts[i]++
+				break
+			}
+		}
+		histogram.count++
+		histogram.sum += elapsed
+	}
+}
+
+// ServeHTTP writes the metrics for a Prometheus scrape
+func (m *serverMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	var gauges []gauge
+	if m.gauges != nil {
+		gauges = m.gauges()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.write(w, gauges)
+}
+
+// write renders every metric; m.mu must be held
+func (m *serverMetrics) write(w io.Writer, gauges []gauge) {
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("replicode_files_analyzed_total", "counter", "Go files analyzed, including reloads and cache misses.")
+	fmt.Fprintf(w, "replicode_files_analyzed_total %d\n", m.filesAnalyzed)
+	header("replicode_analysis_seconds_total", "counter", "Time spent analyzing files.")
+	fmt.Fprintf(w, "replicode_analysis_seconds_total %g\n", m.analysisSeconds)
+	header("replicode_analysis_cache_hits_total", "counter", "Single-file analyses answered from the cache.")
+	fmt.Fprintf(w, "replicode_analysis_cache_hits_total %d\n", m.cacheHits)
+	header("replicode_analysis_cache_misses_total", "counter", "Single-file analyses that had to parse the file.")
+	fmt.Fprintf(w, "replicode_analysis_cache_misses_total %d\n", m.cacheMisses)
+
+	for _, g := range gauges {
+		header(g.name, "gauge", g.help)
+		fmt.Fprintf(w, "%s %g\n", g.name, g.value)
+	}
+
+	header("replicode_http_requests_total", "counter", "HTTP requests by endpoint and status code.")
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "replicode_http_requests_total{endpoint=%q,code=\"%d\"} %d\n", key.endpoint, key.code, m.requests[key])
+	}
+
+	header("replicode_http_request_duration_seconds", "histogram", "HTTP request latency by endpoint.")
+	endpoints := make([]string, 0, len(m.latencies))
+	for endpoint := range m.latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		histogram := m.latencies[endpoint]
+		var cumulative int64
+		for i, bound := range latencyBuckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(w, "replicode_http_request_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", endpoint, bound, cumulative)
+		}
+		fmt.Fprintf(w, "replicode_http_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", endpoint, histogram.count)
+		fmt.Fprintf(w, "replicode_http_request_duration_seconds_sum{endpoint=%q} %g\n", endpoint, histogram.sum)
+		fmt.Fprintf(w, "replicode_http_request_duration_seconds_count{endpoint=%q} %d\n", endpoint, histogram.count)
+	}
+}
@@ -0,0 +1,808 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// legacyGenerators maps the calls legacy tests make for random values and
+// locations to the TestData field replacing them
+var legacyGenerators = map[string]string{
+	"tf.AccRandTimeInt":             "data.RandomInteger",
+	"acctest.RandInt":               "data.RandomInteger",
+	"acceptance.RandTimeInt":        "data.RandomInteger",
+	"acctest.RandString":            "data.RandomString",
+	"acctest.RandStringFromCharSet": "data.RandomString",
+	"acceptance.Location":           "data.Locations.Primary",
+	"testLocation":                  "data.Locations.Primary",
+	"acceptance.AltLocation":        "data.Locations.Secondary",
+	"testAltLocation":               "data.Locations.Secondary",
+}
+
+// legacyTestCaseFields are the resource.TestCase fields a migration drops
+// because data.ResourceTest provides them
+var legacyTestCaseFields = map[string]bool{
+	"PreCheck":                 true,
+	"Providers":                true,
+	"ProviderFactories":        true,
+	"ProtoV5ProviderFactories": true,
+	"CheckDestroy":             true,
+	"Steps":                    true,
+}
+
+// legacyTest is one test of a file running resource.Test or
+// resource.ParallelTest directly
+type legacyTest struct {
+	fn           *ast.FuncDecl
+	t            string // Name of the *testing.T parameter
+	resourceType string // e.g., azurerm_virtual_network or data.azurerm_virtual_network
+	label        string
+	structName   string
+	sequential   bool              // resource.Test rather than resource.ParallelTest
+	vars         map[string]string // Setup variable -> the expression replacing it
+	configVars   map[string]*ast.CallExpr
+	steps        *ast.CompositeLit
+}
+
+// legacyConfig is a package-level config function becoming a template method
+type legacyConfig struct {
+	fn         *ast.FuncDecl
+	structName string
+	method     string
+	params     []string // The expression replacing each parameter
+}
+
+// legacyMigration converts the legacy tests of one file, all or none
+type legacyMigration struct {
+	fset    *token.FileSet
+	file    *ast.File
+	src     []byte
+	funcs   map[string]*ast.FuncDecl // Package-level functions of the file
+	pkg     legacyPackage
+	tests   []*legacyTest
+	configs map[string]*legacyConfig
+}
+
+// legacyPackage is what the other files of a test package declare and call
+type legacyPackage struct {
+	structs map[string]bool
+	methods map[string]bool // StructName.method
+	calls   map[string]bool // Package-level functions called
+}
+
+// loadLegacyPackage reads the declarations and calls of the other test files
+// of a package
+func loadLegacyPackage(path string, file *ast.File) legacyPackage {
+	pkg := legacyPackage{structs: map[string]bool{}, methods: map[string]bool{}, calls: map[string]bool{}}
+	add := func(f *ast.File, calls bool) {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						pkg.structs[ts.Name.Name] = true
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv != nil {
+					pkg.methods[receiverTypeName(decl)+"."+decl.Name.Name] = true
+				}
+			}
+		}
+		if calls {
+			ast.Inspect(f, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					if ident, ok := call.Fun.(*ast.Ident); ok {
+						pkg.calls[ident.Name] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	add(file, false)
+	siblings, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.go"))
+	for _, sibling := range siblings {
+		if filepath.Clean(sibling) == filepath.Clean(path) {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), sibling, nil, parser.SkipObjectResolution)
+		if err == nil && f.Name.Name == file.Name.Name {
+			add(f, true)
+		}
+	}
+	return pkg
+}
+
+// migrateLegacyFile converts the tests of one file that run resource.Test or
+// resource.ParallelTest with a resource.TestCase into the acceptance
+// framework's BuildTestData and data.ResourceTest form, and the config
+// functions their steps use into template methods of the resource's struct.
+// A file is converted entirely or not at all; the reason is returned when it
+// is left as it is. It returns the new content, gofmt-formatted, the number
+// of tests converted, and notes on what the converted code still needs.
+func migrateLegacyFile(path string, src []byte) ([]byte, int, []string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("parsing file: %v", err)
+	}
+	m := &legacyMigration{fset: fset, file: file, src: src, funcs: map[string]*ast.FuncDecl{}, configs: map[string]*legacyConfig{}}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			m.funcs[fn.Name.Name] = fn
+		}
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !strings.HasPrefix(fn.Name.Name, "Test") {
+			continue
+		}
+		test, err := m.legacyTest(fn)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("%s: %v", fn.Name.Name, err)
+		}
+		if test != nil {
+			m.tests = append(m.tests, test)
+		}
+	}
+	if len(m.tests) == 0 {
+		return src, 0, nil, nil
+	}
+	if name, ok := importedName(file, acceptanceImportPath); ok && name != "acceptance" {
+		return nil, 0, nil, fmt.Errorf("acceptance is imported as %s", name)
+	}
+	m.pkg = loadLegacyPackage(path, file)
+
+	for _, test := range m.tests {
+		if err := m.planSteps(test); err != nil {
+			return nil, 0, nil, fmt.Errorf("%s: %v", test.fn.Name.Name, err)
+		}
+	}
+	if err := m.checkConfigs(); err != nil {
+		return nil, 0, nil, err
+	}
+
+	var edits []textEdit
+	for _, config := range m.configs {
+		edits = append(edits, m.configEdits(config)...)
+	}
+	for _, test := range m.tests {
+		edits = append(edits, textEdit{
+			start: fset.Position(test.fn.Body.Lbrace).Offset,
+			end:   fset.Position(test.fn.Body.Rbrace).Offset + 1,
+			text:  m.testBody(test),
+		})
+	}
+
+	// Structs the tests need, after the imports
+	var notes []string
+	var missing []string
+	seen := map[string]bool{}
+	for _, test := range m.tests {
+		if seen[test.structName] {
+			continue
+		}
+		seen[test.structName] = true
+		if !m.pkg.structs[test.structName] {
+			missing = append(missing, test.structName)
+		}
+		if !strings.HasPrefix(test.resourceType, "data.") && !m.pkg.methods[test.structName+".Exists"] {
+			notes = append(notes, fmt.Sprintf("%s needs an Exists method for data.ResourceTest", test.structName))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		offset := fset.Position(file.Name.End()).Offset
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				offset = fset.Position(gen.End()).Offset
+			}
+		}
+		var b strings.Builder
+		for _, name := range missing {
+			fmt.Fprintf(&b, "\n\ntype %s struct{}", name)
+		}
+		edits = append(edits, textEdit{start: offset, end: offset, text: b.String()})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	out, err := fixLegacyImports(path, applyEdits(src, edits))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return out, len(m.tests), notes, nil
+}
+
+// legacyTest returns the legacy form of a test, or nil when the test does
+// not run resource.Test or resource.ParallelTest
+func (m *legacyMigration) legacyTest(fn *ast.FuncDecl) (*legacyTest, error) {
+	if len(fn.Body.List) == 0 {
+		return nil, nil
+	}
+	stmt, ok := fn.Body.List[len(fn.Body.List)-1].(*ast.ExprStmt)
+	if !ok {
+		return nil, nil
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return nil, nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isIdent(sel.X, "resource") || (sel.Sel.Name != "Test" && sel.Sel.Name != "ParallelTest") {
+		return nil, nil
+	}
+	testCase, ok := call.Args[1].(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("the resource.TestCase is not a literal")
+	}
+	test := &legacyTest{fn: fn, t: testingParam(fn.Type), sequential: sel.Sel.Name == "Test", vars: map[string]string{}, configVars: map[string]*ast.CallExpr{}}
+	if test.t == "" || !isIdent(call.Args[0], test.t) {
+		return nil, fmt.Errorf("resource.%s is not called with the test's *testing.T", sel.Sel.Name)
+	}
+
+	// Setup statements
+	fields := map[string]string{}
+	for _, stmt := range fn.Body.List[:len(fn.Body.List)-1] {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			return nil, fmt.Errorf("line %d is not a setup assignment", m.fset.Position(stmt.Pos()).Line)
+		}
+		name := assign.Lhs[0].(*ast.Ident).Name
+		switch rhs := assign.Rhs[0].(type) {
+		case *ast.BasicLit:
+			value, err := strconv.Unquote(rhs.Value)
+			dot := strings.LastIndex(value, ".")
+			if rhs.Kind != token.STRING || err != nil || dot < 0 || !strings.HasPrefix(strings.TrimPrefix(value, "data."), "azurerm_") {
+				return nil, fmt.Errorf("%s is not a resource address", name)
+			}
+			if test.resourceType != "" {
+				return nil, fmt.Errorf("the test refers to several resources")
+			}
+			test.resourceType, test.label = value[:dot], value[dot+1:]
+			test.vars[name] = "data.ResourceName"
+		case *ast.CallExpr:
+			if field := legacyGenerator(m.src, m.fset, rhs); field != "" {
+				if other, ok := fields[field]; ok {
+					return nil, fmt.Errorf("%s and %s would both become %s", other, name, field)
+				}
+				fields[field] = name
+				test.vars[name] = field
+			} else if ident, ok := rhs.Fun.(*ast.Ident); ok && m.funcs[ident.Name] != nil {
+				test.configVars[name] = rhs
+			} else {
+				return nil, fmt.Errorf("%s is not a random value, location, or config", name)
+			}
+		default:
+			return nil, fmt.Errorf("%s is not a random value, location, or config", name)
+		}
+	}
+	if test.resourceType == "" {
+		return nil, fmt.Errorf("no resource address variable")
+	}
+	test.structName = legacyStructName(test.resourceType)
+
+	for _, elt := range testCase.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, fmt.Errorf("the resource.TestCase has unkeyed fields")
+		}
+		key, _ := kv.Key.(*ast.Ident)
+		if key == nil || !legacyTestCaseFields[key.Name] {
+			return nil, fmt.Errorf("the resource.TestCase sets %s", m.text(kv.Key))
+		}
+		if key.Name == "Steps" {
+			if test.steps, ok = kv.Value.(*ast.CompositeLit); !ok {
+				return nil, fmt.Errorf("the steps are not a literal")
+			}
+		}
+	}
+	if test.steps == nil {
+		return nil, fmt.Errorf("the resource.TestCase has no steps")
+	}
+	return test, nil
+}
+
+// legacyGenerator returns the TestData field replacing a call for a random
+// value or location, or ""
+func legacyGenerator(src []byte, fset *token.FileSet, call *ast.CallExpr) string {
+	fun := string(src[fset.Position(call.Fun.Pos()).Offset:fset.Position(call.Fun.End()).Offset])
+	return legacyGenerators[fun]
+}
+
+// legacyStructName returns the conventional struct of a resource type:
+// azurerm_virtual_network is VirtualNetworkResource and
+// data.azurerm_virtual_network VirtualNetworkDataSource
+func legacyStructName(resourceType string) string {
+	suffix := "Resource"
+	if strings.HasPrefix(resourceType, "data.") {
+		resourceType, suffix = strings.TrimPrefix(resourceType, "data."), "DataSource"
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(strings.TrimPrefix(resourceType, "azurerm_"), "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String() + suffix
+}
+
+// planSteps assigns the config functions a test's steps use to the test's
+// struct
+func (m *legacyMigration) planSteps(test *legacyTest) error {
+	for _, elt := range test.steps.Elts {
+		step, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return fmt.Errorf("a step is not a literal")
+		}
+		config := configValue(step)
+		if config == nil {
+			continue
+		}
+		call := test.configVars[identName(config)]
+		if call == nil {
+			call, _ = config.(*ast.CallExpr)
+		}
+		if call == nil {
+			return fmt.Errorf("a step's Config is not a call of a config function")
+		}
+		if err := m.useConfig(test, call); err != nil {
+			return err
+		}
+	}
+	for name, call := range test.configVars {
+		if err := m.useConfig(test, call); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// useConfig assigns the config function a test calls, with arguments the test
+// setup provides
+func (m *legacyMigration) useConfig(test *legacyTest, call *ast.CallExpr) error {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok || m.funcs[ident.Name] == nil {
+		return fmt.Errorf("%s is not a config function of the file", m.text(call.Fun))
+	}
+	params := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		if params[i] = test.vars[identName(arg)]; params[i] == "" {
+			if inner, ok := arg.(*ast.CallExpr); ok {
+				params[i] = legacyGenerator(m.src, m.fset, inner)
+			}
+		}
+		if params[i] == "" || params[i] == "data.ResourceName" {
+			return fmt.Errorf("argument %s of %s is not a random value or location", m.text(arg), ident.Name)
+		}
+	}
+	return m.assignConfig(ident.Name, test.structName, params)
+}
+
+// assignConfig makes a config function a template method of a struct, and
+// the config functions it calls methods of the same struct unless they are
+// already assigned
+func (m *legacyMigration) assignConfig(name, structName string, params []string) error {
+	fn := m.funcs[name]
+	if config, ok := m.configs[name]; ok {
+		if strings.Join(config.params, ",") != strings.Join(params, ",") {
+			return fmt.Errorf("%s is called with different arguments", name)
+		}
+		return nil
+	}
+	var names []string
+	if fn.Type.Params != nil {
+		for _, field := range fn.Type.Params.List {
+			for _, param := range field.Names {
+				names = append(names, param.Name)
+			}
+		}
+	}
+	results := fn.Type.Results
+	if len(names) != len(params) || results == nil || len(results.List) != 1 || !isIdent(results.List[0].Type, "string") {
+		return fmt.Errorf("%s is not a config function returning a string", name)
+	}
+	underscore := strings.LastIndex(name, "_")
+	if underscore < 0 || underscore == len(name)-1 {
+		return fmt.Errorf("%s has no _method suffix to name the template", name)
+	}
+	config := &legacyConfig{fn: fn, structName: structName, method: name[underscore+1:], params: params}
+	m.configs[name] = config
+
+	byParam := map[string]string{}
+	for i, param := range names {
+		byParam[param] = params[i]
+	}
+	var err error
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.Ident:
+			if (n.Name == "data" || n.Name == "r") && byParam[n.Name] == "" {
+				err = fmt.Errorf("%s uses the name %s", name, n.Name)
+			}
+		case *ast.CallExpr:
+			ident, ok := n.Fun.(*ast.Ident)
+			if !ok || m.funcs[ident.Name] == nil || ident.Name == name {
+				return true
+			}
+			args := make([]string, len(n.Args))
+			for i, arg := range n.Args {
+				if args[i] = byParam[identName(arg)]; args[i] == "" {
+					err = fmt.Errorf("%s calls %s with %s, which is not one of its parameters", name, ident.Name, m.text(arg))
+					return false
+				}
+			}
+			err = m.assignConfig(ident.Name, structName, args)
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// checkConfigs rejects config functions that are also called from code the
+// migration leaves alone, and template names taken twice
+func (m *legacyMigration) checkConfigs() error {
+	migrated := map[ast.Node]bool{}
+	for _, test := range m.tests {
+		migrated[test.fn] = true
+	}
+	for _, config := range m.configs {
+		migrated[config.fn] = true
+	}
+	var err error
+	for _, decl := range m.file.Decls {
+		if migrated[decl] {
+			continue
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok && err == nil {
+				if ident, ok := call.Fun.(*ast.Ident); ok && m.configs[ident.Name] != nil {
+					err = fmt.Errorf("%s is also called by %s", ident.Name, declName(decl))
+				}
+			}
+			return err == nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(m.configs))
+	for name := range m.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	methods := map[string]string{}
+	for _, name := range names {
+		config := m.configs[name]
+		if m.pkg.calls[name] {
+			return fmt.Errorf("%s is also called from another file of the package", name)
+		}
+		method := config.structName + "." + config.method
+		if other, ok := methods[method]; ok {
+			return fmt.Errorf("%s and %s would both become %s", other, name, method)
+		}
+		if m.pkg.methods[method] {
+			return fmt.Errorf("%s already exists", method)
+		}
+		methods[method] = name
+	}
+	return nil
+}
+
+// configEdits turns a config function into a template method
+func (m *legacyMigration) configEdits(config *legacyConfig) []textEdit {
+	fn := config.fn
+	byParam := map[string]string{}
+	i := 0
+	for _, field := range fn.Type.Params.List {
+		for _, param := range field.Names {
+			byParam[param.Name] = config.params[i]
+			i++
+		}
+	}
+	body := m.rewrite(fn.Body, byParam, config.structName, "")
+	receiver := config.structName
+	if strings.Contains(body, "r.") {
+		receiver = "r " + config.structName
+	}
+	return []textEdit{{
+		start: m.fset.Position(fn.Pos()).Offset,
+		end:   m.fset.Position(fn.End()).Offset,
+		text:  fmt.Sprintf("func (%s) %s(data acceptance.TestData) string %s", receiver, config.method, body),
+	}}
+}
+
+// testBody returns the converted body of a test
+func (m *legacyMigration) testBody(test *legacyTest) string {
+	vars := map[string]string{}
+	for name, field := range test.vars {
+		vars[name] = field
+	}
+	for name, call := range test.configVars {
+		vars[name] = m.configCall(m.configs[call.Fun.(*ast.Ident).Name], test.structName)
+	}
+	steps := m.rewrite(test.steps, vars, test.structName, "data.ResourceName")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "{\n\tdata := acceptance.BuildTestData(%s, %q, %q)\n", test.t, test.resourceType, test.label)
+	switch {
+	case strings.HasPrefix(test.resourceType, "data."):
+		if strings.Contains(steps, "r.") {
+			fmt.Fprintf(&b, "\tr := %s{}\n", test.structName)
+		}
+		fmt.Fprintf(&b, "\n\tdata.DataSourceTest(%s, %s)\n}", test.t, steps)
+	case test.sequential:
+		fmt.Fprintf(&b, "\tr := %s{}\n\n\tdata.ResourceSequentialTest(%s, r, %s)\n}", test.structName, test.t, steps)
+	default:
+		fmt.Fprintf(&b, "\tr := %s{}\n\n\tdata.ResourceTest(%s, r, %s)\n}", test.structName, test.t, steps)
+	}
+	return b.String()
+}
+
+// configCall returns the call of a template method from code of a struct
+func (m *legacyMigration) configCall(config *legacyConfig, from string) string {
+	if config.structName == from {
+		return "r." + config.method + "(data)"
+	}
+	return config.structName + "{}." + config.method + "(data)"
+}
+
+// rewrite returns the source of a node with identifiers replaced by the
+// expressions in vars, config function calls by template method calls, and,
+// in test steps (where resource names the resource address expression),
+// resource.TestStep, resource checks, and import steps in the acceptance
+// framework's form
+func (m *legacyMigration) rewrite(node ast.Node, vars map[string]string, structName, resource string) string {
+	start := m.fset.Position(node.Pos()).Offset
+	var edits []textEdit
+	replace := func(n ast.Node, text string) {
+		edits = append(edits, textEdit{start: m.fset.Position(n.Pos()).Offset - start, end: m.fset.Position(n.End()).Offset - start, text: text})
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if text, ok := vars[n.Name]; ok {
+				replace(n, text)
+			}
+		case *ast.SelectorExpr:
+			if resource != "" && isIdent(n.X, "resource") {
+				switch n.Sel.Name {
+				case "TestStep", "ComposeTestCheckFunc", "ComposeAggregateTestCheckFunc":
+					replace(n, "acceptance."+n.Sel.Name)
+				}
+			}
+			return false
+		case *ast.CompositeLit:
+			if resource != "" && n != node {
+				if step := m.importStep(n, vars, resource); step != "" {
+					replace(n, step)
+					return false
+				}
+			}
+		case *ast.CallExpr:
+			if ident, ok := n.Fun.(*ast.Ident); ok && m.configs[ident.Name] != nil {
+				replace(n, m.configCall(m.configs[ident.Name], structName))
+				return false
+			}
+			if resource != "" {
+				if text := m.resourceCheck(n, vars, structName, resource); text != "" {
+					replace(n, text)
+					return false
+				}
+			}
+		}
+		return true
+	})
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	return string(applyEdits(m.src[start:m.fset.Position(node.End()).Offset], edits))
+}
+
+// importStep returns data.ImportStep for a step that only imports and
+// verifies the resource, or ""
+func (m *legacyMigration) importStep(lit *ast.CompositeLit, vars map[string]string, resource string) string {
+	var ignored []string
+	importState, verify := false, false
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return ""
+		}
+		switch identName(kv.Key) {
+		case "ResourceName":
+			if vars[identName(kv.Value)] != resource {
+				return ""
+			}
+		case "ImportState":
+			importState = isIdent(kv.Value, "true")
+		case "ImportStateVerify":
+			verify = isIdent(kv.Value, "true")
+		case "ImportStateVerifyIgnore":
+			values, ok := kv.Value.(*ast.CompositeLit)
+			if !ok {
+				return ""
+			}
+			for _, value := range values.Elts {
+				ignored = append(ignored, m.rewrite(value, vars, "", ""))
+			}
+		default:
+			return ""
+		}
+	}
+	if !importState || !verify {
+		return ""
+	}
+	return "data.ImportStep(" + strings.Join(ignored, ", ") + ")"
+}
+
+// resourceCheck returns the check.That form of a check of the resource, or ""
+func (m *legacyMigration) resourceCheck(call *ast.CallExpr, vars map[string]string, structName, resource string) string {
+	if len(call.Args) == 0 || vars[identName(call.Args[0])] != resource {
+		return ""
+	}
+	args := make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = m.rewrite(arg, vars, structName, "")
+	}
+	that := "check.That(" + resource + ")"
+	if ident, ok := call.Fun.(*ast.Ident); ok && len(args) == 1 {
+		if strings.HasPrefix(ident.Name, "testCheck") && strings.HasSuffix(ident.Name, "Exists") && m.pkg.methods[structName+".Exists"] {
+			return that + ".ExistsInAzure(r)"
+		}
+		return ""
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isIdent(sel.X, "resource") {
+		return ""
+	}
+	switch {
+	case sel.Sel.Name == "TestCheckResourceAttr" && len(args) == 3:
+		return fmt.Sprintf("%s.Key(%s).HasValue(%s)", that, args[1], args[2])
+	case sel.Sel.Name == "TestCheckResourceAttrSet" && len(args) == 2:
+		return fmt.Sprintf("%s.Key(%s).Exists()", that, args[1])
+	case sel.Sel.Name == "TestCheckNoResourceAttr" && len(args) == 2:
+		return fmt.Sprintf("%s.Key(%s).DoesNotExist()", that, args[1])
+	}
+	return ""
+}
+
+// text returns the source of a node
+func (m *legacyMigration) text(n ast.Node) string {
+	return string(m.src[m.fset.Position(n.Pos()).Offset:m.fset.Position(n.End()).Offset])
+}
+
+// identName returns the name of an identifier expression, or ""
+func identName(expr ast.Expr) string {
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// declName names a top-level declaration for messages
+func declName(decl ast.Decl) string {
+	if fn, ok := decl.(*ast.FuncDecl); ok {
+		if name := receiverTypeName(fn); name != "" {
+			return name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+	return "a declaration"
+}
+
+// fixLegacyImports adds the acceptance and check imports a migrated file
+// uses, drops the ones it no longer does, and formats it
+func fixLegacyImports(path string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing migrated file: %v", err)
+	}
+	used := usedImports(file.Decls)
+	var add []string
+	if _, ok := importedName(file, acceptanceImportPath); !ok && used["acceptance"] {
+		add = append(add, acceptanceImportPath)
+	}
+	if _, ok := importedName(file, checkImportPath); !ok && used["check"] {
+		add = append(add, checkImportPath)
+	}
+	edits := append(importEdits(fset, file, src, add...), pruneImports(fset, file, src, used)...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	out, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return nil, fmt.Errorf("formatting migrated file: %v", err)
+	}
+	return out, nil
+}
+
+// runMigrateLegacyCommand converts resource.TestCase tests into the
+// acceptance framework's form, printing the changes as a patch or writing
+// them to the files
+func runMigrateLegacyCommand(args []string) int {
+	fs := flag.NewFlagSet("migrate-legacy", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Directory to rewrite recursively (e.g., internal/services)")
+	repoRoot := fs.String("reporoot", "", "Repository root the patch paths are relative to (defaults to the module or repository containing -dir)")
+	output := newCodemodOutput(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+	root := *repoRoot
+	if root == "" {
+		root = defaultRepoRoot(*dir)
+	}
+	if err := output.start(root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	stamps, err := scanTestFiles(*dir, analyzer.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	files := make([]string, 0, len(stamps))
+	for path := range stamps {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	migrated, left, changedFiles := 0, 0, 0
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		if !bytes.Contains(src, []byte("resource.TestCase{")) {
+			continue
+		}
+		rel, err := analyzer.RelativePath(root, path)
+		if err != nil {
+			rel = filepath.ToSlash(path)
+		}
+		out, tests, notes, err := migrateLegacyFile(path, src)
+		if err != nil {
+			left++
+			output.left("Left %s: %v", rel, err)
+			continue
+		}
+		for _, note := range notes {
+			fmt.Fprintf(os.Stderr, "Note: %s: %s\n", rel, note)
+		}
+		if bytes.Equal(out, src) {
+			continue
+		}
+		migrated += tests
+		changedFiles++
+		if err := output.apply(path, rel, src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Migrated"
+	if !output.writing() {
+		verb = "Would migrate"
+	}
+	return output.finish("%s %d tests in %d files; left %d files as they are", verb, migrated, changedFiles, left)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// templateMove moves one template method to a struct of another service and
+// points the references the analysis records at it
+type templateMove struct {
+	structName, method string // Template being moved
+	target, service    string // Struct and service it moves to
+	files              *sourceFiles
+	from               *sourceFile   // File declaring the template
+	decl               *ast.FuncDecl // The template's declaration
+	dest               *sourceFile   // File the template moves to
+	handled            map[*ast.SelectorExpr]bool
+	references         int
+	missing            []string // Recorded references not found in the source
+	warnings           []string
+}
+
+// destination returns the file of the target struct's last template in the
+// service, which the moved template follows
+func destination(results []*analyzer.Result, target, service string) (string, int) {
+	file, line := "", 0
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if fn.IsTestFunc || fn.ReceiverType != target || fn.ServiceName != service {
+				continue
+			}
+			if file == "" || fn.File < file || (fn.File == file && fn.Line > line) {
+				file, line = fn.File, fn.Line
+			}
+		}
+	}
+	return file, line
+}
+
+// cut removes the template from its file and inserts it after the target
+// struct's method declared at line in the destination, with the receiver's
+// type replaced and calls through the receiver to the templates it leaves
+// behind made through a StructName{} literal
+func (m *templateMove) cut(node *GraphNode, destRel string, destLine int) error {
+	var err error
+	if m.from, err = m.files.open(node.File); err != nil {
+		return err
+	}
+	m.decl = findFunc(m.from.file, m.method, m.structName)
+	if m.decl == nil {
+		return fmt.Errorf("declaration of %s not found in %s", node.Name, node.File)
+	}
+	if m.dest, err = m.files.open(destRel); err != nil {
+		return err
+	}
+	var after *ast.FuncDecl
+	for _, decl := range m.dest.file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && receiverTypeName(fn) == m.target && m.dest.fset.Position(fn.Pos()).Line == destLine {
+			after = fn
+		}
+	}
+	if after == nil {
+		return fmt.Errorf("declaration of %s at %s:%d not found", m.target, destRel, destLine)
+	}
+
+	// Rewrite the declaration's text
+	start := m.from.offset(declStart(m.decl))
+	var edits []textEdit
+	recv := m.decl.Recv.List[0]
+	typ := recv.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	edits = append(edits, textEdit{start: m.from.offset(typ.Pos()) - start, end: m.from.offset(typ.End()) - start, text: m.target})
+	if len(recv.Names) == 1 && recv.Names[0].Name != "_" {
+		receiver := recv.Names[0].Name
+		ast.Inspect(m.decl.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if ident, isIdent := sel.X.(*ast.Ident); !ok || !isIdent || ident.Name != receiver || sel.Sel.Name == m.method {
+				return true
+			}
+			if m.target == m.structName {
+				m.warnings = append(m.warnings, fmt.Sprintf("moved template calls %s.%s, which resolves to the %s of service %s", m.structName, sel.Sel.Name, m.target, m.service))
+				return true
+			}
+			edits = append(edits, textEdit{start: m.from.offset(sel.X.Pos()) - start, end: m.from.offset(sel.X.End()) - start, text: m.structName + "{}"})
+			return true
+		})
+	}
+	text := applyEdits(m.from.src[start:m.from.offset(m.decl.End())], edits)
+
+	m.from.edit(lineEdit(m.from.src, start, m.from.offset(m.decl.End())))
+	insert := m.dest.offset(after.End())
+	m.dest.edit(textEdit{start: insert, end: insert, text: "\n\n" + string(text)})
+
+	// Imports the template uses that the destination lacks
+	var missing []string
+	for name := range usedImports([]ast.Decl{m.decl}) {
+		for _, spec := range m.from.file.Imports {
+			if importSpecName(spec) != name {
+				continue
+			}
+			p, _ := strconv.Unquote(spec.Path.Value)
+			if destName, imported := importedName(m.dest.file, p); imported && destName == name {
+				continue
+			}
+			if spec.Name != nil {
+				return fmt.Errorf("template uses %s, imported under the alias %s", p, name)
+			}
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	for _, e := range importEdits(m.dest.fset, m.dest.file, m.dest.src, missing...) {
+		m.dest.edit(e)
+	}
+
+	// Imports only the template used
+	var remaining []ast.Decl
+	for _, decl := range m.from.file.Decls {
+		if decl != m.decl {
+			remaining = append(remaining, decl)
+		}
+	}
+	for _, e := range pruneImports(m.from.fset, m.from.file, m.from.src, usedImports(remaining)) {
+		m.from.edit(e)
+	}
+	return nil
+}
+
+// retarget points the call an edge records at the target struct: through the
+// enclosing method's receiver when it is a method of the target struct in
+// the destination package, or a TargetStruct{} literal otherwise
+func (m *templateMove) retarget(edge *GraphEdge) error {
+	f, err := m.files.open(edge.File)
+	if err != nil {
+		return err
+	}
+	if f == m.from && edge.Line >= f.fset.Position(m.decl.Pos()).Line && edge.Line <= f.fset.Position(m.decl.End()).Line {
+		return nil // A call within the template moves with it
+	}
+
+	fn, sels := f.recordedCalls(edge, m.method)
+	if len(sels) == 0 {
+		m.missing = append(m.missing, fmt.Sprintf("%s:%d: %s", edge.File, edge.Line, edge.Detail))
+		return nil
+	}
+	replacement := m.target + "{}"
+	if receiverTypeName(fn) == m.target && path.Dir(f.rel) == path.Dir(m.dest.rel) {
+		if names := fn.Recv.List[0].Names; len(names) == 1 && names[0].Name != "_" {
+			replacement = names[0].Name
+		}
+	}
+	for _, sel := range sels {
+		m.handled[sel] = true
+		if current := string(f.src[f.offset(sel.X.Pos()):f.offset(sel.X.End())]); current != replacement {
+			f.edit(textEdit{start: f.offset(sel.X.Pos()), end: f.offset(sel.X.End()), text: replacement})
+		}
+	}
+	m.references++
+	return nil
+}
+
+// output returns a file's new content, formatted when the template moved out
+// of or into it
+func (m *templateMove) output(f *sourceFile) ([]byte, error) {
+	out := f.output()
+	if f == m.from || f == m.dest {
+		formatted, err := format.Source(out)
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %v", f.rel, err)
+		}
+		return formatted, nil
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), f.path, out, parser.ParseComments); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", f.rel, err)
+	}
+	return out, nil
+}
+
+// runMoveTemplateCommand moves a template method to another service and
+// updates the call sites the analysis records, printing the changes as a
+// patch or writing them to the files
+func runMoveTemplateCommand(args []string) int {
+	fs := flag.NewFlagSet("move-template", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	template := fs.String("template", "", "Template to move (e.g., VirtualNetworkResource.basic)")
+	service := fs.String("service", "", "Service to move the template to (e.g., network)")
+	target := fs.String("struct", "", "Struct of the service to move the template to (default: the template's struct)")
+	output := newCodemodOutput(fs)
+	force := fs.Bool("force", false, "With -write, move even when calls the analysis does not record are left behind")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *template == "" || *service == "" {
+		fmt.Fprintln(os.Stderr, "Error: -template and -service parameters are required")
+		return 1
+	}
+
+	if err := output.start(source.root()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+	node, err := graph.ResolveNode(*template)
+	if err != nil || node.Kind != NodeTemplate {
+		fmt.Fprintf(os.Stderr, "Error: template %q not found\n", *template)
+		return 1
+	}
+	structName, method, _ := strings.Cut(node.Name, ".")
+	if *target == "" {
+		*target = structName
+	}
+	if node.Service == *service && *target == structName {
+		fmt.Fprintf(os.Stderr, "Error: %s is already in service %s\n", node.Name, *service)
+		return 1
+	}
+	destRel, destLine := destination(results, *target, *service)
+	if destRel == "" {
+		fmt.Fprintf(os.Stderr, "Error: service %s has no templates of %s to move %s next to\n", *service, *target, node.Name)
+		return 1
+	}
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if !fn.IsTestFunc && fn.ReceiverType == *target && fn.ServiceName == *service && fn.FunctionName == method {
+				fmt.Fprintf(os.Stderr, "Error: %s of service %s already has a method named %s\n", *target, *service, method)
+				return 1
+			}
+		}
+	}
+
+	move := &templateMove{
+		structName: structName, method: method, target: *target, service: *service,
+		files: newSourceFiles(source.root()), handled: map[*ast.SelectorExpr]bool{},
+	}
+	if err := move.cut(node, destRel, destLine); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, edge := range graph.InEdges(node.ID) {
+		if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+			continue
+		}
+		if err := move.retarget(edge); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	stamps, err := scanTestFiles(source.Dir, source.walkOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	paths := make([]string, 0, len(stamps))
+	for p := range stamps {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	unrecorded, err := unrecordedCalls(move.files, paths, structName, method, node.File, move.handled)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, warning := range move.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	for _, reference := range move.missing {
+		fmt.Fprintf(os.Stderr, "Warning: recorded reference not found in the source: %s\n", reference)
+	}
+	for _, reference := range unrecorded {
+		output.left("Left %s (not recorded by the analysis)", reference)
+	}
+	if output.writing() && len(unrecorded) > 0 && !*force {
+		fmt.Fprintf(os.Stderr, "Error: moving would leave %d calls of %s behind; update them by hand or pass -force\n", len(unrecorded), node.Name)
+		return 1
+	}
+
+	edited := move.files.edited()
+	for _, f := range edited {
+		out, err := move.output(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := output.apply(f.path, f.rel, f.src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Moved"
+	if !output.writing() {
+		verb = "Would move"
+	}
+	return output.finish("%s %s (%s) to %s.%s (%s) with %d references in %d files; left %d unrecorded calls",
+		verb, node.Name, node.Service, *target, method, *service, move.references, len(edited), len(unrecorded))
+}
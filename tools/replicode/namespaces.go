@@ -0,0 +1,24 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// ResourcesInNamespaces returns the resource types in the graph served by any
+// of the namespaces (compared case-insensitively, as ARM does)
+func (g *DependencyGraph) ResourcesInNamespaces(namespaces []string) []string {
+	wanted := map[string]bool{}
+	for _, namespace := range namespaces {
+		wanted[strings.ToLower(namespace)] = true
+	}
+
+	var resources []string
+	for _, node := range g.Nodes {
+		if node.Kind == NodeResource && wanted[strings.ToLower(node.Namespace)] {
+			resources = append(resources, node.Name)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// TestCoverageGap is a test function the extractor found nothing inside of, or that nothing runs
+type TestCoverageGap struct {
+	FunctionName string `json:"function_name"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+	Service      string `json:"service"`
+}
+
+// OrphanReport lists test functions that produce no dependency data
+type OrphanReport struct {
+	// StepLess are test functions with zero analyzer.TestStepInfo records and zero
+	// sequential references: either broken tests or patterns the analyzer
+	// doesn't understand yet
+	StepLess []TestCoverageGap `json:"step_less"`
+
+	// Orphaned are unexported testAcc* functions that no sequential entry
+	// point references, so `go test` never runs them
+	Orphaned []TestCoverageGap `json:"orphaned"`
+}
+
+// FindOrphanTests finds step-less and orphaned test functions across all results
+func FindOrphanTests(results []*analyzer.Result) *OrphanReport {
+	hasSteps := map[string]bool{}
+	hasSequentialRefs := map[string]bool{}
+	isReferenced := map[string]bool{}
+
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			hasSteps[step.SourceFunction] = true
+		}
+		for _, seq := range result.SequentialReferences {
+			hasSequentialRefs[seq.EntryPointFunction] = true
+			isReferenced[seq.ReferencedFunction] = true
+		}
+	}
+
+	report := &OrphanReport{
+		StepLess: []TestCoverageGap{},
+		Orphaned: []TestCoverageGap{},
+	}
+
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if !fn.IsTestFunc {
+				continue
+			}
+
+			gap := TestCoverageGap{
+				FunctionName: fn.FunctionName,
+				File:         fn.File,
+				Line:         fn.Line,
+				Service:      fn.ServiceName,
+			}
+
+			if !hasSteps[fn.FunctionName] && !hasSequentialRefs[fn.FunctionName] {
+				report.StepLess = append(report.StepLess, gap)
+			}
+			if strings.HasPrefix(fn.FunctionName, "testAcc") && !isReferenced[fn.FunctionName] {
+				report.Orphaned = append(report.Orphaned, gap)
+			}
+		}
+	}
+
+	sortCoverageGaps(report.StepLess)
+	sortCoverageGaps(report.Orphaned)
+
+	return report
+}
+
+// sortCoverageGaps orders gaps by file then line for stable output
+func sortCoverageGaps(gaps []TestCoverageGap) {
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].File != gaps[j].File {
+			return gaps[i].File < gaps[j].File
+		}
+		return gaps[i].Line < gaps[j].Line
+	})
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// pendingOutput is the -o file of this run while the command writes it: the
+// temporary file standing in for stdout, and the path it becomes ("" when
+// -strict-stdout buffers stdout itself). documents counts the JSON documents
+// written to stdout, which -strict-stdout checks the output against.
+var pendingOutput struct {
+	path      string
+	temp      *os.File
+	stdout    *os.File
+	strict    bool
+	documents int
+}
+
+// outputFlags are the flags every command gets for where its output goes
+type outputFlags struct {
+	path   *string
+	mkdir  *bool
+	strict *bool
+}
+
+// registerOutputFlags adds -o, -mkdir, and -strict-stdout to a command's flags
+func registerOutputFlags(fs *flag.FlagSet) outputFlags {
+	return outputFlags{
+		path:   fs.String("o", "", "Write the output to this file instead of stdout, replacing it only once the command succeeds"),
+		mkdir:  fs.Bool("mkdir", false, "With -o, create the missing parent directories of the output file"),
+		strict: fs.Bool("strict-stdout", false, "Hold stdout until the command finishes, then write it only if the command succeeded and a JSON output is exactly one JSON document; logs and errors go to stderr alone"),
+	}
+}
+
+// start sends stdout where the flags direct it: to the -o file, or with
+// -strict-stdout alone to a buffer released when the command finishes
+func (f outputFlags) start() error {
+	switch {
+	case *f.path != "":
+		if err := startOutput(*f.path, *f.mkdir); err != nil {
+			return err
+		}
+	case *f.strict:
+		temp, err := os.CreateTemp("", "replicode-stdout-*.tmp")
+		if err != nil {
+			return fmt.Errorf("buffering stdout: %v", err)
+		}
+		holdStdout("", temp)
+	}
+	pendingOutput.strict = *f.strict
+	return nil
+}
+
+// startOutput sends stdout to a temporary file next to path, so that the
+// rename in finishOutput is atomic: a reader of path sees the previous
+// output or the complete new one, never a truncated document from an
+// interrupted run.
+func startOutput(path string, mkdir bool) error {
+	dir := filepath.Dir(path)
+	if mkdir {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating output directory: %v", err)
+		}
+	}
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating output file: %v", err)
+	}
+	holdStdout(path, temp)
+	return nil
+}
+
+// holdStdout points stdout at temp until finishOutput
+func holdStdout(path string, temp *os.File) {
+	pendingOutput.path, pendingOutput.temp, pendingOutput.stdout = path, temp, os.Stdout
+	os.Stdout = temp
+
+	// An interrupted run leaves path as it was, without the temporary file
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		temp.Close()
+		os.Remove(temp.Name())
+		os.Exit(130)
+	}()
+}
+
+// countDocument records a JSON document written to stdout
+func countDocument(w io.Writer) {
+	if f, ok := w.(*os.File); ok && f == os.Stdout {
+		pendingOutput.documents++
+	}
+}
+
+// finishOutput completes the held stdout of a command that exited with code:
+// discarded after an error, otherwise renamed into place as the -o file or
+// copied to stdout, including when a gate failed (the output is complete).
+// With -strict-stdout, output holding a JSON document must be exactly that
+// document, so that stray text on stdout fails the run instead of corrupting
+// what a caller parses. It returns the exit code, which becomes exitError when
+// the output cannot be written or is not clean.
+func finishOutput(code int) int {
+	temp := pendingOutput.temp
+	if temp == nil {
+		return code
+	}
+	os.Stdout = pendingOutput.stdout
+	pendingOutput.temp = nil
+	target := pendingOutput.path
+	if target == "" {
+		target = "stdout"
+	}
+
+	err := temp.Sync()
+	if err == nil && code != exitError && pendingOutput.strict && pendingOutput.documents > 0 {
+		if _, err = temp.Seek(0, io.SeekStart); err == nil {
+			if err = checkSingleDocument(temp, pendingOutput.documents); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: -strict-stdout: %v\n", err)
+				temp.Close()
+				os.Remove(temp.Name())
+				return exitError
+			}
+		}
+	}
+	if err == nil && code != exitError && pendingOutput.path == "" {
+		if _, err = temp.Seek(0, io.SeekStart); err == nil {
+			_, err = io.Copy(os.Stdout, temp)
+		}
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if code == exitError || err != nil || pendingOutput.path == "" {
+		os.Remove(temp.Name())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: writing %s: %v\n", target, err)
+			return exitError
+		}
+		return code
+	}
+	if err := os.Chmod(temp.Name(), 0o644); err == nil {
+		err = os.Rename(temp.Name(), pendingOutput.path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		fmt.Fprintf(os.Stderr, "Error: writing %s: %v\n", target, err)
+		return exitError
+	}
+	return code
+}
+
+// checkSingleDocument reads r to its end as one JSON value surrounded by
+// nothing but whitespace, without holding the value in memory
+func checkSingleDocument(r io.Reader, documents int) error {
+	if documents > 1 {
+		return fmt.Errorf("%d JSON documents were written, expected one", documents)
+	}
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("output is not a single JSON document: %v", err)
+		}
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("output continues after its JSON document")
+	}
+	return nil
+}
+
+// writeJSON writes v to w as indented JSON followed by a newline
+func writeJSON(w io.Writer, v interface{}) error {
+	countDocument(w)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("marshaling JSON: %v", err)
+	}
+	return nil
+}
+
+// jsonStream writes one indented JSON document a value at a time, so that a
+// document too large to hold in memory can be written as its records are
+// produced. Only the value being written is buffered. The output is what
+// json.MarshalIndent(document, "", "  ") would produce, followed by a newline.
+//
+// A stream stops writing at its first error, which finish returns.
+type jsonStream struct {
+	w       *bufio.Writer
+	values  valueWriter
+	encoder *json.Encoder
+	empty   []bool // Whether each open object or array has no members yet
+	err     error
+}
+
+func newJSONStream(w io.Writer) *jsonStream {
+	s := &jsonStream{w: bufio.NewWriter(w)}
+	s.values.w = s.w
+	s.encoder = json.NewEncoder(&s.values)
+	return s
+}
+
+// open starts an object ('{') or array ('[') as the current value
+func (s *jsonStream) open(delim byte) {
+	s.write([]byte{delim})
+	s.empty = append(s.empty, true)
+}
+
+// close ends the innermost object ('}') or array (']')
+func (s *jsonStream) close(delim byte) {
+	empty := s.empty[len(s.empty)-1]
+	s.empty = s.empty[:len(s.empty)-1]
+	if !empty {
+		s.write([]byte("\n" + s.indent()))
+	}
+	s.write([]byte{delim})
+}
+
+// member starts the next member of the innermost object, or the next element
+// of the innermost array when key is empty. Its value follows as value or open.
+func (s *jsonStream) member(key string) {
+	top := len(s.empty) - 1
+	if !s.empty[top] {
+		s.write([]byte(","))
+	}
+	s.empty[top] = false
+	s.write([]byte("\n" + s.indent()))
+	if key != "" {
+		name, err := json.Marshal(key)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.write(append(name, ':', ' '))
+	}
+}
+
+// value writes a complete value, indented to the current depth
+func (s *jsonStream) value(v interface{}) {
+	if s.err != nil {
+		return
+	}
+	s.values.held = false
+	s.encoder.SetIndent(s.indent(), "  ")
+	if err := s.encoder.Encode(v); err != nil {
+		if s.values.err != nil {
+			s.err = s.values.err
+		} else {
+			s.fail(err)
+		}
+	}
+}
+
+// finish ends the document with a newline and flushes it
+func (s *jsonStream) finish() error {
+	s.write([]byte("\n"))
+	if s.err == nil {
+		s.err = s.w.Flush()
+	}
+	return s.err
+}
+
+func (s *jsonStream) indent() string {
+	return strings.Repeat("  ", len(s.empty))
+}
+
+func (s *jsonStream) write(data []byte) {
+	if s.err == nil {
+		_, s.err = s.w.Write(data)
+	}
+}
+
+func (s *jsonStream) fail(err error) {
+	if s.err == nil {
+		s.err = fmt.Errorf("marshaling JSON: %v", err)
+	}
+}
+
+// valueWriter passes encoded values through to the output but holds back the
+// last byte written, so that the newline json.Encoder ends each value with
+// can be dropped without buffering the value
+type valueWriter struct {
+	w    io.Writer
+	last byte
+	held bool
+	err  error
+}
+
+func (v *valueWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if v.held {
+		if _, v.err = v.w.Write([]byte{v.last}); v.err != nil {
+			return 0, v.err
+		}
+	}
+	if _, v.err = v.w.Write(p[:len(p)-1]); v.err != nil {
+		return 0, v.err
+	}
+	v.last, v.held = p[len(p)-1], true
+	return len(p), nil
+}
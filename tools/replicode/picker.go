@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pickerPageSize is the most matches the picker lists after each filter
+const pickerPageSize = 20
+
+// errNothingPicked is the error of a picker finished without a pick
+var errNothingPicked = errors.New("no resource type picked")
+
+// stdinIsTerminal reports whether stdin is an interactive terminal rather
+// than a pipe, a file, or the null device CI runners attach, so that a
+// prompt has someone to answer it
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// pickResources lets the user choose resource types from candidates: a line
+// of text filters the list by fuzzy match, a line of numbers (e.g., "1,3")
+// picks those entries of the current list, and an empty line or the end of
+// input finishes. Prompts and lists go to out, which is stderr for commands
+// whose stdout is their output. It fails with errNothingPicked when nothing
+// was picked.
+func pickResources(in io.Reader, out io.Writer, candidates []string) ([]string, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no resource types to pick from")
+	}
+	fmt.Fprintf(out, "%d resource types. Type to filter, numbers to pick (e.g., 1,3), an empty line to finish.\n", len(candidates))
+
+	var picked []string
+	seen := map[string]bool{}
+	matches := fuzzyFilter("", candidates)
+	listMatches(out, matches)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "filter> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		numbers, ok := parsePicks(line)
+		if !ok {
+			matches = fuzzyFilter(line, candidates)
+			listMatches(out, matches)
+			continue
+		}
+		for _, n := range numbers {
+			if n < 1 || n > len(matches) || n > pickerPageSize {
+				fmt.Fprintf(out, "No entry %d in the list\n", n)
+				continue
+			}
+			if resource := matches[n-1]; !seen[resource] {
+				seen[resource] = true
+				picked = append(picked, resource)
+			}
+		}
+		fmt.Fprintf(out, "Picked: %s\n", strings.Join(picked, ", "))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(picked) == 0 {
+		return nil, errNothingPicked
+	}
+	return picked, nil
+}
+
+// parsePicks parses a line of entry numbers separated by commas or spaces
+func parsePicks(line string) ([]int, bool) {
+	fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' })
+	numbers := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, len(numbers) > 0
+}
+
+// listMatches writes the first page of matches, numbered from 1
+func listMatches(out io.Writer, matches []string) {
+	for i, resource := range matches {
+		if i == pickerPageSize {
+			fmt.Fprintf(out, "  ... %d more; type more of the name to narrow the list\n", len(matches)-i)
+			break
+		}
+		fmt.Fprintf(out, "%4d  %s\n", i+1, resource)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(out, "  No matches")
+	}
+}
+
+// fuzzyFilter returns the candidates containing the characters of query in
+// order, best match first, then shortest. An empty query matches them all.
+func fuzzyFilter(query string, candidates []string) []string {
+	type match struct {
+		name  string
+		score int
+	}
+	query = strings.ToLower(strings.Join(strings.Fields(query), "")) // "vnet gw" is "vnetgw"
+	var matches []match
+	for _, candidate := range candidates {
+		if score, ok := fuzzyScore(query, candidate); ok {
+			matches = append(matches, match{candidate, score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) < len(b.name)
+		}
+		return a.name < b.name
+	})
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
+	}
+	return names
+}
+
+// fuzzyScore matches query as a subsequence of candidate. Characters matched
+// right after the previous match, or at the start of a word (after "_"),
+// score higher, so "vnet" ranks azurerm_virtual_network above a name that
+// merely contains the letters somewhere.
+func fuzzyScore(query, candidate string) (int, bool) {
+	name := strings.ToLower(candidate)
+	score, at, last := 0, 0, -2
+	for _, r := range query {
+		i := strings.IndexRune(name[at:], r)
+		if i < 0 {
+			return 0, false
+		}
+		i += at
+		score++
+		if i == last+1 {
+			score += 2
+		}
+		if i == 0 || name[i-1] == '_' {
+			score += 3
+		}
+		last, at = i, i+len(string(r))
+	}
+	return score, true
+}
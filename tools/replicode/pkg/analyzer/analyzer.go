@@ -0,0 +1,721 @@
+// Package analyzer extracts the test functions, template calls, test steps,
+// and resource references of azurerm acceptance test files. It is the
+// library behind the replicode command and can be imported by other tools.
+//
+// Analyze handles a single file and AnalyzeDir a directory tree; both return
+// one Result per file with paths relative to Options.RepoRoot.
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FunctionInfo represents a function discovered in the code
+type FunctionInfo struct {
+	File              string
+	Line              int
+	FunctionName      string
+	ReceiverType      string // e.g., "PrivateEndpointResource"
+	ReceiverVar       string // e.g., "r"
+	IsTestFunc        bool
+	IsDataSourceTest  bool // true if calls data.DataSourceTest, false if calls data.ResourceTest
+	IsExported        bool
+	ServiceName       string // NEW: Service extracted from file path (e.g., "network")
+	ResourceUnderTest string // Resource type of the test's first acceptance.BuildTestData call (e.g., "azurerm_private_endpoint")
+}
+
+// FunctionCall represents a function call site
+type FunctionCall struct {
+	CallerFunction string
+	CallerFile     string
+	CallerService  string // NEW: Service of the caller
+	Line           int
+	ReceiverExpr   string // "r", "other", package name
+	MethodName     string // "basic", "withTag"
+	IsMethodCall   bool
+	IsLocalCall    bool   // true if receiver matches caller's receiver
+	FullCall       string // complete call expression for reference
+	NumArgs        int    // number of arguments
+	Arguments      string // comma-separated argument expressions
+	TargetService  string // NEW: Service of the target (if resolvable)
+}
+
+// TestStepInfo represents a test step element from []acceptance.TestStep arrays with full source/target tracking
+type TestStepInfo struct {
+	// Source information (where the test step is)
+	SourceFile     string `json:"source_file"`     // File containing this test step
+	SourceService  string `json:"source_service"`  // NEW: Service containing this test step
+	SourceLine     int    `json:"source_line"`     // Line number where the step starts
+	SourceFunction string `json:"source_function"` // Test function containing this step
+	SourceStruct   string `json:"source_struct"`   // Struct type if test function is a method
+	StepIndex      int    `json:"step_index"`      // Index in the TestStep array (1-based)
+	StepBody       string `json:"step_body"`       // Full text of the {Config:..., Check:...} element
+
+	// Target information (what the Config field references)
+	ConfigExpr     string `json:"config_expr"`     // Full Config expression (e.g., "r.basic(data)")
+	ConfigVariable string `json:"config_variable"` // Variable name (e.g., "r")
+	ConfigMethod   string `json:"config_method"`   // Method name (e.g., "basic")
+	ConfigStruct   string `json:"config_struct"`   // Resolved struct type (e.g., "PrivateEndpointResource")
+	ConfigService  string `json:"config_service"`  // NEW: Service of config struct
+	IsLocalCall    bool   `json:"is_local_call"`   // true if config_struct is in same file
+	TargetFile     string `json:"target_file"`     // File where the config method is defined (if cross-file)
+	TargetLine     int    `json:"target_line"`     // Line number where the config method is defined
+
+	// ResourceUnderTest is the resource type the acceptance.BuildTestData
+	// call of the data variable the Config expression is passed names, or
+	// the test function's when the expression passes none
+	ResourceUnderTest string `json:"resource_under_test,omitempty"`
+
+	// ExpectError is the pattern of a regexp.MustCompile literal in the
+	// step's ExpectError field, or the field's expression as written when it
+	// is built otherwise; negative steps expect their config to fail
+	ExpectError string `json:"expect_error,omitempty"`
+
+	// HasPreConfig flags a step with a PreConfig function, setup code run
+	// before the config is applied that can create resources out of band;
+	// its calls to functions of the file are in PreConfigCalls
+	HasPreConfig   bool            `json:"has_pre_config,omitempty"`
+	PreConfigCalls []PreConfigCall `json:"pre_config_calls,omitempty"`
+
+	// SkipFunc is the condition of the step's SkipFunc, the expression its
+	// function literal returns (e.g., os.Getenv("ARM_TEST_HSM_KEY") == ""),
+	// or the field's expression as written otherwise; when it holds, the
+	// step is skipped and the test still passes
+	SkipFunc string `json:"skip_func,omitempty"`
+
+	// Destroy flags a step that destroys the resources of its config, and
+	// Taint lists the resources it taints so they are replaced on apply
+	Destroy bool           `json:"destroy,omitempty"`
+	Taint   []TaintAddress `json:"taint,omitempty"`
+}
+
+// TaintAddress is one element of a TestStep's Taint field
+type TaintAddress struct {
+	Expr         string `json:"expr"`                    // As written (e.g., data.ResourceName)
+	Address      string `json:"address,omitempty"`       // Resolved address (e.g., azurerm_subnet.test)
+	ResourceType string `json:"resource_type,omitempty"` // Resource type of the address (e.g., azurerm_subnet)
+}
+
+// PreConfigCall is a call a TestStep's PreConfig function makes to a test or
+// template function of the same file
+type PreConfigCall struct {
+	Expr     string `json:"expr"`               // e.g., "r.preCreate(data)"
+	Receiver string `json:"receiver,omitempty"` // Receiver expression of a method call (e.g., "r")
+	Struct   string `json:"struct,omitempty"`   // Resolved receiver type of a method call
+	Method   string `json:"method"`
+	Line     int    `json:"line"`
+}
+
+// TemplateFunctionCall represents a call from one template function to another
+// Found in fmt.Sprintf arguments like: fmt.Sprintf("%s\nresource...", r.template(data))
+type TemplateFunctionCall struct {
+	SourceFunction string `json:"source_function"` // The template function making the call
+	SourceFile     string `json:"source_file"`
+	SourceService  string `json:"source_service"` // NEW: Service of source
+	SourceLine     int    `json:"source_line"`
+
+	TargetExpr      string `json:"target_expr"`       // Full expression: r.template(data)
+	TargetVariable  string `json:"target_variable"`   // Variable: r
+	TargetMethod    string `json:"target_method"`     // Method: template
+	TargetStruct    string `json:"target_struct"`     // Resolved struct type
+	TargetService   string `json:"target_service"`    // NEW: Service of target
+	ReferenceTypeId int    `json:"reference_type_id"` // 3=EMBEDDED_SELF, 2=CROSS_FILE, 10=EXTERNAL_REFERENCE
+	TargetFile      string `json:"target_file"`
+	TargetLine      int    `json:"target_line"`
+}
+
+// SequentialReference represents a sequential test call (t.Run or RunTestsInSequence)
+type SequentialReference struct {
+	EntryPointFunction string `json:"entry_point_function"` // The test function calling t.Run or RunTestsInSequence
+	EntryPointFile     string `json:"entry_point_file"`
+	EntryPointLine     int    `json:"entry_point_line"`
+
+	ReferencedFunction string `json:"referenced_function"` // The function being called sequentially
+	SequentialGroup    string `json:"sequential_group"`    // Group name (e.g., "interactiveQuery", "hadoop")
+	SequentialKey      string `json:"sequential_key"`      // Key name (e.g., "securityProfile", "basic")
+}
+
+// DirectResourceReference represents a direct mention of an Azure resource in HCL template code,
+// or in the RequiresImportError call of a test (REQUIRES_IMPORT_ERROR)
+type DirectResourceReference struct {
+	TemplateFunction string `json:"template_function"` // Template (or test) function containing this reference
+	TemplateFile     string `json:"template_file"`
+	TemplateLine     int    `json:"template_line"` // Line in source where template function is defined
+
+	ResourceName  string `json:"resource_name"`       // e.g., "azurerm_resource_group", "azurerm_virtual_network"
+	ReferenceType string `json:"reference_type"`      // "RESOURCE_BLOCK", "DATA_SOURCE_BLOCK", "ATTRIBUTE_REFERENCE", or "REQUIRES_IMPORT_ERROR"
+	Context       string `json:"context"`             // The actual HCL line containing the reference
+	ContextLine   int    `json:"context_line"`        // Line number within the HCL string (relative)
+	Namespace     string `json:"namespace,omitempty"` // ARM resource provider namespace (e.g., "Microsoft.Network")
+}
+
+// VarAssignment tracks variable assignments within a function scope
+// Used to resolve patterns like: config := r.multipleInstances(...)
+type VarAssignment struct {
+	VarName        string // The variable name (e.g., "config")
+	ReceiverVar    string // The receiver variable (e.g., "r")
+	ReceiverStruct string // The struct type (e.g., "PrivateEndpointResource")
+	MethodName     string // The method being called (e.g., "multipleInstances")
+	FullExpr       string // Full assignment expression
+}
+
+// FunctionReturnType tracks function declarations and their return types
+type FunctionReturnType struct {
+	FunctionName string // The function name (e.g., "newSiteRecoveryVMWareReplicatedVMResource")
+	ReturnType   string // The primary return type (ignoring error returns)
+}
+
+// ParseError is a syntax error in an analyzed file. The analysis goes on with
+// the declarations the parser recovered, so a file with parse errors still
+// yields the records of its intact functions.
+type ParseError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// Result is the analysis of one file; it is also the JSON output of the
+// single-file mode
+type Result struct {
+	FilePath             string                    `json:"file_path"`
+	Functions            []FunctionInfo            `json:"functions"`
+	Calls                []FunctionCall            `json:"calls"`
+	Imports              []ImportInfo              `json:"imports"`
+	TestSteps            []TestStepInfo            `json:"test_steps"`
+	ImportSteps          []ImportStepInfo          `json:"import_steps,omitempty"`
+	TemplateCalls        []TemplateFunctionCall    `json:"template_calls"`
+	SequentialReferences []SequentialReference     `json:"sequential_references"`
+	DirectResourceRefs   []DirectResourceReference `json:"direct_resource_references"`
+	SingletonDeps        []SingletonDependency     `json:"singleton_dependencies,omitempty"`
+	Requirements         []FunctionRequirements    `json:"requirements,omitempty"`
+	LocationFindings     []LocationFinding         `json:"location_findings,omitempty"`
+	CheckAssertions      []CheckAssertion          `json:"check_assertions,omitempty"`         // Only with Options.CheckAssertions
+	CheckDestroyRefs     []CheckDestroyReference   `json:"check_destroy_references,omitempty"` // Only with Options.CheckDestroy
+	CheckCalls           []CheckCall               `json:"check_calls,omitempty"`              // Only with Options.CheckCalls
+	TemplateSources      []TemplateSource          `json:"-"`                                  // Returned HCL for rendering; not part of the JSON contract
+	Patterns             *PatternDetector          `json:"patterns,omitempty"`
+	Extensions           map[string][]Record       `json:"extensions,omitempty"` // Custom extractor name -> its records
+	ParseErrors          []ParseError              `json:"parse_errors,omitempty"`
+	Generated            bool                      `json:"generated,omitempty"` // Marked "// Code generated ... DO NOT EDIT."
+}
+
+// Options controls how files are analyzed
+type Options struct {
+	RepoRoot     string       // Repository root directory (for relative path conversion)
+	ResourceName string       // Optional resource filter for direct references
+	Namespaces   NamespaceMap // Resource type to ARM namespace mapping (built-in when nil)
+	Source       []byte       // File content to analyze instead of reading the file at the path
+
+	// Extractors run after the built-in extractors, following any registered
+	// with Register
+	Extractors []Extractor
+
+	// Skipped is called by AnalyzeDir for each file that fails to analyze;
+	// when nil, a warning is written to stderr
+	Skipped func(path string, err error)
+
+	// Trace is called with the span of each analyzed file, whose children
+	// time the parse and every extractor; nil turns tracing off
+	Trace func(Span)
+
+	// Cache, when set, reuses the results of earlier runs for unchanged files
+	Cache *ResultCache
+
+	// CheckAssertions turns on the extraction of the assertions of TestStep
+	// Check fields into Result.CheckAssertions
+	CheckAssertions bool
+
+	// CheckDestroy turns on the extraction of the resource types the
+	// CheckDestroy functions of TestCases verify into Result.CheckDestroyRefs
+	CheckDestroy bool
+
+	// CheckCalls turns on the recording of the calls inside TestStep Check
+	// fields, which Result.Calls leaves out, into Result.CheckCalls
+	CheckCalls bool
+
+	// Prefilter makes AnalyzeDir skip, without parsing them, the files that
+	// Relevant rejects. Skipped files are left out of the results entirely.
+	Prefilter bool
+
+	// SkipGenerated makes AnalyzeDir skip, without parsing them, the files
+	// marked as generated (see Generated), which are otherwise analyzed and
+	// tagged in Result.Generated
+	SkipGenerated bool
+
+	// FollowSymlinks makes AnalyzeDir walk symlinked directories, each real
+	// directory once, instead of skipping them
+	FollowSymlinks bool
+
+	// ExcludeDirs names further directories, besides DefaultExcludeDirs, that
+	// AnalyzeDir does not walk into
+	ExcludeDirs []string
+
+	// IncludeDirs names directories of DefaultExcludeDirs that AnalyzeDir
+	// walks into anyway
+	IncludeDirs []string
+
+	// ServicePatterns name the service of files laid out other than as
+	// services/<name>
+	ServicePatterns ServicePatterns
+
+	// Repository names the repository of the files in a multi-root analysis.
+	// Their relative paths are prefixed with it (azuread/internal/...) and
+	// their services qualified by it (azuread/applications), so that the
+	// files and services of separate repositories never merge.
+	Repository string
+
+	// ResourcePrefixes are the provider prefixes of the resource types
+	// recognized in template HCL (e.g., "azurerm_", "azuread_"); only
+	// DefaultResourcePrefix when empty
+	ResourcePrefixes []string
+
+	// Symlinks is called by AnalyzeDir for each symlinked directory it
+	// follows or skips and each file it skips as already found through
+	// another path; when nil, skips are noted on stderr
+	Symlinks func(link Symlink)
+}
+
+// fileContext is the per-file state shared by the built-in extractors: the
+// parsed file, its content (read once), and what earlier extraction steps
+// derived from it. Paths are absolute until the result is relativized.
+type fileContext struct {
+	file             *ast.File
+	fset             *token.FileSet
+	path             string
+	source           []byte
+	service          string
+	services         ServicePatterns // Of Options, for the service of an imported package
+	functions        []FunctionInfo
+	resourcePrefixes []string             // Provider prefixes of the resource types scanned for in HCL
+	lineToFunc       map[int]FunctionInfo // Declaration line -> function, for caller context
+}
+
+func newFileContext(file *ast.File, fset *token.FileSet, path string, source []byte, functions []FunctionInfo) *fileContext {
+	fc := &fileContext{
+		file:       file,
+		fset:       fset,
+		path:       path,
+		source:     source,
+		functions:  functions,
+		lineToFunc: make(map[int]FunctionInfo, len(functions)),
+	}
+	for _, fn := range functions {
+		fc.lineToFunc[fn.Line] = fn
+	}
+	return fc
+}
+
+// errNoRepoRoot is returned when paths cannot be made relative
+var errNoRepoRoot = fmt.Errorf("repository root is required for relative path conversion")
+
+// RelativePath converts an absolute file path to relative based on repository root.
+// Spellings of the root and path that filepath.Rel cannot reconcile (drive
+// letter case, backslashes, long-path and UNC prefixes, WSL mounts, one
+// relative and one absolute) are compared in their CanonicalPath form.
+func RelativePath(root string, absPath string) (string, error) {
+	if root == "" {
+		return "", errNoRepoRoot
+	}
+
+	// Use Go's standard library to compute relative path
+	relPath, err := filepath.Rel(root, absPath)
+	if err == nil && relPath != ".." && !strings.HasPrefix(filepath.ToSlash(relPath), "../") {
+		// Convert to forward slashes for consistency across platforms
+		return filepath.ToSlash(relPath), nil
+	}
+	if canonical, ok := trimPathPrefix(CanonicalPath(absPath), CanonicalPath(root)); ok {
+		return canonical, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to convert path to relative: %v", err)
+	}
+	return filepath.ToSlash(relPath), nil // Outside the root
+}
+
+// ServicePatterns are path patterns tried, in order, before the
+// services/<name> layout when naming the service of a file, for repositories
+// laid out differently (e.g., `internal/provider/([^/]+)/`). Each is a regular
+// expression searched for in the slash-separated path, which may be absolute,
+// so patterns should not be anchored at its start; its first capturing group,
+// or the group named service, is the service name.
+type ServicePatterns []*regexp.Regexp
+
+// CompileServicePatterns compiles service patterns, each of which must have a
+// capturing group for the service name
+func CompileServicePatterns(patterns []string) (ServicePatterns, error) {
+	compiled := make(ServicePatterns, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("service pattern %q: %v", pattern, err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("service pattern %q has no capturing group for the service name", pattern)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Strings returns the source text of the patterns
+func (p ServicePatterns) Strings() []string {
+	patterns := make([]string, len(p))
+	for i, re := range p {
+		patterns[i] = re.String()
+	}
+	return patterns
+}
+
+// ServiceName extracts the service name from a file path, trying the
+// patterns before the services/<name> layout
+func (p ServicePatterns) ServiceName(filePath string) string {
+	filePath = strings.ReplaceAll(filePath, `\`, "/") // Windows paths on any host
+	for _, re := range p {
+		m := re.FindStringSubmatch(filePath)
+		if m == nil {
+			continue
+		}
+		group := 1
+		if named := re.SubexpIndex("service"); named > 0 {
+			group = named
+		}
+		if m[group] != "" {
+			return m[group]
+		}
+	}
+
+	parts := strings.Split(filePath, "/")
+	for i, part := range parts {
+		if part == "services" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// ServiceName extracts service name from file path laid out as
+// services/<name>; see ServicePatterns for other layouts
+// Example: internal/services/network/file_test.go → "network"
+func ServiceName(filePath string) string {
+	return ServicePatterns(nil).ServiceName(filePath)
+}
+
+// Analyze parses a single Go file and runs every extractor over it.
+// All file paths in the returned result are relative to opts.RepoRoot.
+func Analyze(path string, opts Options) (result *Result, err error) {
+	if opts.RepoRoot == "" {
+		return nil, errNoRepoRoot
+	}
+	service := opts.ServicePatterns.ServiceName(path)
+	trace := startFileTrace(opts.Trace, path)
+	defer func() { trace.finish(result, service, err) }()
+
+	// Step bodies and template call arguments are cut from the source text
+	src := opts.Source
+	if src == nil {
+		if src, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("reading file: %v", err)
+		}
+	}
+
+	var cacheKey string
+	if opts.Cache != nil {
+		if cacheKey = opts.Cache.key(path, src, opts); cacheKey != "" {
+			done := trace.step("load cached result")
+			cached, ok := opts.Cache.load(cacheKey)
+			done(-1)
+			if ok {
+				return cached, nil
+			}
+		}
+	}
+
+	// Parse the file
+	done := trace.step("parse")
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	done(-1)
+	parseErrors, err := recoveredParseErrors(file, err)
+	if err != nil {
+		trace.fail(err)
+		return nil, fmt.Errorf("parsing file: %v", err)
+	}
+	if len(parseErrors) > 0 {
+		trace.fail(fmt.Errorf("%d parse errors", len(parseErrors)))
+	}
+
+	// Extract data using absolute paths throughout
+	done = trace.step("extract functions")
+	functions := extractFunctions(file, fset, path, service)
+	// Enrich test functions with struct information from their body
+	enrichTestFunctionsWithStructInfo(file, fset, &functions)
+	// Detect if test functions are data source tests or resource tests
+	enrichTestFunctionsWithTestType(file, fset, &functions)
+	// Bind test functions to the resource type their BuildTestData call names
+	enrichTestFunctionsWithResourceUnderTest(file, fset, &functions)
+	done(len(functions))
+	fc := newFileContext(file, fset, path, src, functions)
+	fc.service, fc.services = service, opts.ServicePatterns
+	fc.resourcePrefixes = opts.resourcePrefixes()
+
+	// The node-level extractors share one walk of the file
+	var calls []FunctionCall
+	var checkCalls []CheckCall
+	var testSteps []TestStepInfo
+	var importSteps []ImportStepInfo
+	var templateCalls []TemplateFunctionCall
+	patterns := newPatternDetector() // Sequential, map-based, and anonymous function patterns
+	walk := &fileWalk{}
+	var checkCallsOut *[]CheckCall
+	if opts.CheckCalls {
+		checkCallsOut = &checkCalls
+	}
+	walk.add("extract calls", functionCallVisitor(fc, &calls, checkCallsOut), func() int { return len(calls) + len(checkCalls) })
+	walk.add("extract test steps", testStepVisitor(fc, &testSteps), func() int { return len(testSteps) })
+	walk.add("extract import steps", importStepVisitor(fc, &importSteps), func() int { return len(importSteps) })
+	walk.add("extract template calls", templateCallVisitor(fc, &templateCalls), func() int { return len(templateCalls) })
+	walk.add("detect patterns", patterns.visitor(path), nil)
+
+	// The walk and the extractors that only look at function declarations
+	// read the parsed file and fc without modifying either, so they run
+	// concurrently, each writing only its own results
+	var imports []ImportInfo
+	var sequentialRefs []SequentialReference
+	var directRefs []DirectResourceReference
+	var singletonDeps []SingletonDependency
+	var requirements []FunctionRequirements
+	var templateSources []TemplateSource
+	var locationFindings []LocationFinding
+	var checkAssertions []CheckAssertion
+	var checkDestroyRefs []CheckDestroyReference
+	if opts.Namespaces == nil {
+		opts.Namespaces = builtinNamespaces
+	}
+	runConcurrently(
+		func() { walk.run(file, trace) },
+		func() {
+			done := trace.step("extract imports")
+			imports = ExtractImports(file)
+			done(len(imports))
+		},
+		func() {
+			done := trace.step("extract sequential references")
+			sequentialRefs = extractSequentialReferences(fc)
+			done(len(sequentialRefs))
+		},
+		func() {
+			done := trace.step("extract direct resource references")
+			directRefs = extractDirectResourceReferences(fc, opts.ResourceName)
+			directRefs = append(directRefs, extractRequiresImportReferences(fc, opts.ResourceName)...)
+			annotateNamespaces(directRefs, opts.Namespaces)
+			done(len(directRefs))
+		},
+		func() {
+			done := trace.step("extract singleton dependencies")
+			singletonDeps = extractSingletonDependencies(fc)
+			done(len(singletonDeps))
+		},
+		func() {
+			done := trace.step("extract requirements")
+			requirements = extractRequirements(fc)
+			done(len(requirements))
+		},
+		func() {
+			done := trace.step("extract template sources")
+			templateSources = extractTemplateSources(fc)
+			done(len(templateSources))
+		},
+		func() {
+			done := trace.step("extract location findings")
+			locationFindings = extractLocationFindings(fc)
+			done(len(locationFindings))
+		},
+		func() {
+			if !opts.CheckAssertions {
+				return
+			}
+			done := trace.step("extract check assertions")
+			checkAssertions = extractCheckAssertions(fc)
+			done(len(checkAssertions))
+		},
+		func() {
+			if !opts.CheckDestroy {
+				return
+			}
+			done := trace.step("extract check destroy references")
+			checkDestroyRefs = extractCheckDestroyReferences(fc, opts.Namespaces)
+			done(len(checkDestroyRefs))
+		},
+	)
+
+	result = &Result{
+		FilePath:             path,
+		Functions:            functions,
+		Calls:                calls,
+		Imports:              imports,
+		TestSteps:            testSteps,
+		ImportSteps:          importSteps,
+		TemplateCalls:        templateCalls,
+		SequentialReferences: sequentialRefs,
+		DirectResourceRefs:   directRefs,
+		SingletonDeps:        singletonDeps,
+		Requirements:         requirements,
+		TemplateSources:      templateSources,
+		LocationFindings:     locationFindings,
+		CheckAssertions:      checkAssertions,
+		CheckDestroyRefs:     checkDestroyRefs,
+		CheckCalls:           checkCalls,
+		Patterns:             patterns,
+		ParseErrors:          parseErrors,
+		Generated:            Generated(src),
+	}
+
+	if err := result.relativizePaths(opts.RepoRoot, opts.Repository); err != nil {
+		return nil, err
+	}
+	result.qualifyServices(opts.Repository)
+
+	ctx := &ExtractContext{
+		FilePath:  result.FilePath,
+		Service:   QualifiedService(opts.Repository, fc.service),
+		Source:    src,
+		Functions: result.Functions,
+		Imports:   result.Imports,
+	}
+	if result.Extensions, err = runExtractors(file, fset, ctx, opts.Extractors, trace); err != nil {
+		return nil, err
+	}
+
+	if cacheKey != "" {
+		opts.Cache.store(cacheKey, result)
+	}
+	return result, nil
+}
+
+// recoveredParseErrors returns the syntax errors of a file the parser
+// recovered from, or err when nothing usable was parsed (no package clause)
+// or the failure was not a syntax error
+func recoveredParseErrors(file *ast.File, err error) ([]ParseError, error) {
+	if err == nil {
+		return nil, nil
+	}
+	list, ok := err.(scanner.ErrorList)
+	if !ok || file == nil || file.Name == nil || file.Name.Name == "" {
+		return nil, err
+	}
+	parseErrors := make([]ParseError, 0, len(list))
+	for _, e := range list {
+		parseErrors = append(parseErrors, ParseError{File: e.Pos.Filename, Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+	}
+	return parseErrors, nil
+}
+
+// relativizePaths converts all file paths in the result to paths relative to
+// root, prefixed with the repository of a multi-root analysis. A path that
+// cannot be made relative is kept in its canonical form rather than failing
+// the file.
+func (result *Result) relativizePaths(root, repository string) error {
+	if root == "" {
+		return errNoRepoRoot
+	}
+	rel := func(p string) string {
+		relPath, err := RelativePath(root, p)
+		if err != nil {
+			return CanonicalPath(p)
+		}
+		return qualifiedPath(repository, relPath)
+	}
+
+	result.FilePath = rel(result.FilePath)
+
+	functions := result.Functions
+	calls := result.Calls
+	testSteps := result.TestSteps
+	templateCalls := result.TemplateCalls
+	sequentialRefs := result.SequentialReferences
+	directRefs := result.DirectResourceRefs
+	patterns := result.Patterns
+
+	// Convert all file paths in the results to relative paths
+	for i := range functions {
+		functions[i].File = rel(functions[i].File)
+	}
+	for i := range calls {
+		calls[i].CallerFile = rel(calls[i].CallerFile)
+	}
+	for i := range testSteps {
+		testSteps[i].SourceFile = rel(testSteps[i].SourceFile)
+		if testSteps[i].TargetFile != "" {
+			testSteps[i].TargetFile = rel(testSteps[i].TargetFile)
+			// NOW CORRECTLY SET IsLocalCall based on actual file comparison
+			testSteps[i].IsLocalCall = (testSteps[i].SourceFile == testSteps[i].TargetFile)
+		} else {
+			// If we couldn't resolve target file, assume it's in the same file
+			testSteps[i].IsLocalCall = true
+		}
+	}
+	for i := range result.ImportSteps {
+		result.ImportSteps[i].SourceFile = rel(result.ImportSteps[i].SourceFile)
+	}
+	for i := range templateCalls {
+		templateCalls[i].SourceFile = rel(templateCalls[i].SourceFile)
+		if templateCalls[i].TargetFile != "" {
+			templateCalls[i].TargetFile = rel(templateCalls[i].TargetFile)
+			// Set ReferenceTypeId based on actual file comparison
+			if templateCalls[i].SourceFile == templateCalls[i].TargetFile {
+				templateCalls[i].ReferenceTypeId = 3 // EMBEDDED_SELF (same file)
+			} else {
+				templateCalls[i].ReferenceTypeId = 2 // CROSS_FILE (different file, both analyzed)
+			}
+		} else {
+			// If we couldn't resolve target file, assume it's EXTERNAL (cross-file)
+			// This happens when template calls a function in a different file not analyzed yet
+			templateCalls[i].ReferenceTypeId = 10 // EXTERNAL_REFERENCE (unresolved target)
+		}
+	}
+	for i := range sequentialRefs {
+		sequentialRefs[i].EntryPointFile = rel(sequentialRefs[i].EntryPointFile)
+	}
+	for i := range directRefs {
+		directRefs[i].TemplateFile = rel(directRefs[i].TemplateFile)
+	}
+	for i := range result.SingletonDeps {
+		result.SingletonDeps[i].TemplateFile = rel(result.SingletonDeps[i].TemplateFile)
+	}
+	for i := range result.Requirements {
+		result.Requirements[i].File = rel(result.Requirements[i].File)
+	}
+	for i := range result.LocationFindings {
+		result.LocationFindings[i].File = rel(result.LocationFindings[i].File)
+	}
+	for i := range result.CheckAssertions {
+		result.CheckAssertions[i].SourceFile = rel(result.CheckAssertions[i].SourceFile)
+	}
+	for i := range result.CheckDestroyRefs {
+		result.CheckDestroyRefs[i].SourceFile = rel(result.CheckDestroyRefs[i].SourceFile)
+	}
+	for i := range result.CheckCalls {
+		result.CheckCalls[i].SourceFile = rel(result.CheckCalls[i].SourceFile)
+	}
+	for i := range result.ParseErrors {
+		result.ParseErrors[i].File = rel(result.ParseErrors[i].File)
+	}
+	if patterns != nil {
+		for i := range patterns.VisibilityInfo {
+			if patterns.VisibilityInfo[i].FilePath != "" {
+				patterns.VisibilityInfo[i].FilePath = rel(patterns.VisibilityInfo[i].FilePath)
+			}
+		}
+	}
+
+	return nil
+}
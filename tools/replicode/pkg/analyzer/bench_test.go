@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchmarkTestFile is a provider-style test file of n resources, each with
+// a test of two steps and two templates, one embedding the other
+func benchmarkTestFile(n int) string {
+	var b strings.Builder
+	b.WriteString(`package network_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance/check"
+)
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `
+type Bench%[1]dResource struct{}
+
+func TestAccBench%[1]d_basic(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_bench%[1]d", "test")
+	r := Bench%[1]dResource{}
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.basic(data),
+			Check: acceptance.ComposeTestCheckFunc(
+				check.That(data.ResourceName).ExistsInAzure(r),
+				check.That(data.ResourceName).Key("name").HasValue(fmt.Sprintf("acctest-%%d", data.RandomInteger)),
+			),
+		},
+		data.ImportStep(),
+		{
+			Config: r.complete(data, "updated"),
+		},
+	})
+}
+
+func (Bench%[1]dResource) basic(data acceptance.TestData) string {
+	return fmt.Sprintf(`+"`"+`
+provider "azurerm" {
+  features {}
+}
+
+resource "azurerm_resource_group" "test" {
+  name     = "acctestRG-%%[1]d"
+  location = "%%[2]s"
+}
+
+resource "azurerm_bench%[1]d" "test" {
+  name                = "acctest-%%[1]d"
+  resource_group_name = azurerm_resource_group.test.name
+}
+`+"`"+`, data.RandomInteger, data.Locations.Primary)
+}
+
+func (r Bench%[1]dResource) complete(data acceptance.TestData, tag string) string {
+	return fmt.Sprintf(`+"`"+`
+%%s
+
+resource "azurerm_subnet" "test" {
+  name                 = "acctestsubnet%%d"
+  virtual_network_name = azurerm_virtual_network.test.name
+  tags = {
+    env = "%%s"
+  }
+}
+`+"`"+`, r.basic(data), data.RandomInteger, tag)
+}
+`, i)
+	}
+	return b.String()
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+	root := b.TempDir()
+	dir := filepath.Join(root, "internal", "services", "network")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(dir, "bench_resource_test.go")
+	src := benchmarkTestFile(200)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	opts := Options{RepoRoot: root}
+
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Analyze(path, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExprToString(b *testing.B) {
+	exprs := []struct{ name, text string }{
+		{"ident", "data"},
+		{"selector", "data.RandomInteger"},
+		{"sprintf", `fmt.Sprintf("acctest-%d", data.RandomInteger)`},
+		{"method", `r.complete(data, "updated")`},
+		{"literal_receiver", "network.VirtualNetworkResource{}.basic(data)"},
+		{"chain", `check.That(data.ResourceName).Key("tags.%").HasValue("2")`},
+		{"composite", "&acceptance.TestData{Locations: acceptance.Regions{Primary: location}}"},
+	}
+	for _, e := range exprs {
+		expr, err := parser.ParseExpr(e.text)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(e.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = exprToString(expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ResultCache keeps file analyses on disk so that separate replicode runs
+// over the same files, such as the per-file runs of the PowerShell pipeline
+// followed by select or plan, parse each unchanged file once. An entry is
+// keyed by the file's content, its path, and the options that shape its
+// result, so an edited file or a different -resourcename misses the cache.
+//
+// Results are only cached when no custom extractors are in use, since the
+// cache cannot tell whether their records depend on anything beyond the file.
+type ResultCache struct {
+	dir  string
+	salt string
+}
+
+// NewResultCache opens a cache in dir, creating it if needed. Entries written
+// under a different salt are never read, so passing something that changes
+// with the analyzer's code (a build hash) keeps a new build from reading the
+// results of an old one.
+func NewResultCache(dir, salt string) (*ResultCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %v", err)
+	}
+	return &ResultCache{dir: dir, salt: salt}, nil
+}
+
+// cachedResult is a cache entry: the result and the fields its JSON form leaves out
+type cachedResult struct {
+	Result          *Result          `json:"result"`
+	TemplateSources []TemplateSource `json:"template_sources"`
+}
+
+// key identifies the analysis of src at path under opts, or is "" when the
+// analysis cannot be cached
+func (c *ResultCache) key(path string, src []byte, opts Options) string {
+	if len(opts.Extractors) > 0 || len(Extractors()) > 0 {
+		return ""
+	}
+	namespaces, err := json.Marshal(opts.Namespaces) // Sorted keys, so stable
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	patterns := strings.Join(opts.ServicePatterns.Strings(), "\n")
+	var enabled []string
+	if opts.CheckAssertions {
+		enabled = append(enabled, "check_assertions")
+	}
+	if opts.CheckDestroy {
+		enabled = append(enabled, "check_destroy_references")
+	}
+	if opts.CheckCalls {
+		enabled = append(enabled, "check_calls")
+	}
+	extractions := strings.Join(enabled, ",")
+	prefixes := strings.Join(opts.ResourcePrefixes, ",")
+	for _, part := range []string{c.salt, path, opts.RepoRoot, opts.Repository, opts.ResourceName, prefixes, string(namespaces), patterns, extractions} {
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	h.Write(src)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// load returns the cached result for a key. A missing or unreadable entry is
+// a miss; the file is analyzed and the entry rewritten.
+func (c *ResultCache) load(key string) (*Result, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry cachedResult
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Result == nil {
+		return nil, false
+	}
+	entry.Result.TemplateSources = entry.TemplateSources
+	return entry.Result, true
+}
+
+// store writes a result under a key. Entries are written to a temporary file
+// and renamed into place, so that concurrent runs never read a partial entry.
+// Failing to store is not an error: the cache only saves work.
+func (c *ResultCache) store(key string, result *Result) {
+	data, err := json.Marshal(cachedResult{Result: result, TemplateSources: result.TemplateSources})
+	if err != nil {
+		return
+	}
+	file, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(file.Name(), filepath.Join(c.dir, key+".json")) != nil {
+		os.Remove(file.Name())
+	}
+}
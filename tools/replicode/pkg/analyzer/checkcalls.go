@@ -0,0 +1,96 @@
+package analyzer
+
+import (
+	"go/ast"
+	"path"
+	"strconv"
+)
+
+// checkCallCategory and checkCallReferenceType mark every CheckCall apart
+// from the configuration calls of Result.Calls
+const (
+	checkCallCategory      = "check_call"
+	checkCallReferenceType = "CHECK_CALL"
+)
+
+// CheckCall is a call inside a TestStep's Check field: a check helper such as
+// check.That, an SDK helper, or a shared exists-check of another service's
+// package. The call records leave Check fields out, as they follow
+// configuration; these are recorded apart so that check helpers shared across
+// services can be found without mixing them into the configuration graph.
+type CheckCall struct {
+	SourceFile     string `json:"source_file"`
+	SourceService  string `json:"source_service"`
+	SourceFunction string `json:"source_function"`
+	CheckLine      int    `json:"check_line"` // Line of the Check field
+	Line           int    `json:"line"`
+
+	Call          string `json:"call"`                     // Callee as written (e.g., "check.That", "testCheckSubnetExists", "r.checkExists")
+	Receiver      string `json:"receiver,omitempty"`       // Package or variable the callee is selected from
+	Method        string `json:"method"`                   // Function or method name
+	Arguments     string `json:"arguments,omitempty"`      // Comma-separated argument expressions
+	Package       string `json:"package,omitempty"`        // Import path, when the receiver is an imported package
+	TargetService string `json:"target_service,omitempty"` // Service of the callee's package, when known
+	Category      string `json:"category"`                 // check_call
+	ReferenceType string `json:"reference_type"`           // CHECK_CALL
+}
+
+// importNames maps the names a file refers to its imports by to their paths:
+// the alias, or the last element of the path
+func importNames(file *ast.File) map[string]string {
+	names := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[name] = importPath
+	}
+	return names
+}
+
+// newCheckCall describes a call of a Check field, or returns false for the
+// inner links of a method chain such as check.That(...).Key("sku"), whose
+// root call is recorded itself. Package functions take the service of their
+// import path, and the file's own functions and receiver methods the file's.
+func newCheckCall(fc *fileContext, fn *FunctionInfo, checkLine int, callExpr *ast.CallExpr, imports map[string]string, argBuf []byte) (CheckCall, []byte, bool) {
+	call := CheckCall{
+		SourceFile:     fc.path,
+		SourceService:  fc.service,
+		SourceFunction: fn.FunctionName,
+		CheckLine:      checkLine,
+		Line:           fc.fset.Position(callExpr.Pos()).Line,
+		Category:       checkCallCategory,
+		ReferenceType:  checkCallReferenceType,
+	}
+	switch fun := callExpr.Fun.(type) {
+	case *ast.Ident:
+		call.Method = fun.Name
+		call.TargetService = fc.service
+	case *ast.SelectorExpr:
+		receiver, ok := fun.X.(*ast.Ident)
+		if !ok {
+			return call, argBuf, false
+		}
+		call.Receiver, call.Method = receiver.Name, fun.Sel.Name
+		switch {
+		case receiver.Name == fn.ReceiverVar:
+			call.TargetService = fc.service
+		case receiver.Obj == nil && imports[receiver.Name] != "":
+			call.Package = imports[receiver.Name]
+			call.TargetService = fc.services.ServiceName(call.Package)
+		}
+	default:
+		return call, argBuf, false
+	}
+	call.Call = call.Method
+	if call.Receiver != "" {
+		call.Call = call.Receiver + "." + call.Method
+	}
+	call.Arguments, argBuf = argumentsString(callExpr.Args, argBuf)
+	return call, argBuf, true
+}
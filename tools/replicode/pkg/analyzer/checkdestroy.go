@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// checkDestroyReferenceType is the reference type of every CheckDestroyReference
+const checkDestroyReferenceType = "CHECK_DESTROY"
+
+// CheckDestroyReference is a resource type a test's CheckDestroy function
+// verifies the destruction of. Legacy tests compare each state resource's
+// Type against it, so the reference holds even when their configs are built
+// in ways the Config resolution cannot follow.
+type CheckDestroyReference struct {
+	SourceFile     string `json:"source_file"`
+	SourceService  string `json:"source_service"`
+	SourceFunction string `json:"source_function"` // Test function whose TestCase sets CheckDestroy
+	SourceLine     int    `json:"source_line"`     // Line of the CheckDestroy field
+
+	CheckExpr     string `json:"check_expr"`     // CheckDestroy value (e.g., testCheckAzureRMSubnetDestroy)
+	CheckFunction string `json:"check_function"` // Function it names (e.g., testCheckAzureRMSubnetDestroy)
+	CheckLine     int    `json:"check_line"`     // Line where that function is defined
+
+	ResourceName  string `json:"resource_name"`       // e.g., "azurerm_subnet"
+	ReferenceType string `json:"reference_type"`      // CHECK_DESTROY
+	Namespace     string `json:"namespace,omitempty"` // ARM resource provider namespace (e.g., "Microsoft.Network")
+}
+
+// destroyCheck is a function of the file and the resource types it checks
+type destroyCheck struct {
+	line      int
+	resources []string
+}
+
+// extractCheckDestroyReferences links the CheckDestroy fields of the tracked
+// test functions to the resource types their destroy function checks. Destroy
+// functions are filtered out of the function records, so the file's
+// declarations are searched directly; functions declared in other files are
+// not followed.
+func extractCheckDestroyReferences(fc *fileContext, namespaces NamespaceMap) []CheckDestroyReference {
+	checks := map[string]destroyCheck{}
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+		if resources := checkedResourceTypes(funcDecl.Body); len(resources) > 0 {
+			checks[funcDecl.Name.Name] = destroyCheck{line: fc.fset.Position(funcDecl.Pos()).Line, resources: resources}
+		}
+	})
+	if len(checks) == 0 {
+		return nil
+	}
+
+	byLine := map[int]*FunctionInfo{}
+	for i := range fc.functions {
+		byLine[fc.functions[i].Line] = &fc.functions[i]
+	}
+
+	var refs []CheckDestroyReference
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+		fn := byLine[fc.fset.Position(funcDecl.Pos()).Line]
+		if fn == nil || fn.FunctionName != funcDecl.Name.Name {
+			return
+		}
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			kv, ok := n.(*ast.KeyValueExpr)
+			if !ok {
+				return true
+			}
+			if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "CheckDestroy" {
+				return true
+			}
+			name := destroyFunctionName(kv.Value)
+			check, ok := checks[name]
+			if !ok {
+				return false
+			}
+			for _, resource := range check.resources {
+				refs = append(refs, CheckDestroyReference{
+					SourceFile:     fc.path,
+					SourceService:  fc.service,
+					SourceFunction: fn.FunctionName,
+					SourceLine:     fc.fset.Position(kv.Pos()).Line,
+					CheckExpr:      extractTextRange(fc.source, fc.fset, kv.Value.Pos(), kv.Value.End()),
+					CheckFunction:  name,
+					CheckLine:      check.line,
+					ResourceName:   resource,
+					ReferenceType:  checkDestroyReferenceType,
+					Namespace:      namespaces.Namespace(resource),
+				})
+			}
+			return false
+		})
+	})
+	return refs
+}
+
+// destroyFunctionName returns the function a CheckDestroy value names: the
+// function itself (testCheckSubnetDestroy), a method value (r.checkDestroy),
+// or the factory it calls (testCheckDestroy(data))
+func destroyFunctionName(value ast.Expr) string {
+	if call, ok := value.(*ast.CallExpr); ok {
+		value = call.Fun
+	}
+	switch v := value.(type) {
+	case *ast.Ident:
+		return v.Name
+	case *ast.SelectorExpr:
+		return v.Sel.Name
+	}
+	return ""
+}
+
+// checkedResourceTypes returns the string literals a body compares against a
+// .Type field, as in rs.Type != "azurerm_subnet" or a switch on rs.Type, in
+// order of appearance
+func checkedResourceTypes(body *ast.BlockStmt) []string {
+	var resources []string
+	seen := map[string]bool{}
+	add := func(expr ast.Expr) {
+		if resource, ok := stringLiteral(expr); ok && resource != "" && !seen[resource] {
+			seen[resource] = true
+			resources = append(resources, resource)
+		}
+	}
+	isType := func(expr ast.Expr) bool {
+		sel, ok := expr.(*ast.SelectorExpr)
+		return ok && sel.Sel.Name == "Type"
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.BinaryExpr:
+			if node.Op != token.EQL && node.Op != token.NEQ {
+				return true
+			}
+			if isType(node.X) {
+				add(node.Y)
+			} else if isType(node.Y) {
+				add(node.X)
+			}
+		case *ast.SwitchStmt:
+			if node.Tag == nil || !isType(node.Tag) {
+				return true
+			}
+			for _, stmt := range node.Body.List {
+				if clause, ok := stmt.(*ast.CaseClause); ok {
+					for _, expr := range clause.List {
+						add(expr)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return resources
+}
@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"go/ast"
+)
+
+// attributeCheckHelpers are the terraform-plugin-sdk check functions whose
+// arguments are the resource address, then the attribute path
+var attributeCheckHelpers = map[string]bool{
+	"TestCheckResourceAttr":           true,
+	"TestCheckResourceAttrSet":        true,
+	"TestCheckNoResourceAttr":         true,
+	"TestCheckResourceAttrPair":       true,
+	"TestMatchResourceAttr":           true,
+	"TestCheckTypeSetElemAttr":        true,
+	"TestCheckTypeSetElemNestedAttrs": true,
+}
+
+// CheckAssertion is one check a TestStep's Check field runs: the helper that
+// builds it, the resource it asserts on, and, for attribute checks, the
+// attribute path and the expected value
+type CheckAssertion struct {
+	SourceFile     string `json:"source_file"`
+	SourceService  string `json:"source_service"`
+	SourceFunction string `json:"source_function"`
+	StepLine       int    `json:"step_line"` // Line of the step, as TestStepInfo.SourceLine
+	Line           int    `json:"line"`
+
+	Helper    string `json:"helper"`              // e.g., "check.That" or "acceptance.TestCheckResourceAttr"
+	Resource  string `json:"resource,omitempty"`  // e.g., "data.ResourceName" or "\"azurerm_subnet.test\""
+	Assertion string `json:"assertion,omitempty"` // Final check.That method (e.g., "HasValue", "ExistsInAzure")
+	Attribute string `json:"attribute,omitempty"` // Attribute path (e.g., "sku" or "tags.%")
+	Value     string `json:"value,omitempty"`     // Expected value arguments as written
+}
+
+// extractCheckAssertions parses the Check field of every TestStep literal into
+// the assertions it composes. Check blocks are left out of the call records,
+// which follow configuration only, so this is the one place they are read.
+func extractCheckAssertions(fc *fileContext) []CheckAssertion {
+	byLine := map[int]*FunctionInfo{}
+	for i := range fc.functions {
+		byLine[fc.functions[i].Line] = &fc.functions[i]
+	}
+
+	var assertions []CheckAssertion
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+		fn := byLine[fc.fset.Position(funcDecl.Pos()).Line]
+		if fn == nil || fn.FunctionName != funcDecl.Name.Name {
+			return
+		}
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			compLit, ok := n.(*ast.CompositeLit)
+			if !ok || !isTestStepSlice(compLit) {
+				return true
+			}
+			for _, elt := range compLit.Elts {
+				stepLit, ok := elt.(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				for _, field := range stepLit.Elts {
+					kv, ok := field.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "Check" {
+						continue
+					}
+					step := CheckAssertion{
+						SourceFile:     fc.path,
+						SourceService:  fc.service,
+						SourceFunction: fn.FunctionName,
+						StepLine:       fc.fset.Position(stepLit.Pos()).Line,
+					}
+					assertions = collectCheckAssertions(fc, kv.Value, step, assertions)
+				}
+			}
+			return true
+		})
+	})
+	return assertions
+}
+
+// collectCheckAssertions appends the assertions of a check expression,
+// descending into Compose* helpers, which only combine other checks
+func collectCheckAssertions(fc *fileContext, expr ast.Expr, step CheckAssertion, assertions []CheckAssertion) []CheckAssertion {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return assertions
+	}
+	helper := exprToString(call.Fun)
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && (sel.Sel.Name == "ComposeTestCheckFunc" || sel.Sel.Name == "ComposeAggregateTestCheckFunc") {
+		for _, arg := range call.Args {
+			assertions = collectCheckAssertions(fc, arg, step, assertions)
+		}
+		return assertions
+	}
+
+	assertion := step
+	assertion.Line = fc.fset.Position(call.Pos()).Line
+	text := func(e ast.Expr) string {
+		return extractTextRange(fc.source, fc.fset, e.Pos(), e.End())
+	}
+	args := func(args []ast.Expr) string {
+		if len(args) == 0 {
+			return ""
+		}
+		return extractTextRange(fc.source, fc.fset, args[0].Pos(), args[len(args)-1].End())
+	}
+
+	// check.That(resource).Key("attr").HasValue("v"): walk the chain down to
+	// its check.That root, keeping the outermost method and the Key argument
+	chain := call
+	for {
+		sel, ok := chain.Fun.(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+		if root, ok := sel.X.(*ast.Ident); ok && root.Name == "check" && sel.Sel.Name == "That" {
+			assertion.Helper = "check.That"
+			if len(chain.Args) > 0 {
+				assertion.Resource = text(chain.Args[0])
+			}
+			if chain != call {
+				assertion.Assertion = call.Fun.(*ast.SelectorExpr).Sel.Name
+				assertion.Value = args(call.Args)
+			}
+			return append(assertions, assertion)
+		}
+		inner, ok := sel.X.(*ast.CallExpr)
+		if !ok {
+			break
+		}
+		if sel.Sel.Name == "Key" && len(chain.Args) > 0 {
+			if attribute, ok := stringLiteral(chain.Args[0]); ok {
+				assertion.Attribute = attribute
+			} else {
+				assertion.Attribute = text(chain.Args[0])
+			}
+		}
+		chain = inner
+	}
+
+	// acceptance.TestCheckResourceAttr("azurerm_x.test", "attr", "v") and the
+	// other SDK helpers of a resource address and an attribute path
+	assertion.Helper = helper
+	name := helper
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		name = sel.Sel.Name
+	}
+	if attributeCheckHelpers[name] && len(call.Args) >= 2 {
+		assertion.Resource = text(call.Args[0])
+		if attribute, ok := stringLiteral(call.Args[1]); ok {
+			assertion.Attribute = attribute
+		} else {
+			assertion.Attribute = text(call.Args[1])
+		}
+		assertion.Value = args(call.Args[2:])
+		return append(assertions, assertion)
+	}
+
+	// Custom check helpers (e.g., testCheckSubnetExists(data.ResourceName))
+	// are recorded by name, with their first argument as the resource
+	if len(call.Args) > 0 {
+		assertion.Resource = text(call.Args[0])
+	}
+	return append(assertions, assertion)
+}
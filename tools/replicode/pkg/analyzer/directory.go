@@ -0,0 +1,189 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AnalyzeDir walks a directory tree and analyzes every Go test file in it.
+// Files that fail to analyze are reported through opts.Skipped and left out so
+// that a single bad file doesn't abort a whole directory run.
+func AnalyzeDir(dir string, opts Options) ([]*Result, error) {
+	results := []*Result{}
+	err := AnalyzeDirFunc(dir, opts, func(result *Result) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// AnalyzeDirFunc is AnalyzeDir for callers that consume the results as they
+// are produced: it calls fn with each file's result, in AnalyzeDir's order,
+// instead of collecting them. An error from fn stops the walk and is returned.
+func AnalyzeDirFunc(dir string, opts Options, fn func(result *Result) error) error {
+	files, err := TestFiles(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fileOpts := opts
+		if opts.Prefilter || opts.SkipGenerated {
+			// Read once for both the prefilters and the analysis
+			src, err := os.ReadFile(file)
+			if err == nil && ((opts.Prefilter && !Relevant(src)) || (opts.SkipGenerated && Generated(src))) {
+				continue
+			}
+			fileOpts.Source = src
+		}
+		result, err := Analyze(file, fileOpts)
+		if err != nil {
+			if opts.Skipped != nil {
+				opts.Skipped(file, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", file, err)
+			}
+			continue
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Symlink is a symlink met walking a directory, or a file reached through
+// one that was already found through another path
+type Symlink struct {
+	Path     string `json:"path"`
+	Target   string `json:"target"` // Real path it resolves to
+	Followed bool   `json:"followed"`
+	Reason   string `json:"reason,omitempty"` // Why it was skipped
+}
+
+// DefaultExcludeDirs are the names of the directories AnalyzeDir does not walk
+// into unless Options.IncludeDirs names them: vendored modules, git metadata,
+// and third-party code hold no tests of the provider
+var DefaultExcludeDirs = []string{"vendor", ".git", "third_party"}
+
+// excludedDirs returns the set of directory names the walk skips
+func excludedDirs(opts Options) map[string]bool {
+	excluded := map[string]bool{}
+	for _, name := range DefaultExcludeDirs {
+		excluded[name] = true
+	}
+	for _, name := range opts.ExcludeDirs {
+		excluded[name] = true
+	}
+	for _, name := range opts.IncludeDirs {
+		delete(excluded, name)
+	}
+	return excluded
+}
+
+// TestFiles returns the *_test.go files under a directory, sorted. Symlinked
+// directories are skipped unless opts.FollowSymlinks is set, in which case
+// each real directory is walked once, so a link back to an ancestor ends
+// instead of looping. A file reachable through several paths is returned
+// once, preferring its path without links. Links followed and skipped are
+// reported through opts.Symlinks. Directories named by DefaultExcludeDirs or
+// opts.ExcludeDirs are not walked into, though dir itself always is.
+func TestFiles(dir string, opts Options) ([]string, error) {
+	excluded := excludedDirs(opts)
+	report := opts.Symlinks
+	if report == nil {
+		report = func(link Symlink) {
+			if !link.Followed {
+				fmt.Fprintf(os.Stderr, "Warning: skipping symlink %s -> %s: %s\n", link.Path, link.Target, link.Reason)
+			}
+		}
+	}
+
+	var files []string
+	var links []string                // Symlinks met, resolved once the real tree is walked
+	walkedDirs := map[string]bool{}   // Real paths
+	foundFiles := map[string]string{} // Real path -> path it was found by
+	addFile := func(path, realPath string) {
+		if first, found := foundFiles[realPath]; found {
+			report(Symlink{Path: path, Target: realPath, Reason: "already found as " + first})
+			return
+		}
+		foundFiles[realPath] = path
+		files = append(files, path)
+	}
+	var walk func(dir, realDir string) error
+	walk = func(dir, realDir string) error {
+		walkedDirs[realDir] = true
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			switch {
+			case entry.Type()&fs.ModeSymlink != 0:
+				links = append(links, path)
+			case entry.IsDir():
+				if excluded[entry.Name()] {
+					continue
+				}
+				if realPath := filepath.Join(realDir, entry.Name()); !walkedDirs[realPath] {
+					if err := walk(path, realPath); err != nil {
+						return err
+					}
+				}
+			case strings.HasSuffix(entry.Name(), "_test.go"):
+				// Templates and test functions only live in _test.go files
+				addFile(path, filepath.Join(realDir, entry.Name()))
+			}
+		}
+		return nil
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err == nil {
+		err = walk(dir, realDir)
+	}
+	// Links found in followed directories join the queue
+	for i := 0; err == nil && i < len(links); i++ {
+		path := links[i]
+		target, evalErr := filepath.EvalSymlinks(path)
+		if evalErr != nil {
+			report(Symlink{Path: path, Reason: fmt.Sprintf("broken link: %v", evalErr)})
+			continue
+		}
+		info, statErr := os.Stat(target)
+		switch {
+		case statErr != nil:
+			report(Symlink{Path: path, Target: target, Reason: statErr.Error()})
+		case !info.IsDir():
+			if strings.HasSuffix(path, "_test.go") {
+				addFile(path, target)
+			}
+		case excluded[filepath.Base(path)]:
+			continue
+		case !opts.FollowSymlinks:
+			report(Symlink{Path: path, Target: target, Reason: "symlinked directories are not followed"})
+		case walkedDirs[target]:
+			report(Symlink{Path: path, Target: target, Reason: "directory already walked"})
+		default:
+			report(Symlink{Path: path, Target: target, Followed: true})
+			err = walk(path, target)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("walking directory %s: %v", dir, err)
+	}
+
+	// Deterministic output regardless of filesystem ordering
+	sort.Strings(files)
+	return files, nil
+}
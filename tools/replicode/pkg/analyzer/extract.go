@@ -0,0 +1,2032 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// extractFunctions finds all function declarations - FILTERED for test relevance
+func extractFunctions(file *ast.File, fset *token.FileSet, filename, serviceName string) []FunctionInfo {
+	var functions []FunctionInfo
+
+	// CRITICAL FILTER: Only track test-relevant functions
+	// This prevents the data explosion (1.8M rows -> ~70K rows)
+
+	// Infrastructure/test lifecycle method names to exclude
+	infraMethodNames := map[string]bool{
+		"Exists":           true,
+		"Destroy":          true,
+		"preCheck":         true,
+		"checkDestroy":     true,
+		"testCheckDestroy": true,
+	}
+
+	// Prefixes to exclude
+	excludePrefixes := []string{
+		"Validate", "Parse", "Marshal", "Unmarshal",
+		"Expand", "Flatten",
+	}
+
+	// Suffixes to exclude
+	excludeSuffixes := []string{
+		"Schema", "Arguments", "Attributes",
+		"Validator", "Parser", "Client",
+	}
+
+	forEachFuncDecl(file, func(funcDecl *ast.FuncDecl) {
+		funcName := funcDecl.Name.Name
+
+		// FILTER 1: Exact match exclusions
+		if infraMethodNames[funcName] {
+			return
+		}
+
+		// FILTER 2: Prefix-based exclusions
+		for _, prefix := range excludePrefixes {
+			if strings.HasPrefix(funcName, prefix) {
+				return
+			}
+		}
+
+		// FILTER 3: Suffix-based exclusions
+		for _, suffix := range excludeSuffixes {
+			if strings.HasSuffix(funcName, suffix) {
+				return
+			}
+		}
+
+		// FILTER 4: Capital New* utilities (exclude)
+		// But lowercase newXxxResource() constructors are handled in FILTER 6
+		if len(funcName) > 0 && funcName[0] == 'N' && strings.HasPrefix(funcName, "New") {
+			return
+		}
+
+		// FILTER 5: Test functions (include)
+		isTestFunc := strings.HasPrefix(funcName, "Test") || strings.HasPrefix(funcName, "testAcc")
+
+		// FILTER 6: Lowercase newXxxResource() constructors (include if returns *XxxResource)
+		isResourceConstructor := false
+		if len(funcName) > 0 && funcName[0] == 'n' && strings.HasPrefix(funcName, "new") &&
+			strings.HasSuffix(funcName, "Resource") && funcDecl.Recv == nil {
+			// Check if it returns *XxxResource
+			if funcDecl.Type.Results != nil {
+				for _, field := range funcDecl.Type.Results.List {
+					if starExpr, ok := field.Type.(*ast.StarExpr); ok {
+						if ident, ok := starExpr.X.(*ast.Ident); ok {
+							if strings.HasSuffix(ident.Name, "Resource") {
+								isResourceConstructor = true
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+
+		// FILTER 7: Resource receiver methods that return string (template methods)
+		hasResourceReceiver := false
+		returnsString := false
+
+		if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+			recv := funcDecl.Recv.List[0]
+			var receiverTypeName string
+
+			switch recvType := recv.Type.(type) {
+			case *ast.StarExpr:
+				// Pointer receiver: (r *ManagerResource)
+				if ident, ok := recvType.X.(*ast.Ident); ok {
+					receiverTypeName = ident.Name
+				}
+			case *ast.Ident:
+				// Value receiver: (r ManagerResource)
+				receiverTypeName = recvType.Name
+			}
+
+			// Only track methods on XxxResource or XxxDataSource structs (pointer or value receiver)
+			if strings.HasSuffix(receiverTypeName, "Resource") || strings.HasSuffix(receiverTypeName, "DataSource") {
+				hasResourceReceiver = true
+
+				// Check if returns string
+				if funcDecl.Type.Results != nil {
+					for _, field := range funcDecl.Type.Results.List {
+						if ident, ok := field.Type.(*ast.Ident); ok {
+							if ident.Name == "string" {
+								returnsString = true
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+
+		// Accept function only if it matches one of our criteria:
+		// - Test function (Test* or testAcc*)
+		// - Resource constructor (newXxxResource returning *XxxResource)
+		// - Resource/DataSource method returning string (template method)
+		if !isTestFunc && !isResourceConstructor && !(hasResourceReceiver && returnsString) {
+			return
+		}
+
+		fn := FunctionInfo{
+			File:         filename,
+			Line:         fset.Position(funcDecl.Pos()).Line,
+			FunctionName: funcName,
+			IsTestFunc:   isTestFunc,
+			IsExported:   ast.IsExported(funcName),
+			ServiceName:  serviceName,
+		}
+
+		// Extract receiver if this is a method
+		if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+			recv := funcDecl.Recv.List[0]
+
+			// Get receiver variable name (e.g., "r")
+			if len(recv.Names) > 0 {
+				fn.ReceiverVar = recv.Names[0].Name
+			}
+
+			// Get receiver type (e.g., "PrivateEndpointResource")
+			switch recvType := recv.Type.(type) {
+			case *ast.StarExpr:
+				if ident, ok := recvType.X.(*ast.Ident); ok {
+					fn.ReceiverType = ident.Name
+				}
+			case *ast.Ident:
+				fn.ReceiverType = recvType.Name
+			}
+		}
+
+		functions = append(functions, fn)
+	})
+
+	return functions
+}
+
+// enrichTestFunctionsWithStructInfo finds struct assignments in test function bodies
+// and updates the ReceiverType for test functions (which are not methods)
+func enrichTestFunctionsWithStructInfo(file *ast.File, fset *token.FileSet, functions *[]FunctionInfo) {
+	// Build function return type map (for resolving function calls)
+	functionReturnTypes := extractFunctionReturnTypes(file)
+
+	// Create map of line -> function for lookup
+	lineToFunc := make(map[int]*FunctionInfo)
+	for i := range *functions {
+		fn := &(*functions)[i]
+		lineToFunc[fn.Line] = fn
+	}
+
+	// Visit each function and look for struct assignments
+	forEachFuncDecl(file, func(funcDecl *ast.FuncDecl) {
+		// Only process test functions
+		funcName := funcDecl.Name.Name
+		if !strings.HasPrefix(funcName, "Test") && !strings.HasPrefix(funcName, "testAcc") {
+			return
+		}
+
+		// Skip if it's already a method (has a receiver)
+		if funcDecl.Recv != nil {
+			return
+		}
+
+		// Find the corresponding FunctionInfo
+		line := fset.Position(funcDecl.Pos()).Line
+		fn, exists := lineToFunc[line]
+		if !exists {
+			return
+		}
+
+		// Look for the first variable assignment in the function body
+		// Pattern: r := StructName{} or r, err := newFunction()
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			// Stop if we already found a struct type
+			if fn.ReceiverType != "" {
+				return false
+			}
+
+			assignStmt, ok := n.(*ast.AssignStmt)
+			if !ok {
+				return true
+			}
+
+			// We need at least one LHS and RHS
+			if len(assignStmt.Lhs) == 0 || len(assignStmt.Rhs) == 0 {
+				return true
+			}
+
+			// Get the first variable name
+			lhsIdent, ok := assignStmt.Lhs[0].(*ast.Ident)
+			if !ok {
+				return true
+			}
+			varName := lhsIdent.Name
+
+			// Only care about 'r' variable (common convention)
+			if varName != "r" {
+				return true
+			}
+
+			rhsExpr := assignStmt.Rhs[0]
+
+			// Pattern 1: r := StructName{}
+			if compLit, ok := rhsExpr.(*ast.CompositeLit); ok {
+				if ident, ok := compLit.Type.(*ast.Ident); ok {
+					fn.ReceiverType = ident.Name
+					fn.ReceiverVar = varName
+					return false // Found it, stop searching
+				}
+			}
+
+			// Pattern 2: r, err := newFunction()
+			if callExpr, ok := rhsExpr.(*ast.CallExpr); ok {
+				if funcIdent, ok := callExpr.Fun.(*ast.Ident); ok {
+					functionName := funcIdent.Name
+					if returnType, exists := functionReturnTypes[functionName]; exists {
+						fn.ReceiverType = returnType
+						fn.ReceiverVar = varName
+						return false // Found it, stop searching
+					}
+				}
+			}
+
+			return true
+		})
+	})
+}
+
+// enrichTestFunctionsWithTestType detects if test functions call data.DataSourceTest or data.ResourceTest
+func enrichTestFunctionsWithTestType(file *ast.File, fset *token.FileSet, functions *[]FunctionInfo) {
+	// Create map of line -> function for lookup
+	lineToFunc := make(map[int]*FunctionInfo)
+	for i := range *functions {
+		fn := &(*functions)[i]
+		if fn.IsTestFunc {
+			lineToFunc[fn.Line] = fn
+		}
+	}
+
+	// Visit each function and look for data.DataSourceTest or data.ResourceTest calls
+	forEachFuncDecl(file, func(funcDecl *ast.FuncDecl) {
+		// Only process test functions
+		funcLine := fset.Position(funcDecl.Pos()).Line
+		fn, exists := lineToFunc[funcLine]
+		if !exists {
+			return
+		}
+
+		// Search function body for data.DataSourceTest or data.ResourceTest calls
+		ast.Inspect(funcDecl.Body, func(n2 ast.Node) bool {
+			callExpr, ok := n2.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			// Check if this is a selector expression (e.g., data.DataSourceTest)
+			selExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			// Check if the selector is on "data" identifier
+			if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Name == "data" {
+				methodName := selExpr.Sel.Name
+				switch methodName {
+				case "DataSourceTest":
+					fn.IsDataSourceTest = true
+					return false // Found it, stop searching this function
+				case "ResourceTest":
+					fn.IsDataSourceTest = false
+					return false // Found it, stop searching this function
+				}
+			}
+
+			return true
+		})
+	})
+}
+
+// enrichTestFunctionsWithResourceUnderTest records the resource type named by
+// the first acceptance.BuildTestData call of each test function, which states
+// the resource under test without relying on the HCL of its configs
+func enrichTestFunctionsWithResourceUnderTest(file *ast.File, fset *token.FileSet, functions *[]FunctionInfo) {
+	lineToFunc := make(map[int]*FunctionInfo)
+	for i := range *functions {
+		fn := &(*functions)[i]
+		if fn.IsTestFunc {
+			lineToFunc[fn.Line] = fn
+		}
+	}
+
+	forEachFuncDecl(file, func(funcDecl *ast.FuncDecl) {
+		fn, exists := lineToFunc[fset.Position(funcDecl.Pos()).Line]
+		if !exists || funcDecl.Body == nil {
+			return
+		}
+
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			if fn.ResourceUnderTest != "" {
+				return false
+			}
+			if assign, ok := n.(*ast.AssignStmt); ok {
+				if _, data, ok := testDataAssignment(assign); ok {
+					fn.ResourceUnderTest = data.resourceType
+					return false
+				}
+			}
+			return true
+		})
+	})
+}
+
+// functionCallVisitor finds all function call sites - FILTERED to prevent explosion.
+// With checkCalls set, the calls inside Check: fields are recorded there
+// instead of being skipped; the configuration calls are the same either way.
+func functionCallVisitor(fc *fileContext, out *[]FunctionCall, checkCalls *[]CheckCall) visitor {
+	// CRITICAL FILTER: Only track calls in Config: field and template bodies
+	// IGNORE all calls in Check: field (validation code)
+
+	// Tracked functions (test functions and resource methods), plain
+	// functions by name apart from methods, so that a direct call to basic()
+	// does not match a basic method and r.basic() does not match a basic
+	// function. Methods are keyed by receiver type as well, for the calls
+	// whose receiver is a struct literal; the file's methods are all of its
+	// own package, so a literal of another package's struct matches none.
+	trackedFunctions := make(map[string]FunctionInfo)
+	trackedMethods := make(map[[2]string]FunctionInfo) // {receiver type, method}
+	methodsByName := make(map[string]FunctionInfo)     // For receivers of unknown type
+	for _, fn := range fc.functions {
+		if fn.ReceiverType == "" {
+			if _, exists := trackedFunctions[fn.FunctionName]; !exists {
+				trackedFunctions[fn.FunctionName] = fn
+			}
+			continue
+		}
+		trackedMethods[[2]string{fn.ReceiverType, fn.FunctionName}] = fn
+		if _, exists := methodsByName[fn.FunctionName]; !exists {
+			methodsByName[fn.FunctionName] = fn
+		}
+	}
+	imports := importNames(fc.file)
+
+	serviceName := fc.service
+
+	// Track current function context
+	var currentFunc *FunctionInfo
+	inCheckBlock := false // Track if we're inside a Check: block
+	var argBuf []byte     // Reused to join each recorded call's arguments
+
+	// The Check: field being walked for checkCalls, while inside it
+	var checkEnd token.Pos
+	checkLine := 0
+
+	return func(n ast.Node) bool {
+		// Track which function we're in
+		if funcDecl, ok := n.(*ast.FuncDecl); ok {
+			line := fc.fset.Position(funcDecl.Pos()).Line
+			if fn, exists := fc.lineToFunc[line]; exists {
+				currentFunc = &fn
+				inCheckBlock = false // Reset Check block flag
+			} else {
+				// Not in a tracked function, don't process calls
+				currentFunc = nil
+				inCheckBlock = false
+			}
+		}
+
+		// CRITICAL: Detect Check: field in test steps
+		// Check blocks contain validation code (Exists, checkDestroy), NOT configuration
+		if kvExpr, ok := n.(*ast.KeyValueExpr); ok {
+			if ident, ok := kvExpr.Key.(*ast.Ident); ok {
+				if ident.Name == "Check" {
+					inCheckBlock = true
+					if checkCalls == nil || currentFunc == nil {
+						return false // Don't visit children of Check block
+					}
+					checkEnd, checkLine = kvExpr.End(), fc.fset.Position(kvExpr.Pos()).Line
+					return true
+				}
+			}
+		}
+
+		// Calls inside the Check: field go to checkCalls, in their own category
+		if n != nil && n.Pos() < checkEnd {
+			if callExpr, ok := n.(*ast.CallExpr); ok {
+				var call CheckCall
+				var ok bool
+				if call, argBuf, ok = newCheckCall(fc, currentFunc, checkLine, callExpr, imports, argBuf); ok {
+					*checkCalls = append(*checkCalls, call)
+				}
+			}
+			return true
+		}
+
+		// FILTER: Only track calls if we're inside a tracked function AND NOT in Check block
+		if currentFunc == nil || inCheckBlock {
+			return true
+		}
+
+		// Look for function calls
+		callExpr, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		call := FunctionCall{
+			CallerFile:    fc.path,
+			CallerService: serviceName,
+			Line:          fc.fset.Position(callExpr.Pos()).Line,
+		}
+
+		if currentFunc != nil {
+			call.CallerFunction = currentFunc.FunctionName
+		}
+
+		// The receiver's struct and, when it is another package's, the
+		// import path of that package
+		receiverStruct, receiverPackage := "", ""
+
+		// Analyze the call expression
+		switch fun := callExpr.Fun.(type) {
+		case *ast.SelectorExpr:
+			// Method call: receiver.method()
+			call.IsMethodCall = true
+			call.MethodName = fun.Sel.Name
+
+			// Get receiver expression
+			if ident, ok := fun.X.(*ast.Ident); ok {
+				call.ReceiverExpr = ident.Name
+
+				// Check if this is a local receiver call
+				if currentFunc != nil && currentFunc.ReceiverVar == ident.Name {
+					call.IsLocalCall = true
+				} else if ident.Obj == nil && imports[ident.Name] != "" {
+					receiverPackage = imports[ident.Name] // Package function (e.g., acceptance.BuildTestData)
+				}
+			} else {
+				// Complex receiver expression (e.g., pkg.Type{})
+				call.ReceiverExpr = exprToString(fun.X)
+				receiverStruct, receiverPackage = literalReceiver(fun.X, imports)
+			}
+
+			call.FullCall = call.ReceiverExpr + "." + call.MethodName
+
+		case *ast.Ident:
+			// Direct function call
+			call.IsMethodCall = false
+			call.MethodName = fun.Name
+			call.FullCall = fun.Name
+		}
+
+		call.NumArgs = len(callExpr.Args)
+
+		// FILTER: Only record calls to other tracked functions OR local receiver calls
+		// This prevents tracking calls to SDK functions, validators, etc.
+		shouldRecord := false
+		targetService := ""
+
+		if call.IsLocalCall {
+			// Always track local receiver calls (r.method())
+			shouldRecord = true
+			targetService = serviceName // Same service
+		} else if receiverPackage != "" {
+			// Another package: track the templates of a service package
+			// (network.SubnetResource{}.basic()), never this file's methods
+			if receiverStruct != "" {
+				targetService = fc.services.ServiceName(receiverPackage)
+				shouldRecord = targetService != ""
+			}
+		} else {
+			// Track calls to other tracked test/template functions
+			var fn FunctionInfo
+			var exists bool
+			if !call.IsMethodCall {
+				fn, exists = trackedFunctions[call.MethodName]
+			} else if fn, exists = trackedMethods[[2]string{receiverStruct, call.MethodName}]; !exists {
+				// A variable, or a struct of the package declared in another
+				// file, whose methods share the package's service
+				fn, exists = methodsByName[call.MethodName]
+			}
+			if exists {
+				shouldRecord = true
+				targetService = fn.ServiceName
+			}
+		}
+
+		if shouldRecord && call.MethodName != "" {
+			call.TargetService = targetService
+			// Stringify the arguments only for the calls that are kept
+			call.Arguments, argBuf = argumentsString(callExpr.Args, argBuf)
+			*out = append(*out, call)
+		}
+
+		return true
+	}
+}
+
+// ExtractImports finds all import statements
+func ExtractImports(file *ast.File) []ImportInfo {
+	var imports []ImportInfo
+
+	for _, imp := range file.Imports {
+		info := ImportInfo{
+			PackagePath: strings.Trim(imp.Path.Value, `"`),
+		}
+
+		if imp.Name != nil {
+			info.Alias = imp.Name.Name
+		} else {
+			// Extract package name from path
+			parts := strings.Split(info.PackagePath, "/")
+			info.PackageName = parts[len(parts)-1]
+		}
+
+		imports = append(imports, info)
+	}
+
+	return imports
+}
+
+// extractFunctionReturnTypes walks the AST to find function declarations and their return types
+// This is used to resolve patterns like: r, err := newSomeResource(...)
+func extractFunctionReturnTypes(file *ast.File) map[string]string {
+	returnTypes := make(map[string]string)
+
+	forEachFuncDecl(file, func(funcDecl *ast.FuncDecl) {
+		// Skip methods (we only want package-level functions)
+		if funcDecl.Recv != nil {
+			return
+		}
+
+		// Skip functions with no return values
+		if funcDecl.Type.Results == nil || len(funcDecl.Type.Results.List) == 0 {
+			return
+		}
+
+		functionName := funcDecl.Name.Name
+
+		// Extract the first non-error return type
+		// Pattern: func newResource(...) (*SomeResource, error)
+		// We want to capture "SomeResource" (ignoring pointer and error)
+		for _, field := range funcDecl.Type.Results.List {
+			var typeName string
+
+			switch t := field.Type.(type) {
+			case *ast.Ident:
+				// Direct type: error, bool, etc.
+				typeName = t.Name
+			case *ast.StarExpr:
+				// Pointer type: *SomeResource
+				if ident, ok := t.X.(*ast.Ident); ok {
+					typeName = ident.Name
+				}
+			case *ast.SelectorExpr:
+				// Package-qualified type: pkg.Type
+				if ident, ok := t.X.(*ast.Ident); ok {
+					typeName = ident.Name + "." + t.Sel.Name
+				}
+			}
+
+			// Skip error types - we want the actual return value
+			if typeName != "" && typeName != "error" {
+				returnTypes[functionName] = typeName
+				break // Take the first non-error type
+			}
+		}
+	})
+	return returnTypes
+}
+
+// extractVariableAssignments processes assignment statements to track local variables
+// Handles patterns like:
+//   - r := PrivateEndpointResource{} (struct instantiation)
+//   - config := r.multipleInstances(data, count, false) (method call)
+//   - r, err := newSiteRecoveryVMWareReplicatedVMResource(...) (function call with multiple returns)
+func extractVariableAssignments(assignStmt *ast.AssignStmt, varAssignments map[string]*VarAssignment, currentFunc *FunctionInfo, functionReturnTypes map[string]string, fset *token.FileSet, source []byte) {
+	// Handle different assignment patterns:
+	// 1. Simple: x := value (len(LHS) == len(RHS))
+	// 2. Multi-value return: x, y := function() (len(LHS) > len(RHS), RHS is call expression)
+
+	// For multi-value returns, process the first LHS variable with the call expression
+	if len(assignStmt.Lhs) > len(assignStmt.Rhs) {
+		// Multi-value return from function call
+		if len(assignStmt.Rhs) != 1 {
+			return // Unexpected pattern
+		}
+
+		// Get the first variable being assigned to (usually the return value, e.g., "r")
+		lhsIdent, ok := assignStmt.Lhs[0].(*ast.Ident)
+		if !ok {
+			return
+		}
+
+		varName := lhsIdent.Name
+		rhsExpr := assignStmt.Rhs[0]
+
+		// Check if RHS is a function call
+		callExpr, ok := rhsExpr.(*ast.CallExpr)
+		if !ok {
+			return
+		}
+
+		// Check if it's a simple function call (not a method)
+		if funcIdent, ok := callExpr.Fun.(*ast.Ident); ok {
+			functionName := funcIdent.Name
+
+			// Look up the function's return type
+			if returnType, exists := functionReturnTypes[functionName]; exists {
+				// Extract full expression text
+				fullExpr := extractTextRange(source, fset, rhsExpr.Pos(), rhsExpr.End())
+
+				// Store the assignment with the function's return type as the struct
+				varAssignments[varName] = &VarAssignment{
+					VarName:        varName,
+					ReceiverVar:    varName,
+					ReceiverStruct: returnType,
+					MethodName:     functionName, // Store the constructor function name
+					FullExpr:       fullExpr,
+				}
+			}
+		}
+		return
+	}
+
+	// Simple assignments: len(LHS) == len(RHS)
+	if len(assignStmt.Lhs) != len(assignStmt.Rhs) {
+		return // Mismatched assignment
+	}
+
+	for i := 0; i < len(assignStmt.Lhs); i++ {
+		// Get the variable being assigned to
+		lhsIdent, ok := assignStmt.Lhs[i].(*ast.Ident)
+		if !ok {
+			continue // Not a simple identifier
+		}
+
+		varName := lhsIdent.Name
+		rhsExpr := assignStmt.Rhs[i]
+
+		// Pattern 1: Struct instantiation (r := PrivateEndpointResource{})
+		if compLit, ok := rhsExpr.(*ast.CompositeLit); ok {
+			if ident, ok := compLit.Type.(*ast.Ident); ok {
+				structName := ident.Name
+				// Store as a special assignment with no method
+				varAssignments[varName] = &VarAssignment{
+					VarName:        varName,
+					ReceiverVar:    varName,
+					ReceiverStruct: structName,
+					MethodName:     "", // No method - this is the struct itself
+					FullExpr:       structName + "{}",
+				}
+			}
+			continue
+		}
+
+		// Patterns 2 & 3: Function/method call
+		callExpr, ok := rhsExpr.(*ast.CallExpr)
+		if !ok {
+			continue // Not a function call
+		}
+
+		// Pattern 2: Method call (config := r.multipleInstances(...))
+		if selectorExpr, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
+			// Extract receiver variable
+			receiverIdent, ok := selectorExpr.X.(*ast.Ident)
+			if !ok {
+				continue // Receiver is not a simple identifier
+			}
+
+			// Extract method name
+			methodName := selectorExpr.Sel.Name
+			receiverVar := receiverIdent.Name
+
+			// Resolve receiver struct in priority order:
+			// 1. Check if it's the function's receiver
+			// 2. Check if it's a previously tracked local variable
+			receiverStruct := ""
+			if currentFunc != nil && currentFunc.ReceiverVar == receiverVar {
+				receiverStruct = currentFunc.ReceiverType
+			} else if prevAssignment, exists := varAssignments[receiverVar]; exists {
+				receiverStruct = prevAssignment.ReceiverStruct
+			}
+
+			// Extract full expression text
+			fullExpr := extractTextRange(source, fset, rhsExpr.Pos(), rhsExpr.End())
+
+			// Store the assignment
+			varAssignments[varName] = &VarAssignment{
+				VarName:        varName,
+				ReceiverVar:    receiverVar,
+				ReceiverStruct: receiverStruct,
+				MethodName:     methodName,
+				FullExpr:       fullExpr,
+			}
+			continue
+		}
+
+		// Pattern 3: Function call (r, err := newSiteRecoveryVMWareReplicatedVMResource(...))
+		if funcIdent, ok := callExpr.Fun.(*ast.Ident); ok {
+			functionName := funcIdent.Name
+
+			// Look up the function's return type
+			if returnType, exists := functionReturnTypes[functionName]; exists {
+				// Extract full expression text
+				fullExpr := extractTextRange(source, fset, rhsExpr.Pos(), rhsExpr.End())
+
+				// Store the assignment with the function's return type as the struct
+				varAssignments[varName] = &VarAssignment{
+					VarName:        varName,
+					ReceiverVar:    varName,
+					ReceiverStruct: returnType,
+					MethodName:     functionName, // Store the constructor function name
+					FullExpr:       fullExpr,
+				}
+			}
+		}
+	}
+}
+
+// extractVariableDeclarations handles var declarations like: var f FluidRelayResource
+func extractVariableDeclarations(declStmt *ast.DeclStmt, varAssignments map[string]*VarAssignment) {
+	// Check if this is a GenDecl (general declaration)
+	genDecl, ok := declStmt.Decl.(*ast.GenDecl)
+	if !ok {
+		return
+	}
+
+	// We only care about variable declarations (var)
+	if genDecl.Tok != token.VAR {
+		return
+	}
+
+	// Process each spec in the declaration
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+
+		// Extract the type information
+		var typeName string
+		switch t := valueSpec.Type.(type) {
+		case *ast.Ident:
+			// Simple type: var f FluidRelayResource
+			typeName = t.Name
+		case *ast.StarExpr:
+			// Pointer type: var f *FluidRelayResource
+			if ident, ok := t.X.(*ast.Ident); ok {
+				typeName = ident.Name
+			}
+		case *ast.SelectorExpr:
+			// Qualified type: var f package.FluidRelayResource
+			typeName = t.Sel.Name
+		default:
+			continue
+		}
+
+		// Store the declaration for each variable name
+		for _, name := range valueSpec.Names {
+			varName := name.Name
+			varAssignments[varName] = &VarAssignment{
+				VarName:        varName,
+				ReceiverVar:    varName,
+				ReceiverStruct: typeName,
+				MethodName:     "", // No method - this is the variable itself
+				FullExpr:       "var " + varName + " " + typeName,
+			}
+		}
+	}
+}
+
+// isTestStepSlice reports whether a composite literal is a TestStep slice:
+// []acceptance.TestStep, []resource.TestStep, or []pluginsdk.TestStep
+func isTestStepSlice(compLit *ast.CompositeLit) bool {
+	arrayType, ok := compLit.Type.(*ast.ArrayType)
+	if !ok {
+		return false
+	}
+	selectorExpr, ok := arrayType.Elt.(*ast.SelectorExpr)
+	if !ok || selectorExpr.Sel.Name != "TestStep" {
+		return false
+	}
+	pkgIdent, ok := selectorExpr.X.(*ast.Ident)
+	return ok && (pkgIdent.Name == "acceptance" || pkgIdent.Name == "resource" || pkgIdent.Name == "pluginsdk")
+}
+
+// testStepVisitor finds []acceptance.TestStep composite literals and extracts each element
+func testStepVisitor(fc *fileContext, out *[]TestStepInfo) visitor {
+	// Extract function return types for resolving function call assignments
+	functionReturnTypes := extractFunctionReturnTypes(fc.file)
+
+	serviceName := fc.service
+
+	// Track current function context
+	var currentFunc *FunctionInfo
+
+	// Track variable assignments in current function scope
+	// Map: variable name -> assignment expression info
+	varAssignments := make(map[string]*VarAssignment)
+
+	// Track BuildTestData results, which name the resources steps taint
+	testDataVars := make(map[string]testData)
+
+	return func(n ast.Node) bool {
+		// Track which function we're in
+		if funcDecl, ok := n.(*ast.FuncDecl); ok {
+			line := fc.fset.Position(funcDecl.Pos()).Line
+			if fn, exists := fc.lineToFunc[line]; exists {
+				currentFunc = &fn
+				// Clear variable assignments when entering new function
+				varAssignments = make(map[string]*VarAssignment)
+				testDataVars = make(map[string]testData)
+			}
+		}
+
+		// Track variable assignments like: config := r.multipleInstances(...)
+		if assignStmt, ok := n.(*ast.AssignStmt); ok && currentFunc != nil {
+			extractVariableAssignments(assignStmt, varAssignments, currentFunc, functionReturnTypes, fc.fset, fc.source)
+			if variable, data, ok := testDataAssignment(assignStmt); ok {
+				testDataVars[variable] = data
+			}
+		}
+
+		// Track variable declarations like: var f FluidRelayResource
+		if declStmt, ok := n.(*ast.DeclStmt); ok && currentFunc != nil {
+			extractVariableDeclarations(declStmt, varAssignments)
+		}
+
+		// Look for []acceptance.TestStep composite literals
+		compLit, ok := n.(*ast.CompositeLit)
+		if !ok || !isTestStepSlice(compLit) {
+			return true
+		}
+
+		// Found a []acceptance.TestStep{...} array!
+		// Extract each element in the array
+		stepIndex := 1
+		for _, elt := range compLit.Elts {
+			// Each element should be a composite literal {Config: ..., Check: ...}
+			stepLit, ok := elt.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+
+			// FILTER: Skip infrastructure validation test steps
+			// We only want steps that test actual resource configuration.
+			// Filter out:
+			// 1. Steps with no Config field (import-only steps, check-only steps)
+			//    Examples: data.ImportStep(), steps with only Check field
+			//
+			// Keep steps that have Config field (actual configuration tests)
+			// - It's OK if they also have Check (validation is expected)
+			// - It's OK if they have ExpectError (error configs can have cross-service references)
+			hasConfigField := false
+			for _, field := range stepLit.Elts {
+				kvExpr, ok := field.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kvExpr.Key.(*ast.Ident); ok {
+					if key.Name == "Config" {
+						hasConfigField = true
+						break
+					}
+				}
+			}
+
+			// Skip this step if it has no Config field
+			if !hasConfigField {
+				continue
+			} // Get the full text of this element from source
+			stepBody := extractTextRange(fc.source, fc.fset, stepLit.Pos(), stepLit.End())
+
+			stepInfo := TestStepInfo{
+				SourceFile:    fc.path,
+				SourceLine:    fc.fset.Position(stepLit.Pos()).Line,
+				StepIndex:     stepIndex,
+				StepBody:      stepBody,
+				SourceService: serviceName,
+			}
+
+			if currentFunc != nil {
+				stepInfo.SourceFunction = currentFunc.FunctionName
+				stepInfo.SourceStruct = currentFunc.ReceiverType
+			}
+
+			// Extract Config field information
+			extractConfigInfo(&stepInfo, stepLit, fc.fset, fc.source, currentFunc, varAssignments, fc.functions)
+			stepInfo.ResourceUnderTest = stepResourceUnderTest(stepLit, currentFunc, testDataVars)
+			stepInfo.ExpectError = expectedError(stepLit, fc.fset, fc.source)
+			if field := stepField(stepLit, "PreConfig"); field != nil {
+				stepInfo.HasPreConfig = true
+				stepInfo.PreConfigCalls = preConfigCalls(field.Value, fc, currentFunc, varAssignments)
+			}
+			if field := stepField(stepLit, "SkipFunc"); field != nil {
+				stepInfo.SkipFunc = skipCondition(field.Value, fc.fset, fc.source)
+			}
+			if field := stepField(stepLit, "Destroy"); field != nil {
+				if ident, ok := field.Value.(*ast.Ident); ok && ident.Name == "true" {
+					stepInfo.Destroy = true
+				}
+			}
+			if field := stepField(stepLit, "Taint"); field != nil {
+				stepInfo.Taint = taintAddresses(field.Value, fc.fset, fc.source, testDataVars)
+			}
+
+			*out = append(*out, stepInfo)
+			stepIndex++
+		}
+
+		return true
+	}
+}
+
+// stepResourceUnderTest returns the BuildTestData resource type of the first
+// data variable a step's Config expression passes, falling back to the
+// resource under test of the enclosing test function
+func stepResourceUnderTest(stepLit *ast.CompositeLit, currentFunc *FunctionInfo, testDataVars map[string]testData) string {
+	resourceType := ""
+	if field := stepField(stepLit, "Config"); field != nil {
+		ast.Inspect(field.Value, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && resourceType == "" {
+				resourceType = testDataVars[ident.Name].resourceType
+			}
+			return resourceType == ""
+		})
+	}
+	if resourceType == "" && currentFunc != nil {
+		resourceType = currentFunc.ResourceUnderTest
+	}
+	return resourceType
+}
+
+// templateCallVisitor finds template function calls within fmt.Sprintf arguments
+// This builds the template -> template reference chain for IndirectConfigReferences
+// CROSS-FILE ONLY: Only tracks calls to methods in different files (cross-service dependencies)
+func templateCallVisitor(fc *fileContext, out *[]TemplateFunctionCall) visitor {
+	// Build a map of method name -> function for same-file detection
+	// Key: "ReceiverType.MethodName" -> FunctionInfo
+	methodToFunc := make(map[string]FunctionInfo)
+	for _, fn := range fc.functions {
+		if fn.ReceiverType != "" {
+			key := fn.ReceiverType + "." + fn.FunctionName
+			methodToFunc[key] = fn
+		}
+	}
+
+	imports := importNames(fc.file)
+
+	// Track current function context
+	var currentFunc *FunctionInfo
+
+	return func(n ast.Node) bool {
+		// Track which function we're in
+		if funcDecl, ok := n.(*ast.FuncDecl); ok {
+			line := fc.fset.Position(funcDecl.Pos()).Line
+			if fn, exists := fc.lineToFunc[line]; exists {
+				currentFunc = &fn
+			}
+		}
+
+		// Look for function calls
+		callExpr, ok := n.(*ast.CallExpr)
+		if !ok || currentFunc == nil {
+			return true
+		}
+
+		// Check if this is a fmt.Sprintf call
+		if !isFmtSprintfCall(callExpr) {
+			return true
+		}
+
+		// Extract template calls from sprintf arguments (skip the format string)
+		for i, arg := range callExpr.Args {
+			if i == 0 {
+				// Skip the format string
+				continue
+			}
+
+			// Extract template calls (cross-file only)
+			extractTemplateCallsFromExpr(arg, currentFunc, fc, imports, methodToFunc, out)
+		}
+
+		return true
+	}
+}
+
+// extractTemplateCallsFromExpr extracts template calls from an expression
+// CROSS-FILE ONLY: Only extracts calls to methods in different files
+// DEPTH LIMIT: Only extracts the top-level call, NOT nested calls within arguments
+// Example: fmt.Sprintf("%s", r.basic(data))
+//   - If basic() is in same file: SKIP (embedded call, not tracked)
+//   - If basic() is in different file: TRACK (cross-file dependency)
+func extractTemplateCallsFromExpr(expr ast.Expr, currentFunc *FunctionInfo, fc *fileContext, imports map[string]string, methodToFunc map[string]FunctionInfo, templateCalls *[]TemplateFunctionCall) {
+	// Check if this expression itself is a template call
+	templateCall, targetPackage := analyzeTemplateCallExpr(expr, fc.fset, fc.source, imports)
+	if templateCall == nil {
+		return
+	}
+
+	// Set source information
+	templateCall.SourceFunction = currentFunc.FunctionName
+	templateCall.SourceFile = fc.path
+	templateCall.SourceLine = fc.fset.Position(expr.Pos()).Line
+	templateCall.SourceService = fc.service
+
+	// Resolve the struct type if the variable is the receiver
+	if templateCall.TargetVariable != "" &&
+		currentFunc.ReceiverVar != "" &&
+		templateCall.TargetVariable == currentFunc.ReceiverVar {
+		templateCall.TargetStruct = currentFunc.ReceiverType
+	}
+
+	// Track ALL template calls (same-file + cross-file) for complete dependency chains
+	// Set ReferenceTypeId based on whether target is in same file, but ALWAYS append them
+	if targetPackage != "" {
+		// Another package's struct (network.SubnetResource{}.basic(data)):
+		// never a method of this file, and of the package's service
+		templateCall.ReferenceTypeId = 2 // CROSS_FILE
+		templateCall.TargetService = fc.services.ServiceName(targetPackage)
+	} else if templateCall.TargetStruct != "" && templateCall.TargetMethod != "" {
+		key := templateCall.TargetStruct + "." + templateCall.TargetMethod
+		if _, existsInSameFile := methodToFunc[key]; existsInSameFile {
+			// Same-file call - mark as EMBEDDED_SELF (internal template composition)
+			templateCall.ReferenceTypeId = 3 // EMBEDDED_SELF
+			// Determine target service from the same file (use current function's service)
+			templateCall.TargetService = currentFunc.ServiceName
+		} else {
+			// Cross-file call - mark as CROSS_FILE (will be verified later, might become EXTERNAL_REFERENCE)
+			templateCall.ReferenceTypeId = 2 // CROSS_FILE (assumes target exists in another analyzed file)
+			// Determine target service by looking up the method in functions
+			for _, fn := range fc.functions {
+				if fn.ReceiverType == templateCall.TargetStruct && fn.FunctionName == templateCall.TargetMethod {
+					templateCall.TargetService = fn.ServiceName
+					break
+				}
+			}
+		}
+	}
+
+	// This is a cross-file call - track it
+	*templateCalls = append(*templateCalls, *templateCall)
+
+	// REMOVED: Recursive descent into arguments
+	// We only track direct calls from fmt.Sprintf arguments
+	// Nested calls within those arguments will be tracked when we analyze
+	// the called function's body (depth = 1 tracking)
+}
+
+// extractSequentialReferences extracts t.Run() and RunTestsInSequence() calls from test functions
+func extractSequentialReferences(fc *fileContext) []SequentialReference {
+	var seqRefs []SequentialReference
+
+	// Build a map of test function names for lookup
+	testFuncMap := make(map[string]FunctionInfo)
+	for _, fn := range fc.functions {
+		if fn.IsTestFunc {
+			testFuncMap[fn.FunctionName] = fn
+		}
+	}
+
+	// Walk the AST looking for function declarations (test functions)
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+
+		// Get the current function info
+		var currentFunc *FunctionInfo
+		for i := range fc.functions {
+			if fc.functions[i].FunctionName == funcDecl.Name.Name {
+				currentFunc = &fc.functions[i]
+				break
+			}
+		}
+
+		if currentFunc == nil || !currentFunc.IsTestFunc {
+			return // Skip non-test functions
+		}
+
+		// Look for t.Run() calls and acceptance.RunTestsInSequence() calls
+		ast.Inspect(funcDecl.Body, func(n2 ast.Node) bool {
+			callExpr, ok := n2.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			// Check for data.ResourceSequentialTest(t, r, []acceptance.TestStep{...}) pattern
+			// Pattern: data.ResourceSequentialTest(t, resource, steps)
+			// This indicates the test function calls other test steps sequentially
+			if sel, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
+				if _, ok := sel.X.(*ast.Ident); ok && sel.Sel.Name == "ResourceSequentialTest" {
+					// Extract the current test function as a sequential entry point
+					// The test steps within are the sequential references
+					// For now, we'll just mark this test as having sequential behavior
+					// The actual test steps are handled separately in the TestStepInfo extraction
+
+					// Note: We don't create individual SequentialReference records here
+					// because ResourceSequentialTest uses TestStep arrays, not named function references
+					// The sequential nature is implicit in the test execution order
+				}
+
+				// Check for t.Run(name, func) pattern
+				if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == "t" && sel.Sel.Name == "Run" {
+					// Extract t.Run(name, func) where name is a string literal
+					if len(callExpr.Args) >= 2 {
+						// Get the test name (first argument)
+						var testName string
+						if basicLit, ok := callExpr.Args[0].(*ast.BasicLit); ok && basicLit.Kind == token.STRING {
+							testName = strings.Trim(basicLit.Value, `"`)
+						}
+
+						// Get the function being called (second argument)
+						// This can be a function literal or a function identifier
+						var referencedFunc string
+						switch arg := callExpr.Args[1].(type) {
+						case *ast.Ident:
+							referencedFunc = arg.Name
+						case *ast.FuncLit:
+							// For function literals in t.Run, we don't track them
+							// as separate sequential references
+							return true
+						}
+
+						if testName != "" && referencedFunc != "" {
+							seqRefs = append(seqRefs, SequentialReference{
+								EntryPointFunction: currentFunc.FunctionName,
+								EntryPointFile:     fc.path,
+								EntryPointLine:     fc.fset.Position(callExpr.Pos()).Line,
+								ReferencedFunction: referencedFunc,
+								SequentialGroup:    testName,
+								SequentialKey:      "",
+							})
+						}
+					}
+				}
+
+				// Check for acceptance.RunTestsInSequence(t, map[string]map[string]func) pattern
+				if pkgIdent, ok := sel.X.(*ast.Ident); ok && pkgIdent.Name == "acceptance" && sel.Sel.Name == "RunTestsInSequence" {
+					// The second argument should be a composite literal (the map)
+					if len(callExpr.Args) >= 2 {
+						if compLit, ok := callExpr.Args[1].(*ast.CompositeLit); ok {
+							// Parse the map structure: map[string]map[string]func
+							for _, elt := range compLit.Elts {
+								kvExpr, ok := elt.(*ast.KeyValueExpr)
+								if !ok {
+									continue
+								}
+
+								// Get the outer key (group name)
+								var groupName string
+								if keyLit, ok := kvExpr.Key.(*ast.BasicLit); ok && keyLit.Kind == token.STRING {
+									groupName = strings.Trim(keyLit.Value, `"`)
+								}
+
+								// The value should be another map: map[string]func
+								if innerMap, ok := kvExpr.Value.(*ast.CompositeLit); ok {
+									for _, innerElt := range innerMap.Elts {
+										innerKV, ok := innerElt.(*ast.KeyValueExpr)
+										if !ok {
+											continue
+										}
+
+										// Get the inner key (test key)
+										var testKey string
+										if innerKeyLit, ok := innerKV.Key.(*ast.BasicLit); ok && innerKeyLit.Kind == token.STRING {
+											testKey = strings.Trim(innerKeyLit.Value, `"`)
+										}
+
+										// Get the function name (value)
+										var funcName string
+										if innerValueIdent, ok := innerKV.Value.(*ast.Ident); ok {
+											funcName = innerValueIdent.Name
+										}
+
+										if groupName != "" && testKey != "" && funcName != "" {
+											seqRefs = append(seqRefs, SequentialReference{
+												EntryPointFunction: currentFunc.FunctionName,
+												EntryPointFile:     fc.path,
+												EntryPointLine:     fc.fset.Position(callExpr.Pos()).Line,
+												ReferencedFunction: funcName,
+												SequentialGroup:    groupName,
+												SequentialKey:      testKey,
+											})
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+
+			return true
+		})
+
+		// ADDITIONAL PATTERN: Map-based sequential tests
+		// Pattern: testCases := map[string]map[string]func(t *testing.T){ ... }
+		//          for group, m := range testCases { t.Run(group, ...) }
+		// This extracts the map structure directly from the variable assignment
+		// Variable name can be anything, but commonly "testCases"
+		ast.Inspect(funcDecl.Body, func(n2 ast.Node) bool {
+			// Look for assignment statements
+			assignStmt, ok := n2.(*ast.AssignStmt)
+			if !ok {
+				return true
+			}
+
+			// Check if this is a := ... assignment (short variable declaration)
+			if len(assignStmt.Lhs) != 1 || len(assignStmt.Rhs) != 1 {
+				return true
+			}
+
+			// The RHS should be a composite literal (the map)
+			compLit, ok := assignStmt.Rhs[0].(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+
+			// Check if the composite literal type is map[string]map[string]...
+			// This validates we're looking at the right kind of map structure
+			mapType, ok := compLit.Type.(*ast.MapType)
+			if !ok {
+				return true
+			}
+
+			// Outer map key should be string
+			if keyIdent, ok := mapType.Key.(*ast.Ident); !ok || keyIdent.Name != "string" {
+				return true
+			}
+
+			// Outer map value should be another map
+			innerMapType, ok := mapType.Value.(*ast.MapType)
+			if !ok {
+				return true
+			}
+
+			// Inner map key should be string
+			if innerKeyIdent, ok := innerMapType.Key.(*ast.Ident); !ok || innerKeyIdent.Name != "string" {
+				return true
+			}
+
+			// If we got here, we have a map[string]map[string]... structure
+			// Parse the map structure
+			for _, elt := range compLit.Elts {
+				kvExpr, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+
+				// Get the outer key (group name)
+				var groupName string
+				if keyLit, ok := kvExpr.Key.(*ast.BasicLit); ok && keyLit.Kind == token.STRING {
+					groupName = strings.Trim(keyLit.Value, `"`)
+				}
+
+				// The value should be another map: map[string]func
+				if innerMap, ok := kvExpr.Value.(*ast.CompositeLit); ok {
+					for _, innerElt := range innerMap.Elts {
+						innerKV, ok := innerElt.(*ast.KeyValueExpr)
+						if !ok {
+							continue
+						}
+
+						// Get the inner key (test key)
+						var testKey string
+						if innerKeyLit, ok := innerKV.Key.(*ast.BasicLit); ok && innerKeyLit.Kind == token.STRING {
+							testKey = strings.Trim(innerKeyLit.Value, `"`)
+						}
+
+						// Get the function name (value)
+						var funcName string
+						if innerValueIdent, ok := innerKV.Value.(*ast.Ident); ok {
+							funcName = innerValueIdent.Name
+						}
+
+						if groupName != "" && testKey != "" && funcName != "" {
+							seqRefs = append(seqRefs, SequentialReference{
+								EntryPointFunction: currentFunc.FunctionName,
+								EntryPointFile:     fc.path,
+								EntryPointLine:     fc.fset.Position(assignStmt.Pos()).Line,
+								ReferencedFunction: funcName,
+								SequentialGroup:    groupName,
+								SequentialKey:      testKey,
+							})
+						}
+					}
+				}
+			}
+
+			return true
+		})
+	})
+
+	return seqRefs
+}
+
+// extractDirectResourceReferences extracts direct Azure resource references from template function bodies
+// Parses HCL strings returned by template functions to find:
+// 1. resource "azurerm_xxx" "test" { ... } → RESOURCE_BLOCK
+// 2. data "azurerm_xxx" "test" { ... } → DATA_SOURCE_BLOCK
+// 3. azurerm_xxx.test.attribute → ATTRIBUTE_REFERENCE
+// Only extracts references matching targetResource (e.g., only azurerm_resource_group refs)
+func extractDirectResourceReferences(fc *fileContext, targetResource string) []DirectResourceReference {
+	var directRefs []DirectResourceReference
+
+	// Build a map of template functions (non-test functions that return strings)
+	templateFuncs := make(map[string]*FunctionInfo)
+	for i := range fc.functions {
+		if !fc.functions[i].IsTestFunc {
+			templateFuncs[fc.functions[i].FunctionName] = &fc.functions[i]
+		}
+	}
+
+	// Walk the AST to find template function bodies
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+
+		// Find the corresponding FunctionInfo
+		var currentFunc *FunctionInfo
+		for i := range fc.functions {
+			if fc.functions[i].FunctionName == funcDecl.Name.Name && !fc.functions[i].IsTestFunc {
+				currentFunc = &fc.functions[i]
+				break
+			}
+		}
+
+		if currentFunc == nil {
+			return // Not a template function
+		}
+
+		// Extract string literals from return statements and fmt.Sprintf calls
+		hclContent := extractHCLContentFromFunction(funcDecl)
+		if hclContent == "" {
+			return
+		}
+
+		// Parse the HCL content for resource references (filtered by targetResource)
+		refs := parseHCLForResourceReferences(hclContent, currentFunc.FunctionName, fc.path, currentFunc.Line, targetResource, fc.resourcePrefixes)
+		directRefs = append(directRefs, refs...)
+	})
+
+	return directRefs
+}
+
+// extractRequiresImportReferences records the resource type named by each
+// acceptance.RequiresImportError call (or data.RequiresImportErrorStep step)
+// of a tracked function as a REQUIRES_IMPORT_ERROR direct reference. The
+// argument is a string literal or data.ResourceType of a BuildTestData
+// variable; ContextLine is the call's line relative to the function.
+func extractRequiresImportReferences(fc *fileContext, targetResource string) []DirectResourceReference {
+	var refs []DirectResourceReference
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+		fn, ok := fc.lineToFunc[fc.fset.Position(funcDecl.Pos()).Line]
+		if !ok || fn.FunctionName != funcDecl.Name.Name {
+			return
+		}
+
+		testDataVars := map[string]testData{}
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			if assign, ok := n.(*ast.AssignStmt); ok {
+				if variable, data, ok := testDataAssignment(assign); ok {
+					testDataVars[variable] = data
+				}
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			resourceName := ""
+			switch sel.Sel.Name {
+			case "RequiresImportError":
+				if len(call.Args) != 1 {
+					return true
+				}
+				if name, ok := stringLiteral(call.Args[0]); ok {
+					resourceName = name
+				} else if arg, ok := call.Args[0].(*ast.SelectorExpr); ok && arg.Sel.Name == "ResourceType" {
+					if data, ok := arg.X.(*ast.Ident); ok {
+						resourceName = testDataVars[data.Name].resourceType
+					}
+				}
+			case "RequiresImportErrorStep":
+				if data, ok := sel.X.(*ast.Ident); ok {
+					resourceName = testDataVars[data.Name].resourceType
+				}
+			}
+			if resourceName == "" || (targetResource != "" && resourceName != targetResource) {
+				return true
+			}
+
+			line := fc.fset.Position(call.Pos()).Line
+			refs = append(refs, DirectResourceReference{
+				TemplateFunction: fn.FunctionName,
+				TemplateFile:     fc.path,
+				TemplateLine:     fn.Line,
+				ResourceName:     resourceName,
+				ReferenceType:    "REQUIRES_IMPORT_ERROR",
+				Context:          extractTextRange(fc.source, fc.fset, call.Pos(), call.End()),
+				ContextLine:      line - fn.Line + 1,
+			})
+			return true
+		})
+	})
+	return refs
+}
+
+// extractHCLContentFromFunction extracts HCL string content from a template function
+// Looks for return statements with string literals or fmt.Sprintf calls
+func extractHCLContentFromFunction(funcDecl *ast.FuncDecl) string {
+	var hclContent strings.Builder
+
+	// Walk the function body to find return statements
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		// Look for return statements
+		returnStmt, ok := n.(*ast.ReturnStmt)
+		if !ok || len(returnStmt.Results) == 0 {
+			return true
+		}
+
+		// Check if it's a fmt.Sprintf call
+		if callExpr, ok := returnStmt.Results[0].(*ast.CallExpr); ok {
+			if isFmtSprintfCall(callExpr) {
+				// Extract string literals from fmt.Sprintf arguments
+				for _, arg := range callExpr.Args {
+					if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						// Remove quotes and unescape the string
+						content := strings.Trim(lit.Value, "`\"")
+						content = strings.ReplaceAll(content, "\\n", "\n")
+						content = strings.ReplaceAll(content, "\\t", "\t")
+						hclContent.WriteString(content)
+						hclContent.WriteString("\n")
+					}
+				}
+			}
+		}
+
+		// Also check for direct string literals
+		if lit, ok := returnStmt.Results[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			content := strings.Trim(lit.Value, "`\"")
+			content = strings.ReplaceAll(content, "\\n", "\n")
+			content = strings.ReplaceAll(content, "\\t", "\t")
+			hclContent.WriteString(content)
+		}
+
+		return true
+	})
+
+	return hclContent.String()
+}
+
+// parseHCLForResourceReferences parses HCL content to find Azure resource references
+// Only extracts references matching targetResource (e.g., only azurerm_resource_group)
+// and resource types starting with one of prefixes (e.g., "azurerm_")
+func parseHCLForResourceReferences(hclContent, templateFunc, templateFile string, templateLine int, targetResource string, prefixes []string) []DirectResourceReference {
+	var refs []DirectResourceReference
+
+	// Split into lines for line-by-line analysis
+	lines := strings.Split(hclContent, "\n")
+
+	for lineNum, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Pattern 1: resource "azurerm_xxx" "name" {
+		// Pattern 2: data "azurerm_xxx" "name" {
+		if strings.HasPrefix(trimmed, "resource \"") || strings.HasPrefix(trimmed, "data \"") {
+			// Determine if this is a data source or resource block
+			isDataSource := strings.HasPrefix(trimmed, "data \"")
+
+			// Extract resource name
+			parts := strings.Fields(trimmed)
+			if len(parts) >= 2 {
+				resourceName := strings.Trim(parts[1], "\"")
+				// Only add if it matches targetResource (or if no filter specified)
+				if resourceTypePrefix(resourceName, prefixes) != "" && (targetResource == "" || resourceName == targetResource) {
+					// Set reference type based on whether it's a data source or resource
+					refType := "RESOURCE_BLOCK"
+					if isDataSource {
+						refType = "DATA_SOURCE_BLOCK"
+					}
+
+					refs = append(refs, DirectResourceReference{
+						TemplateFunction: templateFunc,
+						TemplateFile:     templateFile,
+						TemplateLine:     templateLine,
+						ResourceName:     resourceName,
+						ReferenceType:    refType,
+						Context:          trimmed,
+						ContextLine:      lineNum + 1,
+					})
+				}
+			}
+		}
+
+		// Pattern 3: azurerm_xxx.name.attribute (attribute reference)
+		// Look for patterns like: resource_group_name = azurerm_resource_group.test.name
+		if containsResourcePrefix(trimmed, prefixes) {
+			// Use regex to find azurerm_xxx.name patterns
+			// Pattern: azurerm_[a-z0-9_]+\.[a-z0-9_]+
+			words := strings.FieldsFunc(trimmed, func(r rune) bool {
+				return r == ' ' || r == '=' || r == '(' || r == ')' || r == ',' || r == '[' || r == ']' || r == '{' || r == '}'
+			})
+
+			for _, word := range words {
+				if resourceTypePrefix(word, prefixes) != "" && strings.Count(word, ".") >= 1 {
+					// Extract the resource type (azurerm_xxx)
+					parts := strings.Split(word, ".")
+					if len(parts) >= 2 {
+						resourceName := parts[0]
+						// Only add if it matches targetResource (or if no filter specified)
+						if targetResource == "" || resourceName == targetResource {
+							// Only add if we haven't already added a RESOURCE_BLOCK for this resource on this line
+							isDuplicate := false
+							for _, existing := range refs {
+								if existing.ContextLine == lineNum+1 && existing.ResourceName == resourceName && existing.ReferenceType == "RESOURCE_BLOCK" {
+									isDuplicate = true
+									break
+								}
+							}
+
+							if !isDuplicate {
+								refs = append(refs, DirectResourceReference{
+									TemplateFunction: templateFunc,
+									TemplateFile:     templateFile,
+									TemplateLine:     templateLine,
+									ResourceName:     resourceName,
+									ReferenceType:    "ATTRIBUTE_REFERENCE",
+									Context:          trimmed,
+									ContextLine:      lineNum + 1,
+								})
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// isFmtSprintfCall checks if a call expression is fmt.Sprintf
+func isFmtSprintfCall(callExpr *ast.CallExpr) bool {
+	selector, ok := callExpr.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+
+	pkgIdent, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	return pkgIdent.Name == "fmt" && selector.Sel.Name == "Sprintf"
+}
+
+// analyzeTemplateCallExpr analyzes an expression to see if it's a template function call
+// Returns TemplateFunctionCall if it matches patterns like: r.template(data), StructName{}.method(data),
+// pkg.StructName{}.method(data), and for the last the import path of pkg
+func analyzeTemplateCallExpr(expr ast.Expr, fset *token.FileSet, source []byte, imports map[string]string) (*TemplateFunctionCall, string) {
+	callExpr, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, ""
+	}
+
+	templateCall := &TemplateFunctionCall{}
+
+	// Extract the full expression text
+	templateCall.TargetExpr = extractTextRange(source, fset, callExpr.Pos(), callExpr.End())
+
+	// Parse the function being called
+	switch fun := callExpr.Fun.(type) {
+	case *ast.SelectorExpr:
+		// Pattern: r.template(data) or StructName{}.method(data)
+		templateCall.TargetMethod = fun.Sel.Name
+
+		switch x := fun.X.(type) {
+		case *ast.Ident:
+			// Pattern: r.template(data) - variable.method
+			templateCall.TargetVariable = x.Name
+			// ReferenceTypeId will be determined later based on file comparison
+			templateCall.ReferenceTypeId = 3 // Default to EMBEDDED_SELF (will be updated if cross-file)
+
+		default:
+			// Pattern: StructName{}.method(data) or pkg.StructName{}.method(data) - direct struct instantiation
+			// ReferenceTypeId will be determined later by checking if the method exists in the same file
+			var targetPackage string
+			templateCall.TargetStruct, targetPackage = literalReceiver(x, imports)
+			return templateCall, targetPackage
+		}
+
+		return templateCall, ""
+
+	default:
+		// Not a method call pattern we're looking for
+		return nil, ""
+	}
+}
+
+// literalReceiver returns the struct type of a struct literal receiver, as in
+// SubnetResource{}.basic() or (&network.SubnetResource{}).basic(), and the
+// import path of its package when it is qualified with one
+func literalReceiver(x ast.Expr, imports map[string]string) (string, string) {
+	for {
+		if paren, ok := x.(*ast.ParenExpr); ok {
+			x = paren.X
+		} else if unary, ok := x.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			x = unary.X
+		} else {
+			break
+		}
+	}
+	lit, ok := x.(*ast.CompositeLit)
+	if !ok {
+		return "", ""
+	}
+	switch typ := lit.Type.(type) {
+	case *ast.Ident:
+		return typ.Name, ""
+	case *ast.SelectorExpr:
+		if pkg, ok := typ.X.(*ast.Ident); ok {
+			importPath := imports[pkg.Name]
+			if importPath == "" {
+				importPath = pkg.Name // Not imported by name; still another package
+			}
+			return typ.Sel.Name, importPath
+		}
+	}
+	return "", ""
+}
+
+// extractConfigInfo parses the Config field from a TestStep composite literal
+// and extracts variable, method, and struct information
+func extractConfigInfo(stepInfo *TestStepInfo, stepLit *ast.CompositeLit, fset *token.FileSet, source []byte, currentFunc *FunctionInfo, varAssignments map[string]*VarAssignment, functions []FunctionInfo) {
+	// Iterate through the fields of the composite literal
+	for _, elt := range stepLit.Elts {
+		kvExpr, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+
+		// Check if this is the Config field
+		key, ok := kvExpr.Key.(*ast.Ident)
+		if !ok || key.Name != "Config" {
+			continue
+		}
+
+		// Extract the full expression text
+		stepInfo.ConfigExpr = extractTextRange(source, fset, kvExpr.Value.Pos(), kvExpr.Value.End())
+
+		// Parse the expression to extract variable and method
+		parseConfigExpression(stepInfo, kvExpr.Value, currentFunc, varAssignments)
+
+		// Determine ConfigService by looking up the method in functions
+		if stepInfo.ConfigMethod != "" {
+			for _, fn := range functions {
+				if fn.FunctionName == stepInfo.ConfigMethod {
+					stepInfo.ConfigService = fn.ServiceName
+					break
+				}
+			}
+		}
+
+		break
+	}
+}
+
+// expectedError returns the pattern a step's ExpectError field compiles with
+// regexp.MustCompile, the field's source text when it is not a literal
+// pattern, or "" when the step has no ExpectError
+func expectedError(stepLit *ast.CompositeLit, fset *token.FileSet, source []byte) string {
+	for _, field := range stepLit.Elts {
+		kv, ok := field.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); !ok || key.Name != "ExpectError" {
+			continue
+		}
+		if call, ok := kv.Value.(*ast.CallExpr); ok && len(call.Args) == 1 {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "MustCompile" {
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "regexp" {
+					if pattern, ok := stringLiteral(call.Args[0]); ok {
+						return pattern
+					}
+				}
+			}
+		}
+		return extractTextRange(source, fset, kv.Value.Pos(), kv.Value.End())
+	}
+	return ""
+}
+
+// skipCondition returns the condition a SkipFunc value tests: the first
+// result of the return statement ending a function literal, or the value's
+// source text when it is not a literal or returns from several places
+func skipCondition(value ast.Expr, fset *token.FileSet, source []byte) string {
+	if funcLit, ok := value.(*ast.FuncLit); ok && funcLit.Body != nil {
+		returns := 0
+		var last *ast.ReturnStmt
+		ast.Inspect(funcLit.Body, func(n ast.Node) bool {
+			if _, ok := n.(*ast.FuncLit); ok {
+				return false // Returns of nested closures are theirs
+			}
+			if ret, ok := n.(*ast.ReturnStmt); ok {
+				returns++
+				last = ret
+			}
+			return true
+		})
+		if returns == 1 && len(last.Results) > 0 {
+			return extractTextRange(source, fset, last.Results[0].Pos(), last.Results[0].End())
+		}
+	}
+	return extractTextRange(source, fset, value.Pos(), value.End())
+}
+
+// taintAddresses returns the elements of a Taint []string literal, resolving
+// string literals and data.ResourceName of a BuildTestData variable to the
+// address they name. Any other value is recorded as written.
+func taintAddresses(value ast.Expr, fset *token.FileSet, source []byte, testDataVars map[string]testData) []TaintAddress {
+	elts := []ast.Expr{value}
+	if compLit, ok := value.(*ast.CompositeLit); ok {
+		elts = compLit.Elts
+	}
+
+	var addresses []TaintAddress
+	for _, elt := range elts {
+		taint := TaintAddress{Expr: extractTextRange(source, fset, elt.Pos(), elt.End())}
+		if address, ok := stringLiteral(elt); ok {
+			taint.Address = address
+		} else if sel, ok := elt.(*ast.SelectorExpr); ok && sel.Sel.Name == "ResourceName" {
+			if data, ok := sel.X.(*ast.Ident); ok {
+				taint.Address = testDataVars[data.Name].address()
+			}
+		}
+		if resourceType, _, ok := strings.Cut(taint.Address, "."); ok && resourceType != "module" {
+			taint.ResourceType = resourceType
+		}
+		addresses = append(addresses, taint)
+	}
+	return addresses
+}
+
+// preConfigCalls returns the calls a PreConfig function makes to functions
+// declared in the file, including setup helpers that are not tracked
+// functions, resolving method receivers as Config expressions are resolved. A
+// PreConfig given as a method or function value counts as a call to it.
+func preConfigCalls(value ast.Expr, fc *fileContext, currentFunc *FunctionInfo, varAssignments map[string]*VarAssignment) []PreConfigCall {
+	var declared []FunctionInfo // Receiver type and name of every declaration
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		fn := FunctionInfo{FunctionName: funcDecl.Name.Name}
+		if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+			recvType := funcDecl.Recv.List[0].Type
+			if star, ok := recvType.(*ast.StarExpr); ok {
+				recvType = star.X
+			}
+			if ident, ok := recvType.(*ast.Ident); ok {
+				fn.ReceiverType = ident.Name
+			}
+		}
+		declared = append(declared, fn)
+	})
+
+	resolve := func(fun ast.Expr, node ast.Node) (PreConfigCall, bool) {
+		call := PreConfigCall{Line: fc.fset.Position(node.Pos()).Line, Expr: extractTextRange(fc.source, fc.fset, node.Pos(), node.End())}
+		switch f := fun.(type) {
+		case *ast.SelectorExpr:
+			call.Method = f.Sel.Name
+			call.Receiver = exprToString(f.X)
+			switch x := f.X.(type) {
+			case *ast.Ident:
+				if currentFunc != nil && currentFunc.ReceiverVar != "" && x.Name == currentFunc.ReceiverVar {
+					call.Struct = currentFunc.ReceiverType
+				} else if assignment, exists := varAssignments[x.Name]; exists {
+					call.Struct = assignment.ReceiverStruct
+				}
+			case *ast.CompositeLit:
+				if ident, ok := x.Type.(*ast.Ident); ok {
+					call.Struct = ident.Name
+				}
+			}
+			for _, fn := range declared {
+				if fn.ReceiverType != "" && fn.FunctionName == call.Method && (call.Struct == "" || fn.ReceiverType == call.Struct) {
+					return call, true
+				}
+			}
+		case *ast.Ident:
+			call.Method = f.Name
+			for _, fn := range declared {
+				if fn.ReceiverType == "" && fn.FunctionName == call.Method {
+					return call, true
+				}
+			}
+		}
+		return call, false
+	}
+
+	var calls []PreConfigCall
+	lit, ok := value.(*ast.FuncLit)
+	if !ok {
+		if call, tracked := resolve(value, value); tracked {
+			calls = append(calls, call)
+		}
+		return calls
+	}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if callExpr, ok := n.(*ast.CallExpr); ok {
+			if call, tracked := resolve(callExpr.Fun, callExpr); tracked {
+				calls = append(calls, call)
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// parseConfigExpression analyzes the Config field expression
+// Handles patterns like: r.basic(data), StructName{}.method(data), func(...) { return r.method(...) }, config (variable)
+func parseConfigExpression(stepInfo *TestStepInfo, expr ast.Expr, currentFunc *FunctionInfo, varAssignments map[string]*VarAssignment) {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		// This is a function call - extract the function being called
+		switch fun := e.Fun.(type) {
+		case *ast.SelectorExpr:
+			// Pattern: r.basic(data) or StructName{}.method(data)
+			stepInfo.ConfigMethod = fun.Sel.Name
+
+			// Check what's on the left of the dot
+			switch x := fun.X.(type) {
+			case *ast.Ident:
+				// Pattern: r.basic(data) - variable.method
+				stepInfo.ConfigVariable = x.Name
+				stepInfo.IsLocalCall = true // We'll verify this later
+
+			case *ast.CompositeLit:
+				// Pattern: StructName{}.method(data) - direct struct instantiation
+				if ident, ok := x.Type.(*ast.Ident); ok {
+					stepInfo.ConfigStruct = ident.Name
+					stepInfo.IsLocalCall = true
+				}
+			}
+
+		case *ast.Ident:
+			// Pattern: someFunction(data) - direct function call (rare)
+			stepInfo.ConfigMethod = fun.Name
+		}
+
+	case *ast.Ident:
+		// Pattern: Config: config - a variable reference
+		// Store the variable name - we'll need to trace it back to its assignment
+		stepInfo.ConfigVariable = e.Name
+		// Mark as local call by default - variable assignments are typically in same function
+		stepInfo.IsLocalCall = true
+
+	case *ast.FuncLit:
+		// Pattern: func(...) { return r.method(...) } - legacy anonymous function
+		// Parse the function body to find the return statement
+		if e.Body != nil && len(e.Body.List) > 0 {
+			for _, stmt := range e.Body.List {
+				if retStmt, ok := stmt.(*ast.ReturnStmt); ok && len(retStmt.Results) > 0 {
+					// Recursively parse the return expression
+					parseConfigExpression(stepInfo, retStmt.Results[0], currentFunc, varAssignments)
+					break
+				}
+			}
+		}
+	}
+
+	// Resolve the struct type using multiple strategies:
+	// 1. Check if variable is the function's receiver
+	// 2. Check if variable is a local variable with known struct type
+	if stepInfo.ConfigVariable != "" {
+		// Strategy 1: Function receiver (e.g., func (r Resource) TestFunc() { ... Config: r.basic() })
+		if currentFunc != nil && currentFunc.ReceiverVar != "" && stepInfo.ConfigVariable == currentFunc.ReceiverVar {
+			stepInfo.ConfigStruct = currentFunc.ReceiverType
+		}
+		// Strategy 2: Local variable with struct instantiation (e.g., r := Resource{})
+		if stepInfo.ConfigStruct == "" {
+			if assignment, exists := varAssignments[stepInfo.ConfigVariable]; exists {
+				stepInfo.ConfigStruct = assignment.ReceiverStruct
+			}
+		}
+	}
+
+	// Resolve variable assignments for simple variable references (e.g., Config: config)
+	// This handles: config := r.multipleInstances(...) followed by Config: config
+	if stepInfo.ConfigVariable != "" && stepInfo.ConfigMethod == "" {
+		if assignment, exists := varAssignments[stepInfo.ConfigVariable]; exists {
+			// Found the variable assignment! Extract the method and struct info
+			stepInfo.ConfigMethod = assignment.MethodName
+			stepInfo.ConfigStruct = assignment.ReceiverStruct
+			// Update ConfigVariable to point to the receiver (e.g., "r")
+			// Keep the original in ConfigExpr which already has the variable name
+			stepInfo.ConfigVariable = assignment.ReceiverVar
+			stepInfo.IsLocalCall = true
+		}
+	}
+}
+
+// extractTextRange returns the source text between two positions, sliced from
+// the file content by byte offset so that a call costs the length of the text
+// rather than that of the file
+func extractTextRange(source []byte, fset *token.FileSet, start, end token.Pos) string {
+	file := fset.File(start)
+	if file == nil {
+		return ""
+	}
+	from, to := file.Offset(start), file.Offset(end)
+	if to < from || to > len(source) {
+		return ""
+	}
+	return string(source[from:to])
+}
+
+// ImportInfo represents an import statement
+type ImportInfo struct {
+	PackagePath string
+	PackageName string
+	Alias       string
+}
+
+// exprToString converts an expression to a string (best effort)
+func exprToString(expr ast.Expr) string {
+	// Most arguments and receivers are plain names and literals, whose strings
+	// the AST already holds
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.BasicLit:
+		return e.Value
+	}
+	return string(appendExpr(nil, expr))
+}
+
+// appendExpr appends the exprToString form of an expression to dst, so that
+// nested expressions and argument lists are built in one buffer that the
+// caller can reuse
+func appendExpr(dst []byte, expr ast.Expr) []byte {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return append(dst, e.Name...)
+	case *ast.SelectorExpr:
+		dst = appendExpr(dst, e.X)
+		dst = append(dst, '.')
+		return append(dst, e.Sel.Name...)
+	case *ast.CallExpr:
+		// Function call as argument
+		dst = appendExpr(dst, e.Fun)
+		if len(e.Args) > 0 {
+			return append(dst, "(...)"...)
+		}
+		return append(dst, "()"...)
+	case *ast.BasicLit:
+		// Literal value (string, number, etc.)
+		return append(dst, e.Value...)
+	case *ast.CompositeLit:
+		// Composite literal like []string{...}
+		return append(dst, "composite{...}"...)
+	case *ast.UnaryExpr:
+		// Unary expression like &value
+		dst = append(dst, e.Op.String()...)
+		return appendExpr(dst, e.X)
+	case *ast.BinaryExpr:
+		// Binary expression like a + b
+		dst = appendExpr(dst, e.X)
+		dst = append(dst, ' ')
+		dst = append(dst, e.Op.String()...)
+		dst = append(dst, ' ')
+		return appendExpr(dst, e.Y)
+	default:
+		return append(dst, '?')
+	}
+}
+
+// argumentsString joins the exprToString forms of call arguments with ", ",
+// building them in buf, which is returned for reuse by the next call
+func argumentsString(args []ast.Expr, buf []byte) (string, []byte) {
+	switch len(args) {
+	case 0:
+		return "", buf
+	case 1:
+		return exprToString(args[0]), buf
+	}
+	buf = buf[:0]
+	for i, arg := range args {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = appendExpr(buf, arg)
+	}
+	return string(buf), buf
+}
@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"sync"
+)
+
+// Record is one finding of a custom extractor. It is written to the JSON
+// output as-is, so values must be JSON-serializable.
+type Record map[string]interface{}
+
+// ExtractContext is what the built-in extractors found in the file, for
+// custom extractors to build on. Paths are relative to the repository root.
+type ExtractContext struct {
+	FilePath  string
+	Service   string // Service the file belongs to (e.g., "network")
+	Source    []byte
+	Functions []FunctionInfo
+	Imports   []ImportInfo
+}
+
+// Extractor finds additional records in a parsed file, for patterns the
+// built-in extractors do not know about (custom test frameworks, internal
+// helpers). Extract is called once per analyzed file and must be safe for
+// concurrent use.
+type Extractor interface {
+	Name() string // Key of the records in Result.Extensions
+	Extract(file *ast.File, fset *token.FileSet, ctx *ExtractContext) ([]Record, error)
+}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{}
+)
+
+// Register adds an extractor that runs on every analyzed file, in addition to
+// any in Options.Extractors. It is meant to be called from an init function,
+// including that of a Go plugin. Registering two extractors with the same
+// name panics.
+func Register(extractor Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	name := extractor.Name()
+	if name == "" {
+		panic("analyzer: Register of an extractor without a name")
+	}
+	if _, exists := extractors[name]; exists {
+		panic("analyzer: Register called twice for extractor " + name)
+	}
+	extractors[name] = extractor
+}
+
+// Extractors returns the names of the registered extractors, sorted
+func Extractors() []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runExtractors runs the registered extractors and those in opts over a file
+// and returns their non-empty record lists keyed by extractor name
+func runExtractors(file *ast.File, fset *token.FileSet, ctx *ExtractContext, extra []Extractor, trace *fileTrace) (map[string][]Record, error) {
+	var all []Extractor
+	extractorsMu.RLock()
+	for _, extractor := range extractors {
+		all = append(all, extractor)
+	}
+	extractorsMu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name() < all[j].Name() })
+	all = append(all, extra...)
+
+	var extensions map[string][]Record
+	for _, extractor := range all {
+		done := trace.step("extractor " + extractor.Name())
+		records, err := extractor.Extract(file, fset, ctx)
+		done(len(records))
+		if err != nil {
+			trace.fail(err)
+			return nil, fmt.Errorf("extractor %s: %v", extractor.Name(), err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if extensions == nil {
+			extensions = map[string][]Record{}
+		}
+		extensions[extractor.Name()] = append(extensions[extractor.Name()], records...)
+	}
+	return extensions, nil
+}
@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"go/ast"
+)
+
+// importStepKind is the record kind of every ImportStepInfo
+const importStepKind = "IMPORT_STEP"
+
+// ImportStepInfo is a data.ImportStep() element of a TestStep slice. Such
+// steps have no Config field and are left out of the test steps, but they
+// verify that the resource the preceding Config step created can be imported.
+type ImportStepInfo struct {
+	Kind           string   `json:"kind"` // IMPORT_STEP
+	SourceFile     string   `json:"source_file"`
+	SourceService  string   `json:"source_service"`
+	SourceLine     int      `json:"source_line"`
+	SourceFunction string   `json:"source_function"`
+	SourceStruct   string   `json:"source_struct"`
+	ImportExpr     string   `json:"import_expr"`              // e.g., data.ImportStep("admin_password")
+	IgnoredFields  []string `json:"ignored_fields,omitempty"` // Attributes the import verification ignores
+	ResourceType   string   `json:"resource_type,omitempty"`  // acceptance.BuildTestData resource type of the data variable
+
+	// The preceding Config step, as its TestStepInfo records it; 0 and ""
+	// when the import step comes first
+	ConfigStepIndex int    `json:"config_step_index,omitempty"`
+	ConfigLine      int    `json:"config_line,omitempty"`
+	ConfigExpr      string `json:"config_expr,omitempty"`
+}
+
+// importStepVisitor finds the data.ImportStep() elements of TestStep slices
+// and links each to the Config step before it, numbering the Config steps
+// as testStepVisitor does
+func importStepVisitor(fc *fileContext, out *[]ImportStepInfo) visitor {
+	var currentFunc *FunctionInfo
+	resourceTypes := map[string]string{} // data variable -> BuildTestData resource type
+
+	return func(n ast.Node) bool {
+		if funcDecl, ok := n.(*ast.FuncDecl); ok {
+			line := fc.fset.Position(funcDecl.Pos()).Line
+			if fn, exists := fc.lineToFunc[line]; exists {
+				currentFunc = &fn
+				resourceTypes = map[string]string{}
+			}
+		}
+
+		if assign, ok := n.(*ast.AssignStmt); ok {
+			if variable, data, ok := testDataAssignment(assign); ok {
+				resourceTypes[variable] = data.resourceType
+			}
+		}
+
+		compLit, ok := n.(*ast.CompositeLit)
+		if !ok || !isTestStepSlice(compLit) {
+			return true
+		}
+
+		var config *ast.KeyValueExpr
+		configIndex, configLine := 0, 0
+		for _, elt := range compLit.Elts {
+			if stepLit, ok := elt.(*ast.CompositeLit); ok {
+				if field := stepField(stepLit, "Config"); field != nil {
+					config = field
+					configIndex++
+					configLine = fc.fset.Position(stepLit.Pos()).Line
+				}
+				continue
+			}
+
+			call, ok := elt.(*ast.CallExpr)
+			if !ok {
+				continue
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "ImportStep" {
+				continue
+			}
+			step := ImportStepInfo{
+				Kind:          importStepKind,
+				SourceFile:    fc.path,
+				SourceService: fc.service,
+				SourceLine:    fc.fset.Position(call.Pos()).Line,
+				ImportExpr:    extractTextRange(fc.source, fc.fset, call.Pos(), call.End()),
+			}
+			if currentFunc != nil {
+				step.SourceFunction = currentFunc.FunctionName
+				step.SourceStruct = currentFunc.ReceiverType
+			}
+			if data, ok := sel.X.(*ast.Ident); ok {
+				step.ResourceType = resourceTypes[data.Name]
+			}
+			for _, arg := range call.Args {
+				if field, ok := stringLiteral(arg); ok {
+					step.IgnoredFields = append(step.IgnoredFields, field)
+				}
+			}
+			if config != nil {
+				step.ConfigStepIndex = configIndex
+				step.ConfigLine = configLine
+				step.ConfigExpr = extractTextRange(fc.source, fc.fset, config.Value.Pos(), config.Value.End())
+			}
+			*out = append(*out, step)
+		}
+		return true
+	}
+}
+
+// stepField returns the named field of a TestStep literal, or nil
+func stepField(stepLit *ast.CompositeLit, name string) *ast.KeyValueExpr {
+	for _, field := range stepLit.Elts {
+		kv, ok := field.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+			return kv
+		}
+	}
+	return nil
+}
+
+// testData is the resource under test an acceptance.BuildTestData call
+// describes
+type testData struct {
+	resourceType string // e.g., "azurerm_subnet"
+	label        string // e.g., "test"
+}
+
+// address is the resource address data.ResourceName evaluates to
+func (d testData) address() string {
+	if d.label == "" {
+		return ""
+	}
+	return d.resourceType + "." + d.label
+}
+
+// testDataAssignment matches data := acceptance.BuildTestData(t, "azurerm_subnet", "test"),
+// returning the variable and the resource it describes
+func testDataAssignment(assign *ast.AssignStmt) (string, testData, bool) {
+	if len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return "", testData{}, false
+	}
+	ident, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return "", testData{}, false
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return "", testData{}, false
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "BuildTestData" {
+		return "", testData{}, false
+	}
+	resourceType, ok := stringLiteral(call.Args[1])
+	if !ok {
+		return "", testData{}, false
+	}
+	data := testData{resourceType: resourceType}
+	if len(call.Args) >= 3 {
+		data.label, _ = stringLiteral(call.Args[2])
+	}
+	return ident.Name, data, true
+}
@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+// Location finding kinds
+const (
+	locationHardcoded = "HARDCODED_LOCATION" // HCL location attribute set to a region literal
+	locationOverride  = "LOCATION_OVERRIDE"  // Go code assigns a region to data.Locations or ARM_TEST_LOCATION*
+	locationSlot      = "LOCATION_SLOT"      // data.Locations field read; the region comes from the environment
+)
+
+// hclLocationLiteral matches a location attribute set to a literal region
+var hclLocationLiteral = regexp.MustCompile(`^location\s*=\s*"([^"%$]+)"$`)
+
+// LocationFinding is one place a function chooses or reads a region
+type LocationFinding struct {
+	FunctionName string `json:"function_name"`
+	FunctionLine int    `json:"function_line"` // Declaration line of the function
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+	Kind         string `json:"kind"`             // HARDCODED_LOCATION, LOCATION_OVERRIDE, or LOCATION_SLOT
+	Region       string `json:"region,omitempty"` // Normalized region of a pin (e.g., "westeurope")
+	Slot         string `json:"slot,omitempty"`   // data.Locations field (Primary, Secondary, Ternary)
+	Context      string `json:"context"`
+}
+
+// NormalizeRegion lowercases a region and drops spaces ("West Europe" -> "westeurope")
+func NormalizeRegion(region string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(region), " ", ""))
+}
+
+// extractLocationFindings finds the regions each test and template pins and
+// the data.Locations slots it reads
+func extractLocationFindings(fc *fileContext) []LocationFinding {
+	byLine := map[int]*FunctionInfo{}
+	for i := range fc.functions {
+		byLine[fc.functions[i].Line] = &fc.functions[i]
+	}
+
+	var findings []LocationFinding
+	for _, decl := range fc.file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		fn := byLine[fc.fset.Position(funcDecl.Pos()).Line]
+		if fn == nil || fn.FunctionName != funcDecl.Name.Name {
+			continue
+		}
+		finding := func(kind string, pos token.Pos, context string) LocationFinding {
+			return LocationFinding{FunctionName: fn.FunctionName, FunctionLine: fn.Line, File: fc.path, Line: fc.fset.Position(pos).Line, Kind: kind, Context: context}
+		}
+
+		slots := map[string]bool{}
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.BasicLit:
+				// HCL in raw string literals; each line keeps its file line
+				if node.Kind != token.STRING {
+					return true
+				}
+				for offset, line := range strings.Split(node.Value, "\n") {
+					trimmed := strings.Trim(strings.TrimSpace(line), "`")
+					if match := hclLocationLiteral.FindStringSubmatch(trimmed); match != nil {
+						f := finding(locationHardcoded, node.Pos(), trimmed)
+						f.Line += offset
+						f.Region = NormalizeRegion(match[1])
+						findings = append(findings, f)
+					}
+				}
+
+			case *ast.AssignStmt:
+				for i, lhs := range node.Lhs {
+					slot := locationsSlot(lhs)
+					if slot == "" || i >= len(node.Rhs) {
+						continue
+					}
+					if region, ok := stringLiteral(node.Rhs[i]); ok {
+						f := finding(locationOverride, node.Pos(), types.ExprString(lhs)+" = "+region)
+						f.Region, f.Slot = NormalizeRegion(region), slot
+						findings = append(findings, f)
+					}
+				}
+
+			case *ast.CallExpr:
+				sel, ok := node.Fun.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != "Setenv" || len(node.Args) != 2 {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); !ok || (pkg.Name != "os" && pkg.Name != "t") {
+					return true
+				}
+				name, ok := stringLiteral(node.Args[0])
+				region, isLiteral := stringLiteral(node.Args[1])
+				if ok && isLiteral && strings.HasPrefix(name, "ARM_TEST_LOCATION") {
+					f := finding(locationOverride, node.Pos(), name+"="+region)
+					f.Region = NormalizeRegion(region)
+					findings = append(findings, f)
+				}
+
+			case *ast.SelectorExpr:
+				if slot := locationsSlot(node); slot != "" && !slots[slot] {
+					slots[slot] = true
+					f := finding(locationSlot, node.Pos(), types.ExprString(node))
+					f.Slot = slot
+					findings = append(findings, f)
+				}
+			}
+			return true
+		})
+	}
+	return findings
+}
+
+// locationsSlot returns the field of a data.Locations.<Field> expression, or ""
+func locationsSlot(expr ast.Expr) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if inner, ok := sel.X.(*ast.SelectorExpr); ok && inner.Sel.Name == "Locations" {
+		return sel.Sel.Name
+	}
+	return ""
+}
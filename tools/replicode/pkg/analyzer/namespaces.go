@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NamespaceMap maps azurerm resource type prefixes to the ARM resource provider
+// namespace that serves them. The longest matching prefix wins, so exact
+// resource types override broader prefixes.
+type NamespaceMap map[string]string
+
+// builtinNamespaces covers the azurerm services; a -namespace-map file adds to
+// or overrides these entries
+var builtinNamespaces = NamespaceMap{
+	"azurerm_advanced_threat_protection":         "Microsoft.Security",
+	"azurerm_advisor_":                           "Microsoft.Advisor",
+	"azurerm_analysis_services":                  "Microsoft.AnalysisServices",
+	"azurerm_api_management":                     "Microsoft.ApiManagement",
+	"azurerm_app_configuration":                  "Microsoft.AppConfiguration",
+	"azurerm_app_service":                        "Microsoft.Web",
+	"azurerm_application_gateway":                "Microsoft.Network",
+	"azurerm_application_insights":               "Microsoft.Insights",
+	"azurerm_application_security_group":         "Microsoft.Network",
+	"azurerm_automation_":                        "Microsoft.Automation",
+	"azurerm_availability_set":                   "Microsoft.Compute",
+	"azurerm_backup_":                            "Microsoft.RecoveryServices",
+	"azurerm_bastion_host":                       "Microsoft.Network",
+	"azurerm_batch_":                             "Microsoft.Batch",
+	"azurerm_bot_":                               "Microsoft.BotService",
+	"azurerm_capacity_reservation":               "Microsoft.Compute",
+	"azurerm_cdn_":                               "Microsoft.Cdn",
+	"azurerm_cognitive_":                         "Microsoft.CognitiveServices",
+	"azurerm_communication_service":              "Microsoft.Communication",
+	"azurerm_consumption_budget":                 "Microsoft.Consumption",
+	"azurerm_container_app":                      "Microsoft.App",
+	"azurerm_container_group":                    "Microsoft.ContainerInstance",
+	"azurerm_container_registry":                 "Microsoft.ContainerRegistry",
+	"azurerm_cosmosdb_":                          "Microsoft.DocumentDB",
+	"azurerm_cost_":                              "Microsoft.CostManagement",
+	"azurerm_dashboard":                          "Microsoft.Portal",
+	"azurerm_data_factory":                       "Microsoft.DataFactory",
+	"azurerm_data_protection_":                   "Microsoft.DataProtection",
+	"azurerm_data_share":                         "Microsoft.DataShare",
+	"azurerm_databricks_":                        "Microsoft.Databricks",
+	"azurerm_dedicated_host":                     "Microsoft.Compute",
+	"azurerm_dev_test_":                          "Microsoft.DevTestLab",
+	"azurerm_digital_twins":                      "Microsoft.DigitalTwins",
+	"azurerm_disk_encryption_set":                "Microsoft.Compute",
+	"azurerm_dns_":                               "Microsoft.Network",
+	"azurerm_eventgrid_":                         "Microsoft.EventGrid",
+	"azurerm_eventhub":                           "Microsoft.EventHub",
+	"azurerm_express_route":                      "Microsoft.Network",
+	"azurerm_federated_identity_credential":      "Microsoft.ManagedIdentity",
+	"azurerm_firewall":                           "Microsoft.Network",
+	"azurerm_frontdoor":                          "Microsoft.Network",
+	"azurerm_function_app":                       "Microsoft.Web",
+	"azurerm_hdinsight_":                         "Microsoft.HDInsight",
+	"azurerm_healthcare_":                        "Microsoft.HealthcareApis",
+	"azurerm_image":                              "Microsoft.Compute",
+	"azurerm_iothub":                             "Microsoft.Devices",
+	"azurerm_ip_group":                           "Microsoft.Network",
+	"azurerm_key_vault":                          "Microsoft.KeyVault",
+	"azurerm_kubernetes_":                        "Microsoft.ContainerService",
+	"azurerm_kusto_":                             "Microsoft.Kusto",
+	"azurerm_lb":                                 "Microsoft.Network",
+	"azurerm_lighthouse_":                        "Microsoft.ManagedServices",
+	"azurerm_linux_function_app":                 "Microsoft.Web",
+	"azurerm_linux_virtual_machine":              "Microsoft.Compute",
+	"azurerm_linux_web_app":                      "Microsoft.Web",
+	"azurerm_load_test":                          "Microsoft.LoadTestService",
+	"azurerm_local_network_gateway":              "Microsoft.Network",
+	"azurerm_log_analytics_":                     "Microsoft.OperationalInsights",
+	"azurerm_logic_app_":                         "Microsoft.Logic",
+	"azurerm_machine_learning_":                  "Microsoft.MachineLearningServices",
+	"azurerm_managed_application":                "Microsoft.Solutions",
+	"azurerm_managed_disk":                       "Microsoft.Compute",
+	"azurerm_management_group":                   "Microsoft.Management",
+	"azurerm_management_lock":                    "Microsoft.Authorization",
+	"azurerm_maps_":                              "Microsoft.Maps",
+	"azurerm_mariadb_":                           "Microsoft.DBforMariaDB",
+	"azurerm_media_":                             "Microsoft.Media",
+	"azurerm_monitor_":                           "Microsoft.Insights",
+	"azurerm_mssql_":                             "Microsoft.Sql",
+	"azurerm_mysql_":                             "Microsoft.DBforMySQL",
+	"azurerm_nat_gateway":                        "Microsoft.Network",
+	"azurerm_netapp_":                            "Microsoft.NetApp",
+	"azurerm_network_":                           "Microsoft.Network",
+	"azurerm_notification_hub":                   "Microsoft.NotificationHubs",
+	"azurerm_orchestrated_virtual_machine":       "Microsoft.Compute",
+	"azurerm_point_to_site_vpn_gateway":          "Microsoft.Network",
+	"azurerm_policy_":                            "Microsoft.Authorization",
+	"azurerm_postgresql_":                        "Microsoft.DBforPostgreSQL",
+	"azurerm_powerbi_":                           "Microsoft.PowerBIDedicated",
+	"azurerm_private_dns_":                       "Microsoft.Network",
+	"azurerm_private_endpoint":                   "Microsoft.Network",
+	"azurerm_private_link_service":               "Microsoft.Network",
+	"azurerm_proximity_placement_group":          "Microsoft.Compute",
+	"azurerm_public_ip":                          "Microsoft.Network",
+	"azurerm_purview_":                           "Microsoft.Purview",
+	"azurerm_recovery_services_vault":            "Microsoft.RecoveryServices",
+	"azurerm_redis_":                             "Microsoft.Cache",
+	"azurerm_relay_":                             "Microsoft.Relay",
+	"azurerm_resource_group":                     "Microsoft.Resources",
+	"azurerm_resource_provider_registration":     "Microsoft.Resources",
+	"azurerm_role_":                              "Microsoft.Authorization",
+	"azurerm_route_":                             "Microsoft.Network",
+	"azurerm_search_service":                     "Microsoft.Search",
+	"azurerm_security_center_":                   "Microsoft.Security",
+	"azurerm_sentinel_":                          "Microsoft.SecurityInsights",
+	"azurerm_service_plan":                       "Microsoft.Web",
+	"azurerm_servicebus_":                        "Microsoft.ServiceBus",
+	"azurerm_shared_image":                       "Microsoft.Compute",
+	"azurerm_signalr_":                           "Microsoft.SignalRService",
+	"azurerm_site_recovery_":                     "Microsoft.RecoveryServices",
+	"azurerm_snapshot":                           "Microsoft.Compute",
+	"azurerm_spring_cloud_":                      "Microsoft.AppPlatform",
+	"azurerm_sql_":                               "Microsoft.Sql",
+	"azurerm_ssh_public_key":                     "Microsoft.Compute",
+	"azurerm_static_site":                        "Microsoft.Web",
+	"azurerm_static_web_app":                     "Microsoft.Web",
+	"azurerm_storage_":                           "Microsoft.Storage",
+	"azurerm_storage_mover":                      "Microsoft.StorageMover",
+	"azurerm_storage_sync":                       "Microsoft.StorageSync",
+	"azurerm_stream_analytics_":                  "Microsoft.StreamAnalytics",
+	"azurerm_management_group_policy_":           "Microsoft.Authorization",
+	"azurerm_resource_group_policy_":             "Microsoft.Authorization",
+	"azurerm_resource_policy_":                   "Microsoft.Authorization",
+	"azurerm_subscription_policy_":               "Microsoft.Authorization",
+	"azurerm_subnet":                             "Microsoft.Network",
+	"azurerm_subscription":                       "Microsoft.Subscription",
+	"azurerm_synapse_":                           "Microsoft.Synapse",
+	"azurerm_template_deployment":                "Microsoft.Resources",
+	"azurerm_resource_group_template_deployment": "Microsoft.Resources",
+	"azurerm_subscription_template_deployment":   "Microsoft.Resources",
+	"azurerm_tenant_configuration":               "Microsoft.Portal",
+	"azurerm_traffic_manager_":                   "Microsoft.Network",
+	"azurerm_user_assigned_identity":             "Microsoft.ManagedIdentity",
+	"azurerm_virtual_desktop_":                   "Microsoft.DesktopVirtualization",
+	"azurerm_virtual_hub":                        "Microsoft.Network",
+	"azurerm_virtual_machine":                    "Microsoft.Compute",
+	"azurerm_virtual_network":                    "Microsoft.Network",
+	"azurerm_virtual_wan":                        "Microsoft.Network",
+	"azurerm_vmware_":                            "Microsoft.AVS",
+	"azurerm_vpn_":                               "Microsoft.Network",
+	"azurerm_web_application_firewall_policy":    "Microsoft.Network",
+	"azurerm_web_pubsub":                         "Microsoft.SignalRService",
+	"azurerm_windows_function_app":               "Microsoft.Web",
+	"azurerm_windows_virtual_machine":            "Microsoft.Compute",
+	"azurerm_windows_web_app":                    "Microsoft.Web",
+}
+
+// LoadNamespaceMap reads a JSON object of resource type prefixes to namespaces
+// and layers it over the built-in mapping
+func LoadNamespaceMap(path string) (NamespaceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing namespace map %s: %v", path, err)
+	}
+
+	merged := NamespaceMap{}
+	for prefix, namespace := range builtinNamespaces {
+		merged[prefix] = namespace
+	}
+	for prefix, namespace := range entries {
+		merged[prefix] = namespace
+	}
+	return merged, nil
+}
+
+// Namespace returns the ARM namespace of a resource type, or "" when no
+// prefix matches
+func (m NamespaceMap) Namespace(resourceType string) string {
+	best, namespace := 0, ""
+	for prefix, candidate := range m {
+		if len(prefix) > best && strings.HasPrefix(resourceType, prefix) {
+			best, namespace = len(prefix), candidate
+		}
+	}
+	return namespace
+}
+
+// annotateNamespaces sets the ARM namespace of each direct resource reference
+func annotateNamespaces(refs []DirectResourceReference, namespaces NamespaceMap) {
+	for i := range refs {
+		refs[i].Namespace = namespaces.Namespace(refs[i].ResourceName)
+	}
+}
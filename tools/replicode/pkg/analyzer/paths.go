@@ -0,0 +1,71 @@
+package analyzer
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CanonicalPath spells a path the same way whichever side of a mixed
+// Windows/WSL pipeline produced it: forward slashes, no \\?\ long-path or
+// \\.\ device prefix (\\?\UNC\server\share becomes //server/share), WSL
+// /mnt/<drive>/ mounts as <drive>:/, a lowercase drive letter, and no . or ..
+// elements. A relative local path is made absolute. Paths spelled differently
+// that name the same file have the same canonical path, except for case on
+// Windows, which comparisons ignore.
+func CanonicalPath(p string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	switch {
+	case hasPrefixFold(p, "//?/UNC/"):
+		p = "//" + p[len("//?/UNC/"):]
+	case strings.HasPrefix(p, "//?/"), strings.HasPrefix(p, "//./"):
+		p = p[len("//?/"):]
+	}
+	if len(p) >= 6 && strings.HasPrefix(p, "/mnt/") && isDriveLetter(p[5]) && (len(p) == 6 || p[6] == '/') {
+		p = p[5:6] + ":/" + strings.TrimPrefix(p[6:], "/")
+	}
+	if len(p) >= 2 && isDriveLetter(p[0]) && p[1] == ':' {
+		p = strings.ToLower(p[:1]) + p[1:]
+	}
+
+	if !windowsStyle(p) && !path.IsAbs(p) {
+		if abs, err := filepath.Abs(filepath.FromSlash(p)); err == nil {
+			p = filepath.ToSlash(abs)
+		}
+	}
+	if strings.HasPrefix(p, "//") {
+		return "/" + path.Clean(p[1:]) // path.Clean would merge the UNC slashes
+	}
+	return path.Clean(p)
+}
+
+// trimPathPrefix returns the part of a canonical path below a canonical root,
+// comparing without regard to case for Windows paths
+func trimPathPrefix(p, root string) (string, bool) {
+	if p == root {
+		return ".", true
+	}
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	if len(p) <= len(prefix) {
+		return "", false
+	}
+	head := p[:len(prefix)]
+	if head == prefix || ((runtime.GOOS == "windows" || windowsStyle(root)) && strings.EqualFold(head, prefix)) {
+		return p[len(prefix):], true
+	}
+	return "", false
+}
+
+// windowsStyle reports whether a canonical path is a drive or UNC path
+func windowsStyle(p string) bool {
+	return strings.HasPrefix(p, "//") || (len(p) >= 2 && isDriveLetter(p[0]) && p[1] == ':')
+}
+
+func isDriveLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
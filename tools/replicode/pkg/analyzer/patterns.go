@@ -1,4 +1,4 @@
-package main
+package analyzer
 
 import (
 	"go/ast"
@@ -64,45 +64,51 @@ type FunctionVisibilityInfo struct {
 
 // DetectPatterns analyzes AST for all pattern types
 func DetectPatterns(file *ast.File, filePath string) *PatternDetector {
-	detector := &PatternDetector{
+	detector := newPatternDetector()
+	ast.Inspect(file, detector.visitor(filePath))
+	return detector
+}
+
+func newPatternDetector() *PatternDetector {
+	return &PatternDetector{
 		SequentialTests:    []SequentialTestInfo{},
 		MapBasedTests:      []MapBasedTestInfo{},
 		AnonymousFunctions: []AnonymousFunctionInfo{},
 		VisibilityInfo:     []FunctionVisibilityInfo{},
 	}
+}
 
+// visitor detects the patterns in the nodes of a file walk
+func (d *PatternDetector) visitor(filePath string) visitor {
 	// Track current function context for proper linking
 	var currentFunction string
 
-	// Walk the AST to find all patterns
-	ast.Inspect(file, func(n ast.Node) bool {
+	return func(n ast.Node) bool {
 		switch node := n.(type) {
 		case *ast.FuncDecl:
 			// Update context
 			currentFunction = node.Name.Name
 			// Detect visibility for all functions
-			detector.analyzeFunctionDecl(node, filePath)
+			d.analyzeFunctionDecl(node, filePath)
 
 		case *ast.CallExpr:
 			// Detect RunTestsInSequence calls within function context
-			detector.analyzeCallExpr(node, filePath, currentFunction)
+			d.analyzeCallExpr(node, filePath, currentFunction)
 
 		case *ast.ValueSpec:
 			// Detect map-based test declarations (var statements)
-			detector.analyzeValueSpec(node, filePath, currentFunction)
+			d.analyzeValueSpec(node, filePath, currentFunction)
 
 		case *ast.AssignStmt:
 			// Detect map-based test declarations (:= statements)
-			detector.analyzeAssignStmt(node, filePath, currentFunction)
+			d.analyzeAssignStmt(node, filePath, currentFunction)
 
 		case *ast.FuncLit:
 			// Detect anonymous functions
-			detector.analyzeFuncLit(node, filePath, currentFunction)
+			d.analyzeFuncLit(node, filePath, currentFunction)
 		}
 		return true
-	})
-
-	return detector
+	}
 }
 
 // analyzeFunctionDecl checks function declarations for patterns
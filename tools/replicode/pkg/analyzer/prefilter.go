@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// relevanceMarkers are byte sequences at least one of which occurs in every
+// file that declares a function extractFunctions tracks or holds a test step
+// or sequential test: test functions, newXxxResource constructors, and
+// methods on XxxResource or XxxDataSource receivers
+var relevanceMarkers = [][]byte{
+	[]byte("func Test"),
+	[]byte("func testAcc"),
+	[]byte("func new"),
+	[]byte("Resource) "),
+	[]byte("DataSource) "),
+	[]byte("TestStep"),
+	[]byte("RunTestsInSequence"),
+}
+
+// Relevant is the prefilter Options.Prefilter applies: it reports whether a
+// file's content could yield test functions, templates, or the references
+// between them, without parsing it. It errs towards true; a file it rejects
+// would only have contributed imports and function visibility patterns.
+func Relevant(source []byte) bool {
+	for _, marker := range relevanceMarkers {
+		if bytes.Contains(source, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedMarker is the line marking generated Go source
+// (https://go.dev/s/generatedcode)
+var generatedMarker = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// Generated reports whether a file's content carries the standard
+// "// Code generated ... DO NOT EDIT." line before its package clause, as
+// generated SDK and schema files do, without parsing it
+func Generated(source []byte) bool {
+	for len(source) > 0 {
+		line := source
+		if i := bytes.IndexByte(source, '\n'); i >= 0 {
+			line, source = source[:i], source[i+1:]
+		} else {
+			source = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.HasPrefix(line, []byte("package ")) {
+			return false
+		}
+		if generatedMarker.Match(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultResourcePrefix is the provider prefix of the resource types template
+// HCL is scanned for when Options.ResourcePrefixes is empty
+const DefaultResourcePrefix = "azurerm_"
+
+// QualifiedService returns the service of a repository in a multi-root
+// analysis (e.g., "azuread/applications"), or the service unchanged when the
+// repository or the service is ""
+func QualifiedService(repository, service string) string {
+	if repository == "" || service == "" {
+		return service
+	}
+	return repository + "/" + service
+}
+
+// qualifiedPath prefixes a relative path with its repository
+func qualifiedPath(repository, relPath string) string {
+	if repository == "" {
+		return relPath
+	}
+	return path.Join(repository, relPath)
+}
+
+// resourcePrefixes returns the resource type prefixes to scan HCL for
+func (opts Options) resourcePrefixes() []string {
+	if len(opts.ResourcePrefixes) == 0 {
+		return []string{DefaultResourcePrefix}
+	}
+	return opts.ResourcePrefixes
+}
+
+// resourceTypePrefix returns the prefix of prefixes a word starts with, or ""
+func resourceTypePrefix(word string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// qualifyServices prefixes every service of a result with its repository
+func (result *Result) qualifyServices(repository string) {
+	if repository == "" {
+		return
+	}
+	q := func(service *string) { *service = QualifiedService(repository, *service) }
+	for i := range result.Functions {
+		q(&result.Functions[i].ServiceName)
+	}
+	for i := range result.Calls {
+		q(&result.Calls[i].CallerService)
+		q(&result.Calls[i].TargetService)
+	}
+	for i := range result.TestSteps {
+		q(&result.TestSteps[i].SourceService)
+		q(&result.TestSteps[i].ConfigService)
+	}
+	for i := range result.ImportSteps {
+		q(&result.ImportSteps[i].SourceService)
+	}
+	for i := range result.TemplateCalls {
+		q(&result.TemplateCalls[i].SourceService)
+		q(&result.TemplateCalls[i].TargetService)
+	}
+	for i := range result.CheckAssertions {
+		q(&result.CheckAssertions[i].SourceService)
+	}
+	for i := range result.CheckDestroyRefs {
+		q(&result.CheckDestroyRefs[i].SourceService)
+	}
+}
+
+// containsResourcePrefix reports whether a line mentions any of prefixes
+func containsResourcePrefix(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.Contains(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
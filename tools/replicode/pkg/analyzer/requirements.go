@@ -0,0 +1,393 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FunctionRequirements are the prerequisites a single test or template declares in code
+type FunctionRequirements struct {
+	FunctionName string `json:"function_name"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+
+	EnvVars         []string `json:"env_vars,omitempty"`         // Variables read with os.Getenv/os.LookupEnv or by env-gated helpers
+	SkipsWithout    []string `json:"skips_without,omitempty"`    // Variables whose absence makes the function call t.Skip
+	Providers       []string `json:"providers,omitempty"`        // Non-azurerm providers (ExternalProviders, HCL blocks)
+	Features        []string `json:"features,omitempty"`         // Settings inside the provider features block (e.g., "key_vault.purge_soft_delete_on_destroy=false")
+	ProviderAliases []string `json:"provider_aliases,omitempty"` // Aliased providers resources are created with (e.g., "azurerm.alt")
+	AltSubscription bool     `json:"alt_subscription,omitempty"` // Needs a second subscription or tenant
+	TestData        []string `json:"test_data,omitempty"`        // acceptance.TestData fields passed to fmt.Sprintf (e.g., "RandomInteger", "Locations.Secondary")
+}
+
+// envGatedHelpers are acceptance package helpers that read environment
+// variables on a test's behalf, keyed by function name
+var envGatedHelpers = map[string][]string{
+	"PreCheck": {"ARM_CLIENT_ID", "ARM_CLIENT_SECRET", "ARM_SUBSCRIPTION_ID", "ARM_TENANT_ID",
+		"ARM_TEST_LOCATION", "ARM_TEST_LOCATION_ALT", "ARM_TEST_LOCATION_ALT2"},
+	"BuildTestData": {"ARM_TEST_LOCATION", "ARM_TEST_LOCATION_ALT", "ARM_TEST_LOCATION_ALT2"},
+}
+
+// altCredentialFields are the data.Client() fields backed by the alternate
+// subscription environment variables
+var altCredentialFields = map[string]string{
+	"SubscriptionIDAlt": "ARM_SUBSCRIPTION_ID_ALT",
+	"TenantIDAlt":       "ARM_TENANT_ID_ALT",
+	"ClientIDAlt":       "ARM_CLIENT_ID_ALT",
+	"ClientSecretAlt":   "ARM_CLIENT_SECRET_ALT",
+}
+
+// altCredentialEnvVars are the environment variables of the alternate
+// subscription's credentials. Other *_ALT variables, such as
+// ARM_TEST_LOCATION_ALT, need no second subscription.
+var altCredentialEnvVars = map[string]bool{
+	"ARM_SUBSCRIPTION_ID_ALT": true,
+	"ARM_TENANT_ID_ALT":       true,
+	"ARM_CLIENT_ID_ALT":       true,
+	"ARM_CLIENT_SECRET_ALT":   true,
+}
+
+// extractRequirements collects the prerequisites of each test and template
+// function. A test function also inherits the environment variables read by
+// same-file helpers it calls directly, which is how preCheck functions
+// (excluded from the function list) are usually wired in.
+func extractRequirements(fc *fileContext) []FunctionRequirements {
+	// Requirements of every function declared in the file, keyed by
+	// declaration line (method names repeat across receivers) and by name
+	// for resolving helper calls
+	byLine := map[int]*FunctionRequirements{}
+	byName := map[string]*FunctionRequirements{}
+	calls := map[int][]string{}
+	for _, decl := range fc.file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		line := fc.fset.Position(funcDecl.Pos()).Line
+		reqs := &FunctionRequirements{FunctionName: funcDecl.Name.Name}
+		collectGoRequirements(funcDecl.Body, reqs)
+		collectTestDataFields(funcDecl, reqs)
+		if hcl := extractHCLContentFromFunction(funcDecl); hcl != "" {
+			collectHCLRequirements(hcl, reqs)
+		}
+		byLine[line] = reqs
+		if byName[funcDecl.Name.Name] == nil {
+			byName[funcDecl.Name.Name] = reqs
+		}
+
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			switch fun := call.Fun.(type) {
+			case *ast.Ident:
+				calls[line] = append(calls[line], fun.Name)
+			case *ast.SelectorExpr:
+				calls[line] = append(calls[line], fun.Sel.Name)
+			}
+			return true
+		})
+	}
+
+	var requirements []FunctionRequirements
+	for _, fn := range fc.functions {
+		reqs := byLine[fn.Line]
+		if reqs == nil || reqs.FunctionName != fn.FunctionName {
+			continue
+		}
+		merged := *reqs
+		if fn.IsTestFunc {
+			for _, callee := range calls[fn.Line] {
+				if helper := byName[callee]; helper != nil && helper != reqs {
+					merged.EnvVars = append(merged.EnvVars, helper.EnvVars...)
+					merged.SkipsWithout = append(merged.SkipsWithout, helper.SkipsWithout...)
+					merged.AltSubscription = merged.AltSubscription || helper.AltSubscription
+				}
+			}
+		}
+		merged.File = fc.path
+		merged.Line = fn.Line
+		merged.EnvVars = uniqueSorted(merged.EnvVars)
+		merged.SkipsWithout = uniqueSorted(merged.SkipsWithout)
+		merged.Providers = uniqueSorted(merged.Providers)
+		merged.Features = uniqueSorted(merged.Features)
+		merged.ProviderAliases = uniqueSorted(merged.ProviderAliases)
+		merged.TestData = uniqueSorted(merged.TestData)
+		if len(merged.EnvVars)+len(merged.SkipsWithout)+len(merged.Providers)+len(merged.Features)+len(merged.ProviderAliases)+len(merged.TestData) > 0 || merged.AltSubscription {
+			requirements = append(requirements, merged)
+		}
+	}
+
+	return requirements
+}
+
+// collectGoRequirements finds environment variable reads, env-gated helper
+// calls, skip conditions, ExternalProviders entries, and alternate
+// subscription client fields in a function body
+func collectGoRequirements(body *ast.BlockStmt, reqs *FunctionRequirements) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.IfStmt:
+			// if os.Getenv("ARM_X") == "" { t.Skip(...) }
+			if callsSkip(node.Body) {
+				reqs.SkipsWithout = append(reqs.SkipsWithout, envVarsRead(node.Init)...)
+				reqs.SkipsWithout = append(reqs.SkipsWithout, envVarsRead(node.Cond)...)
+			}
+
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if ok && pkg.Name == "acceptance" && envGatedHelpers[sel.Sel.Name] != nil {
+				reqs.EnvVars = append(reqs.EnvVars, envGatedHelpers[sel.Sel.Name]...)
+				return true
+			}
+			if name, ok := envVarRead(node); ok {
+				reqs.EnvVars = append(reqs.EnvVars, name)
+				if altCredentialEnvVars[name] {
+					reqs.AltSubscription = true
+				}
+			}
+
+		case *ast.KeyValueExpr:
+			key, ok := node.Key.(*ast.Ident)
+			if !ok || key.Name != "ExternalProviders" {
+				return true
+			}
+			if providers, ok := node.Value.(*ast.CompositeLit); ok {
+				for _, elt := range providers.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if name, ok := stringLiteral(kv.Key); ok {
+							reqs.Providers = append(reqs.Providers, name)
+						}
+					}
+				}
+			}
+
+		case *ast.SelectorExpr:
+			if name, ok := altCredentialFields[node.Sel.Name]; ok {
+				reqs.EnvVars = append(reqs.EnvVars, name)
+				reqs.AltSubscription = true // e.g., data.Client().SubscriptionIDAlt
+			}
+		}
+		return true
+	})
+}
+
+// collectTestDataFields finds the acceptance.TestData fields and methods a
+// function passes to fmt.Sprintf, read through a TestData parameter or a
+// BuildTestData variable. A method call is recorded with "()" and without
+// its arguments (e.g., "RandomStringOfLength()", "Client().SubscriptionID").
+func collectTestDataFields(funcDecl *ast.FuncDecl, reqs *FunctionRequirements) {
+	vars := map[string]bool{}
+	for _, field := range funcDecl.Type.Params.List {
+		if isTestDataType(field.Type) {
+			for _, name := range field.Names {
+				vars[name.Name] = true
+			}
+		}
+	}
+
+	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			if variable, _, ok := testDataAssignment(node); ok {
+				vars[variable] = true
+			}
+
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Sprintf" || len(node.Args) < 2 {
+				return true
+			}
+			if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "fmt" {
+				return true
+			}
+			for _, arg := range node.Args[1:] {
+				ast.Inspect(arg, func(n ast.Node) bool {
+					expr, ok := n.(ast.Expr)
+					if !ok {
+						return true
+					}
+					root, field := testDataField(expr)
+					if root == nil || !vars[root.Name] {
+						return true
+					}
+					reqs.TestData = append(reqs.TestData, field)
+					return false
+				})
+			}
+		}
+		return true
+	})
+}
+
+// isTestDataType reports whether a parameter type is acceptance.TestData
+func isTestDataType(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.SelectorExpr:
+		return t.Sel.Name == "TestData"
+	case *ast.Ident:
+		return t.Name == "TestData"
+	}
+	return false
+}
+
+// testDataField returns the variable a selector chain starts from and the
+// chain after it, with calls rendered as "()" (data.Client().SubscriptionID
+// returns data and "Client().SubscriptionID")
+func testDataField(expr ast.Expr) (*ast.Ident, string) {
+	switch node := expr.(type) {
+	case *ast.SelectorExpr:
+		if root, ok := node.X.(*ast.Ident); ok {
+			return root, node.Sel.Name
+		}
+		root, field := testDataField(node.X)
+		if root == nil {
+			return nil, ""
+		}
+		return root, field + "." + node.Sel.Name
+	case *ast.CallExpr:
+		root, field := testDataField(node.Fun)
+		if root == nil {
+			return nil, ""
+		}
+		return root, field + "()"
+	}
+	return nil, ""
+}
+
+// envVarRead returns the variable an os.Getenv or os.LookupEnv call reads
+func envVarRead(call *ast.CallExpr) (string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || pkg.Name != "os" || (sel.Sel.Name != "Getenv" && sel.Sel.Name != "LookupEnv") {
+		return "", false
+	}
+	return stringLiteral(call.Args[0])
+}
+
+// envVarsRead returns every variable read by os.Getenv/os.LookupEnv in a node
+func envVarsRead(node ast.Node) []string {
+	var names []string
+	if node == nil {
+		return nil
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if name, ok := envVarRead(call); ok {
+				names = append(names, name)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// callsSkip reports whether a block calls t.Skip, t.Skipf, or t.SkipNow
+func callsSkip(block *ast.BlockStmt) bool {
+	skips := false
+	ast.Inspect(block, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "Skip") {
+				skips = true
+			}
+		}
+		return !skips
+	})
+	return skips
+}
+
+// collectHCLRequirements finds non-azurerm providers, features block
+// settings, providers with their own subscription or tenant, and resources
+// created through an aliased provider (provider = azurerm.alt) in template HCL
+func collectHCLRequirements(hclContent string, reqs *FunctionRequirements) {
+	var blocks []string // Open block names, outermost first
+	for _, line := range strings.Split(hclContent, "\n") {
+		trimmed := strings.TrimSpace(line)
+		fields := strings.Fields(trimmed)
+
+		if len(blocks) == 0 && len(fields) >= 2 {
+			switch fields[0] {
+			case "provider":
+				if name := strings.Trim(fields[1], "\""); name != "azurerm" {
+					reqs.Providers = append(reqs.Providers, name)
+				}
+			case "resource", "data":
+				if prefix, _, ok := strings.Cut(strings.Trim(fields[1], "\""), "_"); ok && prefix != "azurerm" {
+					reqs.Providers = append(reqs.Providers, prefix)
+				}
+			}
+		}
+
+		if len(blocks) > 0 && blocks[0] == "provider" {
+			if name, _, ok := strings.Cut(trimmed, "="); ok {
+				// An alias alone only configures the same subscription differently
+				switch strings.TrimSpace(name) {
+				case "subscription_id", "tenant_id":
+					reqs.AltSubscription = true
+				}
+			}
+		}
+
+		if len(blocks) == 1 && (blocks[0] == "resource" || blocks[0] == "data") {
+			if name, value, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(name) == "provider" {
+				alias := strings.Trim(strings.TrimSpace(value), "\"")
+				reqs.ProviderAliases = append(reqs.ProviderAliases, alias)
+			}
+		}
+
+		if len(blocks) >= 2 && blocks[1] == "features" && !strings.HasSuffix(trimmed, "{") && strings.Contains(trimmed, "=") {
+			name, value, _ := strings.Cut(trimmed, "=")
+			setting := strings.Join(append(append([]string{}, blocks[2:]...), strings.TrimSpace(name)), ".")
+			reqs.Features = append(reqs.Features, setting+"="+strings.TrimSpace(value))
+		}
+
+		for i := 0; i < strings.Count(trimmed, "{"); i++ {
+			name := ""
+			if len(fields) > 0 {
+				name = fields[0]
+			}
+			blocks = append(blocks, name)
+		}
+		for i := 0; i < strings.Count(trimmed, "}") && len(blocks) > 0; i++ {
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+}
+
+// stringLiteral returns the unquoted value of a string literal expression
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// uniqueSorted returns the distinct values sorted
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+)
+
+// SingletonDependency is something a template creates that can exist only
+// once at a time, so two tests using it cannot run concurrently
+type SingletonDependency struct {
+	TemplateFunction string `json:"template_function"`
+	TemplateFile     string `json:"template_file"`
+	TemplateLine     int    `json:"template_line"`
+
+	ResourceName string `json:"resource_name"` // e.g., "azurerm_security_center_contact"
+	Kind         string `json:"kind"`          // "HARDCODED_NAME" or "SCOPE_SINGLETON"
+	Key          string `json:"key"`           // Identity shared by conflicting tests (e.g., "azurerm_resource_group:name=acctestRG-shared")
+	Context      string `json:"context"`       // The HCL line the dependency was found on
+}
+
+// scopeSingletonResources exist at most once per subscription or tenant, so
+// every test that manages one conflicts with every other test that does
+var scopeSingletonResources = map[string]bool{
+	"azurerm_resource_provider_registration":       true,
+	"azurerm_security_center_auto_provisioning":    true,
+	"azurerm_security_center_contact":              true,
+	"azurerm_security_center_setting":              true,
+	"azurerm_security_center_subscription_pricing": true,
+	"azurerm_security_center_workspace":            true,
+	"azurerm_subscription":                         true,
+	"azurerm_advisor_suppression":                  true,
+	"azurerm_management_group":                     true,
+	"azurerm_tenant_configuration":                 true,
+}
+
+// extractSingletonDependencies finds singleton dependencies in template HCL:
+// top-level name arguments that are plain literals (no fmt verbs or
+// interpolation, so every run uses the same name) and resource types that
+// can exist only once per subscription or tenant
+func extractSingletonDependencies(fc *fileContext) []SingletonDependency {
+	var deps []SingletonDependency
+
+	forEachFuncDecl(fc.file, func(funcDecl *ast.FuncDecl) {
+		if funcDecl.Body == nil {
+			return
+		}
+
+		var currentFunc *FunctionInfo
+		for i := range fc.functions {
+			if fc.functions[i].FunctionName == funcDecl.Name.Name && !fc.functions[i].IsTestFunc {
+				currentFunc = &fc.functions[i]
+				break
+			}
+		}
+		if currentFunc == nil {
+			return
+		}
+
+		hclContent := extractHCLContentFromFunction(funcDecl)
+		if hclContent == "" {
+			return
+		}
+
+		for _, dep := range parseHCLForSingletons(hclContent) {
+			dep.TemplateFunction = currentFunc.FunctionName
+			dep.TemplateFile = fc.path
+			dep.TemplateLine = currentFunc.Line
+			deps = append(deps, dep)
+		}
+	})
+
+	return deps
+}
+
+// parseHCLForSingletons scans HCL resource blocks for singleton dependencies.
+// A literal name only names one thing when the parent it lives in is fixed
+// too: the block's scope arguments (resource_group_name, virtual_network_name,
+// and other *_name or *_id arguments) are folded into the key, and a block
+// whose scope is a reference or is randomized yields no key, so a subnet
+// named "internal" in each test's own virtual network conflicts with nothing.
+func parseHCLForSingletons(hclContent string) []SingletonDependency {
+	var deps []SingletonDependency
+
+	resource := ""
+	depth := 0
+	var name, nameContext string
+	var scopes []string
+	scoped := false // A scope argument is a reference or interpolated
+	for _, line := range strings.Split(hclContent, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if depth == 0 && strings.HasPrefix(trimmed, "resource \"azurerm_") {
+			parts := strings.Fields(trimmed)
+			resource = strings.Trim(parts[1], "\"")
+			name, nameContext, scopes, scoped = "", "", nil, false
+			if scopeSingletonResources[resource] {
+				deps = append(deps, SingletonDependency{
+					ResourceName: resource,
+					Kind:         "SCOPE_SINGLETON",
+					Key:          resource,
+					Context:      trimmed,
+				})
+			}
+		}
+
+		if depth == 1 && resource != "" {
+			if argument, value, ok := hardcodedArgument(trimmed); ok {
+				switch {
+				case argument == "name":
+					name, nameContext = value, trimmed
+				case isScopeArgument(argument):
+					scopes = append(scopes, argument+"="+value)
+					if argument == "resource_group_name" {
+						// The resource group itself lives in the subscription
+						deps = append(deps, SingletonDependency{
+							ResourceName: "azurerm_resource_group",
+							Kind:         "HARDCODED_NAME",
+							Key:          "azurerm_resource_group:name=" + value,
+							Context:      trimmed,
+						})
+					}
+				}
+			} else if argument, _, found := strings.Cut(trimmed, "="); found && isScopeArgument(strings.TrimSpace(argument)) {
+				scoped = true
+			}
+		}
+
+		depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+		if depth <= 0 {
+			if resource != "" && name != "" && !scoped {
+				sort.Strings(scopes)
+				key := resource + ":name=" + name
+				if len(scopes) > 0 {
+					key += "," + strings.Join(scopes, ",")
+				}
+				deps = append(deps, SingletonDependency{
+					ResourceName: resource,
+					Kind:         "HARDCODED_NAME",
+					Key:          key,
+					Context:      nameContext,
+				})
+			}
+			depth = 0
+			resource = ""
+		}
+	}
+
+	return deps
+}
+
+// isScopeArgument reports whether a top-level argument places a resource in
+// a parent (resource_group_name, virtual_network_name, server_id, ...)
+func isScopeArgument(argument string) bool {
+	return argument != "name" && (strings.HasSuffix(argument, "_name") || strings.HasSuffix(argument, "_id"))
+}
+
+// hardcodedArgument parses `argument = "literal"` where the literal contains
+// no fmt verbs or HCL interpolation
+func hardcodedArgument(line string) (name, value string, ok bool) {
+	name, rhs, found := strings.Cut(line, "=")
+	if !found {
+		return "", "", false
+	}
+	name = strings.TrimSpace(name)
+	rhs = strings.TrimSpace(rhs)
+	if len(rhs) < 2 || rhs[0] != '"' || rhs[len(rhs)-1] != '"' {
+		return "", "", false
+	}
+	value = rhs[1 : len(rhs)-1]
+	if value == "" || strings.Contains(value, "%") || strings.Contains(value, "${") || strings.Contains(value, "\"") {
+		return "", "", false
+	}
+	return name, value, true
+}
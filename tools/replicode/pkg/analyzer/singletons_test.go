@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHCLForSingletons(t *testing.T) {
+	tests := []struct {
+		name string
+		hcl  string
+		keys []string
+	}{
+		{
+			name: "literal name under a randomized parent",
+			hcl: `
+resource "azurerm_virtual_network" "test" {
+  name                = "acctestvnet-%d"
+  resource_group_name = azurerm_resource_group.test.name
+}
+
+resource "azurerm_subnet" "test" {
+  name                 = "internal"
+  resource_group_name  = azurerm_resource_group.test.name
+  virtual_network_name = azurerm_virtual_network.test.name
+  address_prefixes     = ["10.0.2.0/24"]
+}
+`,
+		},
+		{
+			name: "literal resource group",
+			hcl: `
+resource "azurerm_resource_group" "test" {
+  name     = "acctestRG-shared"
+  location = "westeurope"
+}
+`,
+			keys: []string{"azurerm_resource_group:name=acctestRG-shared"},
+		},
+		{
+			name: "literal name under a literal parent",
+			hcl: `
+resource "azurerm_subnet" "test" {
+  name                 = "internal"
+  resource_group_name  = "acctestRG-shared"
+  virtual_network_name = "acctestvnet-shared"
+}
+`,
+			keys: []string{
+				"azurerm_resource_group:name=acctestRG-shared",
+				"azurerm_subnet:name=internal,resource_group_name=acctestRG-shared,virtual_network_name=acctestvnet-shared",
+			},
+		},
+		{
+			name: "literal name with a parent ID",
+			hcl: `
+resource "azurerm_key_vault_secret" "test" {
+  name         = "secret"
+  key_vault_id = azurerm_key_vault.test.id
+}
+`,
+		},
+		{
+			name: "scope singleton",
+			hcl: `
+resource "azurerm_security_center_contact" "test" {
+  email = "contact@example.com"
+}
+`,
+			keys: []string{"azurerm_security_center_contact"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var keys []string
+			for _, dep := range parseHCLForSingletons(test.hcl) {
+				keys = append(keys, dep.Key)
+			}
+			if !reflect.DeepEqual(keys, test.keys) {
+				t.Errorf("keys = %q, want %q", keys, test.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// TemplateSource is the HCL a template function returns: the fmt.Sprintf
+// format string (or a plain string literal) and its arguments
+type TemplateSource struct {
+	FunctionName string
+	Line         int // Declaration line of the template function
+	Format       string
+	Sprintf      bool // Format is a fmt.Sprintf format rather than literal HCL
+	Args         []TemplateArg
+}
+
+// TemplateArg is one fmt.Sprintf argument of a template
+type TemplateArg struct {
+	Expr         string // Go expression (e.g., "data.RandomInteger", "r.template(data)")
+	TargetStruct string // Struct of a template call argument, when known
+	TargetMethod string // Method of a template call argument
+	Target       string // Resolved template node ID (set when the graph is built)
+}
+
+// extractTemplateSources records the returned HCL of every template function.
+// Only the first return of a string literal or fmt.Sprintf call with a literal
+// format is used; templates building HCL any other way have no source.
+func extractTemplateSources(fc *fileContext) []TemplateSource {
+	byLine := map[int]*FunctionInfo{}
+	for i := range fc.functions {
+		if !fc.functions[i].IsTestFunc && fc.functions[i].ReceiverType != "" {
+			byLine[fc.functions[i].Line] = &fc.functions[i]
+		}
+	}
+
+	var sources []TemplateSource
+	for _, decl := range fc.file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		fn := byLine[fc.fset.Position(funcDecl.Pos()).Line]
+		if fn == nil {
+			continue
+		}
+
+		var source *TemplateSource
+		ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+			if source != nil {
+				return false
+			}
+			switch node := n.(type) {
+			case *ast.FuncLit:
+				return false // Returns inside closures are not the template's
+			case *ast.ReturnStmt:
+				if len(node.Results) > 0 {
+					source = templateSourceFromExpr(node.Results[0], fn)
+				}
+			}
+			return true
+		})
+		if source != nil {
+			sources = append(sources, *source)
+		}
+	}
+	return sources
+}
+
+// templateSourceFromExpr reads a returned string literal or fmt.Sprintf call
+func templateSourceFromExpr(expr ast.Expr, fn *FunctionInfo) *TemplateSource {
+	if format, ok := stringLiteral(expr); ok {
+		return &TemplateSource{FunctionName: fn.FunctionName, Line: fn.Line, Format: format}
+	}
+
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || !isFmtSprintfCall(call) || len(call.Args) == 0 {
+		return nil
+	}
+	format, ok := stringLiteral(call.Args[0])
+	if !ok {
+		return nil
+	}
+
+	source := &TemplateSource{FunctionName: fn.FunctionName, Line: fn.Line, Format: format, Sprintf: true}
+	for _, argExpr := range call.Args[1:] {
+		arg := TemplateArg{Expr: types.ExprString(argExpr)}
+		if argCall, ok := argExpr.(*ast.CallExpr); ok {
+			if selector, ok := argCall.Fun.(*ast.SelectorExpr); ok {
+				switch x := selector.X.(type) {
+				case *ast.Ident:
+					if x.Name != "data" {
+						arg.TargetMethod = selector.Sel.Name
+						if x.Name == fn.ReceiverVar {
+							arg.TargetStruct = fn.ReceiverType
+						}
+					}
+				case *ast.CompositeLit:
+					if ident, ok := x.Type.(*ast.Ident); ok {
+						arg.TargetStruct, arg.TargetMethod = ident.Name, selector.Sel.Name
+					}
+				}
+			}
+		}
+		source.Args = append(source.Args, arg)
+	}
+	return source
+}
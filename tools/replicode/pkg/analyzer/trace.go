@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"sync"
+	"time"
+)
+
+// Span is one timed step of an analysis, reported through Options.Trace. A
+// file's span has a child span for parsing and one for each extractor, so
+// a trace viewer shows both which files and which extractors are slow.
+type Span struct {
+	Name       string
+	Start, End time.Time
+	Attributes map[string]interface{}
+	Error      string // Set when the step failed
+	Children   []Span
+}
+
+// Span attribute keys
+const (
+	AttrFilePath = "replicode.file.path"
+	AttrService  = "replicode.service"
+	AttrRecords  = "replicode.records" // Records an extractor returned
+)
+
+// fileTrace builds the span of one file's analysis. Steps may run
+// concurrently, so their child spans end up in the order they finished. A nil
+// fileTrace, used when tracing is off, ignores every call.
+type fileTrace struct {
+	report func(Span)
+	mu     sync.Mutex // Guards span.Children
+	span   Span
+}
+
+func startFileTrace(report func(Span), path string) *fileTrace {
+	if report == nil {
+		return nil
+	}
+	return &fileTrace{report: report, span: Span{
+		Name:       "analyze file",
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{AttrFilePath: path},
+	}}
+}
+
+// step starts a child span; calling the returned function ends it, recording
+// the number of records found unless it is negative
+func (t *fileTrace) step(name string) func(records int) {
+	if t == nil {
+		return func(int) {}
+	}
+	start := time.Now()
+	return func(records int) {
+		child := Span{Name: name, Start: start, End: time.Now(), Attributes: map[string]interface{}{}}
+		if records >= 0 {
+			child.Attributes[AttrRecords] = records
+		}
+		t.add(child)
+	}
+}
+
+// add appends a finished child span
+func (t *fileTrace) add(child Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.span.Children = append(t.span.Children, child)
+}
+
+// fail marks the most recent child span as the step that failed
+func (t *fileTrace) fail(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.span.Children); n > 0 {
+		t.span.Children[n-1].Error = err.Error()
+	}
+}
+
+// finish ends the file span and reports it, with the record counts of the
+// result when the analysis succeeded
+func (t *fileTrace) finish(result *Result, service string, err error) {
+	if t == nil {
+		return
+	}
+	t.span.End = time.Now()
+	if err != nil {
+		t.span.Error = err.Error()
+	}
+	if result != nil {
+		t.span.Attributes[AttrFilePath] = result.FilePath
+		t.span.Attributes[AttrService] = service
+		t.span.Attributes["replicode.functions"] = len(result.Functions)
+		t.span.Attributes["replicode.test_steps"] = len(result.TestSteps)
+		t.span.Attributes["replicode.template_calls"] = len(result.TemplateCalls)
+		t.span.Attributes["replicode.direct_references"] = len(result.DirectResourceRefs)
+		extensions := 0
+		for _, records := range result.Extensions {
+			extensions += len(records)
+		}
+		t.span.Attributes["replicode.extension_records"] = extensions
+	}
+	t.report(t.span)
+}
@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"go/ast"
+	"sync"
+	"time"
+)
+
+// visitor is one extractor's share of the single traversal of a file. It is
+// called as ast.Inspect would call it in a walk of its own: with each node,
+// returning false to skip that node's children for itself only, and with nil
+// after the children of every node it returned true for.
+type visitor func(n ast.Node) bool
+
+// fileWalk runs the node-level extractors of a file in one traversal, so
+// that a file is walked once however many of them there are
+type fileWalk struct {
+	names    []string
+	visitors []visitor
+	counts   []func() int // Records each visitor found, for its trace span; nil when not counted
+}
+
+// add registers a visitor under the name of its trace span
+func (w *fileWalk) add(name string, v visitor, count func() int) {
+	w.names = append(w.names, name)
+	w.visitors = append(w.visitors, v)
+	w.counts = append(w.counts, count)
+}
+
+// run walks the file once. With tracing on, each visitor gets a span as long
+// as the time spent in it; the spans are laid end to end, so together they
+// show how the traversal's time divides between the extractors.
+func (w *fileWalk) run(file *ast.File, trace *fileTrace) {
+	if trace == nil {
+		walkVisitors(file, w.visitors)
+		return
+	}
+
+	elapsed := make([]time.Duration, len(w.visitors))
+	timed := make([]visitor, len(w.visitors))
+	for i, v := range w.visitors {
+		i, v := i, v
+		timed[i] = func(n ast.Node) bool {
+			start := time.Now()
+			more := v(n)
+			elapsed[i] += time.Since(start)
+			return more
+		}
+	}
+	start := time.Now()
+	walkVisitors(file, timed)
+	for i, name := range w.names {
+		span := Span{Name: name, Start: start, End: start.Add(elapsed[i]), Attributes: map[string]interface{}{}}
+		if w.counts[i] != nil {
+			span.Attributes[AttrRecords] = w.counts[i]()
+		}
+		trace.add(span)
+		start = start.Add(elapsed[i])
+	}
+}
+
+// walkVisitors walks the node once, calling each visitor as ast.Inspect
+// would. A visitor that returns false for a node is skipped until the walk
+// leaves that node, which takes a depth per visitor rather than a new visitor
+// list per node.
+func walkVisitors(node ast.Node, visitors []visitor) {
+	skippedAt := make([]int, len(visitors)) // Depth a visitor declined at; 0 while it is active
+	depth := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			for i, v := range visitors {
+				switch skippedAt[i] {
+				case 0:
+					v(nil)
+				case depth:
+					skippedAt[i] = 0
+				}
+			}
+			depth--
+			return false
+		}
+
+		depth++
+		active := 0
+		for i, v := range visitors {
+			if skippedAt[i] != 0 {
+				continue
+			}
+			if v(n) {
+				active++
+			} else {
+				skippedAt[i] = depth
+			}
+		}
+		if active > 0 {
+			return true
+		}
+
+		// No visitor wants the children, so the walk does not descend and
+		// will not report leaving this node
+		for i := range skippedAt {
+			if skippedAt[i] == depth {
+				skippedAt[i] = 0
+			}
+		}
+		depth--
+		return false
+	})
+}
+
+// forEachFuncDecl calls fn with each function declaration in the file. Function
+// declarations only occur at the top level, so this sees the same nodes as a
+// walk of the whole file that filters for *ast.FuncDecl, without the walk.
+func forEachFuncDecl(file *ast.File, fn func(funcDecl *ast.FuncDecl)) {
+	for _, decl := range file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			fn(funcDecl)
+		}
+	}
+}
+
+// runConcurrently runs each task in its own goroutine and waits for all of them
+func runConcurrently(tasks ...func()) {
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		task := task
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			task()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ExecutionPlan is an ordered list of stages. Items within a stage may run in
+// parallel; a stage starts only after the previous stage has finished.
+type ExecutionPlan struct {
+	Stages           []PlanStage `json:"stages"`
+	EstimatedSeconds int         `json:"estimated_seconds"` // Sum of stage durations
+}
+
+// PlanStage is one parallelizable step of an execution plan
+type PlanStage struct {
+	Index            int        `json:"index"` // 1-based stage number
+	Items            []PlanItem `json:"items"`
+	EstimatedSeconds int        `json:"estimated_seconds"` // Longest item in the stage
+}
+
+// PlanItem is a single go test invocation. Sequential members are addressed
+// as subtests of their entry point (Test/group/key).
+type PlanItem struct {
+	Test             string `json:"test"`               // Top-level test function passed to go test
+	Group            string `json:"group,omitempty"`    // SequentialGroup, for sequential members
+	Key              string `json:"key,omitempty"`      // SequentialKey, for sequential members
+	Function         string `json:"function,omitempty"` // Function run by the sequential member
+	RunPattern       string `json:"run_pattern"`        // Value for go test -run
+	EstimatedSeconds int    `json:"estimated_seconds"`
+	SkippableSteps   int    `json:"skippable_steps,omitempty"` // Steps with a SkipFunc, which may not run
+	Footprint        int    `json:"footprint"`                 // Resources its templates create (see Footprint)
+	Pool             string `json:"pool,omitempty"`            // Agent pool the item must run in (plan -alt-pool)
+}
+
+// sequentialMember is one function run by a sequential entry point
+type sequentialMember struct {
+	group    string
+	key      string
+	function string
+}
+
+// BuildExecutionPlan schedules the given runnable tests. Tests without
+// sequential references are independent and all run in the first stage. For
+// sequential entry points, each SequentialGroup is serialized in key order:
+// the n-th member of every group runs in stage n, so independent groups
+// progress in parallel. Items sharing a singleton dependency (see
+// MutualExclusions) never share a stage; a conflicting item moves to the
+// next free stage, pushing the rest of its group back with it.
+func BuildExecutionPlan(graph *DependencyGraph, tests []string, durations *DurationModel) *ExecutionPlan {
+	plan := &ExecutionPlan{Stages: []PlanStage{}}
+	var stageKeys []map[string]bool
+	addItem := func(earliest int, item PlanItem, keys map[string]bool) int {
+		stage := earliest
+		for ; stage < len(plan.Stages); stage++ {
+			if !sharesSingleton(stageKeys[stage], keys) {
+				break
+			}
+		}
+		for len(plan.Stages) <= stage {
+			plan.Stages = append(plan.Stages, PlanStage{Index: len(plan.Stages) + 1, Items: []PlanItem{}})
+			stageKeys = append(stageKeys, map[string]bool{})
+		}
+		plan.Stages[stage].Items = append(plan.Stages[stage].Items, item)
+		for key := range keys {
+			stageKeys[stage][key] = true
+		}
+		return stage
+	}
+
+	sorted := append([]string(nil), tests...)
+	sort.Strings(sorted)
+	for _, test := range sorted {
+		id := testNodeID(test)
+		groups := map[string][]sequentialMember{}
+		for _, edge := range graph.OutEdges(id) {
+			if edge.Kind != EdgeSequentialRef {
+				continue
+			}
+			target := graph.Nodes[edge.To]
+			if target == nil {
+				continue
+			}
+			group, key, _ := strings.Cut(edge.Detail, "/")
+			groups[group] = append(groups[group], sequentialMember{group: group, key: key, function: target.Name})
+		}
+
+		if len(groups) == 0 {
+			estimate, _ := estimateTestDuration(test, graph.StepCount(id), durations)
+			addItem(0, PlanItem{
+				Test:             test,
+				RunPattern:       "^" + regexp.QuoteMeta(test) + "$",
+				EstimatedSeconds: int(estimate.Seconds()),
+				SkippableSteps:   graph.SkippableSteps(id),
+			}, graph.SingletonKeys(id))
+			continue
+		}
+
+		groupNames := make([]string, 0, len(groups))
+		for group := range groups {
+			groupNames = append(groupNames, group)
+		}
+		sort.Strings(groupNames)
+
+		for _, group := range groupNames {
+			members := groups[group]
+			sort.Slice(members, func(i, j int) bool {
+				if members[i].key != members[j].key {
+					return members[i].key < members[j].key
+				}
+				return members[i].function < members[j].function
+			})
+			next := 0
+			for _, member := range members {
+				memberID := testNodeID(member.function)
+				estimate, _ := estimateTestDuration(member.function, graph.StepCount(memberID), durations)
+				next = 1 + addItem(next, PlanItem{
+					Test:             test,
+					Group:            member.group,
+					Key:              member.key,
+					Function:         member.function,
+					RunPattern:       subtestPattern(test, member.group, member.key),
+					EstimatedSeconds: int(estimate.Seconds()),
+					SkippableSteps:   graph.SkippableSteps(memberID),
+				}, graph.SingletonKeys(memberID))
+			}
+		}
+	}
+
+	for i := range plan.Stages {
+		stage := &plan.Stages[i]
+		sort.Slice(stage.Items, func(a, b int) bool {
+			return stage.Items[a].RunPattern < stage.Items[b].RunPattern
+		})
+		for _, item := range stage.Items {
+			if item.EstimatedSeconds > stage.EstimatedSeconds {
+				stage.EstimatedSeconds = item.EstimatedSeconds
+			}
+		}
+		plan.EstimatedSeconds += stage.EstimatedSeconds
+	}
+
+	return plan
+}
+
+// subtestPattern builds a go test -run expression addressing one subtest level per name
+func subtestPattern(names ...string) string {
+	parts := []string{}
+	for _, name := range names {
+		if name != "" {
+			parts = append(parts, "^"+regexp.QuoteMeta(name)+"$")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// RoutePlanPools assigns every item that needs a second subscription or
+// tenant, or creates resources through an aliased azurerm provider (provider
+// = azurerm.alt), through its own function or anything it reaches, to pool
+func RoutePlanPools(graph *DependencyGraph, plan *ExecutionPlan, pool string) {
+	for s := range plan.Stages {
+		for i := range plan.Stages[s].Items {
+			item := &plan.Stages[s].Items[i]
+			name := item.Test
+			if item.Function != "" {
+				name = item.Function
+			}
+			if reqs := graph.Requirements(name); reqs.AltSubscription || usesAzurermAlias(reqs.ProviderAliases) {
+				item.Pool = pool
+			}
+		}
+	}
+}
+
+// usesAzurermAlias reports whether provider aliases include a non-default
+// azurerm provider, which alternate-subscription tests select by alias
+func usesAzurermAlias(aliases []string) bool {
+	for _, alias := range aliases {
+		if strings.HasPrefix(alias, "azurerm.") {
+			return true
+		}
+	}
+	return false
+}
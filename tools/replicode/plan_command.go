@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// runPlanCommand prints a staged execution plan that respects sequential constraints
+func runPlanCommand(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	var resources stringList
+	fs.Var(&resources, "resource", "Plan only the tests impacted by these resource type(s); default is every runnable test")
+	durationsPath := fs.String("durations", "", "Historical timing data: database directory or TestDurations.csv (see durations ingest)")
+	altPool := fs.String("alt-pool", "multi-subscription", "Pool assigned to items needing alternate subscription or tenant credentials (empty to disable)")
+	format := fs.String("format", "json", "Output format: json, text, or teamcity")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if err := validateFormat(*format, "json", "text", "teamcity"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var durations *DurationModel
+	if *durationsPath != "" {
+		model, err := LoadDurationModel(resolveDurationsPath(*durationsPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		durations = model
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	var tests []string
+	if len(resources) > 0 {
+		for _, test := range SelectImpactedTests(graph, resources, durations) {
+			tests = append(tests, test.Name)
+		}
+	} else {
+		tests = runnableTests(graph)
+	}
+
+	plan := BuildExecutionPlan(graph, entryPoints(graph, tests), durations)
+	AttachPlanFootprints(graph, plan)
+	if *altPool != "" {
+		RoutePlanPools(graph, plan, *altPool)
+	}
+
+	if *format == "teamcity" {
+		writePlanTeamCity(os.Stdout, newProvenance(fs, source.root()), plan)
+		return 0
+	}
+	if *format == "text" {
+		writePlanText(plan)
+		return 0
+	}
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), plan); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runnableTests returns every test function go test can run directly, sorted by name
+func runnableTests(graph *DependencyGraph) []string {
+	var tests []string
+	for _, node := range graph.Nodes {
+		if node.Kind == NodeTest && isRunnableTest(node.Name) {
+			tests = append(tests, node.Name)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// entryPoints drops tests that another test in the set already runs sequentially,
+// so no test is scheduled twice
+func entryPoints(graph *DependencyGraph, tests []string) []string {
+	covered := map[string]bool{}
+	for _, test := range tests {
+		for reachedID := range graph.reachableVia(testNodeID(test), EdgeSequentialRef) {
+			if reached := graph.Nodes[reachedID]; reached != nil {
+				covered[reached.Name] = true
+			}
+		}
+	}
+
+	var entries []string
+	for _, test := range tests {
+		if !covered[test] {
+			entries = append(entries, test)
+		}
+	}
+	return entries
+}
+
+// writePlanText prints an execution plan for interactive use
+func writePlanText(plan *ExecutionPlan) {
+	fmt.Printf("Execution plan: %d stages, %s\n", len(plan.Stages), formatSeconds(plan.EstimatedSeconds))
+
+	for _, stage := range plan.Stages {
+		fmt.Printf("\nStage %d (%d parallel, %s)\n", stage.Index, len(stage.Items), formatSeconds(stage.EstimatedSeconds))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, item := range stage.Items {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", item.RunPattern, item.Function, formatSeconds(item.EstimatedSeconds), item.Pool)
+		}
+		w.Flush()
+	}
+}
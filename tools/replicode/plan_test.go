@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+const aliasedProviderTests = `package network_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+)
+
+type AliasResource struct{}
+
+func TestAccAlias_aliased(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_alias", "test")
+	r := AliasResource{}
+	data.ResourceTest(t, r, []acceptance.TestStep{{Config: r.aliased(data)}})
+}
+
+func TestAccAlias_basic(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_alias", "test")
+	r := AliasResource{}
+	data.ResourceTest(t, r, []acceptance.TestStep{{Config: r.basic(data)}})
+}
+
+func (AliasResource) aliased(data acceptance.TestData) string {
+	return ` + "`" + `
+resource "azurerm_alias" "test" {
+  provider = azurerm.alt
+  name     = "acctest-%d"
+}
+` + "`" + `
+}
+
+func (AliasResource) basic(data acceptance.TestData) string {
+	return ` + "`" + `
+resource "azurerm_alias" "test" {
+  name = "acctest-%d"
+}
+` + "`" + `
+}
+`
+
+func TestRoutePlanPoolsProviderAlias(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "internal", "services", "network")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "alias_resource_test.go")
+	if err := os.WriteFile(path, []byte(aliasedProviderTests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := analyzer.Analyze(path, analyzer.Options{RepoRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := BuildDependencyGraph([]*analyzer.Result{result})
+
+	plan := &ExecutionPlan{Stages: []PlanStage{{Items: []PlanItem{{Test: "TestAccAlias_aliased"}, {Test: "TestAccAlias_basic"}}}}}
+	RoutePlanPools(graph, plan, "multi-subscription")
+
+	pools := map[string]string{}
+	for _, item := range plan.Stages[0].Items {
+		pools[item.Test] = item.Pool
+	}
+	if pools["TestAccAlias_aliased"] != "multi-subscription" {
+		t.Errorf("aliased test pool = %q, want multi-subscription", pools["TestAccAlias_aliased"])
+	}
+	if pools["TestAccAlias_basic"] != "" {
+		t.Errorf("basic test pool = %q, want none", pools["TestAccAlias_basic"])
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// loadExtractorPlugins opens Go plugins (built with -buildmode=plugin) that
+// add custom extractors. A plugin registers its extractors with
+// analyzer.Register from an init function, which runs when it is opened;
+// opening the same plugin twice is a no-op.
+func loadExtractorPlugins(paths []string) error {
+	for _, path := range paths {
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("loading extractor plugin %s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// prCommentMarker is an invisible marker so CI can find and update its previous comment
+const prCommentMarker = "<!-- terracorder-impact -->"
+
+// prCommentMaxPaths bounds the path search used for dependency evidence
+const prCommentMaxPaths = 10
+
+// writePRComment renders a selection as GitHub-flavored Markdown for a pull request comment
+func writePRComment(w io.Writer, provenance *Provenance, graph *DependencyGraph, selection *SelectionResult) {
+	byService := map[string][]SelectedTest{}
+	totalSeconds := 0
+	for _, test := range selection.Tests {
+		service := test.Service
+		if service == "" {
+			service = "(unknown)"
+		}
+		byService[service] = append(byService[service], test)
+		totalSeconds += test.EstimatedSeconds
+	}
+	services := make([]string, 0, len(byService))
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	fmt.Fprintln(w, prCommentMarker)
+	fmt.Fprintf(w, "<!-- %s -->\n", provenance)
+	fmt.Fprintln(w, "## Terracorder impact analysis")
+	fmt.Fprintln(w)
+
+	changed := make([]string, 0, len(selection.Resources)+len(selection.Changes))
+	for _, target := range append(append([]string{}, selection.Resources...), selection.Changes...) {
+		changed = append(changed, "`"+target+"`")
+	}
+	fmt.Fprintf(w, "**Changed:** %s\n\n", strings.Join(changed, ", "))
+
+	fmt.Fprintln(w, "| Impacted tests | Services | Estimated duration |")
+	fmt.Fprintln(w, "|---:|---:|---:|")
+	fmt.Fprintf(w, "| %d | %d | %s |\n", len(selection.Tests), len(services), formatSeconds(totalSeconds))
+	fmt.Fprintln(w)
+
+	if len(selection.Tests) == 0 {
+		fmt.Fprintln(w, "No acceptance tests depend on the changed resources.")
+		return
+	}
+
+	for _, service := range services {
+		tests := byService[service]
+		fmt.Fprintf(w, "<details>\n<summary><b>%s</b> (%d tests)</summary>\n\n", service, len(tests))
+		fmt.Fprintln(w, "| Test | Risk | Steps | Estimate | Evidence |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|---|")
+		for _, test := range tests {
+			fmt.Fprintf(w, "| `%s` | %.2f | %d | %s | %s |\n", test.Name, test.RiskScore, test.Steps,
+				formatSeconds(test.EstimatedSeconds), dependencyEvidence(graph, test))
+		}
+		fmt.Fprintln(w, "\n</details>")
+		fmt.Fprintln(w)
+	}
+}
+
+// dependencyEvidence describes the shortest chain from a test to its first
+// matched resource, ending with the file and line of the HCL reference
+func dependencyEvidence(graph *DependencyGraph, test SelectedTest) string {
+	if len(test.MatchedResources) == 0 {
+		if len(test.MatchedCode) > 0 {
+			return "covers " + markdownCode(test.MatchedCode)
+		}
+		if len(test.MatchedPackages) > 0 {
+			return "imports " + markdownCode(test.MatchedPackages)
+		}
+		return ""
+	}
+
+	chain, location := evidenceChain(graph, test.Name, test.MatchedResources[0])
+	if chain == nil {
+		return markdownCode(test.MatchedResources)
+	}
+	evidence := strings.Join(markdownCodeEach(chain), " → ")
+	if location != "" {
+		evidence += " (" + location + ")"
+	}
+	return evidence
+}
+
+// evidenceChain returns the node names along the shortest path from a test to
+// a resource, without the test, and the "file:line" of the last reference
+// ("" when unknown). The chain is nil when no path is found.
+func evidenceChain(graph *DependencyGraph, test, resource string) (chain []string, location string) {
+	var shortest *GraphPath
+	for _, path := range graph.Paths(testNodeID(test), resourceNodeID(resource), prCommentMaxPaths) {
+		path := path
+		if shortest == nil || len(path.Nodes) < len(shortest.Nodes) {
+			shortest = &path
+		}
+	}
+	if shortest == nil {
+		return nil, ""
+	}
+
+	chain = make([]string, 0, len(shortest.Nodes)-1)
+	for _, id := range shortest.Nodes[1:] {
+		if node := graph.Nodes[id]; node != nil {
+			chain = append(chain, node.Name)
+		}
+	}
+	if hops := shortest.Hops; len(hops) > 0 {
+		if edges := hops[len(hops)-1].Edges; len(edges) > 0 && edges[0].File != "" {
+			location = fmt.Sprintf("%s:%d", edges[0].File, edges[0].Line)
+		}
+	}
+	return chain, location
+}
+
+// markdownCode renders values as comma-separated inline code spans
+func markdownCode(values []string) string {
+	return strings.Join(markdownCodeEach(values), ", ")
+}
+
+// markdownCodeEach renders each value as an inline code span
+func markdownCodeEach(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "`" + value + "`"
+	}
+	return quoted
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// version is the replicode release, set at build time with
+// -ldflags "-X main.version=<version>"
+var version = "dev"
+
+// Provenance identifies the tool build, analyzed commit, and configuration
+// that produced an output document
+type Provenance struct {
+	Tool            string           `json:"tool"`
+	Version         string           `json:"version"`
+	Command         string           `json:"command"`
+	RepoCommit      string           `json:"repo_commit,omitempty"` // HEAD of the analyzed repository
+	AnalyzedAt      time.Time        `json:"analyzed_at"`
+	Flags           []string         `json:"flags"`                      // Flags given on the command line
+	ConfiguredFlags []ConfiguredFlag `json:"configured_flags,omitempty"` // Flags set from the environment or configuration file
+	ConfigHash      string           `json:"config_hash"`                // SHA-256 of every flag value, defaults included
+}
+
+// ConfiguredFlag is a flag the command line left out and the environment or
+// the configuration file set
+type ConfiguredFlag struct {
+	Flag   string `json:"flag"`   // e.g., "-cache=.replicode-cache"
+	Source string `json:"source"` // Environment variable or "<file>: <key>"
+}
+
+// newProvenance describes the current invocation of the command owning fs.
+// repoRoot may be empty for commands that do not analyze a repository.
+func newProvenance(fs *flag.FlagSet, repoRoot string) *Provenance {
+	p := &Provenance{
+		Tool:       "replicode",
+		Version:    toolVersion(),
+		Command:    fs.Name(),
+		AnalyzedAt: time.Now().UTC().Truncate(time.Second),
+		Flags:      []string{},
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		given := fmt.Sprintf("-%s=%s", f.Name, f.Value)
+		if source, ok := configuredFlags[fs][f.Name]; ok {
+			p.ConfiguredFlags = append(p.ConfiguredFlags, ConfiguredFlag{Flag: given, Source: source})
+			return
+		}
+		p.Flags = append(p.Flags, given)
+	})
+
+	var config []string
+	fs.VisitAll(func(f *flag.Flag) {
+		config = append(config, f.Name+"="+f.Value.String())
+	})
+	sort.Strings(config)
+	sum := sha256.Sum256([]byte(p.Command + "\n" + strings.Join(config, "\n")))
+	p.ConfigHash = hex.EncodeToString(sum[:])
+
+	if repoRoot != "" {
+		p.RepoCommit = gitHeadCommit(repoRoot)
+	}
+	return p
+}
+
+// toolVersion is the release version, with the VCS revision the binary was
+// built from when Go embedded one
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return version + "+" + setting.Value[:12]
+		}
+	}
+	return version
+}
+
+// gitHeadCommit resolves HEAD of the repository containing dir by reading the
+// .git directory, so it works without git on PATH. It returns "" when dir is
+// not inside a repository or HEAD cannot be resolved.
+func gitHeadCommit(dir string) string {
+	gitDir := findGitDir(dir)
+	if gitDir == "" {
+		return ""
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref: ") {
+		return ref // Detached HEAD
+	}
+	ref = strings.TrimPrefix(ref, "ref: ")
+
+	// Linked worktrees keep shared refs in the common directory
+	dirs := []string{gitDir}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		dirs = append(dirs, commonDir)
+	}
+
+	for _, d := range dirs {
+		if commit, err := os.ReadFile(filepath.Join(d, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(commit))
+		}
+		if commit := packedRef(filepath.Join(d, "packed-refs"), ref); commit != "" {
+			return commit
+		}
+	}
+	return ""
+}
+
+// findGitDir walks up from dir to the repository's git directory, following
+// the "gitdir:" pointer file used by worktrees and submodules
+func findGitDir(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(abs, ".git")
+		if info, err := os.Stat(candidate); err == nil {
+			if info.IsDir() {
+				return candidate
+			}
+			data, err := os.ReadFile(candidate)
+			if err != nil {
+				return ""
+			}
+			pointer := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+			if !filepath.IsAbs(pointer) {
+				pointer = filepath.Join(abs, pointer)
+			}
+			return pointer
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return ""
+		}
+		abs = parent
+	}
+}
+
+// packedRef looks a ref up in a packed-refs file
+func packedRef(path, ref string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// String summarizes the provenance on one line for Markdown and CI log outputs
+func (p *Provenance) String() string {
+	commit := p.RepoCommit
+	if commit == "" {
+		commit = "unknown"
+	}
+	return fmt.Sprintf("%s %s, %s, commit %s, analyzed %s, config %s", p.Tool, p.Version, p.Command,
+		commit, p.AnalyzedAt.Format(time.RFC3339), p.ConfigHash[:12])
+}
+
+// writeDocument writes v as indented JSON with the provenance as its first
+// key. Objects gain a "provenance" key; any other value is wrapped as
+// {"provenance": ..., "results": v}.
+func writeDocument(w io.Writer, provenance *Provenance, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %v", err)
+	}
+
+	countDocument(w)
+	stream := newJSONStream(w)
+	stream.open('{')
+	stream.member("provenance")
+	stream.value(provenance)
+	if data[0] != '{' {
+		stream.member("results")
+		stream.value(json.RawMessage(data))
+		stream.close('}')
+		return stream.finish()
+	}
+
+	// Indent the object a member at a time rather than copying it whole
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.Token() // The opening brace
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %v", err)
+		}
+		var member json.RawMessage
+		if err := decoder.Decode(&member); err != nil {
+			return fmt.Errorf("marshaling JSON: %v", err)
+		}
+		stream.member(key.(string))
+		stream.value(member)
+	}
+	stream.close('}')
+	return stream.finish()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestProvenanceConfiguredFlags(t *testing.T) {
+	t.Setenv("TERRACORDER_PROVENANCE_TEST_FORMAT", "text")
+	fs := flag.NewFlagSet("provenance-test", flag.ContinueOnError)
+	fs.String("dir", "", "")
+	fs.String("format", "json", "")
+	if err := parseFlags(fs, []string{"-dir=internal/services"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newProvenance(fs, "")
+	if want := []string{"-dir=internal/services"}; !reflect.DeepEqual(p.Flags, want) {
+		t.Errorf("flags = %q, want %q", p.Flags, want)
+	}
+	want := []ConfiguredFlag{{Flag: "-format=text", Source: "TERRACORDER_PROVENANCE_TEST_FORMAT"}}
+	if !reflect.DeepEqual(p.ConfiguredFlags, want) {
+		t.Errorf("configured flags = %+v, want %+v", p.ConfiguredFlags, want)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// databaseIndex is the loaded analysis database shared by query-serve requests
+type databaseIndex struct {
+	mu      sync.RWMutex
+	dir     string
+	db      *AnalysisDatabase
+	loaded  time.Time
+	metrics *serverMetrics
+}
+
+// load reads the database directory and replaces the index contents
+func (x *databaseIndex) load() error {
+	db, err := LoadAnalysisDatabase(x.dir)
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.db, x.loaded = db, time.Now().UTC()
+	return nil
+}
+
+// gauges samples the database for a metrics scrape
+func (x *databaseIndex) gauges() []gauge {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return []gauge{
+		{"replicode_database_resources", "Resources the loaded database was discovered for.", float64(len(x.db.resources))},
+		{"replicode_database_loaded_timestamp_seconds", "Unix time the database was last loaded.", float64(x.loaded.Unix())},
+	}
+}
+
+// snapshot returns the current database; databases are never mutated once loaded
+func (x *databaseIndex) snapshot() *AnalysisDatabase {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.db
+}
+
+// runQueryServeCommand serves canned queries over a stored analysis database
+func runQueryServeCommand(args []string) int {
+	fs := flag.NewFlagSet("query-serve", flag.ContinueOnError)
+	index := &databaseIndex{}
+	index.metrics = newServerMetrics(index.gauges)
+	fs.StringVar(&index.dir, "db", "", "Database directory written by a TerraCorder discovery run (the CSV export directory)")
+	addr := fs.String("addr", "127.0.0.1:8081", "Address to listen on")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if index.dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -db parameter is required")
+		return 1
+	}
+
+	if err := index.load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Loaded database %s (%d resources); listening on %s\n", index.dir, len(index.db.resources), *addr)
+
+	server := &http.Server{Addr: *addr, Handler: newQueryServeMux(index, fs), ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// newQueryServeMux routes the query-serve endpoints
+func newQueryServeMux(index *databaseIndex, fs *flag.FlagSet) *http.ServeMux {
+	provenance := func() *Provenance {
+		return newProvenance(fs, "")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", index.metrics)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, index.metrics.instrument(pattern, handler))
+	}
+	get := func(pattern string, handler func(w http.ResponseWriter, r *http.Request, db *AnalysisDatabase)) {
+		handle(pattern, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET"))
+				return
+			}
+			handler(w, r, index.snapshot())
+		})
+	}
+
+	get("/resources", func(w http.ResponseWriter, r *http.Request, db *AnalysisDatabase) {
+		writeServeDocument(w, provenance(), db.Resources())
+	})
+
+	get("/impacted-tests", func(w http.ResponseWriter, r *http.Request, db *AnalysisDatabase) {
+		resource := r.URL.Query().Get("resource")
+		if resource == "" {
+			writeServeError(w, http.StatusBadRequest, fmt.Errorf("resource parameter is required"))
+			return
+		}
+		result, ok := db.ImpactedTests(resource)
+		if !ok {
+			writeServeError(w, http.StatusNotFound, fmt.Errorf("resource %q is not in the database (discovered: %v)", resource, db.Resources()))
+			return
+		}
+		writeServeDocument(w, provenance(), result)
+	})
+
+	get("/cross-service", func(w http.ResponseWriter, r *http.Request, db *AnalysisDatabase) {
+		writeServeDocument(w, provenance(), db.CrossServiceReferences(r.URL.Query().Get("service")))
+	})
+
+	get("/sequential-groups", func(w http.ResponseWriter, r *http.Request, db *AnalysisDatabase) {
+		writeServeDocument(w, provenance(), db.SequentialGroups(r.URL.Query().Get("entry")))
+	})
+
+	handle("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+			return
+		}
+		if err := index.load(); err != nil {
+			writeServeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		index.mu.RLock()
+		status := map[string]interface{}{"resources": len(index.db.resources), "loaded_at": index.loaded}
+		index.mu.RUnlock()
+		writeServeDocument(w, provenance(), status)
+	})
+
+	return mux
+}
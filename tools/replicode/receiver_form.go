@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// receiverRewrite is one test whose TestStep configs were rewritten into the
+// receiver form, or left alone with the reason, by the receiver-form codemod
+type receiverRewrite struct {
+	Line     int
+	Function string
+	Struct   string
+	Variable string
+	Skipped  string // Why the test was left as it is; "" when rewritten
+}
+
+// receiverFormFile rewrites the tests of one file whose TestStep configs call
+// templates through a StructName{} literal into the conventional form, where
+// the test assigns r := StructName{} after its BuildTestData call and every
+// StructName{} literal in the test becomes r. A variable the test already
+// assigns a StructName{} to is used instead of adding r. It returns the new
+// content, gofmt-formatted, and every test it looked at.
+func receiverFormFile(path string, src []byte) ([]byte, []receiverRewrite, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing file: %v", err)
+	}
+
+	var edits []textEdit
+	var rewrites []receiverRewrite
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil || !(strings.HasPrefix(fn.Name.Name, "Test") || strings.HasPrefix(fn.Name.Name, "testAcc")) {
+			continue
+		}
+		structs := stepLiteralStructs(fn.Body)
+		if len(structs) == 0 {
+			continue
+		}
+		rewrite := receiverRewrite{Line: fset.Position(fn.Pos()).Line, Function: fn.Name.Name, Struct: checkedStruct(fn)}
+		switch {
+		case rewrite.Struct != "":
+			if i := sort.SearchStrings(structs, rewrite.Struct); i == len(structs) || structs[i] != rewrite.Struct {
+				continue // Only other resources' templates are called through literals
+			}
+		case len(structs) == 1:
+			rewrite.Struct = structs[0]
+		default:
+			rewrite.Skipped = fmt.Sprintf("steps call templates of %s", strings.Join(structs, ", "))
+			rewrites = append(rewrites, rewrite)
+			continue
+		}
+
+		// The literals to replace, and the variable replacing them
+		var assign *ast.AssignStmt
+		for _, stmt := range fn.Body.List {
+			if a, ok := stmt.(*ast.AssignStmt); ok && a.Tok == token.DEFINE && len(a.Lhs) == 1 && len(a.Rhs) == 1 && emptyLiteralOf(a.Rhs[0]) == rewrite.Struct {
+				if ident, ok := a.Lhs[0].(*ast.Ident); ok && ident.Name != "_" {
+					assign, rewrite.Variable = a, ident.Name
+					break
+				}
+			}
+		}
+		if assign == nil {
+			rewrite.Variable = "r"
+			if identUsed(fn.Body, rewrite.Variable) {
+				rewrite.Skipped = "r is already used"
+				rewrites = append(rewrites, rewrite)
+				continue
+			}
+		}
+		var literals []*ast.CompositeLit
+		addressed := map[ast.Expr]bool{}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.UnaryExpr:
+				if n.Op == token.AND {
+					addressed[n.X] = true
+				}
+			case *ast.CompositeLit:
+				if emptyLiteralOf(n) == rewrite.Struct && !addressed[n] && (assign == nil || n.Pos() > assign.End()) {
+					literals = append(literals, n)
+				}
+			}
+			return true
+		})
+		if assign != nil && len(literals) > 0 && literals[0].Pos() < assign.Pos() {
+			rewrite.Skipped = fmt.Sprintf("%s{} is used before %s is assigned", rewrite.Struct, rewrite.Variable)
+			rewrites = append(rewrites, rewrite)
+			continue
+		}
+
+		if assign == nil {
+			edits = append(edits, receiverAssignment(fset, fn.Body, rewrite.Variable+" := "+rewrite.Struct+"{}"))
+		}
+		for _, lit := range literals {
+			edits = append(edits, textEdit{start: fset.Position(lit.Pos()).Offset, end: fset.Position(lit.End()).Offset, text: rewrite.Variable})
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(edits) == 0 {
+		return src, rewrites, nil
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	out, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return nil, nil, fmt.Errorf("formatting rewritten file: %v", err)
+	}
+	return out, rewrites, nil
+}
+
+// stepLiteralStructs returns the structs whose StructName{} literals the
+// TestStep configs of a test call templates through, sorted
+func stepLiteralStructs(body *ast.BlockStmt) []string {
+	seen := map[string]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		config := configValue(lit)
+		if config == nil {
+			return true
+		}
+		ast.Inspect(config, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+					if name := emptyLiteralOf(sel.X); name != "" {
+						seen[name] = true
+					}
+				}
+			}
+			return true
+		})
+		return true
+	})
+	structs := make([]string, 0, len(seen))
+	for name := range seen {
+		structs = append(structs, name)
+	}
+	sort.Strings(structs)
+	return structs
+}
+
+// checkedStruct returns the struct a test passes as the resource checker of
+// data.ResourceTest and its variants, as a StructName{} literal or a variable
+// assigned one, or ""
+func checkedStruct(fn *ast.FuncDecl) string {
+	name := ""
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 2 {
+			return name == ""
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "Resource") && isIdent(call.Args[0], "t") {
+			name = selectedStruct(call.Args[1], fn, true)
+		}
+		return name == ""
+	})
+	return name
+}
+
+// emptyLiteralOf returns the struct of an unqualified StructName{} literal
+// with no fields, or ""
+func emptyLiteralOf(expr ast.Expr) string {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok || len(lit.Elts) > 0 {
+		return ""
+	}
+	if ident, ok := lit.Type.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// identUsed reports whether any identifier in a block has the name
+func identUsed(block *ast.BlockStmt, name string) bool {
+	used := false
+	ast.Inspect(block, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
+
+// receiverAssignment returns the edit inserting a statement after a test's
+// BuildTestData assignment, or first in its body when it has none
+func receiverAssignment(fset *token.FileSet, body *ast.BlockStmt, stmt string) textEdit {
+	for _, s := range body.List {
+		assign, ok := s.(*ast.AssignStmt)
+		if !ok || len(assign.Rhs) != 1 {
+			continue
+		}
+		if call, ok := assign.Rhs[0].(*ast.CallExpr); ok {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "BuildTestData" {
+				offset := fset.Position(s.End()).Offset
+				return textEdit{start: offset, end: offset, text: "\n\t" + stmt}
+			}
+		}
+	}
+	offset := fset.Position(body.Lbrace).Offset + 1
+	return textEdit{start: offset, end: offset, text: "\n\t" + stmt + "\n"}
+}
+
+// runReceiverFormCommand rewrites TestStep configs calling templates through
+// StructName{} literals into the r := StructName{} receiver form, printing
+// the changes as a patch or writing them to the files
+func runReceiverFormCommand(args []string) int {
+	fs := flag.NewFlagSet("receiver-form", flag.ContinueOnError)
+	dir := fs.String("dir", "", "Directory to rewrite recursively (e.g., internal/services)")
+	repoRoot := fs.String("reporoot", "", "Repository root the patch paths are relative to (defaults to the module or repository containing -dir)")
+	var excluded stringList
+	fs.Var(&excluded, "exclude-service", "Service to leave as it is (e.g., network), comma-separated or repeated")
+	output := newCodemodOutput(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+	root := *repoRoot
+	if root == "" {
+		root = defaultRepoRoot(*dir)
+	}
+	if err := output.start(root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	excludedServices := map[string]bool{}
+	for _, service := range excluded {
+		excludedServices[service] = true
+	}
+
+	stamps, err := scanTestFiles(*dir, analyzer.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	files := make([]string, 0, len(stamps))
+	for path := range stamps {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+
+	rewritten, skipped, changedFiles := 0, 0, 0
+	for _, path := range files {
+		rel, err := analyzer.RelativePath(root, path)
+		if err != nil {
+			rel = filepath.ToSlash(path)
+		}
+		if excludedServices[analyzer.ServiceName(rel)] {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		if !bytes.Contains(src, []byte("{}.")) {
+			continue
+		}
+		out, rewrites, err := receiverFormFile(path, src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			continue
+		}
+		for _, rewrite := range rewrites {
+			if rewrite.Skipped != "" {
+				skipped++
+				output.left("Left %s:%d (%s): %s", rel, rewrite.Line, rewrite.Function, rewrite.Skipped)
+			} else {
+				rewritten++
+			}
+		}
+		if bytes.Equal(out, src) {
+			continue
+		}
+		changedFiles++
+		if err := output.apply(path, rel, src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Rewrote"
+	if !output.writing() {
+		verb = "Would rewrite"
+	}
+	return output.finish("%s %d tests in %d files; left %d as they are", verb, rewritten, changedFiles, skipped)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// Region requirement statuses
+const (
+	regionPinned   = "PINNED"
+	regionAgnostic = "AGNOSTIC"
+)
+
+// TestRegionRequirement is whether a test can run in any region
+type TestRegionRequirement struct {
+	Test     string                     `json:"test"`
+	Service  string                     `json:"service"`
+	Status   string                     `json:"status"`             // PINNED or AGNOSTIC
+	Regions  []string                   `json:"regions"`            // Regions the test is pinned to
+	Slots    []string                   `json:"slots"`              // data.Locations fields it reads
+	Findings []analyzer.LocationFinding `json:"findings,omitempty"` // Evidence for each pin
+}
+
+// RegionReport is the output of the regions report
+type RegionReport struct {
+	Pinned   int                     `json:"pinned"`
+	Agnostic int                     `json:"agnostic"`
+	ByRegion map[string][]string     `json:"by_region"` // Region -> tests pinned to it
+	Tests    []TestRegionRequirement `json:"tests"`
+}
+
+// RegionRequirement aggregates the location findings of every test and
+// template a test reaches. A test is pinned when any of them hardcodes a
+// region or overrides the environment's locations.
+func (g *DependencyGraph) RegionRequirement(test string) TestRegionRequirement {
+	id := testNodeID(test)
+	requirement := TestRegionRequirement{Test: test, Status: regionAgnostic, Regions: []string{}, Slots: []string{}}
+	if node := g.Nodes[id]; node != nil {
+		requirement.Service = node.Service
+	}
+
+	reached := g.Reachable(id, DirectionOut, 0)
+	reached[id] = 0
+	var regions, slots []string
+	for reachedID := range reached {
+		for _, finding := range g.locations[reachedID] {
+			if finding.Slot != "" {
+				slots = append(slots, finding.Slot)
+			}
+			if finding.Region != "" {
+				regions = append(regions, finding.Region)
+				requirement.Findings = append(requirement.Findings, finding)
+			}
+		}
+	}
+	requirement.Regions = append(requirement.Regions, uniqueSorted(regions)...)
+	requirement.Slots = append(requirement.Slots, uniqueSorted(slots)...)
+	if len(requirement.Regions) > 0 {
+		requirement.Status = regionPinned
+	}
+	sort.Slice(requirement.Findings, func(i, j int) bool {
+		a, b := requirement.Findings[i], requirement.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return requirement
+}
+
+// ComputeRegionReport classifies tests as pinned or region-agnostic and
+// groups the pinned ones by region
+func ComputeRegionReport(graph *DependencyGraph, tests []string) *RegionReport {
+	report := &RegionReport{ByRegion: map[string][]string{}, Tests: []TestRegionRequirement{}}
+	for _, test := range tests {
+		requirement := graph.RegionRequirement(test)
+		if requirement.Status == regionPinned {
+			report.Pinned++
+		} else {
+			report.Agnostic++
+		}
+		for _, region := range requirement.Regions {
+			report.ByRegion[region] = append(report.ByRegion[region], test)
+		}
+		report.Tests = append(report.Tests, requirement)
+	}
+	for region := range report.ByRegion {
+		sort.Strings(report.ByRegion[region])
+	}
+	sort.Slice(report.Tests, func(i, j int) bool {
+		return report.Tests[i].Test < report.Tests[j].Test
+	})
+	return report
+}
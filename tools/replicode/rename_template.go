@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// templateRename renames one template method and the references to it that
+// the analysis records: the Config of TestSteps and the fmt.Sprintf arguments
+// of other templates
+type templateRename struct {
+	structName     string
+	method, to     string
+	files          *sourceFiles
+	handled        map[*ast.SelectorExpr]bool // Calls renamed
+	references     int
+	missing        []string // Recorded references not found in the source
+	unrecorded     []string // References found in the source that the analysis does not record
+	declarationRel string
+}
+
+// renameDeclaration marks the method's name in its declaration
+func (r *templateRename) renameDeclaration(node *GraphNode) error {
+	f, err := r.files.open(node.File)
+	if err != nil {
+		return err
+	}
+	for _, decl := range f.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != r.method || receiverTypeName(fn) != r.structName {
+			continue
+		}
+		if f.fset.Position(fn.Pos()).Line > node.Line || f.fset.Position(fn.End()).Line < node.Line {
+			continue
+		}
+		r.rename(f, fn.Name)
+		r.declarationRel = f.rel
+		return nil
+	}
+	return fmt.Errorf("declaration of %s.%s not found at %s:%d", r.structName, r.method, node.File, node.Line)
+}
+
+// renameReference marks the method's name in the calls an edge records
+func (r *templateRename) renameReference(edge *GraphEdge) error {
+	f, err := r.files.open(edge.File)
+	if err != nil {
+		return err
+	}
+	_, sels := f.recordedCalls(edge, r.method)
+	if len(sels) == 0 {
+		r.missing = append(r.missing, fmt.Sprintf("%s:%d: %s", edge.File, edge.Line, edge.Detail))
+		return nil
+	}
+	for _, sel := range sels {
+		r.rename(f, sel.Sel)
+		r.handled[sel] = true
+	}
+	r.references++
+	return nil
+}
+
+// unrecordedCalls lists the calls of a struct's method that the analysis
+// does not record and a codemod has not handled, such as a template assigned
+// to a variable before it is formatted or a call through another package's
+// StructName{} literal. Calls of the method on a value whose type can't be
+// traced, in files that can see the struct, are listed as well, since they
+// may be calls of it. declared is the file declaring the method; only files
+// mentioning the method are parsed.
+func unrecordedCalls(files *sourceFiles, paths []string, structName, method, declared string, handled map[*ast.SelectorExpr]bool) ([]string, error) {
+	needle := []byte("." + method)
+	var calls []string
+	for _, path := range paths {
+		rel, err := analyzer.RelativePath(files.root, path)
+		if err != nil {
+			rel = filepath.ToSlash(path)
+		}
+		f, ok := files.files[rel]
+		if !ok {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Contains(src, needle) {
+				continue
+			}
+			if f, err = files.open(rel); err != nil {
+				return nil, err
+			}
+		}
+
+		samePackage := filepath.Dir(rel) == filepath.Dir(declared)
+		visible := samePackage || importsDir(f.file, filepath.Dir(declared))
+		for _, decl := range f.file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != method || handled[sel] {
+					return true
+				}
+				call := fmt.Sprintf("%s:%d: %s", f.rel, f.fset.Position(sel.Pos()).Line, f.src[f.offset(sel.Pos()):f.offset(sel.End())])
+				switch name, known := operandStruct(sel.X, fn, samePackage); {
+				case name == structName:
+					calls = append(calls, call)
+				case !known && visible:
+					calls = append(calls, call+" on a value of unknown type")
+				}
+				return true
+			})
+		}
+	}
+	sort.Strings(calls)
+	return calls, nil
+}
+
+// selectedStruct returns the struct a selector's operand is a value of, when
+// it is a StructName{} literal, the method's receiver, or a variable the
+// function declares with the struct's type or assigns one to; "" otherwise.
+// Unqualified struct names only count in the template's own package.
+func selectedStruct(x ast.Expr, fn *ast.FuncDecl, samePackage bool) string {
+	name, _ := operandStruct(x, fn, samePackage)
+	return name
+}
+
+// operandStruct is selectedStruct, also reporting whether the operand's type
+// is known: not for the result of a call, a field, or a variable whose value
+// the function doesn't show, any of which may be a value of the struct
+func operandStruct(x ast.Expr, fn *ast.FuncDecl, samePackage bool) (string, bool) {
+	switch e := x.(type) {
+	case *ast.ParenExpr:
+		return operandStruct(e.X, fn, samePackage)
+	case *ast.StarExpr:
+		return operandStruct(e.X, fn, samePackage)
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return operandStruct(e.X, fn, samePackage)
+		}
+		return "", true
+	case *ast.CompositeLit:
+		return typeStruct(e.Type, samePackage), true
+	case *ast.Ident:
+		if e.Obj == nil || e.Obj.Kind != ast.Var {
+			return "", true // A package, a type, or a package-level name
+		}
+		if fn.Recv != nil && len(fn.Recv.List) == 1 {
+			for _, name := range fn.Recv.List[0].Names {
+				if name.Name == e.Name {
+					return typeStruct(fn.Recv.List[0].Type, samePackage), true
+				}
+			}
+		}
+		for _, field := range fn.Type.Params.List {
+			for _, name := range field.Names {
+				if name.Name == e.Name {
+					return typeStruct(field.Type, samePackage), true
+				}
+			}
+		}
+		assigned, known := "", false
+		trace := func(value ast.Expr) {
+			if ident, ok := value.(*ast.Ident); ok && ident.Name == e.Name {
+				return // r = r
+			}
+			if s, k := operandStruct(value, fn, samePackage); k {
+				assigned, known = s, true
+			}
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if len(n.Lhs) == len(n.Rhs) {
+					for i, lhs := range n.Lhs {
+						if ident, ok := lhs.(*ast.Ident); ok && ident.Name == e.Name {
+							trace(n.Rhs[i])
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					switch {
+					case name.Name != e.Name:
+					case n.Type != nil:
+						assigned, known = typeStruct(n.Type, samePackage), true
+					case i < len(n.Values):
+						trace(n.Values[i])
+					}
+				}
+			}
+			return assigned == ""
+		})
+		return assigned, known
+	}
+	return "", false
+}
+
+// typeStruct returns the struct a type names, StructName or pkg.StructName or
+// a pointer to one, counting unqualified names only in the template's package
+func typeStruct(t ast.Expr, samePackage bool) string {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.Ident:
+		if samePackage {
+			return t.Name
+		}
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
+
+// importsDir reports whether a file imports the package of a directory
+// relative to the repository root (e.g., internal/services/network)
+func importsDir(file *ast.File, dir string) bool {
+	suffix := "/" + filepath.ToSlash(dir)
+	for _, spec := range file.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil && strings.HasSuffix(importPath, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverTypeName returns the type name of a method's receiver, or ""
+func receiverTypeName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return ""
+	}
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if ident, ok := t.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// rename records renaming an identifier naming the method
+func (r *templateRename) rename(f *sourceFile, ident *ast.Ident) {
+	offset := f.offset(ident.Pos())
+	f.edit(textEdit{start: offset, end: offset + len(r.method), text: r.to})
+}
+
+// runRenameTemplateCommand renames a template method and every reference the
+// analysis records, printing the changes as a patch or writing them to the files
+func runRenameTemplateCommand(args []string) int {
+	fs := flag.NewFlagSet("rename-template", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	template := fs.String("template", "", "Template to rename (e.g., VirtualNetworkResource.basic)")
+	to := fs.String("to", "", "New method name (e.g., basicConfig)")
+	output := newCodemodOutput(fs)
+	force := fs.Bool("force", false, "With -write, rename even when calls the analysis does not record are left behind")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *template == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "Error: -template and -to parameters are required")
+		return 1
+	}
+	if !token.IsIdentifier(*to) {
+		fmt.Fprintf(os.Stderr, "Error: %q is not a valid method name\n", *to)
+		return 1
+	}
+
+	if err := output.start(source.root()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+	node, err := graph.ResolveNode(*template)
+	if err != nil || node.Kind != NodeTemplate {
+		fmt.Fprintf(os.Stderr, "Error: template %q not found\n", *template)
+		return 1
+	}
+	structName, method, _ := strings.Cut(node.Name, ".")
+	if method == *to {
+		fmt.Fprintf(os.Stderr, "Error: %s is already named %s\n", node.Name, *to)
+		return 1
+	}
+	if graph.Nodes[templateNodeID(structName, *to)] != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s already has a method named %s\n", structName, *to)
+		return 1
+	}
+
+	rename := &templateRename{structName: structName, method: method, to: *to, files: newSourceFiles(source.root()), handled: map[*ast.SelectorExpr]bool{}}
+	if err := rename.renameDeclaration(node); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, edge := range graph.InEdges(node.ID) {
+		if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+			continue
+		}
+		if err := rename.renameReference(edge); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	stamps, err := scanTestFiles(source.Dir, source.walkOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	paths := make([]string, 0, len(stamps))
+	for path := range stamps {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	rename.unrecorded, err = unrecordedCalls(rename.files, paths, structName, method, rename.declarationRel, rename.handled)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, reference := range rename.missing {
+		fmt.Fprintf(os.Stderr, "Warning: recorded reference not found in the source: %s\n", reference)
+	}
+	for _, reference := range rename.unrecorded {
+		output.left("Left %s (not recorded by the analysis)", reference)
+	}
+	if output.writing() && len(rename.unrecorded) > 0 && !*force {
+		fmt.Fprintf(os.Stderr, "Error: renaming would leave %d calls of %s behind; rename them by hand or pass -force\n", len(rename.unrecorded), node.Name)
+		return 1
+	}
+
+	edited := rename.files.edited()
+	for _, f := range edited {
+		out := f.output()
+		if _, err := parser.ParseFile(token.NewFileSet(), f.path, out, parser.ParseComments); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: parsing renamed %s: %v\n", f.rel, err)
+			return 1
+		}
+		if err := output.apply(f.path, f.rel, f.src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Renamed"
+	if !output.writing() {
+		verb = "Would rename"
+	}
+	return output.finish("%s %s to %s.%s and %d references in %d files; left %d unrecorded calls",
+		verb, node.Name, structName, *to, rename.references, len(edited), len(rename.unrecorded))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Deterministic stand-ins for the acceptance.TestData values templates format in
+const (
+	sampleRandomInteger = 1234567890
+	sampleRandomString  = "abcde"
+	sampleGUID          = "00000000-0000-0000-0000-000000000000"
+	samplePlaceholder   = "placeholder"
+)
+
+// sampleLocations maps data.Locations fields to the regions used in rendered templates
+var sampleLocations = map[string]string{
+	"data.Locations.Primary":   "westeurope",
+	"data.Locations.Secondary": "eastus2",
+	"data.Locations.Ternary":   "westus2",
+}
+
+// templateRenderer renders templates to concrete HCL, caching each rendering
+type templateRenderer struct {
+	graph     *DependencyGraph
+	rendered  map[string]string
+	rendering map[string]bool
+}
+
+// newTemplateRenderer creates a renderer over a graph's template sources
+func newTemplateRenderer(graph *DependencyGraph) *templateRenderer {
+	return &templateRenderer{graph: graph, rendered: map[string]string{}, rendering: map[string]bool{}}
+}
+
+// Render substitutes sample TestData values for a template's placeholders,
+// rendering embedded templates recursively
+func (r *templateRenderer) Render(id string) (string, error) {
+	if hcl, ok := r.rendered[id]; ok {
+		return hcl, nil
+	}
+	name := strings.TrimPrefix(id, string(NodeTemplate)+":")
+	source, ok := r.graph.templateSources[id]
+	if !ok {
+		return "", fmt.Errorf("%s does not return a literal or fmt.Sprintf HCL string", name)
+	}
+	if !source.Sprintf {
+		r.rendered[id] = source.Format
+		return source.Format, nil
+	}
+	if r.rendering[id] {
+		return "", fmt.Errorf("%s embeds itself", name)
+	}
+	r.rendering[id] = true
+	defer delete(r.rendering, id)
+
+	values := make([]interface{}, 0, len(source.Args))
+	for _, arg := range source.Args {
+		switch {
+		case arg.Target != "":
+			hcl, err := r.Render(arg.Target)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, hcl)
+		case arg.TargetMethod != "":
+			return "", fmt.Errorf("%s: cannot resolve template call %s", name, arg.Expr)
+		default:
+			values = append(values, sampleValue(arg.Expr))
+		}
+	}
+
+	hcl := fmt.Sprintf(source.Format, values...)
+	if strings.Contains(hcl, "%!") {
+		return "", fmt.Errorf("%s: format verbs do not match its %d arguments", name, len(source.Args))
+	}
+	r.rendered[id] = hcl
+	return hcl, nil
+}
+
+// sampleValue is the deterministic value substituted for a non-template argument
+func sampleValue(expr string) interface{} {
+	if location, ok := sampleLocations[expr]; ok {
+		return location
+	}
+	switch {
+	case expr == "data.RandomInteger":
+		return sampleRandomInteger
+	case expr == "data.RandomString":
+		return sampleRandomString
+	case strings.HasPrefix(expr, "data.RandomStringOfLength("), strings.HasPrefix(expr, "data.RandomIntOfLength("):
+		length, err := strconv.Atoi(strings.TrimSuffix(expr[strings.Index(expr, "(")+1:], ")"))
+		if err != nil || length < 1 {
+			length = len(sampleRandomString)
+		}
+		if strings.Contains(expr, "Int") {
+			value, _ := strconv.ParseInt(strings.Repeat("1", min(length, 18)), 10, 64)
+			return value
+		}
+		return strings.Repeat("a", length)
+	case strings.HasPrefix(expr, "data.Client()"), strings.HasSuffix(expr, "TenantID"), strings.HasSuffix(expr, "SubscriptionID"),
+		strings.HasSuffix(expr, "ClientID"), strings.HasSuffix(expr, "ObjectID"):
+		return sampleGUID
+	}
+	if value, err := strconv.Unquote(expr); err == nil {
+		return value
+	}
+	if value, err := strconv.Atoi(expr); err == nil {
+		return value
+	}
+	return placeholderArg(expr)
+}
+
+// placeholderArg stands in for an argument with no known sample value. It
+// formats as 1 under numeric verbs so the rendered HCL stays well-typed.
+type placeholderArg string
+
+// Format implements fmt.Formatter
+func (p placeholderArg) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b':
+		fmt.Fprint(f, 1)
+	case 'q':
+		fmt.Fprintf(f, "%q", samplePlaceholder)
+	case 't':
+		fmt.Fprint(f, false)
+	default:
+		fmt.Fprint(f, samplePlaceholder)
+	}
+}
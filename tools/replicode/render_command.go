@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RenderedStep is one TestStep configuration written as a .tf file
+type RenderedStep struct {
+	Index    int    `json:"index"` // 1-based position among the test's steps
+	Template string `json:"template,omitempty"`
+	Path     string `json:"path,omitempty"`  // main.tf written for the step, relative to the output directory
+	Error    string `json:"error,omitempty"` // Why the step could not be rendered
+}
+
+// RenderedTest lists the rendered step configurations of one test
+type RenderedTest struct {
+	Test    string         `json:"test"`
+	Service string         `json:"service"`
+	Steps   []RenderedStep `json:"steps"`
+}
+
+// RenderResult is the output of the render command
+type RenderResult struct {
+	Directory string         `json:"directory"`
+	Rendered  int            `json:"rendered"` // Steps written
+	Failed    int            `json:"failed"`   // Steps that could not be rendered
+	Tests     []RenderedTest `json:"tests"`
+}
+
+// runRenderCommand writes each test's step configurations as concrete .tf files
+func runRenderCommand(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	out := fs.String("out", "", "Output directory; steps are written to <service>/<test>/step-<n>/main.tf")
+	var resources stringList
+	fs.Var(&resources, "resource", "Only tests impacted by these resource type(s)")
+	var tests stringList
+	fs.Var(&tests, "test", "Only these test function(s), comma-separated or repeated")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "Error: -out parameter is required")
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+
+	ids, err := renderTargets(graph, tests, resources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result := &RenderResult{Directory: *out, Tests: []RenderedTest{}}
+	renderer := newTemplateRenderer(graph)
+	for _, id := range ids {
+		node := graph.Nodes[id]
+		rendered := RenderedTest{Test: node.Name, Service: node.Service, Steps: []RenderedStep{}}
+		for i, edge := range stepEdges(graph, id) {
+			step := RenderedStep{Index: i + 1}
+			if edge.To == "" {
+				step.Error = "cannot resolve step config " + edge.Detail
+				result.Failed++
+				rendered.Steps = append(rendered.Steps, step)
+				continue
+			}
+			if template := graph.Nodes[edge.To]; template != nil {
+				step.Template = template.Name
+			}
+			hcl, err := renderer.Render(edge.To)
+			if err != nil {
+				step.Error = err.Error()
+				result.Failed++
+				rendered.Steps = append(rendered.Steps, step)
+				continue
+			}
+
+			service := node.Service
+			if service == "" {
+				service = "_"
+			}
+			step.Path = filepath.ToSlash(filepath.Join(service, node.Name, fmt.Sprintf("step-%d", step.Index), "main.tf"))
+			path := filepath.Join(*out, filepath.FromSlash(step.Path))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			if err := os.WriteFile(path, []byte(hcl), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			result.Rendered++
+			rendered.Steps = append(rendered.Steps, step)
+		}
+		if len(rendered.Steps) > 0 {
+			result.Tests = append(result.Tests, rendered)
+		}
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// renderTargets returns the test node IDs to render, sorted: the named tests,
+// the tests impacted by the resources together with the sequential tests they
+// run, or every test
+func renderTargets(graph *DependencyGraph, tests, resources []string) ([]string, error) {
+	targets := map[string]bool{}
+	switch {
+	case len(tests) > 0:
+		for _, name := range tests {
+			id := testNodeID(name)
+			if graph.Nodes[id] == nil {
+				return nil, fmt.Errorf("test function %q not found", name)
+			}
+			targets[id] = true
+		}
+	case len(resources) > 0:
+		for _, test := range SelectImpactedTests(graph, resources, nil) {
+			id := testNodeID(test.Name)
+			targets[id] = true
+			for reached := range graph.reachableVia(id, EdgeSequentialRef) {
+				targets[reached] = true
+			}
+		}
+	default:
+		for id, node := range graph.Nodes {
+			if node.Kind == NodeTest {
+				targets[id] = true
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(targets))
+	for id := range targets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// stepEdges returns a test's TestStep references in source order. Steps whose
+// Config could not be resolved are included with an empty To.
+func stepEdges(graph *DependencyGraph, id string) []*GraphEdge {
+	var steps []*GraphEdge
+	for _, edge := range graph.OutEdges(id) {
+		if edge.Kind == EdgeStepRef {
+			steps = append(steps, edge)
+		}
+	}
+	for _, ref := range graph.Unresolved {
+		if ref.Kind == EdgeStepRef && ref.From == id {
+			steps = append(steps, &GraphEdge{From: id, Kind: EdgeStepRef, File: ref.File, Line: ref.Line, Detail: ref.Detail})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		if steps[i].File != steps[j].File {
+			return steps[i].File < steps[j].File
+		}
+		return steps[i].Line < steps[j].Line
+	})
+	return steps
+}
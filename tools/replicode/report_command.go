@@ -0,0 +1,838 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// reports maps report names to their handlers
+var reports = map[string]func(args []string) int{
+	"annotations":    runAnnotationsReport,
+	"api-versions":   runAPIVersionsReport,
+	"blast-radius":   runBlastRadiusReport,
+	"coverage":       runCoverageReport,
+	"deprecated":     runDeprecatedReport,
+	"exclusions":     runExclusionsReport,
+	"footprint":      runFootprintReport,
+	"hotspots":       runHotspotsReport,
+	"orphans":        runOrphansReport,
+	"pr-comment":     runPRCommentReport,
+	"regions":        runRegionsReport,
+	"resource":       runResourceReport,
+	"schema":         runSchemaReport,
+	"sdk-imports":    runSDKImportsReport,
+	"service-matrix": runServiceMatrixReport,
+	"template-reuse": runTemplateReuseReport,
+	"untested":       runUntestedReport,
+}
+
+// runReportCommand dispatches the report subcommands
+func runReportCommand(args []string) int {
+	if len(args) > 0 {
+		if report, ok := reports[args[0]]; ok {
+			return report(args[1:])
+		}
+		if !isHelpFlag(args[0]) {
+			fmt.Fprintf(os.Stderr, "Error: unknown report %q\n", args[0])
+		}
+	}
+
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(usageOutput(args), "Usage: replicode report <%s> -dir <path> [options]\n", strings.Join(names, "|"))
+	return usageStatus(args)
+}
+
+// validateFormat checks a -format value against the formats a command supports
+func validateFormat(format string, allowed ...string) error {
+	for _, candidate := range allowed {
+		if format == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q (expected %s)", format, strings.Join(allowed, " or "))
+}
+
+// reportFlags holds the flags every report accepts
+type reportFlags struct {
+	*flag.FlagSet
+	source  sourceOptions
+	format  *string
+	formats []string // Accepted -format values
+}
+
+// newReportFlags creates the flag set for a report with the shared flags registered
+func newReportFlags(name string) *reportFlags {
+	r := &reportFlags{FlagSet: flag.NewFlagSet("report "+name, flag.ContinueOnError), formats: []string{"json", "text"}}
+	r.source.register(r.FlagSet)
+	r.source.registerRepos(r.FlagSet)
+	r.format = r.String("format", "json", "Output format: json or text")
+	return r
+}
+
+// acceptFormats adds output formats a report supports beyond json and text
+func (r *reportFlags) acceptFormats(formats ...string) {
+	r.formats = append(r.formats, formats...)
+	r.Lookup("format").Usage = "Output format: " + strings.Join(r.formats, ", ")
+}
+
+// parseAndLoad parses the report arguments and analyzes the source directory.
+// Errors are reported on stderr; ok is false when the report should exit,
+// with status as its exit code.
+func (r *reportFlags) parseAndLoad(args []string) (results []*analyzer.Result, status int, ok bool) {
+	if err := parseFlags(r.FlagSet, args); err != nil {
+		return nil, flagStatus(err), false
+	}
+	if err := validateFormat(*r.format, r.formats...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return nil, exitError, false
+	}
+
+	results, err := r.source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return nil, exitError, false
+	}
+	return results, exitOK, true
+}
+
+// writeReportJSON writes a report as JSON under a provenance header, returning
+// the command exit code
+func (r *reportFlags) writeReportJSON(v interface{}) int {
+	if err := writeDocument(os.Stdout, newProvenance(r.FlagSet, r.source.root()), v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runHotspotsReport ranks templates by fan-in/fan-out
+func runHotspotsReport(args []string) int {
+	fs := newReportFlags("hotspots")
+	sortKey := fs.String("sort", "fan-in", "Ranking metric: fan-in, fan-out, dependent-tests, or staleness")
+	top := fs.Int("top", 25, "Number of templates to report (0 = all)")
+	withGit := fs.Bool("git", false, "Add the last commit, author, and age of each template (requires git)")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+	if *sortKey == "staleness" && !*withGit {
+		fmt.Fprintln(os.Stderr, "Error: -sort staleness requires -git")
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	if *withGit {
+		templates := map[string]bool{}
+		for id, node := range graph.Nodes {
+			if node.Kind == NodeTemplate {
+				templates[id] = true
+			}
+		}
+		if err := EnrichGitMetadata(graph, fs.source.root(), templates); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	hotspots, err := ComputeTemplateHotspots(graph, *sortKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *top > 0 && len(hotspots) > *top {
+		hotspots = hotspots[:*top]
+	}
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprint(w, "RANK\tTEMPLATE\tSERVICE\tFAN-IN\tTESTS\tTEMPLATES\tCROSS-SVC\tDEPENDENT TESTS\tFAN-OUT")
+		if *withGit {
+			fmt.Fprint(w, "\tAGE (DAYS)\tLAST AUTHOR")
+		}
+		fmt.Fprintln(w)
+		for i, h := range hotspots {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d",
+				i+1, h.Template.Name, h.Template.Service, h.FanIn, h.FanInTests, h.FanInTemplates,
+				h.CrossServiceIn, h.DependentTests, h.FanOut)
+			if git := h.Template.Git; git != nil {
+				fmt.Fprintf(w, "\t%d\t%s", git.AgeDays, git.Author)
+			} else if *withGit {
+				fmt.Fprint(w, "\t-\t-")
+			}
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(hotspots)
+}
+
+// runFootprintReport ranks tests by the number of resources their templates create
+func runFootprintReport(args []string) int {
+	fs := newReportFlags("footprint")
+	var resources stringList
+	fs.Var(&resources, "resource", "Only tests impacted by these resource type(s); default is every runnable test")
+	top := fs.Int("top", 25, "Number of tests to report (0 = all)")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	graph := BuildDependencyGraph(results)
+	tests := runnableTests(graph)
+	if len(resources) > 0 {
+		tests = nil
+		for _, test := range SelectImpactedTests(graph, resources, nil) {
+			tests = append(tests, test.Name)
+		}
+	}
+	footprints := ComputeFootprints(graph, tests)
+	if *top > 0 && len(footprints) > *top {
+		footprints = footprints[:*top]
+	}
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEST\tSERVICE\tFOOTPRINT\tBLOCKS\tDYNAMIC\tTEMPLATES")
+		for _, f := range footprints {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\n", f.Test, f.Service, f.Footprint, f.ResourceBlocks, f.DynamicBlocks, f.Templates)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(footprints)
+}
+
+// runRegionsReport separates tests pinned to specific regions from
+// region-agnostic tests
+func runRegionsReport(args []string) int {
+	fs := newReportFlags("regions")
+	var regions stringList
+	fs.Var(&regions, "region", "Only tests pinned to these region(s), comma-separated or repeated (e.g., westeurope)")
+	pinnedOnly := fs.Bool("pinned", false, "Only list pinned tests")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	graph := BuildDependencyGraph(results)
+	report := ComputeRegionReport(graph, runnableTests(graph))
+	if len(regions) > 0 || *pinnedOnly {
+		wanted := map[string]bool{}
+		for _, region := range regions {
+			wanted[analyzer.NormalizeRegion(region)] = true
+		}
+		var kept []TestRegionRequirement
+		for _, test := range report.Tests {
+			if test.Status != regionPinned {
+				continue
+			}
+			for _, region := range test.Regions {
+				if len(wanted) == 0 || wanted[region] {
+					kept = append(kept, test)
+					break
+				}
+			}
+		}
+		report.Tests = append([]TestRegionRequirement{}, kept...)
+	}
+
+	if *fs.format == "text" {
+		fmt.Printf("%d pinned, %d region-agnostic tests\n\n", report.Pinned, report.Agnostic)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEST\tSERVICE\tSTATUS\tREGIONS\tSLOTS")
+		for _, test := range report.Tests {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", test.Test, test.Service, test.Status,
+				strings.Join(test.Regions, ","), strings.Join(test.Slots, ","))
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(report)
+}
+
+// runOrphansReport lists step-less and orphaned test functions
+func runOrphansReport(args []string) int {
+	fs := newReportFlags("orphans")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	report := FindOrphanTests(results)
+
+	if *fs.format == "text" {
+		writeCoverageGaps("Step-less tests (no TestSteps and no sequential references)", report.StepLess)
+		fmt.Println()
+		writeCoverageGaps("Orphaned tests (testAcc* functions no sequential entry point runs)", report.Orphaned)
+		return 0
+	}
+
+	return fs.writeReportJSON(report)
+}
+
+// writeCoverageGaps prints a titled list of test functions
+func writeCoverageGaps(title string, gaps []TestCoverageGap) {
+	fmt.Printf("%s: %d\n", title, len(gaps))
+	for _, gap := range gaps {
+		fmt.Printf("  %s (%s:%d)\n", gap.FunctionName, gap.File, gap.Line)
+	}
+}
+
+// runCoverageReport prints how completely the analysis resolved test
+// functions, steps, templates, and template calls
+func runCoverageReport(args []string) int {
+	fs := newReportFlags("coverage")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	coverage := ComputeResolutionCoverage(BuildDependencyGraph(results), results)
+
+	if *fs.format == "text" {
+		rows := []struct {
+			name   string
+			counts ResolutionCounts
+		}{
+			{"Test functions", coverage.TestFunctions},
+			{"Steps", coverage.Steps},
+			{"Templates", coverage.Templates},
+			{"Template calls", coverage.TemplateCalls},
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RECORDS\tTOTAL\tRESOLVED\tHEURISTIC\tUNRESOLVED")
+		for _, row := range rows {
+			c := row.counts
+			fmt.Fprintf(w, "%s\t%d\t%d (%.1f%%)\t%d (%.1f%%)\t%d (%.1f%%)\n", row.name, c.Total,
+				c.Resolved, 100*c.ResolvedRatio, c.Heuristic, 100*c.HeuristicRatio, c.Unresolved, 100*c.UnresolvedRatio)
+		}
+		w.Flush()
+		for _, row := range rows {
+			writePatternCounts(row.name+" heuristics", row.counts.Heuristics)
+			writePatternCounts(row.name+" blockers", row.counts.Blockers)
+		}
+		return 0
+	}
+
+	return fs.writeReportJSON(coverage)
+}
+
+// writePatternCounts prints a titled list of patterns, most frequent first
+func writePatternCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	patterns := make([]string, 0, len(counts))
+	for pattern := range counts {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if counts[patterns[i]] != counts[patterns[j]] {
+			return counts[patterns[i]] > counts[patterns[j]]
+		}
+		return patterns[i] < patterns[j]
+	})
+	fmt.Printf("\n%s:\n", title)
+	for _, pattern := range patterns {
+		fmt.Printf("  %-24s %d\n", pattern, counts[pattern])
+	}
+}
+
+// runServiceMatrixReport prints the service×service dependency matrix
+func runServiceMatrixReport(args []string) int {
+	fs := newReportFlags("service-matrix")
+	fs.acceptFormats("csv")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	matrix := ComputeServiceMatrix(BuildDependencyGraph(results))
+
+	if *fs.format == "csv" {
+		if err := writeServiceEdges(os.Stdout, matrix); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if *fs.format == "text" {
+		// Rows are the depending service, columns the service being depended on
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprint(w, "SOURCE \\ TARGET\t")
+		for _, service := range matrix.Services {
+			fmt.Fprintf(w, "%s\t", service)
+		}
+		fmt.Fprintln(w)
+		for i, service := range matrix.Services {
+			fmt.Fprintf(w, "%s\t", service)
+			for _, count := range matrix.Counts[i] {
+				fmt.Fprintf(w, "%d\t", count)
+			}
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(matrix)
+}
+
+// runTemplateReuseReport ranks templates by the consumers they have outside their own service
+func runTemplateReuseReport(args []string) int {
+	fs := newReportFlags("template-reuse")
+	top := fs.Int("top", 25, "Number of templates to report (0 = all)")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	reuse := ComputeTemplateReuse(BuildDependencyGraph(results))
+	if *top > 0 && len(reuse) > *top {
+		reuse = reuse[:*top]
+	}
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RANK\tTEMPLATE\tSERVICE\tEXTERNAL\tTESTS\tTEMPLATES\tINTERNAL\tCONSUMER SERVICES")
+		for i, r := range reuse {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", i+1, r.Template.Name, r.Template.Service,
+				r.ExternalConsumers, r.ExternalTests, r.ExternalTemplates, r.InternalConsumers, strings.Join(r.ConsumerServices, ","))
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(reuse)
+}
+
+// runBlastRadiusReport lists the tests of other services that depend on one service
+func runBlastRadiusReport(args []string) int {
+	fs := newReportFlags("blast-radius")
+	service := fs.String("service", "", "Service whose external dependents to list (e.g., network)")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+	if *service == "" {
+		fmt.Fprintln(os.Stderr, "Error: -service parameter is required")
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	if graph.Nodes[serviceNodeID(*service)] == nil {
+		fmt.Fprintf(os.Stderr, "Error: service %q not found\n", *service)
+		return 1
+	}
+	radius := ComputeBlastRadius(graph, results, *service)
+
+	if *fs.format == "text" {
+		fmt.Printf("%d external tests in %d services depend on %s (%d templates, %d resource types)\n",
+			radius.Tests, len(radius.Services), radius.Service, radius.Templates, len(radius.Resources))
+		for _, group := range radius.Services {
+			fmt.Printf("\n%s (%d)\n", group.Service, len(group.Tests))
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, test := range group.Tests {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", test.Name, strings.Join(test.Templates, ","), strings.Join(test.Resources, ","))
+			}
+			w.Flush()
+		}
+		return 0
+	}
+
+	return fs.writeReportJSON(radius)
+}
+
+// runSDKImportsReport lists the Azure SDK packages each service imports and
+// the tests a change to each package impacts
+func runSDKImportsReport(args []string) int {
+	fs := newReportFlags("sdk-imports")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	index, err := ScanSDKImports(fs.source.Dir, fs.source.root(), analyzer.ServicePatterns(fs.source.Services), results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	usage := SDKImportUsage(BuildDependencyGraph(results), index)
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PACKAGE\tSERVICES\tTESTS")
+		for _, entry := range usage {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", entry.Package, strings.Join(entry.Services, ","), entry.Tests)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(usage)
+}
+
+// runAPIVersionsReport lists the Azure API versions each service and test exercises
+func runAPIVersionsReport(args []string) int {
+	fs := newReportFlags("api-versions")
+	byTest := fs.Bool("tests", false, "Text output lists tests instead of services")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	index, err := ScanSDKImports(fs.source.Dir, fs.source.root(), analyzer.ServicePatterns(fs.source.Services), results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	report := ComputeAPIVersions(BuildDependencyGraph(results), index)
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if *byTest {
+			fmt.Fprintln(w, "TEST\tSERVICE\tAPI VERSIONS")
+			for _, test := range report.Tests {
+				versions := make([]string, len(test.Versions))
+				for i, version := range test.Versions {
+					versions[i] = version.String()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", test.Test, test.Service, strings.Join(versions, ", "))
+			}
+		} else {
+			fmt.Fprintln(w, "SERVICE\tAPI\tVERSION\tPACKAGES")
+			for _, service := range report.Services {
+				for _, version := range service.Versions {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", service.Service, version.API, version.Version, len(version.Packages))
+				}
+			}
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(report)
+}
+
+// runExclusionsReport lists pairs of tests that share a singleton dependency
+// and therefore cannot run concurrently
+func runExclusionsReport(args []string) int {
+	fs := newReportFlags("exclusions")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	graph := BuildDependencyGraph(results)
+	pairs := MutualExclusions(graph, runnableTests(graph))
+
+	if *fs.format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEST A\tTEST B\tSHARED")
+		for _, pair := range pairs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", pair.TestA, pair.TestB, strings.Join(pair.Keys, ", "))
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(pairs)
+}
+
+// runSchemaReport validates referenced resource types against a provider schema
+func runSchemaReport(args []string) int {
+	fs := newReportFlags("schema")
+	schemaPath := fs.String("schema", "", "Output of `terraform providers schema -json`")
+	provider := fs.String("provider", "azurerm", "Analyzed provider: registry address or short name")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+	if *schemaPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -schema parameter is required")
+		return 1
+	}
+
+	schema, err := LoadProviderSchema(*schemaPath, *provider)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	validation := ValidateResourceNames(results, schema)
+
+	if *fs.format == "text" {
+		fmt.Printf("Checked %d references to %d resource types against %s: %d findings\n\n",
+			validation.References, validation.ResourceTypes, validation.Provider, len(validation.Findings))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tREFERENCE\tKIND\tSUGGESTION\tREFERENCES\tFIRST LOCATION")
+		for _, f := range validation.Findings {
+			first := f.Locations[0]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s:%d (%s)\n", f.ResourceName, f.ReferenceType, f.Kind,
+				f.Suggestion+f.Provider, len(f.Locations), first.TemplateFile, first.TemplateLine, first.TemplateFunction)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(validation)
+}
+
+// runUntestedReport lists the registered resource types no acceptance test
+// template references
+func runUntestedReport(args []string) int {
+	fs := newReportFlags("untested")
+	schemaPath := fs.String("schema", "", "Output of `terraform providers schema -json`; its resource types are checked")
+	provider := fs.String("provider", "azurerm", "Analyzed provider in -schema: registry address or short name")
+	registrations := fs.Bool("registrations", false, "Check the resource types registered by the non-test files under -dir (SupportedResources and ResourceType)")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+	if *schemaPath == "" && !*registrations {
+		fmt.Fprintln(os.Stderr, "Error: -schema or -registrations parameter is required")
+		return 1
+	}
+	if *registrations && fs.source.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -registrations requires -dir")
+		return 1
+	}
+
+	var registered []RegisteredResource
+	if *registrations {
+		scanned, err := ScanRegisteredResources(fs.source.Dir, fs.source.root(), analyzer.ServicePatterns(fs.source.Services))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		registered = scanned
+	}
+	if *schemaPath != "" {
+		schema, err := LoadProviderSchema(*schemaPath, *provider)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for name := range schema.Resources {
+			registered = append(registered, RegisteredResource{Name: name})
+		}
+	}
+	report := FindUntestedResources(registered, results)
+
+	if *fs.format == "text" {
+		fmt.Printf("%d of %d registered resource types appear in no test template\n\n", len(report.Untested), report.Registered)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tSERVICE\tREGISTERED AT")
+		for _, resource := range report.Untested {
+			location := "-"
+			if resource.File != "" {
+				location = fmt.Sprintf("%s:%d", resource.File, resource.Line)
+			}
+			service := resource.Service
+			if service == "" {
+				service = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", resource.Name, service, location)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(report)
+}
+
+// runDeprecatedReport lists the templates and tests still using deprecated
+// or removed resources, from a list, the provider schema, or both
+func runDeprecatedReport(args []string) int {
+	fs := newReportFlags("deprecated")
+	listPath := fs.String("list", "", "Deprecated resource list: file path or http(s) URL, one resource type per line")
+	schemaPath := fs.String("schema", "", "Output of `terraform providers schema -json`; its deprecated types are added to the list")
+	provider := fs.String("provider", "azurerm", "Analyzed provider in -schema: registry address or short name")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+	if *listPath == "" && *schemaPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -list or -schema parameter is required")
+		return 1
+	}
+
+	deprecated := DeprecatedList{}
+	if *schemaPath != "" {
+		schema, err := LoadProviderSchema(*schemaPath, *provider)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for name, description := range schema.Deprecated {
+			deprecated[name] = deprecationReason(description)
+		}
+	}
+	if *listPath != "" {
+		list, err := LoadDeprecatedList(*listPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for name, reason := range list {
+			if reason != "" || deprecated[name] == "" {
+				deprecated[name] = reason
+			}
+		}
+	}
+
+	report := FindDeprecatedUsage(BuildDependencyGraph(results), deprecated)
+
+	if *fs.format == "text" {
+		fmt.Printf("%d of %d deprecated resource types are still in use\n\n", len(report.Resources), report.Checked)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOURCE\tTEMPLATES\tTESTS\tREASON")
+		for _, usage := range report.Resources {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", usage.Resource, len(usage.Templates), len(usage.Tests), usage.Reason)
+		}
+		w.Flush()
+		return 0
+	}
+
+	return fs.writeReportJSON(report)
+}
+
+// runPRCommentReport renders the impact of a change as a Markdown pull request comment
+func runPRCommentReport(args []string) int {
+	fs := flag.NewFlagSet("report pr-comment", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	var resources stringList
+	fs.Var(&resources, "resource", "Changed resource type(s), comma-separated or repeated (e.g., azurerm_subnet)")
+	durationsPath := fs.String("durations", "", "Historical timing data: database directory or TestDurations.csv")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if len(resources) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: -resource parameter is required")
+		return 1
+	}
+
+	var durations *DurationModel
+	if *durationsPath != "" {
+		model, err := LoadDurationModel(resolveDurationsPath(*durationsPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		durations = model
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	selection := &SelectionResult{
+		Resources: append([]string{}, resources...),
+		Tests:     SelectImpactedTests(graph, resources, durations),
+	}
+	ScoreTestRisk(graph, selection.Tests, durations)
+	orderSelection(selection.Tests, "risk")
+
+	writePRComment(os.Stdout, newProvenance(fs, source.root()), graph, selection)
+	return 0
+}
+
+// runResourceReport renders the Markdown dependency dossier of a resource type
+func runResourceReport(args []string) int {
+	fs := flag.NewFlagSet("report resource", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	linkBase := fs.String("link-base", "", "URL file paths are appended to for links (e.g., https://github.com/hashicorp/terraform-provider-azurerm/blob/main); relative links by default")
+	resource := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		resource, args = args[0], args[1:]
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if resource == "" && fs.NArg() == 1 {
+		resource = fs.Arg(0)
+	}
+	if resource == "" || fs.NArg() > 1 || (fs.NArg() == 1 && fs.Arg(0) != resource) {
+		fmt.Fprintln(usageOutput(args), "Usage: replicode report resource <azurerm_type> -dir <path> [-link-base <url>]")
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	if graph.Nodes[resourceNodeID(resource)] == nil {
+		fmt.Fprintf(os.Stderr, "Error: resource type %q not found\n", resource)
+		return 1
+	}
+	writeResourceDossier(os.Stdout, newProvenance(fs, source.root()), graph, resource, *linkBase)
+	return 0
+}
+
+// runAnnotationsReport emits findings as a GitHub check run output payload
+func runAnnotationsReport(args []string) int {
+	fs := newReportFlags("annotations")
+	var changed stringList
+	fs.Var(&changed, "changed", "Only annotate these changed lines: path or path:start-end, comma-separated or repeated")
+	threshold := fs.Int("fan-in-threshold", 25, "Annotate templates with at least this many dependent tests")
+	results, status, ok := fs.parseAndLoad(args)
+	if !ok {
+		return status
+	}
+
+	changes := make([]CodeChange, 0, len(changed))
+	for _, value := range changed {
+		change, err := ParseCodeChange(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		changes = append(changes, change)
+	}
+
+	annotations := CollectAnnotations(BuildDependencyGraph(results), *threshold, changes)
+
+	if *fs.format == "text" {
+		for _, a := range annotations {
+			fmt.Printf("%s:%d: %s: %s: %s\n", a.Path, a.StartLine, a.AnnotationLevel, a.Title, a.Message)
+		}
+		return 0
+	}
+
+	// The payload goes to the checks API as-is, so provenance is part of the summary
+	provenance := newProvenance(fs.FlagSet, fs.source.root())
+	output := &CheckRunOutput{
+		Title:       fmt.Sprintf("Terracorder: %d findings", len(annotations)),
+		Summary:     "Dependency findings from the acceptance test reference graph.\n\n" + provenance.String(),
+		Annotations: annotations,
+	}
+	if err := writeJSON(os.Stdout, output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
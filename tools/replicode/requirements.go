@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+)
+
+// TestRequirements is the prerequisite manifest of one test: its own
+// requirements merged with those of every template and sequential sub-test
+// it reaches. CI uses it to route tests to capable agents.
+type TestRequirements struct {
+	Test            string   `json:"test"`
+	EnvVars         []string `json:"env_vars"`
+	SkipsWithout    []string `json:"skips_without"` // Unset, the test skips instead of failing
+	Providers       []string `json:"providers"`
+	Features        []string `json:"features"`
+	ProviderAliases []string `json:"provider_aliases"`
+	AltSubscription bool     `json:"alt_subscription"`
+	TestData        []string `json:"test_data"` // acceptance.TestData fields the test's configs are rendered with
+}
+
+// Requirements builds the prerequisite manifest of a test from every test
+// and template node it reaches
+func (g *DependencyGraph) Requirements(test string) TestRequirements {
+	manifest := TestRequirements{
+		Test:            test,
+		EnvVars:         []string{},
+		SkipsWithout:    []string{},
+		Providers:       []string{},
+		Features:        []string{},
+		ProviderAliases: []string{},
+		TestData:        []string{},
+	}
+
+	id := testNodeID(test)
+	reached := g.Reachable(id, DirectionOut, 0)
+	reached[id] = 0
+	for reachedID := range reached {
+		for _, reqs := range g.requirements[reachedID] {
+			manifest.EnvVars = append(manifest.EnvVars, reqs.EnvVars...)
+			manifest.SkipsWithout = append(manifest.SkipsWithout, reqs.SkipsWithout...)
+			manifest.Providers = append(manifest.Providers, reqs.Providers...)
+			manifest.Features = append(manifest.Features, reqs.Features...)
+			manifest.ProviderAliases = append(manifest.ProviderAliases, reqs.ProviderAliases...)
+			manifest.AltSubscription = manifest.AltSubscription || reqs.AltSubscription
+			manifest.TestData = append(manifest.TestData, reqs.TestData...)
+		}
+	}
+
+	manifest.EnvVars = append([]string{}, uniqueSorted(manifest.EnvVars)...)
+	manifest.SkipsWithout = append([]string{}, uniqueSorted(manifest.SkipsWithout)...)
+	manifest.Providers = append([]string{}, uniqueSorted(manifest.Providers)...)
+	manifest.Features = append([]string{}, uniqueSorted(manifest.Features)...)
+	manifest.ProviderAliases = append([]string{}, uniqueSorted(manifest.ProviderAliases)...)
+	manifest.TestData = append([]string{}, uniqueSorted(manifest.TestData)...)
+	return manifest
+}
+
+// AttachTestEnvVars records the environment variables and alternate
+// subscription needs of each selected test, so CI can tell a test that will
+// skip from one that will run and route multi-subscription tests
+func AttachTestEnvVars(graph *DependencyGraph, tests []SelectedTest) {
+	for i := range tests {
+		manifest := graph.Requirements(tests[i].Name)
+		tests[i].AltSubscription = manifest.AltSubscription
+		if len(manifest.ProviderAliases) > 0 {
+			tests[i].ProviderAliases = manifest.ProviderAliases
+		}
+		if len(manifest.EnvVars) > 0 {
+			tests[i].EnvVars = manifest.EnvVars
+		}
+		if len(manifest.SkipsWithout) > 0 {
+			tests[i].SkipsWithout = manifest.SkipsWithout
+		}
+	}
+}
+
+// uniqueSorted returns the distinct values sorted
+func uniqueSorted(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// runRequirementsCommand prints the prerequisite manifest of each test
+func runRequirementsCommand(args []string) int {
+	fs := flag.NewFlagSet("requirements", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	var resources stringList
+	fs.Var(&resources, "resource", "Only tests impacted by these resource type(s); default is every runnable test")
+	var tests stringList
+	fs.Var(&tests, "test", "Only these test function(s), comma-separated or repeated")
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	names := []string(tests)
+	switch {
+	case len(names) > 0:
+		for _, name := range names {
+			if graph.Nodes[testNodeID(name)] == nil {
+				fmt.Fprintf(os.Stderr, "Error: test function %q not found\n", name)
+				return 1
+			}
+		}
+	case len(resources) > 0:
+		for _, test := range SelectImpactedTests(graph, resources, nil) {
+			names = append(names, test.Name)
+		}
+	default:
+		names = runnableTests(graph)
+	}
+
+	manifests := make([]TestRequirements, 0, len(names))
+	for _, name := range names {
+		manifests = append(manifests, graph.Requirements(name))
+	}
+
+	if *format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEST\tENV VARS\tSKIPS WITHOUT\tPROVIDERS\tFEATURES\tPROVIDER ALIASES\tALT SUBSCRIPTION\tTEST DATA")
+		for _, m := range manifests {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n", m.Test, strings.Join(m.EnvVars, ","), strings.Join(m.SkipsWithout, ","),
+				strings.Join(m.Providers, ","), strings.Join(m.Features, ","), strings.Join(m.ProviderAliases, ","), m.AltSubscription, strings.Join(m.TestData, ","))
+		}
+		w.Flush()
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), manifests); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
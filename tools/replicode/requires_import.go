@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// checkImportPath is the package providing check.That, used by the generated test
+const checkImportPath = acceptanceImportPath + "/check"
+
+// hclAttribute matches an attribute assignment line of an HCL block
+var hclAttribute = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*=[^=]`)
+
+// importBlockSkipped are the meta-arguments of the resource under test that
+// the import block of a requiresImport template does not copy
+var importBlockSkipped = map[string]bool{"count": true, "for_each": true, "depends_on": true, "provider": true, "lifecycle": true}
+
+// requiresImportGap is a resource whose tests have no requiresImport test or
+// template, and whether coverage was generated for it or why not
+type requiresImportGap struct {
+	Struct   string
+	File     string // Repository-relative file of the basic template
+	Line     int    // Line of the basic template
+	Basic    string // The _basic test running the basic template
+	Template string // ID of the basic template node
+	Skipped  string // Why no coverage was generated; "" when it was
+}
+
+// findRequiresImportGaps returns the resources with a basic template run by a
+// _basic test in its file but no requiresImport template or test, sorted by
+// file and line
+func findRequiresImportGaps(graph *DependencyGraph) []*requiresImportGap {
+	var gaps []*requiresImportGap
+	for _, node := range graph.Nodes {
+		structName, method, _ := strings.Cut(node.Name, ".")
+		if node.Kind != NodeTemplate || method != "basic" || !strings.HasSuffix(structName, "Resource") {
+			continue
+		}
+		if graph.Nodes[templateNodeID(structName, "requiresImport")] != nil {
+			continue
+		}
+		gap := &requiresImportGap{Struct: structName, File: node.File, Line: node.Line, Template: node.ID}
+		for _, edge := range graph.InEdges(node.ID) {
+			test := graph.Nodes[edge.From]
+			if edge.Kind != EdgeStepRef || test == nil || test.File != node.File || !strings.HasSuffix(test.Name, "_basic") {
+				continue
+			}
+			if gap.Basic == "" || test.Name < gap.Basic {
+				gap.Basic = test.Name
+			}
+		}
+		if gap.Basic != "" && graph.Nodes[testNodeID(requiresImportTestName(gap.Basic))] != nil {
+			continue
+		}
+		if gap.Basic == "" {
+			gap.Skipped = "no _basic test in its file runs the basic template"
+		}
+		gaps = append(gaps, gap)
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].File != gaps[j].File {
+			return gaps[i].File < gaps[j].File
+		}
+		return gaps[i].Line < gaps[j].Line
+	})
+	return gaps
+}
+
+// requiresImportTestName is the name of the requiresImport test beside a _basic test
+func requiresImportTestName(basic string) string {
+	return strings.TrimSuffix(basic, "_basic") + "_requiresImport"
+}
+
+// resourceAttributes returns the top-level attributes of the resource block
+// of the given type and label in HCL, without meta-arguments, in order
+func resourceAttributes(hcl, resourceType, label string) []string {
+	header := regexp.MustCompile(`^\s*resource\s+"` + regexp.QuoteMeta(resourceType) + `"\s+"` + regexp.QuoteMeta(label) + `"\s*\{\s*$`)
+	var attributes []string
+	depth := -1 // Outside the block
+	for _, line := range strings.Split(hcl, "\n") {
+		if depth < 0 {
+			if header.MatchString(line) {
+				depth = 0
+			}
+			continue
+		}
+		if depth == 0 {
+			if m := hclAttribute.FindStringSubmatch(line); m != nil && !importBlockSkipped[m[1]] {
+				attributes = append(attributes, m[1])
+			}
+		}
+		depth += strings.Count(line, "{") + strings.Count(line, "[") + strings.Count(line, "(") -
+			strings.Count(line, "}") - strings.Count(line, "]") - strings.Count(line, ")")
+		if depth < 0 {
+			break // The block's closing brace
+		}
+	}
+	return attributes
+}
+
+// importedName returns the name a file refers to an imported package by
+func importedName(file *ast.File, path string) (string, bool) {
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != path {
+			continue
+		}
+		if imp.Name == nil {
+			return path[strings.LastIndex(path, "/")+1:], true
+		}
+		if imp.Name.Name != "_" && imp.Name.Name != "." {
+			return imp.Name.Name, true
+		}
+	}
+	return path[strings.LastIndex(path, "/")+1:], false
+}
+
+// findFunc returns the function or method declared with a name and receiver type
+func findFunc(file *ast.File, name, receiver string) *ast.FuncDecl {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == name && receiverTypeName(fn) == receiver {
+			return fn
+		}
+	}
+	return nil
+}
+
+// buildTestData returns the variable, resource type, and label of the
+// BuildTestData call a test starts with, and whether it runs data.ResourceTest
+func buildTestData(fn *ast.FuncDecl) (variable, resourceType, label string, resourceTest bool) {
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			call, ok := n.Rhs[0].(*ast.CallExpr)
+			if !ok || len(n.Lhs) != 1 || len(call.Args) != 3 || variable != "" {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			ident, isIdent := n.Lhs[0].(*ast.Ident)
+			if !ok || !isIdent || sel.Sel.Name != "BuildTestData" {
+				return true
+			}
+			typeLit, ok1 := call.Args[1].(*ast.BasicLit)
+			labelLit, ok2 := call.Args[2].(*ast.BasicLit)
+			if ok1 && ok2 && typeLit.Kind == token.STRING && labelLit.Kind == token.STRING {
+				variable = ident.Name
+				resourceType, _ = strconv.Unquote(typeLit.Value)
+				label, _ = strconv.Unquote(labelLit.Value)
+			}
+		case *ast.CallExpr:
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "ResourceTest" {
+				resourceTest = true
+			}
+		}
+		return true
+	})
+	return variable, resourceType, label, resourceTest
+}
+
+// requiresImportEdits returns the edits adding the requiresImport test after
+// a gap's _basic test and the requiresImport template after its basic
+// template, or why they can't be generated
+func requiresImportEdits(graph *DependencyGraph, fset *token.FileSet, file *ast.File, src []byte, gap *requiresImportGap) ([]textEdit, string) {
+	test := findFunc(file, gap.Basic, "")
+	basic := findFunc(file, "basic", gap.Struct)
+	if test == nil || basic == nil {
+		return nil, "basic test or template not found in the source"
+	}
+	tName := testingParam(test.Type)
+	data, resourceType, label, resourceTest := buildTestData(test)
+	switch {
+	case tName == "":
+		return nil, gap.Basic + " has no *testing.T parameter"
+	case data == "":
+		return nil, gap.Basic + " does not call BuildTestData with a literal resource type and label"
+	case !resourceTest:
+		return nil, gap.Basic + " does not run data.ResourceTest"
+	}
+
+	params := basic.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 {
+		return nil, "basic template does not take a single acceptance.TestData parameter"
+	}
+	paramType := string(src[fset.Position(params[0].Type.Pos()).Offset:fset.Position(params[0].Type.End()).Offset])
+	if !strings.HasSuffix(paramType, ".TestData") {
+		return nil, "basic template does not take a single acceptance.TestData parameter"
+	}
+	source, ok := graph.templateSources[gap.Template]
+	if !ok {
+		return nil, "basic template does not return literal HCL"
+	}
+	attributes := resourceAttributes(source.Format, resourceType, label)
+	if len(attributes) == 0 {
+		return nil, fmt.Sprintf("basic template has no resource %q %q block with attributes", resourceType, label)
+	}
+
+	acceptance, _ := acceptanceQualifier(file)
+	check, _ := importedName(file, checkImportPath)
+	fmtName, _ := importedName(file, "fmt")
+	receiver := "r"
+	if names := basic.Recv.List[0].Names; len(names) == 1 && names[0].Name != "_" {
+		receiver = names[0].Name
+	}
+	param := params[0].Names[0].Name
+
+	var testCode strings.Builder
+	fmt.Fprintf(&testCode, "\n\nfunc %s(%s *testing.T) {\n", requiresImportTestName(gap.Basic), tName)
+	fmt.Fprintf(&testCode, "\t%s := %s.BuildTestData(%s, %q, %q)\n", data, acceptance, tName, resourceType, label)
+	fmt.Fprintf(&testCode, "\tr := %s{}\n\n", gap.Struct)
+	fmt.Fprintf(&testCode, "\t%s.ResourceTest(%s, r, []%s.TestStep{\n", data, tName, acceptance)
+	fmt.Fprintf(&testCode, "\t\t{\n\t\t\tConfig: r.basic(%s),\n", data)
+	fmt.Fprintf(&testCode, "\t\t\tCheck: %s.ComposeTestCheckFunc(\n", acceptance)
+	fmt.Fprintf(&testCode, "\t\t\t\t%s.That(%s.ResourceName).ExistsInAzure(r),\n\t\t\t),\n\t\t},\n", check, data)
+	fmt.Fprintf(&testCode, "\t\t%s.RequiresImportErrorStep(r.requiresImport),\n\t})\n}", data)
+
+	width := 0
+	for _, attribute := range attributes {
+		if len(attribute) > width {
+			width = len(attribute)
+		}
+	}
+	var templateCode strings.Builder
+	fmt.Fprintf(&templateCode, "\n\nfunc (%s %s) requiresImport(%s %s) string {\n", receiver, gap.Struct, param, paramType)
+	fmt.Fprintf(&templateCode, "\treturn %s.Sprintf(`\n%%s\n\nresource %q \"import\" {\n", fmtName, resourceType)
+	for _, attribute := range attributes {
+		fmt.Fprintf(&templateCode, "  %-*s = %s.%s.%s\n", width, attribute, resourceType, label, attribute)
+	}
+	fmt.Fprintf(&templateCode, "}\n`, %s.basic(%s))\n}", receiver, param)
+
+	testEnd := fset.Position(test.End()).Offset
+	basicEnd := fset.Position(basic.End()).Offset
+	if testEnd == basicEnd {
+		return nil, "basic test and template overlap"
+	}
+	return []textEdit{
+		{start: testEnd, end: testEnd, text: testCode.String()},
+		{start: basicEnd, end: basicEnd, text: templateCode.String()},
+	}, ""
+}
+
+// generateRequiresImport adds requiresImport coverage for a file's gaps,
+// recording on each gap why it was skipped, and returns the new content
+func generateRequiresImport(graph *DependencyGraph, path string, src []byte, gaps []*requiresImportGap) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing file: %v", err)
+	}
+
+	var edits []textEdit
+	for _, gap := range gaps {
+		gapEdits, reason := requiresImportEdits(graph, fset, file, src, gap)
+		if reason != "" {
+			gap.Skipped = reason
+			continue
+		}
+		edits = append(edits, gapEdits...)
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+	var missing []string
+	if _, imported := importedName(file, "fmt"); !imported {
+		missing = append(missing, "fmt")
+	}
+	if _, imported := acceptanceQualifier(file); !imported {
+		missing = append(missing, acceptanceImportPath)
+	}
+	if _, imported := importedName(file, checkImportPath); !imported {
+		missing = append(missing, checkImportPath)
+	}
+	if len(missing) > 0 {
+		edits = append(edits, importEdits(fset, file, src, missing...)...)
+	}
+
+	formatted, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return formatted, nil
+}
+
+// runRequiresImportCommand finds resources whose tests lack requiresImport
+// coverage and generates the standard test and template for them, printing
+// the changes as a patch or writing them to the files
+func runRequiresImportCommand(args []string) int {
+	fs := flag.NewFlagSet("requires-import", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	output := newCodemodOutput(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if err := output.start(source.root()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+	gaps := findRequiresImportGaps(graph)
+
+	byFile := map[string][]*requiresImportGap{}
+	var files []string
+	for _, gap := range gaps {
+		if gap.Skipped != "" {
+			continue
+		}
+		if byFile[gap.File] == nil {
+			files = append(files, gap.File)
+		}
+		byFile[gap.File] = append(byFile[gap.File], gap)
+	}
+
+	changedFiles := 0
+	for _, rel := range files {
+		path := filepath.Join(source.root(), filepath.FromSlash(rel))
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		out, err := generateRequiresImport(graph, path, src, byFile[rel])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", rel, err)
+			for _, gap := range byFile[rel] {
+				gap.Skipped = err.Error()
+			}
+			continue
+		}
+		if bytes.Equal(out, src) {
+			continue
+		}
+		changedFiles++
+		if err := output.apply(path, rel, src, out, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	generated, skipped := 0, 0
+	for _, gap := range gaps {
+		if gap.Skipped != "" {
+			skipped++
+			output.left("Left %s:%d (%s): %s", gap.File, gap.Line, gap.Struct, gap.Skipped)
+		} else {
+			generated++
+		}
+	}
+	verb := "Added"
+	if !output.writing() {
+		verb = "Would add"
+	}
+	return output.finish("%s requiresImport coverage for %d resources in %d files; left %d without it", verb, generated, changedFiles, skipped)
+}
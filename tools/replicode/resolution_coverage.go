@@ -0,0 +1,212 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// Resolution classes of a reference, or of the function holding references
+const (
+	resolutionResolved   = "resolved"   // Resolved to the declaration in the referencing file's package
+	resolutionHeuristic  = "heuristic"  // Matched by a fallback that could pick the wrong declaration
+	resolutionUnresolved = "unresolved" // Not attached to any declaration
+)
+
+// resolutionRank orders the classes so that a function takes the worst class of its references
+var resolutionRank = map[string]int{resolutionResolved: 0, resolutionHeuristic: 1, resolutionUnresolved: 2}
+
+// ResolutionCounts is how many of one kind of record each class holds
+type ResolutionCounts struct {
+	Total           int            `json:"total"`
+	Resolved        int            `json:"resolved"`
+	Heuristic       int            `json:"heuristic"`
+	Unresolved      int            `json:"unresolved"`
+	ResolvedRatio   float64        `json:"resolved_ratio"`
+	HeuristicRatio  float64        `json:"heuristic_ratio"`
+	UnresolvedRatio float64        `json:"unresolved_ratio"`
+	Heuristics      map[string]int `json:"heuristics"` // Fallback that matched -> records
+	Blockers        map[string]int `json:"blockers"`   // Pattern that blocked resolution -> records
+}
+
+// ResolutionCoverage quantifies how completely the test functions, steps,
+// templates, and template calls of an analysis were resolved. A function
+// takes the worst class of its references, and counts each fallback or
+// blocker of them once.
+type ResolutionCoverage struct {
+	TestFunctions ResolutionCounts `json:"test_functions"`
+	Steps         ResolutionCounts `json:"steps"`
+	Templates     ResolutionCounts `json:"templates"`
+	TemplateCalls ResolutionCounts `json:"template_calls"`
+}
+
+// resolution is the class of one reference and the fallback or blocker behind it
+type resolution struct {
+	class   string
+	pattern string // "" when resolved
+}
+
+// functionResolution accumulates the class of a function from its references
+type functionResolution struct {
+	class    string
+	patterns map[string]bool
+}
+
+// add folds a reference's resolution into the function's
+func (f *functionResolution) add(r resolution) {
+	if resolutionRank[r.class] > resolutionRank[f.class] {
+		f.class = r.class
+		f.patterns = map[string]bool{}
+	}
+	if r.class == f.class && r.pattern != "" {
+		f.patterns[r.pattern] = true
+	}
+}
+
+// record counts one record of a class with its fallback or blocker
+func (c *ResolutionCounts) record(class string, patterns ...string) {
+	c.Total++
+	switch class {
+	case resolutionResolved:
+		c.Resolved++
+	case resolutionHeuristic:
+		c.Heuristic++
+		for _, pattern := range patterns {
+			c.Heuristics[pattern]++
+		}
+	case resolutionUnresolved:
+		c.Unresolved++
+		for _, pattern := range patterns {
+			c.Blockers[pattern]++
+		}
+	}
+}
+
+// finish computes the ratios
+func (c *ResolutionCounts) finish() {
+	if c.Total == 0 {
+		return
+	}
+	c.ResolvedRatio = float64(c.Resolved) / float64(c.Total)
+	c.HeuristicRatio = float64(c.Heuristic) / float64(c.Total)
+	c.UnresolvedRatio = float64(c.Unresolved) / float64(c.Total)
+}
+
+// ComputeResolutionCoverage classifies every test step and template call of
+// the results, and every test and template node by its steps or calls. A
+// test without steps is resolved when it runs sequential sub-tests and
+// unresolved otherwise.
+func ComputeResolutionCoverage(graph *DependencyGraph, results []*analyzer.Result) *ResolutionCoverage {
+	newCounts := func() ResolutionCounts {
+		return ResolutionCounts{Heuristics: map[string]int{}, Blockers: map[string]int{}}
+	}
+	coverage := &ResolutionCoverage{TestFunctions: newCounts(), Steps: newCounts(), Templates: newCounts(), TemplateCalls: newCounts()}
+
+	functions := map[string]*functionResolution{}
+	stepless := map[string]bool{} // Test node IDs no step belongs to
+	for id, node := range graph.Nodes {
+		if node.Kind == NodeTest || node.Kind == NodeTemplate {
+			functions[id] = &functionResolution{class: resolutionResolved, patterns: map[string]bool{}}
+		}
+		if node.Kind == NodeTest {
+			stepless[id] = true
+		}
+	}
+
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			from, _ := graph.resolveFunction(result, "", step.SourceFunction)
+			r := graph.stepResolution(result, from, step)
+			coverage.Steps.record(r.class, r.pattern)
+			if functions[from] != nil {
+				functions[from].add(r)
+				delete(stepless, from)
+			}
+		}
+		for _, call := range result.TemplateCalls {
+			from := ""
+			if source := enclosingFunction(result, call.SourceFunction, call.SourceLine); source != nil {
+				from = graph.functionNodeID(*source)
+			}
+			r := graph.templateResolution(result, from, call.TargetExpr, call.TargetStruct, call.TargetVariable, call.TargetMethod)
+			coverage.TemplateCalls.record(r.class, r.pattern)
+			if functions[from] != nil {
+				functions[from].add(r)
+			}
+		}
+	}
+
+	for id := range stepless {
+		runsSubTests := false
+		for _, edge := range graph.OutEdges(id) {
+			runsSubTests = runsSubTests || edge.Kind == EdgeSequentialRef
+		}
+		if !runsSubTests {
+			functions[id].add(resolution{resolutionUnresolved, "no_test_steps"})
+		}
+	}
+
+	for id, f := range functions {
+		patterns := make([]string, 0, len(f.patterns))
+		for pattern := range f.patterns {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		if graph.Nodes[id].Kind == NodeTest {
+			coverage.TestFunctions.record(f.class, patterns...)
+		} else {
+			coverage.Templates.record(f.class, patterns...)
+		}
+	}
+
+	coverage.TestFunctions.finish()
+	coverage.Steps.finish()
+	coverage.Templates.finish()
+	coverage.TemplateCalls.finish()
+	return coverage
+}
+
+// stepResolution classifies the Config reference of a test step. A step
+// without a Config method is blocked by a variable the analyzer could not
+// trace to its assignment, or by an expression it does not parse (inline
+// fmt.Sprintf, string literals, concatenation).
+func (g *DependencyGraph) stepResolution(result *analyzer.Result, from string, step analyzer.TestStepInfo) resolution {
+	if step.ConfigMethod == "" {
+		if step.ConfigVariable != "" {
+			return resolution{resolutionUnresolved, "untraced_variable"}
+		}
+		return resolution{resolutionUnresolved, "unsupported_expression"}
+	}
+	return g.templateResolution(result, from, step.ConfigExpr, step.ConfigStruct, step.ConfigVariable, step.ConfigMethod)
+}
+
+// templateResolution classifies a reference to a template method, following
+// resolveTemplate: the declaration in the referencing package resolves it,
+// while the only other package's declaration or a method name unique in the
+// file are heuristic matches
+func (g *DependencyGraph) templateResolution(result *analyzer.Result, caller, expr, structName, variable, method string) resolution {
+	if method == "" {
+		return resolution{resolutionUnresolved, "unsupported_expression"}
+	}
+	if structName == "" {
+		if to, _ := g.resolveTemplate(result, caller, expr, "", method); to != "" {
+			return resolution{resolutionHeuristic, "unique_method_name"}
+		}
+		if variable != "" {
+			return resolution{resolutionUnresolved, "unknown_receiver"}
+		}
+		return resolution{resolutionUnresolved, "plain_function"}
+	}
+
+	if _, ok := g.functions[functionKey{packageOf(result.FilePath), structName, method}]; ok {
+		return resolution{resolutionResolved, ""}
+	}
+	switch len(g.declared[templateNodeID(structName, method)]) {
+	case 0:
+		return resolution{resolutionUnresolved, "undeclared_template"}
+	case 1:
+		return resolution{resolutionHeuristic, "other_package"}
+	default:
+		return resolution{resolutionUnresolved, "ambiguous_package"}
+	}
+}
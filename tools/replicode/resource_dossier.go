@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// dossierTemplate is a template that references a resource, directly in its
+// own HCL or through templates it embeds
+type dossierTemplate struct {
+	Node   *GraphNode
+	Direct bool
+}
+
+// writeResourceDossier renders every test, template, and service touching a
+// resource type as a Markdown document, with each function linked to its
+// file and line under linkBase (relative links when linkBase is "")
+func writeResourceDossier(w io.Writer, provenance *Provenance, graph *DependencyGraph, resource, linkBase string) {
+	resourceID := resourceNodeID(resource)
+	var templates []dossierTemplate
+	for id := range graph.Reachable(resourceID, DirectionIn, 0) {
+		node := graph.Nodes[id]
+		if node == nil || node.Kind != NodeTemplate {
+			continue
+		}
+		entry := dossierTemplate{Node: node}
+		for _, edge := range graph.OutEdges(id) {
+			entry.Direct = entry.Direct || (edge.Kind == EdgeResourceRef && edge.To == resourceID)
+		}
+		templates = append(templates, entry)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Direct != templates[j].Direct {
+			return templates[i].Direct
+		}
+		return templates[i].Node.ID < templates[j].Node.ID
+	})
+
+	tests := SelectImpactedTests(graph, []string{resource}, nil)
+	byService := map[string][]SelectedTest{}
+	templateCounts := map[string]int{}
+	for _, test := range tests {
+		byService[test.Service] = append(byService[test.Service], test)
+	}
+	for _, template := range templates {
+		templateCounts[template.Node.Service]++
+	}
+	services := map[string]bool{}
+	for service := range byService {
+		services[service] = true
+	}
+	for service := range templateCounts {
+		services[service] = true
+	}
+	serviceNames := make([]string, 0, len(services))
+	for service := range services {
+		serviceNames = append(serviceNames, service)
+	}
+	sort.Strings(serviceNames)
+
+	fmt.Fprintf(w, "<!-- %s -->\n", provenance)
+	fmt.Fprintf(w, "# `%s` dependency dossier\n\n", resource)
+	fmt.Fprintln(w, "| Tests | Templates | Direct references | Services |")
+	fmt.Fprintln(w, "|---:|---:|---:|---:|")
+	direct := 0
+	for _, template := range templates {
+		if template.Direct {
+			direct++
+		}
+	}
+	fmt.Fprintf(w, "| %d | %d | %d | %d |\n\n", len(tests), len(templates), direct, len(serviceNames))
+
+	fmt.Fprintln(w, "## Services")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Service | Tests | Templates |")
+	fmt.Fprintln(w, "|---|---:|---:|")
+	for _, service := range serviceNames {
+		fmt.Fprintf(w, "| %s | %d | %d |\n", dossierService(service), len(byService[service]), templateCounts[service])
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "## Templates")
+	fmt.Fprintln(w)
+	if len(templates) == 0 {
+		fmt.Fprintln(w, "No template references this resource.")
+	} else {
+		fmt.Fprintln(w, "| Template | Service | Reference | Location |")
+		fmt.Fprintln(w, "|---|---|---|---|")
+		for _, template := range templates {
+			reference := "embeds a referencing template"
+			if template.Direct {
+				reference = "direct"
+			}
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", template.Node.Name, dossierService(template.Node.Service), reference,
+				sourceLink(linkBase, template.Node.File, template.Node.Line))
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "## Tests")
+	fmt.Fprintln(w)
+	if len(tests) == 0 {
+		fmt.Fprintln(w, "No acceptance tests depend on this resource.")
+		return
+	}
+	for _, service := range serviceNames {
+		serviceTests := byService[service]
+		if len(serviceTests) == 0 {
+			continue
+		}
+		sort.Slice(serviceTests, func(i, j int) bool { return serviceTests[i].Name < serviceTests[j].Name })
+		fmt.Fprintf(w, "### %s\n\n", dossierService(service))
+		fmt.Fprintln(w, "| Test | Steps | Location | Evidence |")
+		fmt.Fprintln(w, "|---|---:|---|---|")
+		for _, test := range serviceTests {
+			fmt.Fprintf(w, "| `%s` | %d | %s | %s |\n", test.Name, test.Steps, sourceLink(linkBase, test.File, test.Line), dependencyEvidence(graph, test))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// dossierService names a service in a dossier table
+func dossierService(service string) string {
+	if service == "" {
+		return "(unknown)"
+	}
+	return service
+}
+
+// sourceLink renders a file and line as a Markdown link under linkBase
+// (e.g., a repository's blob URL), or relative to the document when linkBase
+// is ""
+func sourceLink(linkBase, file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	target := file
+	if linkBase != "" {
+		target = strings.TrimSuffix(linkBase, "/") + "/" + file
+	}
+	return fmt.Sprintf("[%s:%d](%s#L%d)", file, line, target, line)
+}
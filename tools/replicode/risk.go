@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Risk score weights. Proximity dominates because a test that declares the
+// changed resource itself is far more likely to break than one that only
+// reads an attribute of it several templates away.
+const (
+	riskWeightProximity   = 0.5
+	riskWeightFanIn       = 0.2
+	riskWeightFailureRate = 0.3
+)
+
+// coverageProximity is the minimum proximity of a test that executed changed Go code
+const coverageProximity = 0.5
+
+// sdkProximity is the minimum proximity of a test exercising a service that
+// imports a changed SDK package; weaker evidence than recorded coverage
+const sdkProximity = 0.25
+
+// RiskFactors are the normalized (0..1) inputs to a test's risk score
+type RiskFactors struct {
+	Proximity   float64 `json:"proximity"`    // Closeness of the test to a changed resource
+	FanIn       float64 `json:"fan_in"`       // Fan-in of the busiest template linking the test to a changed resource
+	FailureRate float64 `json:"failure_rate"` // Historical failure rate (0 without timing data)
+}
+
+// selectionOrders are the accepted values of select -order
+var selectionOrders = map[string]bool{"risk": true, "name": true}
+
+// ScoreTestRisk fills in the risk score of each selected test.
+//
+// Proximity is 1 when a template used directly by a TestStep declares a
+// changed resource block, halved for attribute-only references, and divided
+// by the template's depth below the test. Tests selected because their
+// recorded coverage executed changed Go code get a proximity of at least
+// coverageProximity, and tests selected through SDK imports at least
+// sdkProximity. Fan-in is the highest direct fan-in
+// among the templates referencing a changed resource, normalized against the
+// busiest template in the graph on a log scale.
+func ScoreTestRisk(graph *DependencyGraph, tests []SelectedTest, durations *DurationModel) {
+	fanIn := map[string]int{}
+	maxFanIn := 0
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTemplate {
+			continue
+		}
+		referrers := map[string]bool{}
+		for _, edge := range graph.InEdges(id) {
+			if edge.Kind == EdgeStepRef || edge.Kind == EdgeTemplateCall {
+				referrers[edge.From] = true
+			}
+		}
+		fanIn[id] = len(referrers)
+		if len(referrers) > maxFanIn {
+			maxFanIn = len(referrers)
+		}
+	}
+
+	for i := range tests {
+		test := &tests[i]
+		targets := map[string]bool{}
+		for _, resource := range test.MatchedResources {
+			targets[resourceNodeID(resource)] = true
+		}
+
+		var factors RiskFactors
+		for reachedID, depth := range graph.Reachable(testNodeID(test.Name), DirectionOut, 0) {
+			node := graph.Nodes[reachedID]
+			if node != nil && node.Kind == NodeTest {
+				// A test that taints a changed resource or expects its
+				// RequiresImportError exercises it directly
+				for _, edge := range graph.OutEdges(reachedID) {
+					if (edge.Kind == EdgeTaintRef || edge.Kind == EdgeResourceRef) && targets[edge.To] {
+						factors.Proximity = 1.0
+					}
+				}
+			}
+			if node == nil || node.Kind != NodeTemplate {
+				continue
+			}
+			for _, edge := range graph.OutEdges(reachedID) {
+				if edge.Kind != EdgeResourceRef || !targets[edge.To] {
+					continue
+				}
+				weight := 0.5
+				if edge.Detail == "RESOURCE_BLOCK" {
+					weight = 1.0
+				}
+				factors.Proximity = math.Max(factors.Proximity, weight/float64(depth))
+				if maxFanIn > 0 {
+					normalized := math.Log1p(float64(fanIn[reachedID])) / math.Log1p(float64(maxFanIn))
+					factors.FanIn = math.Max(factors.FanIn, normalized)
+				}
+			}
+		}
+		if len(test.MatchedCode) > 0 {
+			factors.Proximity = math.Max(factors.Proximity, coverageProximity)
+		}
+		if len(test.MatchedPackages) > 0 {
+			factors.Proximity = math.Max(factors.Proximity, sdkProximity)
+		}
+		if durations != nil {
+			if stats := durations.Tests[test.Name]; stats != nil {
+				factors.FailureRate = stats.FailureRate()
+			}
+		}
+
+		factors.Proximity = roundScore(factors.Proximity)
+		factors.FanIn = roundScore(factors.FanIn)
+		factors.FailureRate = roundScore(factors.FailureRate)
+		test.Risk = factors
+		test.RiskScore = roundScore(riskWeightProximity*factors.Proximity +
+			riskWeightFanIn*factors.FanIn +
+			riskWeightFailureRate*factors.FailureRate)
+	}
+}
+
+// orderSelection sorts selected tests by risk (highest first) or by name
+func orderSelection(tests []SelectedTest, order string) {
+	sort.SliceStable(tests, func(i, j int) bool {
+		if order == "risk" && tests[i].RiskScore != tests[j].RiskScore {
+			return tests[i].RiskScore > tests[j].RiskScore
+		}
+		return tests[i].Name < tests[j].Name
+	})
+}
+
+// roundScore rounds a score to three decimal places for stable output
+func roundScore(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// Schema finding kinds
+const (
+	schemaUnknownResource = "UNKNOWN_RESOURCE" // Not in the provider schema: a typo, removed, or an analyzer false positive
+	schemaWrongBlockKind  = "WRONG_BLOCK_KIND" // A data source used as a resource or the other way around
+	schemaForeignResource = "FOREIGN_RESOURCE" // Defined by another provider in the schema, not the analyzed one
+)
+
+// schemaMaxSuggestionEdit is the largest edit distance offered as a typo suggestion
+const schemaMaxSuggestionEdit = 3
+
+// providerSchemaDocument is the subset of `terraform providers schema -json` output we read
+type providerSchemaDocument struct {
+	ProviderSchemas map[string]struct {
+		ResourceSchemas   map[string]json.RawMessage `json:"resource_schemas"`
+		DataSourceSchemas map[string]json.RawMessage `json:"data_source_schemas"`
+	} `json:"provider_schemas"`
+}
+
+// ProviderSchema is the set of resource and data source types each provider defines
+type ProviderSchema struct {
+	Provider    string            // Address of the analyzed provider (e.g., registry.terraform.io/hashicorp/azurerm)
+	Resources   map[string]bool   // Resource types of the analyzed provider
+	DataSources map[string]bool   // Data source types of the analyzed provider
+	Foreign     map[string]string // Types defined only by other providers, with their address
+	Deprecated  map[string]string // Deprecated types of the analyzed provider, with the schema description
+}
+
+// schemaBlock is the subset of a resource or data source schema we read
+type schemaBlock struct {
+	Block struct {
+		Description string `json:"description"`
+		Deprecated  bool   `json:"deprecated"`
+	} `json:"block"`
+}
+
+// LoadProviderSchema reads `terraform providers schema -json` output. provider
+// selects the analyzed provider by full address or by its last path element
+// (e.g., "azurerm"); it may be empty when the schema holds a single provider.
+func LoadProviderSchema(path, provider string) (*ProviderSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document providerSchemaDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parsing provider schema %s: %v", path, err)
+	}
+	if len(document.ProviderSchemas) == 0 {
+		return nil, fmt.Errorf("provider schema %s contains no provider_schemas", path)
+	}
+
+	schema := &ProviderSchema{
+		Resources:   map[string]bool{},
+		DataSources: map[string]bool{},
+		Foreign:     map[string]string{},
+		Deprecated:  map[string]string{},
+	}
+	for address := range document.ProviderSchemas {
+		if provider == "" && len(document.ProviderSchemas) == 1 || address == provider || strings.HasSuffix(address, "/"+provider) {
+			schema.Provider = address
+		}
+	}
+	if schema.Provider == "" {
+		return nil, fmt.Errorf("provider %q not found in schema %s", provider, path)
+	}
+
+	for address, schemas := range document.ProviderSchemas {
+		for name, raw := range schemas.ResourceSchemas {
+			if address == schema.Provider {
+				schema.Resources[name] = true
+				schema.markDeprecated(name, raw)
+			} else {
+				schema.Foreign[name] = address
+			}
+		}
+		for name, raw := range schemas.DataSourceSchemas {
+			if address == schema.Provider {
+				schema.DataSources[name] = true
+				schema.markDeprecated(name, raw)
+			} else {
+				schema.Foreign[name] = address
+			}
+		}
+	}
+	return schema, nil
+}
+
+// markDeprecated records a type whose schema block is marked deprecated.
+// Blocks that fail to decode are treated as not deprecated.
+func (s *ProviderSchema) markDeprecated(name string, raw json.RawMessage) {
+	var block schemaBlock
+	if err := json.Unmarshal(raw, &block); err == nil && block.Block.Deprecated {
+		s.Deprecated[name] = strings.TrimSpace(block.Block.Description)
+	}
+}
+
+// SchemaFindingLocation is one template reference to a flagged resource type
+type SchemaFindingLocation struct {
+	TemplateFunction string `json:"template_function"`
+	TemplateFile     string `json:"template_file"`
+	TemplateLine     int    `json:"template_line"`
+	Context          string `json:"context"`
+}
+
+// SchemaFinding is a resource type referenced by templates that the provider
+// schema does not define the way it is used
+type SchemaFinding struct {
+	ResourceName  string                  `json:"resource_name"`
+	ReferenceType string                  `json:"reference_type"` // RESOURCE_BLOCK, DATA_SOURCE_BLOCK, or ATTRIBUTE_REFERENCE
+	Kind          string                  `json:"kind"`           // UNKNOWN_RESOURCE, WRONG_BLOCK_KIND, or FOREIGN_RESOURCE
+	Suggestion    string                  `json:"suggestion,omitempty"`
+	Provider      string                  `json:"provider,omitempty"` // Defining provider of a FOREIGN_RESOURCE
+	Locations     []SchemaFindingLocation `json:"locations"`
+}
+
+// SchemaValidation is the output of the schema report
+type SchemaValidation struct {
+	Provider      string          `json:"provider"`
+	ResourceTypes int             `json:"resource_types"` // Distinct referenced types checked
+	References    int             `json:"references"`     // Direct resource references checked
+	Findings      []SchemaFinding `json:"findings"`
+}
+
+// ValidateResourceNames checks every direct resource reference against the
+// provider schema. Resource blocks and attribute references must name a
+// resource type and data blocks a data source type of the analyzed provider.
+func ValidateResourceNames(results []*analyzer.Result, schema *ProviderSchema) *SchemaValidation {
+	validation := &SchemaValidation{Provider: schema.Provider, Findings: []SchemaFinding{}}
+	findings := map[string]*SchemaFinding{}
+	checked := map[string]bool{}
+
+	for _, result := range results {
+		for _, ref := range result.DirectResourceRefs {
+			validation.References++
+			checked[ref.ResourceName] = true
+
+			defined, other := schema.Resources, schema.DataSources
+			if ref.ReferenceType == "DATA_SOURCE_BLOCK" {
+				defined, other = schema.DataSources, schema.Resources
+			}
+			if defined[ref.ResourceName] {
+				continue
+			}
+
+			key := ref.ResourceName + "|" + ref.ReferenceType
+			finding := findings[key]
+			if finding == nil {
+				finding = &SchemaFinding{ResourceName: ref.ResourceName, ReferenceType: ref.ReferenceType}
+				switch {
+				case other[ref.ResourceName]:
+					finding.Kind = schemaWrongBlockKind
+				case schema.Foreign[ref.ResourceName] != "":
+					finding.Kind = schemaForeignResource
+					finding.Provider = schema.Foreign[ref.ResourceName]
+				default:
+					finding.Kind = schemaUnknownResource
+					finding.Suggestion = closestName(ref.ResourceName, defined)
+				}
+				findings[key] = finding
+			}
+			finding.Locations = append(finding.Locations, SchemaFindingLocation{
+				TemplateFunction: ref.TemplateFunction,
+				TemplateFile:     ref.TemplateFile,
+				TemplateLine:     ref.TemplateLine,
+				Context:          ref.Context,
+			})
+		}
+	}
+	validation.ResourceTypes = len(checked)
+
+	for _, finding := range findings {
+		sort.Slice(finding.Locations, func(i, j int) bool {
+			a, b := finding.Locations[i], finding.Locations[j]
+			if a.TemplateFile != b.TemplateFile {
+				return a.TemplateFile < b.TemplateFile
+			}
+			return a.TemplateLine < b.TemplateLine
+		})
+		validation.Findings = append(validation.Findings, *finding)
+	}
+	sort.Slice(validation.Findings, func(i, j int) bool {
+		a, b := validation.Findings[i], validation.Findings[j]
+		if a.ResourceName != b.ResourceName {
+			return a.ResourceName < b.ResourceName
+		}
+		return a.ReferenceType < b.ReferenceType
+	})
+	return validation
+}
+
+// closestName returns the known name nearest to name by edit distance, or ""
+// when none is close enough to be a plausible typo
+func closestName(name string, known map[string]bool) string {
+	best, bestDistance := "", schemaMaxSuggestionEdit+1
+	for candidate := range known {
+		distance := editDistance(name, candidate)
+		if distance < bestDistance || distance == bestDistance && candidate < best {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
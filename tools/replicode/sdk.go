@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// sdkModules are the module paths whose packages are tracked as Azure SDK clients
+var sdkModules = []string{
+	"github.com/hashicorp/go-azure-sdk/",
+	"github.com/Azure/azure-sdk-for-go/",
+}
+
+// isSDKPackage reports whether an import path belongs to an Azure SDK module
+func isSDKPackage(path string) bool {
+	for _, module := range sdkModules {
+		if strings.HasPrefix(path, module) {
+			return true
+		}
+	}
+	return false
+}
+
+// SDKImportIndex records which provider services import each Azure SDK package
+type SDKImportIndex struct {
+	// Packages maps an SDK package path to the services importing it, and each
+	// service to the importing files
+	Packages map[string]map[string][]string
+}
+
+// ScanSDKImports indexes the SDK imports of every Go file under dir. Test files
+// come from the existing analysis results; the remaining files (clients,
+// resources, registrations) are parsed for their imports only. Files are
+// assigned to services by the given patterns before the services/<name> layout.
+func ScanSDKImports(dir, repoRoot string, services analyzer.ServicePatterns, results []*analyzer.Result) (*SDKImportIndex, error) {
+	index := &SDKImportIndex{Packages: map[string]map[string][]string{}}
+	for _, result := range results {
+		index.add(services.ServiceName(result.FilePath), result.FilePath, result.Imports)
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".go") || strings.HasSuffix(d.Name(), "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return fmt.Errorf("parsing imports of %s: %v", path, err)
+		}
+		if repoRoot != "" {
+			if relative, err := analyzer.RelativePath(repoRoot, path); err == nil {
+				path = relative
+			}
+		}
+		index.add(services.ServiceName(path), path, analyzer.ExtractImports(file))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, services := range index.Packages {
+		for service, files := range services {
+			services[service] = uniqueSorted(files)
+		}
+	}
+	return index, nil
+}
+
+// add records a file's SDK imports under its service
+func (x *SDKImportIndex) add(service, path string, imports []analyzer.ImportInfo) {
+	if service == "" {
+		return
+	}
+	for _, imp := range imports {
+		if !isSDKPackage(imp.PackagePath) {
+			continue
+		}
+		services := x.Packages[imp.PackagePath]
+		if services == nil {
+			services = map[string][]string{}
+			x.Packages[imp.PackagePath] = services
+		}
+		services[service] = append(services[service], filepath.ToSlash(path))
+	}
+}
+
+// matchSDKPackage reports whether an import path is named by a query: the
+// full path, a parent of it, or a trailing part of it such as
+// "resource-manager/network/2023-09-01"
+func matchSDKPackage(path, query string) bool {
+	query = strings.Trim(query, "/")
+	return path == query || strings.HasPrefix(path, query+"/") ||
+		strings.HasSuffix(path, "/"+query) || strings.Contains(path, "/"+query+"/")
+}
+
+// ServicesImporting maps each service importing a queried package to the
+// matched package paths, sorted
+func (x *SDKImportIndex) ServicesImporting(queries []string) map[string][]string {
+	services := map[string][]string{}
+	for path, importers := range x.Packages {
+		for _, query := range queries {
+			if !matchSDKPackage(path, query) {
+				continue
+			}
+			for service := range importers {
+				services[service] = append(services[service], path)
+			}
+			break
+		}
+	}
+	for service, paths := range services {
+		sort.Strings(paths)
+		services[service] = paths
+	}
+	return services
+}
+
+// sdkServicesReached returns the services whose tests or templates a test
+// runs, including those of sequential sub-tests and cross-service templates
+func sdkServicesReached(graph *DependencyGraph, id string) map[string]bool {
+	services := map[string]bool{}
+	if node := graph.Nodes[id]; node != nil && node.Service != "" {
+		services[node.Service] = true
+	}
+	for reached := range graph.Reachable(id, DirectionOut, 0) {
+		node := graph.Nodes[reached]
+		if node != nil && (node.Kind == NodeTest || node.Kind == NodeTemplate) && node.Service != "" {
+			services[node.Service] = true
+		}
+	}
+	return services
+}
+
+// AddSDKImpactedTests merges the tests exercising services that import a
+// changed SDK package into a selection. A test is impacted when it, or a
+// template or sequential sub-test it reaches, belongs to an importing
+// service. Tests already selected gain MatchedPackages.
+func AddSDKImpactedTests(graph *DependencyGraph, selected []SelectedTest, importers map[string][]string, durations *DurationModel) []SelectedTest {
+	index := map[string]int{}
+	for i, test := range selected {
+		index[test.Name] = i
+	}
+
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTest || !isRunnableTest(node.Name) {
+			continue
+		}
+		var matched []string
+		for service := range sdkServicesReached(graph, id) {
+			matched = append(matched, importers[service]...)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		matched = uniqueSorted(matched)
+
+		if i, ok := index[node.Name]; ok {
+			selected[i].MatchedPackages = matched
+			continue
+		}
+		steps := graph.StepCount(id)
+		estimate, source := estimateTestDuration(node.Name, steps, durations)
+		selected = append(selected, SelectedTest{
+			Name:             node.Name,
+			File:             node.File,
+			Line:             node.Line,
+			Service:          node.Service,
+			MatchedResources: []string{},
+			MatchedPackages:  matched,
+			Steps:            steps,
+			EstimatedSeconds: int(estimate.Seconds()),
+			EstimateSource:   source,
+		})
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected
+}
+
+// SDKPackageUsage is one SDK package with the services importing it and the
+// tests a change to it impacts
+type SDKPackageUsage struct {
+	Package  string   `json:"package"`
+	Services []string `json:"services"`
+	Files    []string `json:"files"`
+	Tests    int      `json:"tests"` // Runnable tests impacted by a change to the package
+}
+
+// SDKImportUsage lists every imported SDK package, sorted by path
+func SDKImportUsage(graph *DependencyGraph, index *SDKImportIndex) []SDKPackageUsage {
+	// Services reached by each runnable test, computed once for all packages
+	reached := map[string]map[string]bool{}
+	for id, node := range graph.Nodes {
+		if node.Kind == NodeTest && isRunnableTest(node.Name) {
+			reached[id] = sdkServicesReached(graph, id)
+		}
+	}
+
+	usage := []SDKPackageUsage{}
+	for path, importers := range index.Packages {
+		entry := SDKPackageUsage{Package: path, Services: []string{}, Files: []string{}}
+		for service, files := range importers {
+			entry.Services = append(entry.Services, service)
+			entry.Files = append(entry.Files, files...)
+		}
+		sort.Strings(entry.Services)
+		sort.Strings(entry.Files)
+		for _, services := range reached {
+			for service := range importers {
+				if services[service] {
+					entry.Tests++
+					break
+				}
+			}
+		}
+		usage = append(usage, entry)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Package < usage[j].Package
+	})
+	return usage
+}
+
+// apiVersionSegment matches an Azure API version path element (e.g., 2023-09-01 or 2022-10-01-preview)
+var apiVersionSegment = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-[a-z]+)?$`)
+
+// APIVersion is an Azure API and version an SDK package is generated from
+type APIVersion struct {
+	API     string `json:"api"`     // Path element naming the API (e.g., "network")
+	Version string `json:"version"` // e.g., "2023-09-01"
+}
+
+// String formats the version as api/version
+func (v APIVersion) String() string {
+	return v.API + "/" + v.Version
+}
+
+// parseAPIVersion extracts the API version encoded in an SDK import path. The
+// API is the path element before the version, skipping azure-sdk-for-go's
+// "mgmt" (services/network/mgmt/2020-05-01/network).
+func parseAPIVersion(path string) (APIVersion, bool) {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if i == 0 || !apiVersionSegment.MatchString(part) {
+			continue
+		}
+		api := parts[i-1]
+		if api == "mgmt" && i >= 2 {
+			api = parts[i-2]
+		}
+		return APIVersion{API: api, Version: part}, true
+	}
+	return APIVersion{}, false
+}
+
+// apiVersionLess orders versions by API, then version
+func apiVersionLess(a, b APIVersion) bool {
+	if a.API != b.API {
+		return a.API < b.API
+	}
+	return a.Version < b.Version
+}
+
+// ServiceAPIVersions lists the API versions one service's SDK imports use
+type ServiceAPIVersions struct {
+	Service  string              `json:"service"`
+	Versions []ServiceAPIVersion `json:"versions"`
+}
+
+// ServiceAPIVersion is one API version with the service's packages using it
+type ServiceAPIVersion struct {
+	APIVersion
+	Packages []string `json:"packages"`
+}
+
+// TestAPIVersions lists the API versions a test exercises through the
+// services it reaches
+type TestAPIVersions struct {
+	Test     string       `json:"test"`
+	Service  string       `json:"service"`
+	Versions []APIVersion `json:"versions"`
+}
+
+// APIVersionReport is the output of the api-versions report
+type APIVersionReport struct {
+	Services []ServiceAPIVersions `json:"services"`
+	Tests    []TestAPIVersions    `json:"tests"`
+}
+
+// ServiceAPIVersionMap returns the API versions each service imports, with
+// the importing packages. Packages without a version in their path (shared
+// SDK plumbing) are left out.
+func (x *SDKImportIndex) ServiceAPIVersionMap() map[string]map[APIVersion][]string {
+	services := map[string]map[APIVersion][]string{}
+	for path, importers := range x.Packages {
+		version, ok := parseAPIVersion(path)
+		if !ok {
+			continue
+		}
+		for service := range importers {
+			if services[service] == nil {
+				services[service] = map[APIVersion][]string{}
+			}
+			services[service][version] = append(services[service][version], path)
+		}
+	}
+	return services
+}
+
+// ComputeAPIVersions attributes API versions to every service and runnable
+// test. A test exercises the versions of every service whose tests or
+// templates it reaches, the same attribution select -sdk uses.
+func ComputeAPIVersions(graph *DependencyGraph, index *SDKImportIndex) *APIVersionReport {
+	byService := index.ServiceAPIVersionMap()
+	report := &APIVersionReport{Services: []ServiceAPIVersions{}, Tests: []TestAPIVersions{}}
+
+	for service, versions := range byService {
+		entry := ServiceAPIVersions{Service: service}
+		for version, packages := range versions {
+			entry.Versions = append(entry.Versions, ServiceAPIVersion{APIVersion: version, Packages: uniqueSorted(packages)})
+		}
+		sort.Slice(entry.Versions, func(i, j int) bool {
+			return apiVersionLess(entry.Versions[i].APIVersion, entry.Versions[j].APIVersion)
+		})
+		report.Services = append(report.Services, entry)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Service < report.Services[j].Service
+	})
+
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTest || !isRunnableTest(node.Name) {
+			continue
+		}
+		seen := map[APIVersion]bool{}
+		entry := TestAPIVersions{Test: node.Name, Service: node.Service, Versions: []APIVersion{}}
+		for service := range sdkServicesReached(graph, id) {
+			for version := range byService[service] {
+				if !seen[version] {
+					seen[version] = true
+					entry.Versions = append(entry.Versions, version)
+				}
+			}
+		}
+		sort.Slice(entry.Versions, func(i, j int) bool {
+			return apiVersionLess(entry.Versions[i], entry.Versions[j])
+		})
+		report.Tests = append(report.Tests, entry)
+	}
+	sort.Slice(report.Tests, func(i, j int) bool {
+		return report.Tests[i].Test < report.Tests[j].Test
+	})
+	return report
+}
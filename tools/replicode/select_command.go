@@ -0,0 +1,305 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// stringList is a flag.Value collecting comma-separated and repeated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// runSelectCommand selects the tests impacted by a change to one or more resources
+func runSelectCommand(args []string) int {
+	fs := flag.NewFlagSet("select", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	var resources stringList
+	fs.Var(&resources, "resource", "Changed resource type(s), comma-separated or repeated (e.g., azurerm_subnet)")
+	var namespaces stringList
+	fs.Var(&namespaces, "namespace", "Changed ARM namespace(s), selecting every resource type it serves (e.g., Microsoft.Network)")
+	var changed stringList
+	fs.Var(&changed, "changed", "Changed Go code as path or path:start-end, comma-separated or repeated (requires -coverage)")
+	var packages stringList
+	fs.Var(&packages, "sdk", "Changed Azure SDK package path(s) or path suffixes, comma-separated or repeated (e.g., resource-manager/network/2023-09-01)")
+	coveragePath := fs.String("coverage", "", "Per-test coverage: database directory or TestCoverage.csv (see coverage ingest)")
+	shards := fs.Int("shards", 0, "Partition the selected tests into this many duration-balanced shards")
+	durationsPath := fs.String("durations", "", "Historical timing data: database directory or TestDurations.csv (see durations ingest)")
+	budget := fs.Duration("budget", 0, "Keep the highest-risk tests fitting this wall-clock budget (e.g., 4h)")
+	flakyList := fs.String("flaky", "", "Flaky-test list: file path or http(s) URL, one test name per line")
+	flakyMode := fs.String("flaky-mode", flakyAnnotate, "Flaky-test handling: annotate, retry, or quarantine")
+	flakyRetries := fs.Int("flaky-retries", 2, "Retries assigned to flaky tests with -flaky-mode retry")
+	withGit := fs.Bool("git", false, "Add the last commit, author, and age of each test function (requires git)")
+	order := fs.String("order", "risk", "Test order: risk (highest first) or name")
+	format := fs.String("format", "json", "Output format: json, text, or teamcity")
+	failImpacted := fs.Int("fail-if-impacted-gt", -1, "Exit with 3 when more than this many tests are selected (-1 = never)")
+	failUnresolved := fs.Bool("fail-on-unresolved", false, "Exit with 4 when the analysis left references unresolved, so the selection may be incomplete")
+	explain := fs.Bool("plan", false, "Instead of the selection, print every candidate test with the rule and edge that included or excluded it")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	// Without a change to select for, a user at a terminal picks resource types
+	pick := len(resources) == 0 && len(namespaces) == 0 && len(changed) == 0 && len(packages) == 0
+	if pick && !stdinIsTerminal() {
+		fmt.Fprintln(os.Stderr, "Error: -resource, -namespace, -changed, or -sdk parameter is required")
+		return 1
+	}
+	if len(changed) > 0 && *coveragePath == "" {
+		fmt.Fprintln(os.Stderr, "Error: -changed requires -coverage")
+		return 1
+	}
+	changes := make([]CodeChange, 0, len(changed))
+	for _, value := range changed {
+		change, err := ParseCodeChange(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		changes = append(changes, change)
+	}
+	if err := validateFormat(*format, "json", "text", "teamcity"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *explain && *format == "teamcity" {
+		fmt.Fprintln(os.Stderr, "Error: -plan supports json or text format")
+		return 1
+	}
+	if !selectionOrders[*order] {
+		fmt.Fprintf(os.Stderr, "Error: invalid order %q (expected risk or name)\n", *order)
+		return 1
+	}
+	if *shards < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -shards must not be negative")
+		return 1
+	}
+	if !flakyModes[*flakyMode] {
+		fmt.Fprintf(os.Stderr, "Error: invalid flaky mode %q (expected annotate, retry, or quarantine)\n", *flakyMode)
+		return 1
+	}
+	if *budget < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -budget must not be negative")
+		return 1
+	}
+
+	var durations *DurationModel
+	if *durationsPath != "" {
+		model, err := LoadDurationModel(resolveDurationsPath(*durationsPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		durations = model
+	}
+
+	var coverage *CoverageModel
+	if *coveragePath != "" {
+		model, err := LoadCoverageModel(resolveCoveragePath(*coveragePath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		coverage = model
+	}
+
+	var flaky FlakyList
+	if *flakyList != "" {
+		list, err := LoadFlakyList(*flakyList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		flaky = list
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	if pick {
+		var candidates []string
+		for _, node := range graph.Nodes {
+			if node.Kind == NodeResource {
+				candidates = append(candidates, node.Name)
+			}
+		}
+		if resources, err = pickResources(os.Stdin, os.Stderr, candidates); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if len(namespaces) > 0 {
+		named := map[string]bool{}
+		for _, resource := range resources {
+			named[resource] = true
+		}
+		for _, resource := range graph.ResourcesInNamespaces(namespaces) {
+			if !named[resource] {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	selection := &SelectionResult{
+		Resources:  append([]string{}, resources...),
+		Namespaces: namespaces,
+		Tests:      SelectImpactedTests(graph, resources, durations),
+	}
+	if len(changes) > 0 {
+		selection.Changes = changed
+		selection.Tests = AddCoveredTests(graph, selection.Tests, coverage, changes, durations)
+	}
+	if len(packages) > 0 {
+		index, err := ScanSDKImports(source.Dir, source.root(), analyzer.ServicePatterns(source.Services), results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		selection.Packages = packages
+		selection.Tests = AddSDKImpactedTests(graph, selection.Tests, index.ServicesImporting(packages), durations)
+	}
+	AttachTestEnvVars(graph, selection.Tests)
+	AttachTestFootprints(graph, selection.Tests)
+	ScoreTestRisk(graph, selection.Tests, durations)
+	orderSelection(selection.Tests, *order)
+	if *budget > 0 {
+		selection.Tests, selection.Budget = ApplyDurationBudget(graph, selection.Tests, *budget)
+	}
+	ApplyFlakyList(selection.Tests, flaky, *flakyMode, *flakyRetries)
+	switch {
+	case *flakyMode == flakyQuarantine && flaky != nil:
+		selection.Shards = QuarantineShards(graph, selection.Tests, *shards)
+	case *shards > 0:
+		selection.Shards = ShardTests(graph, selection.Tests, *shards)
+	}
+	if *withGit {
+		if err := AttachTestGitMetadata(graph, selection.Tests, source.root()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	selection.Unresolved = len(graph.Unresolved)
+
+	if *explain {
+		plan := ExplainSelection(graph, selection)
+		if *format == "text" {
+			writeSelectionPlanText(plan)
+			return exitOK
+		}
+		if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	switch *format {
+	case "teamcity":
+		writeSelectionTeamCity(os.Stdout, newProvenance(fs, source.root()), selection)
+	case "text":
+		writeSelectionText(selection)
+	default:
+		if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), selection); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+	}
+	return selectionGates(selection, *failImpacted, *failUnresolved)
+}
+
+// selectionGates checks a written selection against the -fail-* conditions
+// and returns the exit code. The impacted-test threshold is checked first, as
+// it is the one a merge gate acts on.
+func selectionGates(selection *SelectionResult, maxImpacted int, failUnresolved bool) int {
+	if maxImpacted >= 0 && len(selection.Tests) > maxImpacted {
+		fmt.Fprintf(os.Stderr, "Error: %d tests impacted, more than the %d allowed by -fail-if-impacted-gt\n", len(selection.Tests), maxImpacted)
+		return exitImpacted
+	}
+	if failUnresolved && selection.Unresolved > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %d references are unresolved, so the selection may be incomplete (-fail-on-unresolved)\n", selection.Unresolved)
+		return exitUnresolved
+	}
+	return exitOK
+}
+
+// writeSelectionPlanText prints the decisions of select -plan, included tests first
+func writeSelectionPlanText(plan *SelectionPlan) {
+	fmt.Printf("%d included, %d excluded for %s\n\n", plan.Included, plan.Excluded, strings.Join(plan.Resources, ", "))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DECISION\tRULE\tTEST\tEVIDENCE")
+	for _, decision := range plan.Decisions {
+		verdict := "exclude"
+		if decision.Included {
+			verdict = "include"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", verdict, decision.Rule, decision.Test, decision.Evidence)
+	}
+	w.Flush()
+}
+
+// writeSelectionText prints a selection for interactive use
+func writeSelectionText(selection *SelectionResult) {
+	targets := append(append(append([]string{}, selection.Resources...), selection.Changes...), selection.Packages...)
+	fmt.Printf("Selected %d tests for %s\n\n", len(selection.Tests), strings.Join(targets, ", "))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tSERVICE\tRISK\tSTEPS\tESTIMATE\tMATCHED")
+	for _, test := range selection.Tests {
+		name := test.Name
+		if test.Flaky {
+			name += " [flaky]"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.3f\t%d\t%s\t%s\n", name, test.Service, test.RiskScore, test.Steps,
+			formatSeconds(test.EstimatedSeconds), strings.Join(append(append(append([]string{}, test.MatchedResources...), test.MatchedCode...), test.MatchedPackages...), ","))
+	}
+	w.Flush()
+
+	if selection.Budget != nil {
+		fmt.Printf("\nBudget %s, used %s, deferred %d tests\n", formatSeconds(selection.Budget.BudgetSeconds),
+			formatSeconds(selection.Budget.UsedSeconds), len(selection.Budget.Deferred))
+		for _, deferred := range selection.Budget.Deferred {
+			fmt.Printf("  %s (%s, risk %.3f): %s\n", deferred.Name, formatSeconds(deferred.EstimatedSeconds),
+				deferred.RiskScore, deferred.Reason)
+		}
+	}
+
+	if len(selection.Shards) > 0 {
+		fmt.Println()
+	}
+	for _, shard := range selection.Shards {
+		label := "Shard"
+		if shard.Quarantine {
+			label = "Quarantine shard"
+		}
+		fmt.Printf("%s %d (%d tests, %s): %s\n", label, shard.Index, len(shard.Tests),
+			formatSeconds(shard.EstimatedSeconds), shard.RunPattern)
+	}
+}
+
+// formatSeconds renders a duration in seconds as a compact human string
+func formatSeconds(seconds int) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
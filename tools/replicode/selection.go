@@ -0,0 +1,206 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultStepDuration is the estimated wall-clock time of one acceptance TestStep
+// when no historical timing data is available
+const defaultStepDuration = 5 * time.Minute
+
+// SelectedTest is a runnable test function chosen by impact selection
+type SelectedTest struct {
+	Name             string       `json:"name"`
+	File             string       `json:"file"`
+	Line             int          `json:"line"`
+	Service          string       `json:"service"`
+	MatchedResources []string     `json:"matched_resources"`          // Target resources in the test's closure
+	MatchedCode      []string     `json:"matched_code,omitempty"`     // Changed Go code the test executed (from coverage)
+	MatchedPackages  []string     `json:"matched_packages,omitempty"` // Changed SDK packages imported by services the test exercises
+	Steps            int          `json:"steps"`                      // TestSteps including sequential sub-tests
+	SkippableSteps   int          `json:"skippable_steps,omitempty"`  // Steps with a SkipFunc, which may not run
+	SkipRate         float64      `json:"skip_rate,omitempty"`        // Fraction of recorded runs that skipped
+	Footprint        int          `json:"footprint"`                  // Resources its templates create (see Footprint)
+	EnvVars          []string     `json:"env_vars,omitempty"`         // Environment variables the test and its templates read
+	SkipsWithout     []string     `json:"skips_without,omitempty"`    // Unset, the test skips instead of failing
+	AltSubscription  bool         `json:"alt_subscription,omitempty"` // Needs multi-subscription or multi-tenant credentials
+	ProviderAliases  []string     `json:"provider_aliases,omitempty"` // Aliased providers its templates create resources with
+	EstimatedSeconds int          `json:"estimated_seconds"`          // Estimated wall-clock duration
+	EstimateSource   string       `json:"estimate_source"`            // "history" or "steps"
+	RiskScore        float64      `json:"risk_score"`                 // Weighted risk, higher runs first (see ScoreTestRisk)
+	Risk             RiskFactors  `json:"risk_factors"`
+	Flaky            bool         `json:"flaky,omitempty"`        // Listed in the -flaky list
+	FlakyReason      string       `json:"flaky_reason,omitempty"` // Comment from the flaky list
+	Retries          int          `json:"retries,omitempty"`      // Retries to allow (select -flaky-mode retry)
+	Git              *GitMetadata `json:"git,omitempty"`          // Last change to the test function (select -git)
+}
+
+// SelectionResult is the output of the select command
+type SelectionResult struct {
+	Resources  []string       `json:"resources"`
+	Namespaces []string       `json:"namespaces,omitempty"` // ARM namespaces expanded into Resources
+	Changes    []string       `json:"changes,omitempty"`    // Changed Go code matched against coverage
+	Packages   []string       `json:"packages,omitempty"`   // Changed SDK packages matched against service imports
+	Tests      []SelectedTest `json:"tests"`
+	Shards     []TestShard    `json:"shards,omitempty"`
+	Budget     *BudgetSummary `json:"budget,omitempty"`
+	Unresolved int            `json:"unresolved,omitempty"` // References the analysis could not resolve; tests depending on them may be missing
+}
+
+// SelectImpactedTests returns every runnable test whose resource closure
+// includes at least one of the target resources. Unexported testAcc*
+// functions are never selected directly: they only run through the
+// sequential entry point that references them, which is selected instead.
+// Durations come from the historical model when it knows the test (a nil
+// model is allowed) and from the step-count heuristic otherwise.
+func SelectImpactedTests(graph *DependencyGraph, resources []string, durations *DurationModel) []SelectedTest {
+	targets := map[string]bool{}
+	for _, resource := range resources {
+		targets[resource] = true
+	}
+
+	selected := []SelectedTest{}
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTest || !isRunnableTest(node.Name) {
+			continue
+		}
+
+		var matched []string
+		for _, resource := range graph.ResourceClosure(id) {
+			if targets[resource] {
+				matched = append(matched, resource)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		steps := graph.StepCount(id)
+		estimate, source := estimateTestDuration(node.Name, steps, durations)
+		selected = append(selected, SelectedTest{
+			Name:             node.Name,
+			File:             node.File,
+			Line:             node.Line,
+			Service:          node.Service,
+			MatchedResources: matched,
+			Steps:            steps,
+			SkippableSteps:   graph.SkippableSteps(id),
+			SkipRate:         roundScore(durations.SkipRate(node.Name)),
+			EstimatedSeconds: int(estimate.Seconds()),
+			EstimateSource:   source,
+		})
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected
+}
+
+// AddCoveredTests merges tests whose recorded coverage executed changed Go
+// code into a selection. This catches changes to clients and expand/flatten
+// helpers, which the reference graph deliberately leaves out. Tests already
+// selected gain MatchedCode; new tests are appended with no matched resources.
+func AddCoveredTests(graph *DependencyGraph, selected []SelectedTest, coverage *CoverageModel, changes []CodeChange, durations *DurationModel) []SelectedTest {
+	covering := coverage.TestsCovering(changes)
+
+	index := map[string]int{}
+	for i, test := range selected {
+		index[test.Name] = i
+	}
+
+	for name, matched := range covering {
+		if len(matched) == 0 || !isRunnableTest(name) {
+			continue
+		}
+		if i, ok := index[name]; ok {
+			selected[i].MatchedCode = matched
+			continue
+		}
+
+		// Coverage can name tests outside the analyzed directory
+		test := SelectedTest{Name: name, MatchedResources: []string{}, MatchedCode: matched}
+		id := testNodeID(name)
+		if node := graph.Nodes[id]; node != nil {
+			test.File, test.Line, test.Service = node.File, node.Line, node.Service
+			test.Steps = graph.StepCount(id)
+			test.SkippableSteps = graph.SkippableSteps(id)
+		}
+		estimate, source := estimateTestDuration(name, test.Steps, durations)
+		test.EstimatedSeconds = int(estimate.Seconds())
+		test.EstimateSource = source
+		selected = append(selected, test)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return selected[i].Name < selected[j].Name
+	})
+	return selected
+}
+
+// isRunnableTest reports whether go test can run a function directly by name
+func isRunnableTest(name string) bool {
+	return strings.HasPrefix(name, "Test")
+}
+
+// estimateTestDuration estimates a test's duration, preferring recorded history
+// over its step count. The second result names the source of the estimate.
+func estimateTestDuration(name string, steps int, durations *DurationModel) (time.Duration, string) {
+	if estimate, ok := durations.Estimate(name); ok {
+		return estimate, "history"
+	}
+	if steps < 1 {
+		steps = 1
+	}
+	return time.Duration(steps) * defaultStepDuration, "steps"
+}
+
+// StepCount returns the number of TestStep Config references a test executes,
+// including the steps of sequential sub-tests it runs
+func (g *DependencyGraph) StepCount(id string) int {
+	tests := g.reachableVia(id, EdgeSequentialRef)
+	tests[id] = true
+
+	count := 0
+	for testID := range tests {
+		for _, edge := range g.outEdges[testID] {
+			if edge.Kind == EdgeStepRef {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// SkippableSteps returns how many of the steps StepCount counts have a
+// SkipFunc, and so may be skipped depending on the environment
+func (g *DependencyGraph) SkippableSteps(id string) int {
+	tests := g.reachableVia(id, EdgeSequentialRef)
+	tests[id] = true
+
+	count := 0
+	for testID := range tests {
+		count += g.skippable[testID]
+	}
+	return count
+}
+
+// reachableVia returns every node reachable from start following only edges of one kind
+func (g *DependencyGraph) reachableVia(start string, kind EdgeKind) map[string]bool {
+	reached := map[string]bool{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range g.outEdges[current] {
+			if edge.Kind != kind || reached[edge.To] || edge.To == start {
+				continue
+			}
+			reached[edge.To] = true
+			queue = append(queue, edge.To)
+		}
+	}
+	return reached
+}
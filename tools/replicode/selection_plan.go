@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Selection decision rules
+const (
+	ruleResourceClosure = "resource_closure" // Included: a changed resource is in the test's closure
+	ruleCoverage        = "coverage"         // Included: the test executed changed Go code
+	ruleSDKImport       = "sdk_import"       // Included: the test exercises a service importing a changed SDK package
+	ruleBudget          = "budget"           // Excluded: impacted, but deferred to stay within -budget
+	ruleNoMatch         = "no_match"         // Excluded: nothing the test depends on changed
+	ruleSequentialOnly  = "sequential_only"  // Excluded: an unexported test runs through its sequential entry point
+)
+
+// SelectionDecision explains why one candidate test is in or out of a selection
+type SelectionDecision struct {
+	Test     string `json:"test"`
+	Service  string `json:"service,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Included bool   `json:"included"`
+	Rule     string `json:"rule"`     // Rule that decided it (see the rule constants)
+	Evidence string `json:"evidence"` // The edge chain, matched code or package, or deferral reason behind the rule
+}
+
+// SelectionPlan is the output of select -plan: every candidate test with its decision
+type SelectionPlan struct {
+	Resources []string            `json:"resources"`
+	Included  int                 `json:"included"`
+	Excluded  int                 `json:"excluded"`
+	Decisions []SelectionDecision `json:"decisions"`
+}
+
+// ExplainSelection decides, for every test function in the graph, why the
+// selection includes or excludes it. Included tests cite the first rule that
+// matched them, in the order resource closure, coverage, SDK import; the
+// chain of a resource match ends with the file and line of the reference.
+func ExplainSelection(graph *DependencyGraph, selection *SelectionResult) *SelectionPlan {
+	plan := &SelectionPlan{Resources: selection.Resources, Decisions: []SelectionDecision{}}
+	if plan.Resources == nil {
+		plan.Resources = []string{}
+	}
+
+	selected := map[string]SelectedTest{}
+	for _, test := range selection.Tests {
+		selected[test.Name] = test
+	}
+	deferred := map[string]DeferredTest{}
+	if selection.Budget != nil {
+		for _, test := range selection.Budget.Deferred {
+			deferred[test.Name] = test
+		}
+	}
+
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTest {
+			continue
+		}
+		decision := SelectionDecision{Test: node.Name, Service: node.Service, File: node.File, Line: node.Line}
+		if test, ok := selected[node.Name]; ok {
+			decision.Included = true
+			decision.Rule, decision.Evidence = inclusionReason(graph, test)
+		} else if test, ok := deferred[node.Name]; ok {
+			decision.Rule = ruleBudget
+			decision.Evidence = fmt.Sprintf("deferred (%s), estimated %ds at risk %.3f", test.Reason, test.EstimatedSeconds, test.RiskScore)
+		} else if !isRunnableTest(node.Name) {
+			decision.Rule = ruleSequentialOnly
+			decision.Evidence = "not run directly; no sequential entry point references it"
+			var entries []string
+			for _, edge := range graph.InEdges(id) {
+				if edge.Kind == EdgeSequentialRef {
+					if entry := graph.Nodes[edge.From]; entry != nil {
+						entries = append(entries, entry.Name)
+					}
+				}
+			}
+			if len(entries) > 0 {
+				sort.Strings(entries)
+				decision.Evidence = "runs through " + strings.Join(entries, ", ")
+			}
+		} else {
+			decision.Rule = ruleNoMatch
+			decision.Evidence = fmt.Sprintf("none of the %d resource types in its closure changed", len(graph.ResourceClosure(id)))
+		}
+
+		if decision.Included {
+			plan.Included++
+		} else {
+			plan.Excluded++
+		}
+		plan.Decisions = append(plan.Decisions, decision)
+	}
+
+	sort.Slice(plan.Decisions, func(i, j int) bool {
+		a, b := plan.Decisions[i], plan.Decisions[j]
+		if a.Included != b.Included {
+			return a.Included
+		}
+		return a.Test < b.Test
+	})
+	return plan
+}
+
+// inclusionReason returns the rule and evidence that put a test in a selection
+func inclusionReason(graph *DependencyGraph, test SelectedTest) (rule, evidence string) {
+	switch {
+	case len(test.MatchedResources) > 0:
+		chain, location := evidenceChain(graph, test.Name, test.MatchedResources[0])
+		if chain == nil {
+			return ruleResourceClosure, strings.Join(test.MatchedResources, ", ")
+		}
+		evidence = strings.Join(append([]string{test.Name}, chain...), " -> ")
+		if location != "" {
+			evidence += " (" + location + ")"
+		}
+		return ruleResourceClosure, evidence
+	case len(test.MatchedCode) > 0:
+		return ruleCoverage, "covers " + strings.Join(test.MatchedCode, ", ")
+	default:
+		return ruleSDKImport, "imports " + strings.Join(test.MatchedPackages, ", ")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// goldenSuffix is appended to a fixture's path to name its golden file
+const goldenSuffix = ".json"
+
+// runSelftestCommand dispatches the selftest subcommands
+func runSelftestCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "generate":
+			return runSelftest(args[1:], true)
+		case "run":
+			return runSelftest(args[1:], false)
+		}
+		if !isHelpFlag(args[0]) {
+			fmt.Fprintf(os.Stderr, "Error: unknown selftest command %q\n", args[0])
+		}
+	}
+	fmt.Fprintln(usageOutput(args), "Usage: replicode selftest generate -fixtures <directory> [-golden <directory>]")
+	fmt.Fprintln(usageOutput(args), "       replicode selftest run -fixtures <directory> [-golden <directory>]")
+	return usageStatus(args)
+}
+
+// runSelftest analyzes every fixture file and either writes its result as
+// the fixture's golden JSON (generate) or compares it against the golden,
+// printing a unified diff of each difference (run). Fixtures are analyzed
+// with the fixture directory as the repository root, so the goldens do not
+// depend on where the checkout lives.
+func runSelftest(args []string, generate bool) int {
+	name := "selftest run"
+	if generate {
+		name = "selftest generate"
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fixtures := fs.String("fixtures", "", "Directory of curated Go fixture files, laid out as internal/services/<service>/... for service attribution")
+	golden := fs.String("golden", "", "Directory of golden JSON files (default: <fixtures>/golden)")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if *fixtures == "" {
+		fmt.Fprintln(os.Stderr, "Error: -fixtures parameter is required")
+		return 1
+	}
+	if *golden == "" {
+		*golden = filepath.Join(*fixtures, "golden")
+	}
+
+	paths, err := fixtureFiles(*fixtures, *golden)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no Go fixture files in %s\n", *fixtures)
+		return 1
+	}
+
+	expected := map[string]bool{}
+	changed, failed := 0, 0
+	for _, path := range paths {
+		rel, err := analyzer.RelativePath(*fixtures, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		goldenPath := filepath.Join(*golden, filepath.FromSlash(rel)+goldenSuffix)
+		expected[goldenPath] = true
+
+		current, err := snapshotFixture(path, *fixtures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", rel, err)
+			failed++
+			continue
+		}
+		previous, err := os.ReadFile(goldenPath)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+
+		switch {
+		case generate:
+			if err == nil && bytes.Equal(previous, current) {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			if err := os.WriteFile(goldenPath, current, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			changed++
+			fmt.Fprintf(os.Stderr, "Wrote %s\n", goldenPath)
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "Missing golden for %s; run selftest generate\n", rel)
+		case !bytes.Equal(previous, current):
+			failed++
+			fmt.Print(unifiedDiff("golden/"+rel+goldenSuffix, "current/"+rel+goldenSuffix, previous, current))
+		}
+	}
+
+	// Goldens of fixtures that no longer exist
+	stale, err := staleGoldens(*golden, expected)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, path := range stale {
+		if !generate {
+			fmt.Fprintf(os.Stderr, "Stale golden %s has no fixture\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		changed++
+		fmt.Fprintf(os.Stderr, "Removed %s\n", path)
+	}
+
+	if generate {
+		fmt.Fprintf(os.Stderr, "Updated %d goldens for %d fixtures\n", changed, len(paths))
+		if failed > 0 {
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d fixtures differ from their goldens; %d stale goldens\n", failed, len(paths), len(stale))
+	if failed > 0 || len(stale) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fixtureFiles returns the Go files under the fixture directory, sorted,
+// leaving out the golden directory
+func fixtureFiles(dir, golden string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filepath.Clean(path) == filepath.Clean(golden) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".go") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking directory %s: %v", dir, err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// snapshotFixture returns the analysis of a fixture as golden JSON
+func snapshotFixture(path, root string) ([]byte, error) {
+	result, err := analyzer.Analyze(path, analyzer.Options{RepoRoot: root})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, result); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// staleGoldens returns the golden files that no fixture produces, sorted
+func staleGoldens(golden string, expected map[string]bool) ([]string, error) {
+	var stale []string
+	err := filepath.WalkDir(golden, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == golden {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, goldenSuffix) && !expected[path] {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking directory %s: %v", golden, err)
+	}
+	return stale, nil
+}
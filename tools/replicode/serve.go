@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// serveMaxBody is the largest request body /analyze accepts
+const serveMaxBody = 8 << 20
+
+// serveCacheEntries is the most /analyze results the server keeps
+const serveCacheEntries = 256
+
+// analysisIndex is the in-memory analysis of a directory shared by server requests
+type analysisIndex struct {
+	mu      sync.RWMutex
+	source  sourceOptions
+	opts    analyzer.Options
+	results []*analyzer.Result
+	graph   *DependencyGraph
+	loaded  time.Time
+	cache   map[string]cachedAnalysis // Absolute path -> last /analyze result, at most serveCacheEntries
+	metrics *serverMetrics
+}
+
+// cachedAnalysis is a single-file analysis and the content it was made from
+type cachedAnalysis struct {
+	sum    [sha256.Size]byte
+	result *analyzer.Result
+}
+
+// load analyzes the source directory and replaces the index contents
+func (x *analysisIndex) load() error {
+	start := time.Now()
+	results, err := analyzer.AnalyzeDir(x.source.Dir, x.opts)
+	if err != nil {
+		return err
+	}
+	x.metrics.observeAnalysis(len(results), time.Since(start))
+	x.source.exportTrace(start, len(results))
+	graph := BuildDependencyGraph(results)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.results, x.graph, x.loaded = results, graph, time.Now().UTC()
+	x.cache = map[string]cachedAnalysis{}
+	return nil
+}
+
+// analyze returns the analysis of one file's content, reusing the last
+// result for the path when the content has not changed
+func (x *analysisIndex) analyze(path string, content []byte) (*analyzer.Result, error) {
+	sum := sha256.Sum256(content)
+	x.mu.RLock()
+	cached, ok := x.cache[path]
+	x.mu.RUnlock()
+	if ok && cached.sum == sum {
+		x.metrics.observeCache(true)
+		return cached.result, nil
+	}
+	x.metrics.observeCache(false)
+
+	opts := x.opts
+	opts.Source = content
+	start := time.Now()
+	result, err := analyzer.Analyze(path, opts)
+	x.source.trace.export("analyze "+filepath.ToSlash(path), start, nil)
+	if err != nil {
+		return nil, err
+	}
+	x.metrics.observeAnalysis(1, time.Since(start))
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.cache[path]; !ok && len(x.cache) >= serveCacheEntries {
+		for evicted := range x.cache {
+			delete(x.cache, evicted) // Any entry; a miss only costs an analysis
+			break
+		}
+	}
+	x.cache[path] = cachedAnalysis{sum: sum, result: result}
+	return result, nil
+}
+
+// requestPath resolves an /analyze path, relative ones against the repository
+// root, and refuses those outside the served directory, so that requests
+// can't read files elsewhere on the host or probe for them. Symbolic links
+// are followed for files that exist.
+func (x *analysisIndex) requestPath(requested string) (string, error) {
+	path := requested
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(x.source.root(), path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(x.source.Dir)
+	if err != nil {
+		return "", err
+	}
+	inside := func(path, dir string) bool {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	if !inside(path, dir) {
+		return "", fmt.Errorf("path %s is outside the served directory", requested)
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		if realDir, err := filepath.EvalSymlinks(dir); err == nil && !inside(real, realDir) {
+			return "", fmt.Errorf("path %s is outside the served directory", requested)
+		}
+	}
+	return path, nil
+}
+
+// gauges samples the index for a metrics scrape
+func (x *analysisIndex) gauges() []gauge {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return []gauge{
+		{"replicode_indexed_files", "Files in the in-memory index.", float64(len(x.results))},
+		{"replicode_graph_nodes", "Nodes in the dependency graph.", float64(len(x.graph.Nodes))},
+		{"replicode_unresolved_references", "Template and step references the graph could not resolve.", float64(len(x.graph.Unresolved))},
+		{"replicode_index_loaded_timestamp_seconds", "Unix time the directory was last analyzed.", float64(x.loaded.Unix())},
+	}
+}
+
+// replace swaps in the analysis of one file and rebuilds the graph
+func (x *analysisIndex) replace(result *analyzer.Result) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	results := make([]*analyzer.Result, 0, len(x.results)+1)
+	for _, existing := range x.results {
+		if existing.FilePath != result.FilePath {
+			results = append(results, existing)
+		}
+	}
+	x.results = append(results, result)
+	x.graph = BuildDependencyGraph(x.results)
+}
+
+// snapshot returns the current graph; graphs are never mutated once built
+func (x *analysisIndex) snapshot() *DependencyGraph {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.graph
+}
+
+// analyzeRequest is the body of POST /analyze. Content, when set, is analyzed
+// in place of the file at Path (e.g., an unsaved editor buffer).
+type analyzeRequest struct {
+	Path    string `json:"path"`
+	Content string `json:"content,omitempty"`
+}
+
+// TestClosure is the response of GET /tests/{name}/closure
+type TestClosure struct {
+	Test      string   `json:"test"` // Node ID of the test (e.g., "test:TestAccSubnet_basic@network")
+	Resources []string `json:"resources"`
+}
+
+// runServeCommand serves analysis queries over HTTP from an in-memory index
+func runServeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	index := &analysisIndex{}
+	index.metrics = newServerMetrics(index.gauges)
+	index.source.register(fs)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	ui := fs.Bool("ui", false, "Serve an interactive dependency graph at / and its data at /graph")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if index.source.Dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir parameter is required")
+		return 1
+	}
+
+	opts, err := index.source.analyzeOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	index.opts = opts
+	if err := index.load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Indexed %d files from %s; listening on %s\n", len(index.results), index.source.Dir, *addr)
+
+	server := &http.Server{Addr: *addr, Handler: newServeMux(index, fs, *ui), ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// newServeMux routes the server endpoints, with the graph visualization when ui is set
+func newServeMux(index *analysisIndex, fs *flag.FlagSet, ui bool) *http.ServeMux {
+	provenance := func() *Provenance {
+		return newProvenance(fs, index.source.root())
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", index.metrics)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, index.metrics.instrument(pattern, handler))
+	}
+
+	handle("/analyze", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+			return
+		}
+		var request analyzeRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, serveMaxBody)).Decode(&request); err != nil {
+			writeServeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %v", err))
+			return
+		}
+		if request.Path == "" {
+			writeServeError(w, http.StatusBadRequest, fmt.Errorf("path is required"))
+			return
+		}
+
+		path, err := index.requestPath(request.Path)
+		if err != nil {
+			writeServeError(w, http.StatusForbidden, err)
+			return
+		}
+		content := []byte(request.Content)
+		if request.Content == "" {
+			if content, err = os.ReadFile(path); err != nil {
+				writeServeError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+		}
+		result, err := index.analyze(path, content)
+		if err != nil {
+			writeServeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		// ?update=1 makes later queries see the new analysis
+		if r.URL.Query().Get("update") == "1" {
+			index.replace(result)
+		}
+		writeServeDocument(w, provenance(), result)
+	})
+
+	handle("/impact", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET"))
+			return
+		}
+		resources := queryList(r.URL.Query(), "resource")
+		if len(resources) == 0 {
+			writeServeError(w, http.StatusBadRequest, fmt.Errorf("resource parameter is required"))
+			return
+		}
+		graph := index.snapshot()
+		selection := &SelectionResult{Resources: resources, Tests: SelectImpactedTests(graph, resources, nil)}
+		AttachTestEnvVars(graph, selection.Tests)
+		AttachTestFootprints(graph, selection.Tests)
+		ScoreTestRisk(graph, selection.Tests, nil)
+		orderSelection(selection.Tests, "risk")
+		writeServeDocument(w, provenance(), selection)
+	})
+
+	// /tests/{name}/closure
+	handle("/tests/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET"))
+			return
+		}
+		name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/tests/"), "/closure")
+		if bare, _, _ := strings.Cut(name, "@"); !ok || bare == "" || strings.Contains(bare, "/") {
+			writeServeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %s", r.URL.Path))
+			return
+		}
+		graph := index.snapshot()
+		// A bare name, a name qualified with its package
+		// ("TestX@internal/services/network"), or a node ID, resolved as the
+		// graph commands resolve -node
+		query := name
+		if strings.Contains(name, "@") && !strings.HasPrefix(name, string(NodeTest)+":") {
+			query = testNodeID(name)
+		}
+		node, err := graph.ResolveNode(query)
+		var ambiguous *AmbiguousNodeError
+		if errors.As(err, &ambiguous) {
+			var tests []string
+			for _, id := range ambiguous.Candidates {
+				if graph.Nodes[id].Kind == NodeTest {
+					tests = append(tests, id)
+				}
+			}
+			if len(tests) > 1 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				writeJSON(w, map[string]interface{}{"error": fmt.Sprintf("test function %q is ambiguous, use one of: %s", name, strings.Join(tests, ", ")), "candidates": tests})
+				return
+			}
+			if len(tests) == 1 {
+				node, err = graph.Nodes[tests[0]], nil
+			}
+		}
+		if err != nil || node.Kind != NodeTest {
+			writeServeError(w, http.StatusNotFound, fmt.Errorf("test function %q not found", name))
+			return
+		}
+		writeServeDocument(w, provenance(), &TestClosure{Test: node.ID, Resources: graph.ResourceClosure(node.ID)})
+	})
+
+	handle("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+			return
+		}
+		if err := index.load(); err != nil {
+			writeServeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		index.mu.RLock()
+		status := map[string]interface{}{"files": len(index.results), "loaded_at": index.loaded}
+		index.mu.RUnlock()
+		writeServeDocument(w, provenance(), status)
+	})
+
+	if ui {
+		registerUI(mux, index, handle, provenance)
+	}
+	return mux
+}
+
+// queryList collects comma-separated and repeated values of a query parameter
+func queryList(query url.Values, name string) []string {
+	var list stringList
+	for _, value := range query[name] {
+		list.Set(value)
+	}
+	return list
+}
+
+// writeServeDocument writes a JSON response with the provenance header
+func writeServeDocument(w http.ResponseWriter, provenance *Provenance, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeDocument(w, provenance, v); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: writing response: %v\n", err)
+	}
+}
+
+// writeServeError writes a JSON error response
+func writeServeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, map[string]string{"error": err.Error()})
+}
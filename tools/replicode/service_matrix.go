@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ServiceMatrix counts references from tests/templates in one service to templates owned by another
+type ServiceMatrix struct {
+	Services []string            `json:"services"` // Row and column order
+	Counts   [][]int             `json:"counts"`   // Counts[i][j] = references from Services[i] to Services[j]
+	Cells    []ServiceMatrixCell `json:"cells"`
+}
+
+// ServiceMatrixCell is one non-zero entry of the matrix
+type ServiceMatrixCell struct {
+	SourceService string `json:"source_service"`
+	TargetService string `json:"target_service"`
+	References    int    `json:"references"`     // Step Config references plus template calls
+	StepRefs      int    `json:"step_refs"`      // TestStep Config references
+	TemplateCalls int    `json:"template_calls"` // Template-to-template calls
+	Tests         int    `json:"tests"`          // Distinct runnable tests of the source service reaching a template of the target
+}
+
+// ComputeServiceMatrix builds the service×service reference matrix. Services
+// come from the resolved graph endpoints, so references whose target lives in
+// another file (unresolvable in single-file TargetService fields) still count.
+// Test counts follow each test's closure, so a cell can count tests without
+// counting references when the dependency is only transitive.
+func ComputeServiceMatrix(graph *DependencyGraph) *ServiceMatrix {
+	cells := map[[2]string]*ServiceMatrixCell{}
+	services := map[string]bool{}
+	cellOf := func(source, target string) *ServiceMatrixCell {
+		key := [2]string{source, target}
+		if cells[key] == nil {
+			cells[key] = &ServiceMatrixCell{SourceService: source, TargetService: target}
+		}
+		return cells[key]
+	}
+
+	for _, node := range graph.Nodes {
+		if node.Kind == NodeService {
+			services[node.Name] = true
+		}
+	}
+
+	for from := range graph.Nodes {
+		for _, edge := range graph.OutEdges(from) {
+			if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+				continue
+			}
+			source, target := graph.Nodes[edge.From], graph.Nodes[edge.To]
+			if source == nil || target == nil || source.Service == "" || target.Service == "" {
+				continue
+			}
+
+			c := cellOf(source.Service, target.Service)
+			c.References++
+			if edge.Kind == EdgeStepRef {
+				c.StepRefs++
+			} else {
+				c.TemplateCalls++
+			}
+		}
+	}
+
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTest || node.Service == "" || !isRunnableTest(node.Name) {
+			continue
+		}
+		reached := map[string]bool{}
+		for reachedID := range graph.Reachable(id, DirectionOut, 0) {
+			if target := graph.Nodes[reachedID]; target != nil && target.Kind == NodeTemplate && target.Service != "" && !reached[target.Service] {
+				reached[target.Service] = true
+				cellOf(node.Service, target.Service).Tests++
+			}
+		}
+	}
+
+	matrix := &ServiceMatrix{Services: []string{}, Cells: []ServiceMatrixCell{}}
+	for service := range services {
+		matrix.Services = append(matrix.Services, service)
+	}
+	sort.Strings(matrix.Services)
+
+	index := map[string]int{}
+	for i, service := range matrix.Services {
+		index[service] = i
+	}
+
+	matrix.Counts = make([][]int, len(matrix.Services))
+	for i := range matrix.Counts {
+		matrix.Counts[i] = make([]int, len(matrix.Services))
+	}
+	for _, cell := range cells {
+		matrix.Counts[index[cell.SourceService]][index[cell.TargetService]] = cell.References
+		matrix.Cells = append(matrix.Cells, *cell)
+	}
+
+	sort.Slice(matrix.Cells, func(i, j int) bool {
+		if matrix.Cells[i].SourceService != matrix.Cells[j].SourceService {
+			return matrix.Cells[i].SourceService < matrix.Cells[j].SourceService
+		}
+		return matrix.Cells[i].TargetService < matrix.Cells[j].TargetService
+	})
+
+	return matrix
+}
+
+// writeServiceEdges writes the non-zero cells as a weighted edge list, one
+// source,target row per cell under a header, for graph and BI tools
+func writeServiceEdges(w io.Writer, matrix *ServiceMatrix) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"source", "target", "references", "step_refs", "template_calls", "tests"})
+	for _, cell := range matrix.Cells {
+		out.Write([]string{cell.SourceService, cell.TargetService, strconv.Itoa(cell.References),
+			strconv.Itoa(cell.StepRefs), strconv.Itoa(cell.TemplateCalls), strconv.Itoa(cell.Tests)})
+	}
+	out.Flush()
+	return out.Error()
+}
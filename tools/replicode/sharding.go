@@ -0,0 +1,162 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TestShard is one group of selected tests meant to run on a single CI agent
+type TestShard struct {
+	Index            int      `json:"index"` // 1-based shard number
+	Tests            []string `json:"tests"`
+	EstimatedSeconds int      `json:"estimated_seconds"`
+	RunPattern       string   `json:"run_pattern"`          // Value for go test -run
+	Quarantine       bool     `json:"quarantine,omitempty"` // Holds known-flaky tests (select -flaky-mode quarantine)
+}
+
+// shardUnit is a set of tests that must run in the same shard
+type shardUnit struct {
+	tests   []string
+	seconds int
+	risk    float64
+}
+
+// ShardTests partitions the selected tests into n shards balanced by estimated
+// duration. Tests linked through sequential references (an entry point and
+// the tests it runs in sequence) always land in the same shard, as do tests
+// sharing a singleton dependency (see MutualExclusions). A selected test that
+// a selected entry point already runs in sequence is left to that entry point,
+// so it neither runs twice nor counts twice towards its shard's duration.
+func ShardTests(graph *DependencyGraph, tests []SelectedTest, n int) []TestShard {
+	if n < 1 {
+		n = 1
+	}
+
+	tests = dropSequencedTests(graph, tests)
+	units := mergeExclusiveUnits(graph, sequentialUnits(graph, tests))
+
+	// Longest-processing-time first: place the biggest unit on the lightest shard
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].seconds != units[j].seconds {
+			return units[i].seconds > units[j].seconds
+		}
+		return units[i].tests[0] < units[j].tests[0]
+	})
+
+	shards := make([]TestShard, n)
+	for i := range shards {
+		shards[i] = TestShard{Index: i + 1, Tests: []string{}}
+	}
+	for _, unit := range units {
+		lightest := 0
+		for i := range shards {
+			if shards[i].EstimatedSeconds < shards[lightest].EstimatedSeconds {
+				lightest = i
+			}
+		}
+		shards[lightest].Tests = append(shards[lightest].Tests, unit.tests...)
+		shards[lightest].EstimatedSeconds += unit.seconds
+	}
+
+	for i := range shards {
+		sort.Strings(shards[i].Tests)
+		shards[i].RunPattern = runPattern(shards[i].Tests)
+	}
+
+	return shards
+}
+
+// dropSequencedTests removes the tests a selected entry point reaches through
+// sequential references. Tests that only reach each other (a cycle with no
+// entry point outside it) are all kept.
+func dropSequencedTests(graph *DependencyGraph, tests []SelectedTest) []SelectedTest {
+	reaches := map[string]map[string]bool{}
+	for _, test := range tests {
+		reached := map[string]bool{}
+		for reachedID := range graph.reachableVia(testNodeID(test.Name), EdgeSequentialRef) {
+			if node := graph.Nodes[reachedID]; node != nil {
+				reached[node.Name] = true
+			}
+		}
+		reaches[test.Name] = reached
+	}
+	sequenced := func(name string) bool {
+		for other, reached := range reaches {
+			if other != name && reached[name] && !reaches[name][other] {
+				return true
+			}
+		}
+		return false
+	}
+
+	kept := make([]SelectedTest, 0, len(tests))
+	for _, test := range tests {
+		if !sequenced(test.Name) {
+			kept = append(kept, test)
+		}
+	}
+	return kept
+}
+
+// sequentialUnits groups the selected tests into units that must run together:
+// tests linked through sequential references are unioned into one unit
+func sequentialUnits(graph *DependencyGraph, tests []SelectedTest) []*shardUnit {
+	parent := map[string]string{}
+	byName := map[string]SelectedTest{}
+	for _, test := range tests {
+		parent[test.Name] = test.Name
+		byName[test.Name] = test
+	}
+	var find func(name string) string
+	find = func(name string) string {
+		if parent[name] != name {
+			parent[name] = find(parent[name])
+		}
+		return parent[name]
+	}
+	for _, test := range tests {
+		id := testNodeID(test.Name)
+		for reachedID := range graph.reachableVia(id, EdgeSequentialRef) {
+			reached := graph.Nodes[reachedID]
+			if reached == nil {
+				continue
+			}
+			if _, selected := parent[reached.Name]; selected {
+				parent[find(reached.Name)] = find(test.Name)
+			}
+		}
+	}
+
+	unitsByRoot := map[string]*shardUnit{}
+	var units []*shardUnit
+	for _, test := range tests {
+		root := find(test.Name)
+		unit := unitsByRoot[root]
+		if unit == nil {
+			unit = &shardUnit{}
+			unitsByRoot[root] = unit
+			units = append(units, unit)
+		}
+		unit.tests = append(unit.tests, test.Name)
+		unit.seconds += byName[test.Name].EstimatedSeconds
+		unit.risk += byName[test.Name].RiskScore
+	}
+
+	for _, unit := range units {
+		sort.Strings(unit.tests)
+	}
+	return units
+}
+
+// runPattern builds an anchored go test -run expression matching exactly the given tests
+func runPattern(tests []string) string {
+	if len(tests) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(tests))
+	for i, test := range tests {
+		quoted[i] = regexp.QuoteMeta(test)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// splitFile is one file a test file is split into: the declarations owned by
+// a struct, or, for the original file, those owned by none or by the struct
+// the file is named after
+type splitFile struct {
+	Struct string
+	Rel    string
+	decls  []ast.Decl
+}
+
+// splitPlan is how a test file is split
+type splitPlan struct {
+	Rel     string
+	Files   []*splitFile      // New files, by struct
+	Kept    []string          // Functions kept in the original file because no single struct owns them
+	keep    *splitFile        // What remains in the original file
+	owner   map[string]string // Test function -> struct
+	structs map[string]bool   // Structs declared in the file that own methods or tests
+}
+
+// splitFileName is the conventional test file name for a struct's tests:
+// virtual_network_resource_test.go for VirtualNetworkResource and
+// storage_account_data_source_test.go for StorageAccountDataSource
+func splitFileName(structName string) string {
+	if name, ok := strings.CutSuffix(structName, "DataSource"); ok && name != "" {
+		return snakeCase(name) + "_data_source_test.go"
+	}
+	if name, ok := strings.CutSuffix(structName, "Resource"); ok && name != "" {
+		return snakeCase(name) + "_resource_test.go"
+	}
+	return snakeCase(structName) + "_test.go"
+}
+
+// snakeCase converts a Go identifier to snake case, keeping acronyms
+// together (e.g., PrivateDNSZone -> private_dns_zone)
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var out strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				out.WriteByte('_')
+			}
+		}
+		out.WriteRune(unicode.ToLower(r))
+	}
+	return out.String()
+}
+
+// planSplit decides which struct owns each top-level declaration of a file:
+// a struct owns its type declaration and methods, the tests the analysis
+// records as instantiating it, other tests whose steps use only its
+// templates, the entry points whose sequential tests it all owns, and,
+// failing those, the tests named TestAcc<Name>_ after it. Files with
+// fewer than two owning structs are not split (nil plan).
+func planSplit(graph *DependencyGraph, result *analyzer.Result, file *ast.File) *splitPlan {
+	structs := map[string]bool{}
+	for _, fn := range result.Functions {
+		if fn.ReceiverType != "" {
+			structs[fn.ReceiverType] = true
+		}
+	}
+	declared := map[string]bool{}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE && len(gen.Specs) == 1 {
+			if spec := gen.Specs[0].(*ast.TypeSpec); structs[spec.Name.Name] {
+				declared[spec.Name.Name] = true
+			}
+		}
+	}
+	if len(declared) < 2 {
+		return nil
+	}
+
+	plan := &splitPlan{Rel: result.FilePath, owner: map[string]string{}, structs: declared}
+	tests := map[string]bool{}
+	for _, fn := range result.Functions {
+		if !fn.IsTestFunc {
+			continue
+		}
+		// The analysis records the struct a test instantiates as its receiver
+		tests[fn.FunctionName] = true
+		if declared[fn.ReceiverType] {
+			plan.owner[fn.FunctionName] = fn.ReceiverType
+		}
+	}
+
+	// Other tests by the templates their steps use, then entry points by the
+	// tests they run in sequence, until no more are resolved
+	sequential := map[string][]string{}
+	stepStructs := map[string]map[string]bool{}
+	for name := range tests {
+		if _, done := plan.owner[name]; done {
+			continue
+		}
+		for _, edge := range graph.OutEdges(testNodeID(name)) {
+			if edge.File != result.FilePath {
+				continue
+			}
+			switch edge.Kind {
+			case EdgeStepRef:
+				if structName, _, _ := strings.Cut(graph.Nodes[edge.To].Name, "."); declared[structName] {
+					if stepStructs[name] == nil {
+						stepStructs[name] = map[string]bool{}
+					}
+					stepStructs[name][structName] = true
+				}
+			case EdgeSequentialRef:
+				sequential[name] = append(sequential[name], graph.Nodes[edge.To].Name)
+			}
+		}
+	}
+	for name, owners := range stepStructs {
+		if len(owners) == 1 {
+			for structName := range owners {
+				plan.owner[name] = structName
+			}
+		}
+	}
+	for resolved := true; resolved; {
+		resolved = false
+		for name, targets := range sequential {
+			if _, done := plan.owner[name]; done || stepStructs[name] != nil {
+				continue
+			}
+			owner := ""
+			for _, target := range targets {
+				o := plan.owner[target]
+				if o == "" || (owner != "" && o != owner) {
+					owner = ""
+					break
+				}
+				owner = o
+			}
+			if owner != "" {
+				plan.owner[name] = owner
+				resolved = true
+			}
+		}
+	}
+	for name := range tests {
+		if _, done := plan.owner[name]; done || stepStructs[name] != nil || sequential[name] != nil {
+			continue
+		}
+		for structName := range declared {
+			prefix := strings.TrimSuffix(strings.TrimSuffix(structName, "Resource"), "DataSource")
+			if strings.HasPrefix(name, "TestAcc"+prefix+"_") || strings.HasPrefix(name, "testAcc"+prefix+"_") {
+				plan.owner[name] = structName
+			}
+		}
+	}
+	for name := range tests {
+		if _, done := plan.owner[name]; !done {
+			plan.Kept = append(plan.Kept, name)
+		}
+	}
+	sort.Strings(plan.Kept)
+
+	// The struct the file is named after stays in it
+	dir, base := path.Split(result.FilePath)
+	plan.keep = &splitFile{Rel: result.FilePath}
+	files := map[string]*splitFile{}
+	for _, decl := range file.Decls {
+		structName := plan.declOwner(decl)
+		if structName == "" || splitFileName(structName) == base {
+			plan.keep.decls = append(plan.keep.decls, decl)
+			continue
+		}
+		if files[structName] == nil {
+			files[structName] = &splitFile{Struct: structName, Rel: dir + splitFileName(structName)}
+			plan.Files = append(plan.Files, files[structName])
+		}
+		files[structName].decls = append(files[structName].decls, decl)
+	}
+	sort.Slice(plan.Files, func(i, j int) bool { return plan.Files[i].Rel < plan.Files[j].Rel })
+	return plan
+}
+
+// declOwner returns the struct owning a top-level declaration, or ""
+func (p *splitPlan) declOwner(decl ast.Decl) string {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil {
+			if receiver := receiverTypeName(d); p.structs[receiver] {
+				return receiver
+			}
+			return ""
+		}
+		return p.owner[d.Name.Name]
+	case *ast.GenDecl:
+		if d.Tok == token.TYPE && len(d.Specs) == 1 {
+			if name := d.Specs[0].(*ast.TypeSpec).Name.Name; p.structs[name] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// renderSplitFile builds a file from the original's header comments, package
+// clause, the imports its declarations use, and the declarations
+func renderSplitFile(fset *token.FileSet, file *ast.File, src []byte, decls []ast.Decl) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write(src[:fset.Position(file.Package).Offset]) // Copyright header and package doc
+	fmt.Fprintf(&out, "package %s\n\n", file.Name.Name)
+
+	used := usedImports(decls)
+	var std, other []string
+	for _, spec := range file.Imports {
+		name := importSpecName(spec)
+		if name == "_" || name == "." || !used[name] {
+			continue
+		}
+		text := string(src[fset.Position(spec.Pos()).Offset:fset.Position(spec.End()).Offset])
+		if p, _ := strconv.Unquote(spec.Path.Value); standardImport(p) {
+			std = append(std, text)
+		} else {
+			other = append(other, text)
+		}
+	}
+	if len(std)+len(other) > 0 {
+		out.WriteString("import (\n" + importGroups(std, other) + ")\n")
+	}
+	for _, decl := range decls {
+		out.WriteString("\n" + declText(fset, src, decl) + "\n")
+	}
+	return format.Source(out.Bytes())
+}
+
+// renderKeptFile returns the original file without the declarations that
+// moved and the imports only they used, or nil when nothing but the package
+// clause and imports would remain
+func renderKeptFile(fset *token.FileSet, file *ast.File, src []byte, plan *splitPlan) ([]byte, error) {
+	kept := map[ast.Decl]bool{}
+	var remaining []ast.Decl
+	for _, decl := range plan.keep.decls {
+		kept[decl] = true
+		if gen, ok := decl.(*ast.GenDecl); !ok || gen.Tok != token.IMPORT {
+			remaining = append(remaining, decl)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, nil
+	}
+
+	used := usedImports(remaining)
+	var edits []textEdit
+	for _, decl := range file.Decls {
+		if !kept[decl] {
+			edits = append(edits, lineEdit(src, fset.Position(declStart(decl)).Offset, fset.Position(decl.End()).Offset))
+		}
+	}
+	edits = append(edits, pruneImports(fset, file, src, used)...)
+	return format.Source(applyEdits(src, edits))
+}
+
+// runSplitTestsCommand splits test files holding several resources' tests
+// into one file per resource, printing the changes as a patch or writing them
+func runSplitTestsCommand(args []string) int {
+	fs := flag.NewFlagSet("split-tests", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	output := newCodemodOutput(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if err := output.start(source.root()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+	sort.Slice(results, func(i, j int) bool { return results[i].FilePath < results[j].FilePath })
+	root := source.root()
+
+	split, created := 0, 0
+	for _, result := range results {
+		filePath := filepath.Join(root, filepath.FromSlash(result.FilePath))
+		src, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", result.FilePath, err)
+			continue
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filePath, src, parser.ParseComments)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", result.FilePath, err)
+			continue
+		}
+		plan := planSplit(graph, result, file)
+		if plan == nil || len(plan.Files) == 0 {
+			continue
+		}
+
+		outputs := map[string][]byte{}
+		conflict := ""
+		for _, f := range plan.Files {
+			target := filepath.Join(root, filepath.FromSlash(f.Rel))
+			if _, err := os.Stat(target); err == nil {
+				conflict = f.Rel
+				break
+			}
+			out, err := renderSplitFile(fset, file, src, f.decls)
+			if err != nil {
+				conflict = fmt.Sprintf("%s (%v)", f.Rel, err)
+				break
+			}
+			outputs[f.Rel] = out
+		}
+		if conflict != "" {
+			output.left("Left %s: %s already exists", result.FilePath, conflict)
+			continue
+		}
+		kept, err := renderKeptFile(fset, file, src, plan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", result.FilePath, err)
+			continue
+		}
+		for _, name := range plan.Kept {
+			output.left("Kept %s in %s: no single resource owns it", name, result.FilePath)
+		}
+		split++
+		created += len(plan.Files)
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		// The new files first, so a failure doesn't lose the tests moved
+		for _, f := range plan.Files {
+			if err := output.apply(filepath.Join(root, filepath.FromSlash(f.Rel)), f.Rel, nil, outputs[f.Rel], info.Mode().Perm()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+		if err := output.apply(filePath, result.FilePath, src, kept, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	verb := "Split"
+	if !output.writing() {
+		verb = "Would split"
+	}
+	return output.finish("%s %d files into %d new files", verb, split, created)
+}
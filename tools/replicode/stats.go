@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// ServiceStats are the release statistics of one service
+type ServiceStats struct {
+	Service          string `json:"service"`
+	Tests            int    `json:"tests"`
+	Templates        int    `json:"templates"`
+	Steps            int    `json:"steps"`             // TestSteps with a Config field
+	CrossServiceOut  int    `json:"cross_service_out"` // Step references and template calls to other services' templates
+	CrossServiceIn   int    `json:"cross_service_in"`  // Step references and template calls from other services
+	Unresolved       int    `json:"unresolved"`        // References the graph could not attach to a template
+	SequentialGroups int    `json:"sequential_groups"` // Distinct sequential groups of its entry point tests
+}
+
+// StatsReport is the per-service statistics with their totals
+type StatsReport struct {
+	Services []ServiceStats `json:"services"`
+	Total    ServiceStats   `json:"total"` // Service is "TOTAL"; cross-service counts are references, each counted once
+}
+
+// ComputeServiceStats aggregates the tests, templates, steps, cross-service
+// references, unresolved references, and sequential groups of each service
+func ComputeServiceStats(graph *DependencyGraph, results []*analyzer.Result) *StatsReport {
+	stats := map[string]*ServiceStats{}
+	service := func(name string) *ServiceStats {
+		if stats[name] == nil {
+			stats[name] = &ServiceStats{Service: name}
+		}
+		return stats[name]
+	}
+	report := &StatsReport{Services: []ServiceStats{}, Total: ServiceStats{Service: "TOTAL"}}
+
+	for _, node := range graph.Nodes {
+		switch node.Kind {
+		case NodeService:
+			service(node.Name)
+		case NodeTest:
+			if node.Service != "" {
+				service(node.Service).Tests++
+			}
+		case NodeTemplate:
+			if node.Service != "" {
+				service(node.Service).Templates++
+			}
+		}
+	}
+
+	for from := range graph.Nodes {
+		for _, edge := range graph.OutEdges(from) {
+			if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+				continue
+			}
+			source, target := graph.Nodes[edge.From], graph.Nodes[edge.To]
+			if source == nil || target == nil || source.Service == "" || target.Service == "" || source.Service == target.Service {
+				continue
+			}
+			service(source.Service).CrossServiceOut++
+			service(target.Service).CrossServiceIn++
+			report.Total.CrossServiceOut++
+			report.Total.CrossServiceIn++
+		}
+	}
+
+	for _, ref := range graph.Unresolved {
+		if node := graph.Nodes[ref.From]; node != nil && node.Service != "" {
+			service(node.Service).Unresolved++
+		}
+	}
+
+	groups := map[string]map[string]bool{} // Service -> sequential groups
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			if step.SourceService != "" {
+				service(step.SourceService).Steps++
+			}
+		}
+		for _, seq := range result.SequentialReferences {
+			from, _ := graph.resolveFunction(result, "", seq.EntryPointFunction)
+			entry := graph.Nodes[from]
+			if entry == nil || entry.Service == "" || seq.SequentialGroup == "" {
+				continue
+			}
+			name := entry.Service
+			if groups[name] == nil {
+				groups[name] = map[string]bool{}
+			}
+			// Groups are named per entry point, so the same name in two tests is two groups
+			groups[name][seq.EntryPointFunction+"/"+seq.SequentialGroup] = true
+		}
+	}
+	for name, entries := range groups {
+		service(name).SequentialGroups = len(entries)
+	}
+
+	for _, s := range stats {
+		report.Services = append(report.Services, *s)
+		report.Total.Tests += s.Tests
+		report.Total.Templates += s.Templates
+		report.Total.Steps += s.Steps
+		report.Total.Unresolved += s.Unresolved
+		report.Total.SequentialGroups += s.SequentialGroups
+	}
+	sort.Slice(report.Services, func(i, j int) bool { return report.Services[i].Service < report.Services[j].Service })
+	return report
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runStatsCommand prints the per-service summary statistics
+func runStatsCommand(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	report := ComputeServiceStats(BuildDependencyGraph(results), results)
+
+	if *format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "SERVICE\tTESTS\tTEMPLATES\tSTEPS\tCROSS-SVC OUT\tCROSS-SVC IN\tUNRESOLVED\tSEQUENTIAL GROUPS\t")
+		for _, s := range append(report.Services, report.Total) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", s.Service, s.Tests, s.Templates, s.Steps,
+				s.CrossServiceOut, s.CrossServiceIn, s.Unresolved, s.SequentialGroups)
+		}
+		w.Flush()
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// SummaryRow counts what the analysis found in one service's files
+type SummaryRow struct {
+	Service    string
+	Files      int
+	Tests      int
+	Steps      int
+	Templates  int
+	DirectRefs int // Direct resource references
+	Unresolved int // References the graph could not attach to a template
+}
+
+// SummarizeAnalysis counts the tests, steps, templates, direct resource
+// references, and unresolved references of the results by the service of
+// their file, sorted by service, followed by a TOTAL row when there are
+// several services. Files outside any service are counted under "-".
+func SummarizeAnalysis(results []*analyzer.Result, services analyzer.ServicePatterns) []SummaryRow {
+	rows := map[string]*SummaryRow{}
+	row := func(service string) *SummaryRow {
+		if service == "" {
+			service = "-"
+		}
+		if rows[service] == nil {
+			rows[service] = &SummaryRow{Service: service}
+		}
+		return rows[service]
+	}
+
+	for _, result := range results {
+		r := row(resultService(result, services))
+		r.Files++
+		for _, fn := range result.Functions {
+			switch {
+			case fn.IsTestFunc:
+				r.Tests++
+			case fn.ReceiverType != "":
+				r.Templates++
+			}
+		}
+		r.Steps += len(result.TestSteps)
+		r.DirectRefs += len(result.DirectResourceRefs)
+	}
+	graph := BuildDependencyGraph(results)
+	for _, ref := range graph.Unresolved {
+		service := ""
+		if node := graph.Nodes[ref.From]; node != nil {
+			service = node.Service
+		}
+		row(service).Unresolved++
+	}
+
+	summary := make([]SummaryRow, 0, len(rows)+1)
+	total := SummaryRow{Service: "TOTAL"}
+	for _, r := range rows {
+		summary = append(summary, *r)
+		total.Files += r.Files
+		total.Tests += r.Tests
+		total.Steps += r.Steps
+		total.Templates += r.Templates
+		total.DirectRefs += r.DirectRefs
+		total.Unresolved += r.Unresolved
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Service < summary[j].Service })
+	if len(summary) > 1 {
+		summary = append(summary, total)
+	}
+	return summary
+}
+
+// resultService is the service of a result's file, as its functions and
+// steps record it: result paths may be relative to a root below the
+// internal/services directory the service is read from
+func resultService(result *analyzer.Result, services analyzer.ServicePatterns) string {
+	if len(result.Functions) > 0 {
+		return result.Functions[0].ServiceName
+	}
+	if len(result.TestSteps) > 0 {
+		return result.TestSteps[0].SourceService
+	}
+	return services.ServiceName(result.FilePath)
+}
+
+// writeSummaryTable writes the summary rows as right-aligned columns
+func writeSummaryTable(w io.Writer, rows []SummaryRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SERVICE\tFILES\tTESTS\tSTEPS\tTEMPLATES\tDIRECT REFS\tUNRESOLVED\t")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n", r.Service, r.Files, r.Tests, r.Steps, r.Templates, r.DirectRefs, r.Unresolved)
+	}
+	return tw.Flush()
+}
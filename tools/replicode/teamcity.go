@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// teamCityEscaper escapes attribute values in TeamCity service messages
+var teamCityEscaper = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+)
+
+// writeTeamCityMessage writes one ##teamcity[...] service message. Attributes
+// are given as name/value pairs and keep their order.
+func writeTeamCityMessage(w io.Writer, name string, attrs ...string) {
+	var b strings.Builder
+	b.WriteString("##teamcity[")
+	b.WriteString(name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %s='%s'", attrs[i], teamCityEscaper.Replace(attrs[i+1]))
+	}
+	b.WriteString("]")
+	fmt.Fprintln(w, b.String())
+}
+
+// writeTeamCityStatistic reports a build statistic value
+func writeTeamCityStatistic(w io.Writer, key string, value int) {
+	writeTeamCityMessage(w, "buildStatisticValue", "key", "terracorder."+key, "value", fmt.Sprint(value))
+}
+
+// writeSelectionTeamCity reports a selection as TeamCity service messages:
+// one message per selected test, build statistics for the counts and
+// estimated duration, and a run pattern parameter for a follow-up build step
+func writeSelectionTeamCity(w io.Writer, provenance *Provenance, selection *SelectionResult) {
+	targets := append(append([]string{}, selection.Resources...), selection.Changes...)
+	writeTeamCityMessage(w, "blockOpened", "name", "Terracorder selection", "description", strings.Join(targets, ", "))
+	writeTeamCityMessage(w, "message", "text", provenance.String())
+
+	names := make([]string, 0, len(selection.Tests))
+	totalSeconds := 0
+	for _, test := range selection.Tests {
+		names = append(names, test.Name)
+		totalSeconds += test.EstimatedSeconds
+		text := fmt.Sprintf("Selected %s (%s, risk %.3f, ~%s)", test.Name, test.Service, test.RiskScore, formatSeconds(test.EstimatedSeconds))
+		status := "NORMAL"
+		if test.Flaky {
+			text += " [flaky]"
+			status = "WARNING"
+		}
+		writeTeamCityMessage(w, "message", "text", text, "status", status)
+	}
+
+	writeTeamCityStatistic(w, "selectedTests", len(selection.Tests))
+	writeTeamCityStatistic(w, "estimatedSeconds", totalSeconds)
+	if len(selection.Shards) > 0 {
+		writeTeamCityStatistic(w, "shards", len(selection.Shards))
+	}
+	if selection.Budget != nil {
+		writeTeamCityStatistic(w, "deferredTests", len(selection.Budget.Deferred))
+	}
+	writeTeamCityMessage(w, "setParameter", "name", "env.TERRACORDER_RUN_PATTERN", "value", runPattern(names))
+
+	writeTeamCityMessage(w, "blockClosed", "name", "Terracorder selection")
+}
+
+// writePlanTeamCity reports an execution plan as TeamCity service messages
+func writePlanTeamCity(w io.Writer, provenance *Provenance, plan *ExecutionPlan) {
+	writeTeamCityMessage(w, "blockOpened", "name", "Terracorder execution plan")
+	writeTeamCityMessage(w, "message", "text", provenance.String())
+
+	items := 0
+	for _, stage := range plan.Stages {
+		items += len(stage.Items)
+		for _, item := range stage.Items {
+			text := fmt.Sprintf("Stage %d: %s (~%s)", stage.Index, item.RunPattern, formatSeconds(item.EstimatedSeconds))
+			if item.Pool != "" {
+				text += " [pool " + item.Pool + "]"
+			}
+			writeTeamCityMessage(w, "message", "text", text)
+		}
+	}
+
+	writeTeamCityStatistic(w, "planStages", len(plan.Stages))
+	writeTeamCityStatistic(w, "planItems", items)
+	writeTeamCityStatistic(w, "estimatedSeconds", plan.EstimatedSeconds)
+
+	writeTeamCityMessage(w, "blockClosed", "name", "Terracorder execution plan")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+)
+
+// TemplateReuse is a template consumed by tests or templates outside its own service
+type TemplateReuse struct {
+	Template *GraphNode `json:"template"`
+
+	ExternalConsumers int      `json:"external_consumers"` // Distinct tests and templates of other services referencing it directly
+	ExternalTests     int      `json:"external_tests"`     // Of which tests, through a TestStep Config
+	ExternalTemplates int      `json:"external_templates"` // Of which templates, through fmt.Sprintf
+	ConsumerServices  []string `json:"consumer_services"`  // Services of the external consumers
+	InternalConsumers int      `json:"internal_consumers"` // Distinct consumers in its own service
+}
+
+// ComputeTemplateReuse finds the templates referenced from other services,
+// ranked by their external consumers. Services are those of the resolved
+// graph endpoints, as in the service matrix, since the per-file TargetService
+// of a reference to a template in another file is empty.
+func ComputeTemplateReuse(graph *DependencyGraph) []TemplateReuse {
+	reuse := []TemplateReuse{}
+	for id, node := range graph.Nodes {
+		if node.Kind != NodeTemplate || node.Service == "" {
+			continue
+		}
+
+		entry := TemplateReuse{Template: node, ConsumerServices: []string{}}
+		consumers := map[string]bool{}
+		services := map[string]bool{}
+		for _, edge := range graph.InEdges(id) {
+			if edge.Kind != EdgeStepRef && edge.Kind != EdgeTemplateCall {
+				continue
+			}
+			from := graph.Nodes[edge.From]
+			if from == nil || from.Service == "" || consumers[edge.From] {
+				continue
+			}
+			consumers[edge.From] = true
+
+			if from.Service == node.Service {
+				entry.InternalConsumers++
+				continue
+			}
+			entry.ExternalConsumers++
+			if from.Kind == NodeTest {
+				entry.ExternalTests++
+			} else {
+				entry.ExternalTemplates++
+			}
+			services[from.Service] = true
+		}
+		if entry.ExternalConsumers == 0 {
+			continue
+		}
+
+		for service := range services {
+			entry.ConsumerServices = append(entry.ConsumerServices, service)
+		}
+		sort.Strings(entry.ConsumerServices)
+		reuse = append(reuse, entry)
+	}
+
+	sort.Slice(reuse, func(i, j int) bool {
+		if reuse[i].ExternalConsumers != reuse[j].ExternalConsumers {
+			return reuse[i].ExternalConsumers > reuse[j].ExternalConsumers
+		}
+		if len(reuse[i].ConsumerServices) != len(reuse[j].ConsumerServices) {
+			return len(reuse[i].ConsumerServices) > len(reuse[j].ConsumerServices)
+		}
+		return reuse[i].Template.ID < reuse[j].Template.ID
+	})
+	return reuse
+}
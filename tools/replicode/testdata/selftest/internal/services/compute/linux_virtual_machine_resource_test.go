@@ -0,0 +1,127 @@
+package compute_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance/check"
+	"github.com/hashicorp/terraform-provider-azurerm/internal/services/network"
+)
+
+// Cross-service templates through a package-qualified struct literal,
+// environment-gated tests, and an aliased provider in a second subscription
+
+type LinuxVirtualMachineResource struct{}
+
+func TestAccLinuxVirtualMachine_basic(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_linux_virtual_machine", "test")
+	r := LinuxVirtualMachineResource{}
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.basic(data),
+			Check: acceptance.ComposeTestCheckFunc(
+				check.That(data.ResourceName).ExistsInAzure(r),
+			),
+		},
+		data.ImportStep("admin_password"),
+	})
+}
+
+func TestAccLinuxVirtualMachine_imageFromGallery(t *testing.T) {
+	if os.Getenv("ARM_TEST_GALLERY_IMAGE_ID") == "" {
+		t.Skip("ARM_TEST_GALLERY_IMAGE_ID is not set")
+	}
+	data := acceptance.BuildTestData(t, "azurerm_linux_virtual_machine", "test")
+	r := LinuxVirtualMachineResource{}
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.imageFromGallery(data, os.Getenv("ARM_TEST_GALLERY_IMAGE_ID")),
+		},
+	})
+}
+
+func TestAccLinuxVirtualMachine_otherSubscription(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_linux_virtual_machine", "test")
+	r := LinuxVirtualMachineResource{}
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.otherSubscription(data),
+		},
+	})
+}
+
+func (LinuxVirtualMachineResource) template(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_network_interface" "test" {
+  name                = "acctestnic-%d"
+  location            = azurerm_resource_group.test.location
+  resource_group_name = azurerm_resource_group.test.name
+
+  ip_configuration {
+    name                          = "internal"
+    subnet_id                     = azurerm_subnet.test.id
+    private_ip_address_allocation = "Dynamic"
+  }
+}
+`, network.SubnetResource{}.basic(data), data.RandomInteger)
+}
+
+func (r LinuxVirtualMachineResource) basic(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_linux_virtual_machine" "test" {
+  name                  = "acctestVM-%d"
+  resource_group_name   = azurerm_resource_group.test.name
+  location              = azurerm_resource_group.test.location
+  size                  = "Standard_F2"
+  admin_username        = "adminuser"
+  network_interface_ids = [azurerm_network_interface.test.id]
+}
+`, r.template(data), data.RandomInteger)
+}
+
+func (r LinuxVirtualMachineResource) imageFromGallery(data acceptance.TestData, imageID string) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_linux_virtual_machine" "test" {
+  name                  = "acctestVM-%d"
+  resource_group_name   = azurerm_resource_group.test.name
+  location              = azurerm_resource_group.test.location
+  size                  = "Standard_F2"
+  admin_username        = "adminuser"
+  source_image_id       = "%s"
+  network_interface_ids = [azurerm_network_interface.test.id]
+}
+`, r.template(data), data.RandomInteger, imageID)
+}
+
+func (r LinuxVirtualMachineResource) otherSubscription(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+provider "azurerm" {
+  alias           = "alt"
+  subscription_id = "%s"
+  features {}
+}
+
+%s
+
+resource "azurerm_linux_virtual_machine" "test" {
+  provider              = azurerm.alt
+  name                  = "acctestVM-%d"
+  resource_group_name   = azurerm_resource_group.test.name
+  location              = azurerm_resource_group.test.location
+  size                  = "Standard_F2"
+  admin_username        = "adminuser"
+  network_interface_ids = [azurerm_network_interface.test.id]
+}
+`, data.Client().SubscriptionIDAlt, r.template(data), data.RandomInteger)
+}
@@ -0,0 +1,125 @@
+package network_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance"
+	"github.com/hashicorp/terraform-provider-azurerm/internal/acceptance/check"
+)
+
+// Templates of another file called through a struct literal, a step whose
+// config is assigned to a variable first, and sequential sub-tests
+
+type SubnetResource struct{}
+
+func TestAccSubnet_basic(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_subnet", "test")
+	r := SubnetResource{}
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.basic(data),
+			Check: acceptance.ComposeTestCheckFunc(
+				check.That(data.ResourceName).ExistsInAzure(r),
+			),
+		},
+		data.ImportStep(),
+	})
+}
+
+func TestAccSubnet_updated(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_subnet", "test")
+	r := SubnetResource{}
+	config := r.withServiceEndpoints(data)
+
+	data.ResourceTest(t, r, []acceptance.TestStep{
+		{
+			Config: config,
+		},
+		data.ImportStep(),
+	})
+}
+
+func TestAccSubnet_sequential(t *testing.T) {
+	acceptance.RunTestsInSequence(t, map[string]map[string]func(t *testing.T){
+		"delegation": {
+			"basic":   testAccSubnet_delegation,
+			"updated": testAccSubnet_delegationUpdated,
+		},
+	})
+}
+
+func testAccSubnet_delegation(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_subnet", "test")
+	r := SubnetResource{}
+
+	data.ResourceSequentialTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.delegation(data),
+		},
+		data.ImportStep(),
+	})
+}
+
+func testAccSubnet_delegationUpdated(t *testing.T) {
+	data := acceptance.BuildTestData(t, "azurerm_subnet", "test")
+	r := SubnetResource{}
+
+	data.ResourceSequentialTest(t, r, []acceptance.TestStep{
+		{
+			Config: r.delegation(data),
+		},
+		{
+			Config: r.basic(data),
+		},
+	})
+}
+
+func (SubnetResource) basic(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_subnet" "test" {
+  name                 = "internal"
+  resource_group_name  = azurerm_resource_group.test.name
+  virtual_network_name = azurerm_virtual_network.test.name
+  address_prefixes     = ["10.0.2.0/24"]
+}
+`, VirtualNetworkResource{}.basic(data))
+}
+
+func (r SubnetResource) withServiceEndpoints(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_subnet" "test" {
+  name                 = "internal"
+  resource_group_name  = azurerm_resource_group.test.name
+  virtual_network_name = azurerm_virtual_network.test.name
+  address_prefixes     = ["10.0.2.0/24"]
+  service_endpoints    = ["Microsoft.Sql", "Microsoft.Storage"]
+}
+`, VirtualNetworkResource{}.basic(data))
+}
+
+func (SubnetResource) delegation(data acceptance.TestData) string {
+	return fmt.Sprintf(`
+%s
+
+resource "azurerm_subnet" "test" {
+  name                 = "internal"
+  resource_group_name  = azurerm_resource_group.test.name
+  virtual_network_name = azurerm_virtual_network.test.name
+  address_prefixes     = ["10.0.2.0/24"]
+
+  delegation {
+    name = "first"
+
+    service_delegation {
+      name = "Microsoft.ContainerInstance/containerGroups"
+    }
+  }
+}
+`, VirtualNetworkResource{}.basic(data))
+}
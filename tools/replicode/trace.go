@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// spanTrace collects the file spans of an analysis run and exports them as
+// one OpenTelemetry trace, in the OTLP JSON encoding. The target is a file,
+// which gets one trace per line (the format the OpenTelemetry Collector's
+// otlpjsonfile receiver reads), or the http(s) URL of an OTLP/HTTP traces
+// endpoint. A nil spanTrace, used when -trace is not given, ignores every call.
+type spanTrace struct {
+	target string
+	mu     sync.Mutex
+	spans  []analyzer.Span
+}
+
+func newSpanTrace(target string) *spanTrace {
+	if target == "" {
+		return nil
+	}
+	return &spanTrace{target: target}
+}
+
+// options adds the trace hook to per-file analysis options
+func (t *spanTrace) options(opts analyzer.Options) analyzer.Options {
+	if t != nil {
+		opts.Trace = t.record
+	}
+	return opts
+}
+
+// record collects a file span; it is the analyzer.Options.Trace hook
+func (t *spanTrace) record(span analyzer.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+}
+
+// export sends the collected spans as children of a root span covering the
+// run and starts a new collection. A failed export is a warning, not an
+// error: tracing must not fail an analysis.
+func (t *spanTrace) export(name string, start time.Time, attributes map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mu.Unlock()
+
+	root := analyzer.Span{Name: name, Start: start, End: time.Now(), Attributes: attributes, Children: spans}
+	if err := t.send(otlpTrace(root)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: exporting trace: %v\n", err)
+	}
+}
+
+// send writes an encoded trace to the target
+func (t *spanTrace) send(request *otlpRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %v", err)
+	}
+
+	if strings.HasPrefix(t.target, "http://") || strings.HasPrefix(t.target, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Post(t.target, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s returned %s", t.target, resp.Status)
+		}
+		return nil
+	}
+
+	file, err := os.OpenFile(t.target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// OTLP JSON encoding of an ExportTraceServiceRequest, limited to the fields
+// replicode sets
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"` // 2 is STATUS_CODE_ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpSpanKindInternal is SPAN_KIND_INTERNAL
+const otlpSpanKindInternal = 1
+
+// otlpTrace flattens a span tree into one trace under a new trace ID
+func otlpTrace(root analyzer.Span) *otlpRequest {
+	traceID := randomHex(16)
+	var spans []otlpSpan
+	var walk func(span analyzer.Span, parent string)
+	walk = func(span analyzer.Span, parent string) {
+		encoded := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            randomHex(8),
+			ParentSpanID:      parent,
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.Error != "" {
+			encoded.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+		spans = append(spans, encoded)
+		for _, child := range span.Children {
+			walk(child, encoded.SpanID)
+		}
+	}
+	walk(root, "")
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name":    "replicode",
+			"service.version": toolVersion(),
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "replicode", Version: toolVersion()},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// otlpAttributes encodes attributes as typed OTLP key-values, sorted by key
+func otlpAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)} // int64 is a string in OTLP JSON
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+	return encoded
+}
+
+// randomHex returns n random bytes hex-encoded, for trace and span IDs
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// uiDefaultLimit is the most nodes GET /graph returns unless ?limit= is given.
+// Browsers lay out a few thousand nodes interactively; the full azurerm graph
+// has to be narrowed by service or resource first.
+const uiDefaultLimit = 2000
+
+// GraphView is the response of GET /graph: the filtered subgraph plus the
+// filter values the index offers
+type GraphView struct {
+	*GraphExport
+	Matched   int      `json:"matched"`   // Nodes the filter matched
+	Truncated bool     `json:"truncated"` // Matched exceeds the limit; no nodes are returned
+	Services  []string `json:"services"`  // Every service in the index
+	Resources []string `json:"resources"` // Every resource type in the index
+}
+
+// graphView filters the graph to the given services and resources. A service
+// selects its tests and templates and whatever they reference directly; a
+// resource selects everything that depends on it. Both given, the view is the
+// intersection. An empty filter selects the whole graph.
+func graphView(graph *DependencyGraph, services, resources []string, limit int) *GraphView {
+	view := &GraphView{Services: []string{}, Resources: []string{}}
+	for _, node := range graph.Nodes {
+		switch node.Kind {
+		case NodeService:
+			view.Services = append(view.Services, node.Name)
+		case NodeResource:
+			view.Resources = append(view.Resources, node.Name)
+		}
+	}
+	sort.Strings(view.Services)
+	sort.Strings(view.Resources)
+
+	var ids map[string]bool
+	if len(resources) > 0 {
+		ids = map[string]bool{}
+		for _, resource := range resources {
+			id := resourceNodeID(resource)
+			if graph.Nodes[id] == nil {
+				continue
+			}
+			ids[id] = true
+			for reached := range graph.Reachable(id, DirectionIn, 0) {
+				ids[reached] = true
+			}
+		}
+	}
+	if len(services) > 0 {
+		selected := map[string]bool{}
+		for _, service := range services {
+			selected[serviceNodeID(service)] = true
+		}
+		for id, node := range graph.Nodes {
+			if !selected[serviceNodeID(node.Service)] || node.Kind == NodeResource {
+				continue
+			}
+			selected[id] = true
+			for _, edge := range graph.OutEdges(id) {
+				if edge.Kind != EdgeMemberOf {
+					selected[edge.To] = true
+				}
+			}
+		}
+		if ids == nil {
+			ids = selected
+		} else {
+			for id := range ids {
+				if !selected[id] {
+					delete(ids, id)
+				}
+			}
+		}
+	}
+	if ids == nil {
+		ids = map[string]bool{}
+		for id := range graph.Nodes {
+			ids[id] = true
+		}
+	}
+
+	view.Matched = len(ids)
+	if limit > 0 && len(ids) > limit {
+		view.Truncated = true
+		ids = nil
+	}
+	view.GraphExport = graph.Subgraph(ids)
+	return view
+}
+
+// registerUI adds the graph visualization page and its data endpoint
+func registerUI(mux *http.ServeMux, index *analysisIndex, handle func(string, http.HandlerFunc), provenance func() *Provenance) {
+	handle("/graph", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeServeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use GET"))
+			return
+		}
+		query := r.URL.Query()
+		limit := uiDefaultLimit
+		if value := query.Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				writeServeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+				return
+			}
+			limit = parsed
+		}
+		writeServeDocument(w, provenance(), graphView(index.snapshot(), queryList(query, "service"), queryList(query, "resource"), limit))
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeServeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %s", r.URL.Path))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, uiPage)
+	})
+}
+
+// uiPage is the graph visualization: a force-directed layout on a canvas,
+// with no dependencies so that it works offline and on locked-down networks
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>replicode dependency graph</title>
+<style>
+  body { margin: 0; font: 13px system-ui, sans-serif; display: flex; height: 100vh; }
+  #side { width: 300px; padding: 12px; box-sizing: border-box; border-right: 1px solid #ccc; overflow-y: auto; }
+  #side label { display: block; margin-top: 10px; font-weight: 600; }
+  #side input[type=text] { width: 100%; box-sizing: border-box; }
+  #graph { flex: 1; }
+  #status { margin-top: 10px; color: #555; }
+  #details { margin-top: 14px; white-space: pre-wrap; word-break: break-all; }
+  .kind { display: inline-block; margin-right: 8px; font-weight: normal; }
+  .swatch { display: inline-block; width: 10px; height: 10px; border-radius: 5px; margin-right: 3px; }
+</style>
+</head>
+<body>
+<div id="side">
+  <label for="service">Service</label>
+  <input type="text" id="service" list="services" placeholder="e.g. network (comma-separated)">
+  <datalist id="services"></datalist>
+  <label for="resource">Resource</label>
+  <input type="text" id="resource" list="resources" placeholder="e.g. azurerm_subnet (comma-separated)">
+  <datalist id="resources"></datalist>
+  <p><button id="apply">Apply filter</button></p>
+  <label>Show</label>
+  <div id="kinds"></div>
+  <label for="search">Highlight</label>
+  <input type="text" id="search" placeholder="Node name contains">
+  <div id="status"></div>
+  <div id="details">Click a node for details. Double-click a resource or service to filter by it. Drag to pan, scroll to zoom.</div>
+</div>
+<canvas id="graph"></canvas>
+<script>
+"use strict";
+const colors = { test: "#1f77b4", template: "#ff7f0e", resource: "#2ca02c", service: "#9467bd" };
+const canvas = document.getElementById("graph"), ctx = canvas.getContext("2d");
+let nodes = [], edges = [], byId = {}, hidden = {}, selected = null, highlight = "";
+let view = { x: 0, y: 0, scale: 1 }, alpha = 0;
+
+for (const kind of Object.keys(colors)) {
+  const label = document.createElement("label");
+  label.className = "kind";
+  label.innerHTML = '<input type="checkbox" checked> <span class="swatch" style="background:' + colors[kind] + '"></span>' + kind;
+  label.firstChild.onchange = e => { hidden[kind] = !e.target.checked; draw(); };
+  document.getElementById("kinds").appendChild(label);
+}
+
+function resize() {
+  canvas.width = canvas.clientWidth * devicePixelRatio;
+  canvas.height = canvas.clientHeight * devicePixelRatio;
+  draw();
+}
+
+async function load() {
+  const params = new URLSearchParams();
+  for (const name of ["service", "resource"]) {
+    const value = document.getElementById(name).value.trim();
+    if (value) params.set(name, value);
+  }
+  const status = document.getElementById("status");
+  status.textContent = "Loading...";
+  const response = await fetch("graph?" + params);
+  const data = await response.json();
+  if (!response.ok) { status.textContent = data.error; return; }
+
+  fill("services", data.services);
+  fill("resources", data.resources);
+  if (data.truncated) {
+    status.textContent = data.matched + " nodes match; narrow the filter by service or resource.";
+    nodes = []; edges = []; draw();
+    return;
+  }
+  status.textContent = data.nodes.length + " nodes, " + data.edges.length + " edges";
+
+  byId = {};
+  nodes = data.nodes.map(n => Object.assign(n, { x: Math.random() * 800 - 400, y: Math.random() * 800 - 400, vx: 0, vy: 0 }));
+  for (const n of nodes) byId[n.id] = n;
+  edges = data.edges.filter(e => byId[e.from] && byId[e.to]);
+  selected = null;
+  view = { x: 0, y: 0, scale: 1 };
+  if (alpha <= 0.02) requestAnimationFrame(tick); // Otherwise the simulation is still running
+  alpha = 1;
+}
+
+function fill(id, values) {
+  const list = document.getElementById(id);
+  if (list.childElementCount) return;
+  for (const value of values) {
+    const option = document.createElement("option");
+    option.value = value;
+    list.appendChild(option);
+  }
+}
+
+// One step of the force simulation: node repulsion, edge springs, and gravity
+function tick() {
+  for (let i = 0; i < nodes.length; i++) {
+    const a = nodes[i];
+    for (let j = i + 1; j < nodes.length; j++) {
+      const b = nodes[j];
+      let dx = a.x - b.x, dy = a.y - b.y, d2 = dx * dx + dy * dy + 0.01;
+      if (d2 > 250000) continue;
+      const f = 400 / d2;
+      a.vx += dx * f; a.vy += dy * f; b.vx -= dx * f; b.vy -= dy * f;
+    }
+  }
+  for (const e of edges) {
+    const a = byId[e.from], b = byId[e.to];
+    const dx = b.x - a.x, dy = b.y - a.y, d = Math.sqrt(dx * dx + dy * dy) + 0.01;
+    const f = (d - 60) * 0.02 / d;
+    a.vx += dx * f; a.vy += dy * f; b.vx -= dx * f; b.vy -= dy * f;
+  }
+  for (const n of nodes) {
+    n.vx -= n.x * 0.002; n.vy -= n.y * 0.002;
+    n.x += n.vx * alpha; n.y += n.vy * alpha;
+    n.vx *= 0.6; n.vy *= 0.6;
+  }
+  alpha *= 0.99;
+  draw();
+  if (alpha > 0.02) requestAnimationFrame(tick);
+}
+
+function visible(n) { return !hidden[n.kind]; }
+
+function draw() {
+  const w = canvas.width, h = canvas.height, s = view.scale * devicePixelRatio;
+  ctx.setTransform(1, 0, 0, 1, 0, 0);
+  ctx.clearRect(0, 0, w, h);
+  ctx.setTransform(s, 0, 0, s, w / 2 + view.x * devicePixelRatio, h / 2 + view.y * devicePixelRatio);
+
+  ctx.lineWidth = 1 / view.scale;
+  for (const e of edges) {
+    const a = byId[e.from], b = byId[e.to];
+    if (!visible(a) || !visible(b)) continue;
+    const near = selected && (a === selected || b === selected);
+    ctx.strokeStyle = near ? "#d62728" : "rgba(0,0,0,0.15)";
+    ctx.beginPath(); ctx.moveTo(a.x, a.y); ctx.lineTo(b.x, b.y); ctx.stroke();
+  }
+  ctx.font = (11 / view.scale) + "px system-ui, sans-serif";
+  for (const n of nodes) {
+    if (!visible(n)) continue;
+    const match = highlight && n.name.toLowerCase().includes(highlight);
+    ctx.fillStyle = colors[n.kind];
+    ctx.beginPath(); ctx.arc(n.x, n.y, (match || n === selected ? 8 : 5) / Math.sqrt(view.scale), 0, 2 * Math.PI); ctx.fill();
+    if (match || n === selected || view.scale > 1.5 || n.kind === "service") {
+      ctx.fillStyle = "#000";
+      ctx.fillText(n.name, n.x + 7 / view.scale, n.y + 4 / view.scale);
+    }
+  }
+}
+
+// nodeAt returns the visible node under a mouse event, if any
+function nodeAt(event) {
+  const rect = canvas.getBoundingClientRect();
+  const x = (event.clientX - rect.left - rect.width / 2 - view.x) / view.scale;
+  const y = (event.clientY - rect.top - rect.height / 2 - view.y) / view.scale;
+  let best = null, bestDist = (10 / view.scale) ** 2;
+  for (const n of nodes) {
+    const d = (n.x - x) ** 2 + (n.y - y) ** 2;
+    if (visible(n) && d < bestDist) { best = n; bestDist = d; }
+  }
+  return best;
+}
+
+function showDetails(n) {
+  const lines = [n.id, "kind: " + n.kind];
+  if (n.service) lines.push("service: " + n.service);
+  if (n.namespace) lines.push("namespace: " + n.namespace);
+  if (n.file) lines.push("file: " + n.file + ":" + n.line);
+  lines.push("", "out:");
+  for (const e of edges) if (e.from === n.id) lines.push("  " + e.kind + " -> " + e.to);
+  lines.push("in:");
+  for (const e of edges) if (e.to === n.id) lines.push("  " + e.from + " -> " + e.kind);
+  document.getElementById("details").textContent = lines.join("\n");
+}
+
+let drag = null;
+canvas.onmousedown = e => { drag = { x: e.clientX, y: e.clientY, moved: false }; };
+canvas.onmousemove = e => {
+  if (!drag) return;
+  view.x += e.clientX - drag.x; view.y += e.clientY - drag.y;
+  drag.moved = drag.moved || Math.abs(e.clientX - drag.x) + Math.abs(e.clientY - drag.y) > 2;
+  drag.x = e.clientX; drag.y = e.clientY;
+  draw();
+};
+canvas.onmouseup = e => {
+  if (drag && !drag.moved) {
+    selected = nodeAt(e);
+    if (selected) showDetails(selected);
+    draw();
+  }
+  drag = null;
+};
+canvas.ondblclick = e => {
+  const n = nodeAt(e);
+  if (!n || (n.kind !== "resource" && n.kind !== "service")) return;
+  document.getElementById(n.kind).value = n.name;
+  load();
+};
+canvas.onwheel = e => {
+  e.preventDefault();
+  const factor = e.deltaY < 0 ? 1.1 : 1 / 1.1;
+  view.scale *= factor; view.x *= factor; view.y *= factor;
+  draw();
+};
+document.getElementById("apply").onclick = load;
+for (const id of ["service", "resource"]) {
+  document.getElementById(id).onkeydown = e => { if (e.key === "Enter") load(); };
+}
+document.getElementById("search").oninput = e => { highlight = e.target.value.toLowerCase(); draw(); };
+window.onresize = resize;
+resize();
+load();
+</script>
+</body>
+</html>
+`
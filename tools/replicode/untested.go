@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// RegisteredResource is a resource type the provider registers
+type RegisteredResource struct {
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"` // Registering service; "" when only the schema lists the type
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// UntestedReport is the output of the untested report
+type UntestedReport struct {
+	Registered int                  `json:"registered"` // Registered resource types checked
+	Tested     int                  `json:"tested"`     // Of which referenced by at least one template
+	Untested   []RegisteredResource `json:"untested"`
+}
+
+// ScanRegisteredResources finds the resource types registered by the
+// non-test Go files under dir: the string keys of a service's
+// SupportedResources map (untyped resources) and the string literal returned
+// by a ResourceType method (typed resources). Files mentioning neither are
+// skipped before parsing. Files are assigned to services by the given
+// patterns before the services/<name> layout.
+func ScanRegisteredResources(dir, repoRoot string, services analyzer.ServicePatterns) ([]RegisteredResource, error) {
+	var registered []RegisteredResource
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".go") || strings.HasSuffix(d.Name(), "_test.go") {
+			return nil
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(source, []byte("SupportedResources")) && !bytes.Contains(source, []byte("ResourceType()")) {
+			return nil
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, source, 0)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %v", path, err)
+		}
+		service := services.ServiceName(path)
+		if repoRoot != "" {
+			if relative, err := analyzer.RelativePath(repoRoot, path); err == nil {
+				path = relative
+			}
+		}
+		path = filepath.ToSlash(path)
+
+		record := func(lit *ast.BasicLit) {
+			if lit.Kind != token.STRING {
+				return
+			}
+			if name, err := strconv.Unquote(lit.Value); err == nil && name != "" {
+				registered = append(registered, RegisteredResource{Name: name, Service: service, File: path, Line: fset.Position(lit.Pos()).Line})
+			}
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			switch {
+			case fn.Name.Name == "SupportedResources":
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					if kv, ok := n.(*ast.KeyValueExpr); ok {
+						if lit, ok := kv.Key.(*ast.BasicLit); ok {
+							record(lit)
+						}
+					}
+					return true
+				})
+			case fn.Name.Name == "ResourceType" && fn.Recv != nil:
+				for _, stmt := range fn.Body.List {
+					if ret, ok := stmt.(*ast.ReturnStmt); ok && len(ret.Results) == 1 {
+						if lit, ok := ret.Results[0].(*ast.BasicLit); ok {
+							record(lit)
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+// FindUntestedResources reports the registered resource types no acceptance
+// test template references, as a resource block or an attribute reference.
+// Data source blocks name data source types and RequiresImportError names
+// come from test code, so neither counts. A type registered more than once
+// (or listed in both the schema and registrations) is checked once, keeping
+// the registration with a location.
+func FindUntestedResources(registered []RegisteredResource, results []*analyzer.Result) *UntestedReport {
+	referenced := map[string]bool{}
+	for _, result := range results {
+		for _, ref := range result.DirectResourceRefs {
+			if ref.ReferenceType == "RESOURCE_BLOCK" || ref.ReferenceType == "ATTRIBUTE_REFERENCE" {
+				referenced[ref.ResourceName] = true
+			}
+		}
+	}
+
+	unique := map[string]RegisteredResource{}
+	for _, resource := range registered {
+		if existing, ok := unique[resource.Name]; !ok || existing.File == "" {
+			unique[resource.Name] = resource
+		}
+	}
+
+	report := &UntestedReport{Registered: len(unique), Untested: []RegisteredResource{}}
+	for name, resource := range unique {
+		if referenced[name] {
+			report.Tested++
+			continue
+		}
+		report.Untested = append(report.Untested, resource)
+	}
+	sort.Slice(report.Untested, func(i, j int) bool {
+		a, b := report.Untested[i], report.Untested[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// Template check statuses
+const (
+	templateValid          = "VALID"
+	templateUnformatted    = "UNFORMATTED"     // Parses, but terraform fmt would rewrite it
+	templateInvalidHCL     = "INVALID_HCL"     // terraform fmt cannot parse it
+	templateValidateFailed = "VALIDATE_FAILED" // terraform validate rejected it
+	templateRenderFailed   = "RENDER_FAILED"   // Placeholders could not be substituted
+)
+
+// templateDirUnsafe matches characters not kept in template workspace directory names
+var templateDirUnsafe = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// TemplateCheck is the result of checking one template's rendered HCL
+type TemplateCheck struct {
+	Template string `json:"template"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"` // terraform or renderer output explaining the status
+}
+
+// TemplateCheckReport is the output of the validate-templates command
+type TemplateCheckReport struct {
+	Workspace string          `json:"workspace,omitempty"` // Kept workspace directory (-workdir)
+	Counts    map[string]int  `json:"counts"`
+	Templates []TemplateCheck `json:"templates"`
+}
+
+// templateChecker runs terraform against rendered templates in a workspace
+type templateChecker struct {
+	terraform   string
+	workspace   string
+	pluginCache string
+	validate    bool
+}
+
+// runValidateTemplatesCommand renders templates and checks them with terraform
+func runValidateTemplatesCommand(args []string) int {
+	fs := flag.NewFlagSet("validate-templates", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	var names stringList
+	fs.Var(&names, "template", "Only these templates (e.g., VirtualNetworkResource.basic), comma-separated or repeated")
+	terraform := fs.String("terraform", "terraform", "Terraform binary")
+	validate := fs.Bool("validate", false, "Also run terraform init and validate on templates TestSteps use directly")
+	workdir := fs.String("workdir", "", "Write template workspaces here and keep them (default: a removed temp directory)")
+	failedOnly := fs.Bool("failed", false, "Only report templates that are not VALID")
+	format := fs.String("format", "json", "Output format: json or text")
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+
+	if err := validateFormat(*format, "json", "text"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if _, err := exec.LookPath(*terraform); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: terraform not found: %v\n", err)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	graph := BuildDependencyGraph(results)
+
+	var ids []string
+	if len(names) > 0 {
+		for _, name := range names {
+			node, err := graph.ResolveNode(name)
+			if err != nil || node.Kind != NodeTemplate {
+				fmt.Fprintf(os.Stderr, "Error: template %q not found\n", name)
+				return 1
+			}
+			ids = append(ids, node.ID)
+		}
+	} else {
+		for id, node := range graph.Nodes {
+			if node.Kind == NodeTemplate {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+
+	report := &TemplateCheckReport{Workspace: *workdir, Counts: map[string]int{}, Templates: []TemplateCheck{}}
+	workspace := *workdir
+	if workspace == "" {
+		workspace, err = os.MkdirTemp("", "terracorder-templates-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer os.RemoveAll(workspace)
+	}
+	checker := &templateChecker{
+		terraform:   *terraform,
+		workspace:   workspace,
+		pluginCache: filepath.Join(workspace, ".plugin-cache"),
+		validate:    *validate,
+	}
+	if err := os.MkdirAll(checker.pluginCache, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	checks := checker.checkAll(graph, ids)
+	for _, check := range checks {
+		report.Counts[check.Status]++
+		if !*failedOnly || check.Status != templateValid {
+			report.Templates = append(report.Templates, check)
+		}
+	}
+
+	if *format == "text" {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TEMPLATE\tSTATUS\tLOCATION\tMESSAGE")
+		for _, check := range report.Templates {
+			message := strings.SplitN(check.Message, "\n", 2)[0]
+			fmt.Fprintf(w, "%s\t%s\t%s:%d\t%s\n", check.Template, check.Status, check.File, check.Line, message)
+		}
+		w.Flush()
+		return 0
+	}
+
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// checkAll renders and checks templates concurrently, returning results in ids order
+func (c *templateChecker) checkAll(graph *DependencyGraph, ids []string) []TemplateCheck {
+	// Rendering shares a cache, so it happens up front; terraform runs in parallel
+	renderer := newTemplateRenderer(graph)
+	checks := make([]TemplateCheck, len(ids))
+	hcl := make([]string, len(ids))
+	for i, id := range ids {
+		node := graph.Nodes[id]
+		checks[i] = TemplateCheck{Template: node.Name, File: node.File, Line: node.Line}
+		rendered, err := renderer.Render(id)
+		if err != nil {
+			checks[i].Status, checks[i].Message = templateRenderFailed, err.Error()
+			continue
+		}
+		hcl[i] = rendered
+	}
+
+	// terraform init is not safe to run concurrently against a shared plugin cache
+	workers := runtime.NumCPU()
+	if c.validate {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				stepConfig := false
+				for _, edge := range graph.InEdges(ids[i]) {
+					stepConfig = stepConfig || edge.Kind == EdgeStepRef
+				}
+				checks[i].Status, checks[i].Message = c.check(checks[i].Template, hcl[i], stepConfig)
+			}
+		}()
+	}
+	for i := range ids {
+		if checks[i].Status == "" {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	return checks
+}
+
+// check writes a template to its own workspace directory and runs terraform
+// fmt, then init and validate for step configurations when enabled
+func (c *templateChecker) check(name, hcl string, stepConfig bool) (string, string) {
+	dir := filepath.Join(c.workspace, templateDirUnsafe.ReplaceAllString(name, "_"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return templateRenderFailed, err.Error()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(hcl), 0644); err != nil {
+		return templateRenderFailed, err.Error()
+	}
+
+	if output, err := c.run(dir, "fmt", "-check", "-no-color", "main.tf"); err != nil {
+		if strings.Contains(output, "Error") {
+			return templateInvalidHCL, output
+		}
+		return templateUnformatted, ""
+	}
+
+	if !c.validate || !stepConfig {
+		return templateValid, ""
+	}
+	if output, err := c.run(dir, "init", "-backend=false", "-input=false", "-no-color"); err != nil {
+		return templateValidateFailed, output
+	}
+	if output, err := c.run(dir, "validate", "-no-color"); err != nil {
+		return templateValidateFailed, output
+	}
+	return templateValid, ""
+}
+
+// run runs a terraform command in dir, returning its trimmed combined output
+func (c *templateChecker) run(dir string, args ...string) (string, error) {
+	cmd := exec.Command(c.terraform, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TF_PLUGIN_CACHE_DIR="+c.pluginCache, "TF_IN_AUTOMATION=1")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return strings.TrimSpace(output.String()), err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// ResolutionCompleteness measures how much of what the extractors found they
+// could resolve: TestSteps whose Config struct is known, and template calls
+// whose target service is known. New code patterns the heuristics do not
+// understand show up as a growing unresolved fraction.
+type ResolutionCompleteness struct {
+	TestSteps           int     `json:"test_steps"`
+	UnresolvedSteps     int     `json:"unresolved_steps"` // No ConfigStruct
+	TemplateCalls       int     `json:"template_calls"`
+	UnresolvedCalls     int     `json:"unresolved_calls"` // No TargetService
+	UnresolvedStepRatio float64 `json:"unresolved_step_ratio"`
+	UnresolvedCallRatio float64 `json:"unresolved_call_ratio"`
+}
+
+// resolutionCompleteness counts the unresolved TestSteps and template calls
+// of the results. A call resolved to a struct whose template another file of
+// the results declares takes that file's service, as the per-file analysis
+// only knows the services of templates in the calling file: the declaration
+// in the calling file's package, else the only package's declaration.
+func resolutionCompleteness(results []*analyzer.Result) ResolutionCompleteness {
+	services := map[functionKey]string{}
+	declared := map[string][]string{} // Struct.method -> service of each package declaring it
+	for _, result := range results {
+		for _, fn := range result.Functions {
+			if !fn.IsTestFunc && fn.ReceiverType != "" {
+				key := declarationKey(fn)
+				if _, exists := services[key]; !exists {
+					services[key] = fn.ServiceName
+					declared[fn.ReceiverType+"."+fn.FunctionName] = append(declared[fn.ReceiverType+"."+fn.FunctionName], fn.ServiceName)
+				}
+			}
+		}
+	}
+	targetService := func(result *analyzer.Result, call analyzer.TemplateFunctionCall) string {
+		if service, ok := services[functionKey{packageOf(result.FilePath), call.TargetStruct, call.TargetMethod}]; ok {
+			return service
+		}
+		if candidates := declared[call.TargetStruct+"."+call.TargetMethod]; len(candidates) == 1 {
+			return candidates[0]
+		}
+		return "" // Undeclared, or ambiguous between packages
+	}
+
+	var c ResolutionCompleteness
+	for _, result := range results {
+		for _, step := range result.TestSteps {
+			c.TestSteps++
+			if step.ConfigStruct == "" {
+				c.UnresolvedSteps++
+			}
+		}
+		for _, call := range result.TemplateCalls {
+			c.TemplateCalls++
+			if call.TargetService == "" && (call.TargetStruct == "" || targetService(result, call) == "") {
+				c.UnresolvedCalls++
+			}
+		}
+	}
+	if c.TestSteps > 0 {
+		c.UnresolvedStepRatio = float64(c.UnresolvedSteps) / float64(c.TestSteps)
+	}
+	if c.TemplateCalls > 0 {
+		c.UnresolvedCallRatio = float64(c.UnresolvedCalls) / float64(c.TemplateCalls)
+	}
+	return c
+}
+
+// exceeds reports whether either unresolved fraction is above the threshold
+func (c ResolutionCompleteness) exceeds(threshold float64) bool {
+	return c.UnresolvedStepRatio > threshold || c.UnresolvedCallRatio > threshold
+}
+
+// writeText writes the completeness against the threshold as two lines
+func (c ResolutionCompleteness) writeText(w io.Writer, threshold float64) {
+	fmt.Fprintf(w, "TestSteps without a ConfigStruct: %d of %d (%.1f%%, threshold %.1f%%)\n",
+		c.UnresolvedSteps, c.TestSteps, 100*c.UnresolvedStepRatio, 100*threshold)
+	fmt.Fprintf(w, "Template calls without a TargetService: %d of %d (%.1f%%, threshold %.1f%%)\n",
+		c.UnresolvedCalls, c.TemplateCalls, 100*c.UnresolvedCallRatio, 100*threshold)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/WodansSon/terraform-terracorder/cmd/replicode/pkg/analyzer"
+)
+
+// WhyTestResult explains everything a single test function depends on
+type WhyTestResult struct {
+	Test      *GraphNode           `json:"test"`
+	Services  []string             `json:"services"`
+	Tests     []WhyTestDependency  `json:"sequential_tests"` // Tests run sequentially from this entry point
+	Templates []WhyTestDependency  `json:"templates"`
+	Resources []WhyTestResourceRef `json:"resources"`
+}
+
+// WhyTestDependency is a test or template reached from the test, with the edges that reach it
+type WhyTestDependency struct {
+	Node *GraphNode   `json:"node"`
+	Via  []*GraphEdge `json:"via"`
+}
+
+// WhyTestResourceRef groups the HCL references to one resource type across the test's templates
+type WhyTestResourceRef struct {
+	ResourceName string                             `json:"resource_name"`
+	References   []analyzer.DirectResourceReference `json:"references"`
+}
+
+// runWhyTestCommand prints every resource, template, and service a test depends on
+func runWhyTestCommand(args []string) int {
+	fs := flag.NewFlagSet("why-test", flag.ContinueOnError)
+	var source sourceOptions
+	source.register(fs)
+	source.registerRepos(fs)
+	format := fs.String("format", "json", "Output format: json or text")
+
+	// Allow the test name either before or after the flags
+	testName, args := leadingPositional(args)
+	if err := parseFlags(fs, args); err != nil {
+		return flagStatus(err)
+	}
+	if testName == "" && fs.NArg() > 0 {
+		testName = fs.Arg(0)
+	}
+	if testName == "" {
+		fmt.Fprintln(os.Stderr, "Usage: replicode why-test <TestName> -dir <path> [-reporoot <root>] [-format json|text]")
+		return 1
+	}
+	if *format != "json" && *format != "text" {
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q (expected json or text)\n", *format)
+		return 1
+	}
+
+	results, err := source.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	graph := BuildDependencyGraph(results)
+	node, ok := graph.Nodes[testNodeID(testName)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: test function %q not found\n", testName)
+		return 1
+	}
+
+	result := explainTest(graph, results, node)
+
+	if *format == "text" {
+		writeWhyTestText(os.Stdout, result)
+		return 0
+	}
+	if err := writeDocument(os.Stdout, newProvenance(fs, source.root()), result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// leadingPositional splits off a leading non-flag argument so commands accept
+// both "cmd NAME -flag v" and "cmd -flag v NAME"
+func leadingPositional(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// explainTest collects the dependencies of a test along with their evidence
+func explainTest(graph *DependencyGraph, results []*analyzer.Result, test *GraphNode) *WhyTestResult {
+	result := &WhyTestResult{
+		Test:      test,
+		Services:  []string{},
+		Tests:     []WhyTestDependency{},
+		Templates: []WhyTestDependency{},
+		Resources: []WhyTestResourceRef{},
+	}
+
+	reached := graph.Reachable(test.ID, DirectionOut, 0)
+	inClosure := map[string]bool{test.ID: true}
+	for id := range reached {
+		inClosure[id] = true
+	}
+
+	services := map[string]bool{}
+	if test.Service != "" {
+		services[test.Service] = true
+	}
+
+	for id := range reached {
+		node := graph.Nodes[id]
+		if node == nil {
+			continue
+		}
+
+		if node.Service != "" {
+			services[node.Service] = true
+		}
+
+		if node.Kind != NodeTest && node.Kind != NodeTemplate {
+			continue
+		}
+
+		// Only keep the edges that come from inside the test's closure
+		dependency := WhyTestDependency{Node: node, Via: []*GraphEdge{}}
+		for _, edge := range graph.InEdges(id) {
+			if inClosure[edge.From] {
+				dependency.Via = append(dependency.Via, edge)
+			}
+		}
+		sortEdges(dependency.Via)
+
+		if node.Kind == NodeTest {
+			result.Tests = append(result.Tests, dependency)
+		} else {
+			result.Templates = append(result.Templates, dependency)
+		}
+	}
+
+	// Resource evidence comes straight from the HCL references of the reached templates
+	byResource := map[string][]analyzer.DirectResourceReference{}
+	for _, fileResult := range results {
+		for _, ref := range fileResult.DirectResourceRefs {
+			fn := functionAtLine(fileResult, ref.TemplateFunction, ref.TemplateLine)
+			if fn == nil || !inClosure[graph.functionNodeID(*fn)] {
+				continue
+			}
+			byResource[ref.ResourceName] = append(byResource[ref.ResourceName], ref)
+		}
+	}
+	for name, refs := range byResource {
+		result.Resources = append(result.Resources, WhyTestResourceRef{ResourceName: name, References: refs})
+	}
+
+	for service := range services {
+		result.Services = append(result.Services, service)
+	}
+	sort.Strings(result.Services)
+	sortDependencies(result.Tests)
+	sortDependencies(result.Templates)
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].ResourceName < result.Resources[j].ResourceName
+	})
+
+	return result
+}
+
+// sortDependencies orders dependencies by node ID for stable output
+func sortDependencies(deps []WhyTestDependency) {
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Node.ID < deps[j].Node.ID
+	})
+}
+
+// writeWhyTestText prints a why-test result for interactive use
+func writeWhyTestText(w io.Writer, result *WhyTestResult) {
+	fmt.Fprintf(w, "%s (%s:%d)\n", result.Test.Name, result.Test.File, result.Test.Line)
+	fmt.Fprintf(w, "\nServices: %s\n", strings.Join(result.Services, ", "))
+
+	if len(result.Tests) > 0 {
+		fmt.Fprintln(w, "\nSequential tests:")
+		writeWhyTestDependencies(w, result.Tests)
+	}
+
+	fmt.Fprintln(w, "\nTemplates:")
+	writeWhyTestDependencies(w, result.Templates)
+
+	fmt.Fprintln(w, "\nResources:")
+	for _, resource := range result.Resources {
+		fmt.Fprintf(w, "  %s\n", resource.ResourceName)
+		for _, ref := range resource.References {
+			fmt.Fprintf(w, "    %-19s %s:%d (%s, HCL line %d): %s\n",
+				ref.ReferenceType, ref.TemplateFile, ref.TemplateLine, ref.TemplateFunction, ref.ContextLine, ref.Context)
+		}
+	}
+}
+
+// writeWhyTestDependencies prints each dependency with the edges that reach it
+func writeWhyTestDependencies(w io.Writer, deps []WhyTestDependency) {
+	for _, dep := range deps {
+		fmt.Fprintf(w, "  %s [%s] (%s:%d)\n", dep.Node.Name, dep.Node.Service, dep.Node.File, dep.Node.Line)
+		for _, edge := range dep.Via {
+			fmt.Fprintf(w, "    <- %s %s at %s:%d: %s\n", edge.Kind, edge.From, edge.File, edge.Line, edge.Detail)
+		}
+	}
+}